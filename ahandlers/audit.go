@@ -2,19 +2,119 @@ package ahandlers
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
 	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/authorizer"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
 )
 
+type auditOrg struct {
+	Id           primitive.ObjectID `bson:"_id"`
+	Organization primitive.ObjectID `bson:"organization"`
+}
+
 type auditsData struct {
 	Audits []*audit.Audit `json:"audits"`
 	Count  int64          `json:"count"`
 }
 
+// auditChange records a change that has already been applied, failures
+// are logged and do not fail the request.
+func auditChange(c *gin.Context, db *database.Database, typ,
+	resource string, resourceId, orgId primitive.ObjectID,
+	changes []*audit.Change) {
+
+	err := newAuditChange(c, db, typ, resource, resourceId, orgId, changes)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"type":        typ,
+			"resource":    resource,
+			"resource_id": resourceId.Hex(),
+			"error":       err,
+		}).Error("audit: Failed to record change")
+	}
+}
+
+// auditOrgs returns the organizations of resources, used to audit deletes
+// before the resources are removed.
+func auditOrgs(db *database.Database, coll *database.Collection,
+	resourceIds ...primitive.ObjectID) (
+	orgs map[primitive.ObjectID]primitive.ObjectID, err error) {
+
+	orgs = map[primitive.ObjectID]primitive.ObjectID{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"_id": &bson.M{
+				"$in": resourceIds,
+			},
+		},
+		&options.FindOptions{
+			Projection: &bson.D{
+				{"organization", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		doc := &auditOrg{}
+		err = cursor.Decode(doc)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		orgs[doc.Id] = doc.Organization
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func newAuditChange(c *gin.Context, db *database.Database, typ,
+	resource string, resourceId, orgId primitive.ObjectID,
+	changes []*audit.Change) (err error) {
+
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		return
+	}
+
+	userId := primitive.NilObjectID
+	if usr != nil {
+		userId = usr.Id
+	}
+
+	err = audit.NewChange(db, c.Request, userId, orgId, typ, resource,
+		resourceId, changes)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func auditsGet(c *gin.Context) {
 	if demo.IsDemo() {
 		data := &auditsData{
@@ -50,3 +150,84 @@ func auditsGet(c *gin.Context) {
 
 	c.JSON(200, data)
 }
+
+func auditsChangesGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{
+		"y": &bson.M{
+			"$in": []string{
+				audit.ResourceCreate,
+				audit.ResourceUpdate,
+				audit.ResourceDelete,
+			},
+		},
+	}
+
+	typ := c.Query("type")
+	if typ != "" {
+		query["y"] = typ
+	}
+
+	userId, ok := utils.ParseObjectId(c.Query("user"))
+	if ok {
+		query["u"] = userId
+	}
+
+	orgId, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["o"] = orgId
+	}
+
+	resource := c.Query("resource")
+	if resource != "" {
+		query["r"] = resource
+	}
+
+	resourceId, ok := utils.ParseObjectId(c.Query("resource_id"))
+	if ok {
+		query["i"] = resourceId
+	}
+
+	timeQuery := bson.M{}
+
+	start := c.Query("start")
+	if start != "" {
+		startTime, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			utils.AbortWithStatus(c, 400)
+			return
+		}
+		timeQuery["$gte"] = startTime
+	}
+
+	end := c.Query("end")
+	if end != "" {
+		endTime, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			utils.AbortWithStatus(c, 400)
+			return
+		}
+		timeQuery["$lte"] = endTime
+	}
+
+	if len(timeQuery) > 0 {
+		query["t"] = &timeQuery
+	}
+
+	audits, count, err := audit.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &auditsData{
+		Audits: audits,
+		Count:  count,
+	}
+
+	c.JSON(200, data)
+}
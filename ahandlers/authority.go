@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/authority"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -64,6 +65,8 @@ func authorityPut(c *gin.Context) {
 		return
 	}
 
+	authrOrig := audit.Snapshot(authr)
+
 	authr.Name = data.Name
 	authr.Comment = data.Comment
 	authr.Type = data.Type
@@ -101,6 +104,9 @@ func authorityPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "authority", authr.Id,
+		authr.Organization, audit.Diff(authrOrig, authr, fields))
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, authr)
@@ -153,6 +159,9 @@ func authorityPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "authority", authr.Id,
+		authr.Organization, nil)
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, authr)
@@ -171,12 +180,21 @@ func authorityDelete(c *gin.Context) {
 		return
 	}
 
-	err := authority.Remove(db, authorityId)
+	orgs, err := auditOrgs(db, db.Authorities(), authorityId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = authority.Remove(db, authorityId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "authority", authorityId,
+		orgs[authorityId], nil)
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, nil)
@@ -199,12 +217,23 @@ func authoritiesDelete(c *gin.Context) {
 		return
 	}
 
+	orgs, err := auditOrgs(db, db.Authorities(), data...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	err = authority.RemoveMulti(db, data)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	for _, authorityId := range data {
+		auditChange(c, db, audit.ResourceDelete, "authority",
+			authorityId, orgs[authorityId], nil)
+	}
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, nil)
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/balancer"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -19,18 +20,20 @@ import (
 )
 
 type balancerData struct {
-	Id           primitive.ObjectID   `json:"id"`
-	Name         string               `json:"name"`
-	Comment      string               `json:"comment"`
-	State        bool                 `json:"state"`
-	Type         string               `json:"type"`
-	Organization primitive.ObjectID   `json:"organization"`
-	Datacenter   primitive.ObjectID   `json:"datacenter"`
-	Certificates []primitive.ObjectID `json:"certificates"`
-	WebSockets   bool                 `json:"websockets"`
-	Domains      []*balancer.Domain   `json:"domains"`
-	Backends     []*balancer.Backend  `json:"backends"`
-	CheckPath    string               `json:"check_path"`
+	Id            primitive.ObjectID   `json:"id"`
+	Name          string               `json:"name"`
+	Comment       string               `json:"comment"`
+	State         bool                 `json:"state"`
+	Type          string               `json:"type"`
+	Organization  primitive.ObjectID   `json:"organization"`
+	Datacenter    primitive.ObjectID   `json:"datacenter"`
+	Certificates  []primitive.ObjectID `json:"certificates"`
+	WebSockets    bool                 `json:"websockets"`
+	Domains       []*balancer.Domain   `json:"domains"`
+	Backends      []*balancer.Backend  `json:"backends"`
+	CheckPath     string               `json:"check_path"`
+	ListenPort    int                  `json:"listen_port"`
+	ProxyProtocol bool                 `json:"proxy_protocol"`
 }
 
 type balancersData struct {
@@ -67,6 +70,8 @@ func balancerPut(c *gin.Context) {
 		return
 	}
 
+	balncOrig := audit.Snapshot(balnc)
+
 	balnc.Name = data.Name
 	balnc.Comment = data.Comment
 	balnc.State = data.State
@@ -78,6 +83,8 @@ func balancerPut(c *gin.Context) {
 	balnc.Domains = data.Domains
 	balnc.Backends = data.Backends
 	balnc.CheckPath = data.CheckPath
+	balnc.ListenPort = data.ListenPort
+	balnc.ProxyProtocol = data.ProxyProtocol
 
 	fields := set.NewSet(
 		"name",
@@ -91,6 +98,8 @@ func balancerPut(c *gin.Context) {
 		"domains",
 		"backends",
 		"check_path",
+		"listen_port",
+		"proxy_protocol",
 	)
 
 	errData, err := balnc.Validate(db)
@@ -110,6 +119,9 @@ func balancerPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "balancer", balnc.Id,
+		balnc.Organization, audit.Diff(balncOrig, balnc, fields))
+
 	event.PublishDispatch(db, "balancer.change")
 
 	balnc.Json()
@@ -137,17 +149,19 @@ func balancerPost(c *gin.Context) {
 	}
 
 	balnc := &balancer.Balancer{
-		Name:         data.Name,
-		Comment:      data.Comment,
-		State:        data.State,
-		Type:         data.Type,
-		Organization: data.Organization,
-		Datacenter:   data.Datacenter,
-		Certificates: data.Certificates,
-		WebSockets:   data.WebSockets,
-		Domains:      data.Domains,
-		Backends:     data.Backends,
-		CheckPath:    data.CheckPath,
+		Name:          data.Name,
+		Comment:       data.Comment,
+		State:         data.State,
+		Type:          data.Type,
+		Organization:  data.Organization,
+		Datacenter:    data.Datacenter,
+		Certificates:  data.Certificates,
+		WebSockets:    data.WebSockets,
+		Domains:       data.Domains,
+		Backends:      data.Backends,
+		CheckPath:     data.CheckPath,
+		ListenPort:    data.ListenPort,
+		ProxyProtocol: data.ProxyProtocol,
 	}
 
 	errData, err := balnc.Validate(db)
@@ -167,6 +181,9 @@ func balancerPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "balancer", balnc.Id,
+		balnc.Organization, nil)
+
 	event.PublishDispatch(db, "balancer.change")
 
 	balnc.Json()
@@ -187,12 +204,21 @@ func balancerDelete(c *gin.Context) {
 		return
 	}
 
-	err := balancer.Remove(db, balancerId)
+	orgs, err := auditOrgs(db, db.Balancers(), balancerId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = balancer.Remove(db, balancerId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "balancer", balancerId,
+		orgs[balancerId], nil)
+
 	event.PublishDispatch(db, "balancer.change")
 
 	c.JSON(200, nil)
@@ -215,12 +241,23 @@ func balancersDelete(c *gin.Context) {
 		return
 	}
 
+	orgs, err := auditOrgs(db, db.Balancers(), data...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	err = balancer.RemoveMulti(db, data)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	for _, balancerId := range data {
+		auditChange(c, db, audit.ResourceDelete, "balancer", balancerId,
+			orgs[balancerId], nil)
+	}
+
 	event.PublishDispatch(db, "balancer.change")
 
 	c.JSON(200, nil)
@@ -5,6 +5,7 @@ import (
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/block"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -55,6 +56,8 @@ func blockPut(c *gin.Context) {
 		return
 	}
 
+	blckOrig := audit.Snapshot(blck)
+
 	blck.Name = dta.Name
 	blck.Comment = dta.Comment
 	blck.Type = dta.Type
@@ -94,6 +97,9 @@ func blockPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "block", blck.Id,
+		primitive.NilObjectID, audit.Diff(blckOrig, blck, fields))
+
 	event.PublishDispatch(db, "block.change")
 
 	c.JSON(200, blck)
@@ -147,6 +153,9 @@ func blockPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "block", blck.Id,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "block.change")
 
 	c.JSON(200, blck)
@@ -171,6 +180,9 @@ func blockDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "block", blckId,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "block.change")
 
 	c.JSON(200, nil)
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/acme"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/certificate"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -54,6 +55,8 @@ func certificatePut(c *gin.Context) {
 		return
 	}
 
+	certOrig := audit.Snapshot(cert)
+
 	cert.Name = data.Name
 	cert.Comment = data.Comment
 	cert.Organization = data.Organization
@@ -93,6 +96,9 @@ func certificatePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "certificate", cert.Id,
+		cert.Organization, audit.Diff(certOrig, cert, fields))
+
 	if cert.Type == certificate.LetsEncrypt {
 		err = acme.Update(db, cert)
 		if err != nil {
@@ -161,6 +167,9 @@ func certificatePost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "certificate", cert.Id,
+		cert.Organization, nil)
+
 	if cert.Type == certificate.LetsEncrypt {
 		err = acme.Update(db, cert)
 		if err != nil {
@@ -193,12 +202,21 @@ func certificateDelete(c *gin.Context) {
 		return
 	}
 
-	err := certificate.Remove(db, certId)
+	orgs, err := auditOrgs(db, db.Certificates(), certId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	err = certificate.Remove(db, certId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceDelete, "certificate", certId,
+		orgs[certId], nil)
+
 	event.PublishDispatch(db, "certificate.change")
 
 	c.JSON(200, nil)
@@ -5,6 +5,7 @@ import (
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -55,6 +56,8 @@ func datacenterPut(c *gin.Context) {
 		return
 	}
 
+	dcOrig := audit.Snapshot(dc)
+
 	dc.Name = data.Name
 	dc.Comment = data.Comment
 	dc.MatchOrganizations = data.MatchOrganizations
@@ -94,6 +97,9 @@ func datacenterPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "datacenter", dc.Id,
+		primitive.NilObjectID, audit.Diff(dcOrig, dc, fields))
+
 	event.PublishDispatch(db, "datacenter.change")
 
 	c.JSON(200, dc)
@@ -147,6 +153,9 @@ func datacenterPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "datacenter", dc.Id,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "datacenter.change")
 
 	c.JSON(200, dc)
@@ -171,6 +180,9 @@ func datacenterDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "datacenter", dcId,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "datacenter.change")
 
 	c.JSON(200, nil)
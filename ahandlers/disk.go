@@ -11,6 +11,7 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/aggregate"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -20,6 +21,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
 type diskData struct {
@@ -38,6 +40,8 @@ type diskData struct {
 	Size             int                `json:"size"`
 	NewSize          int                `json:"new_size"`
 	Backup           bool               `json:"backup"`
+	Limits           vm.DiskLimits      `json:"limits"`
+	NoLimits         bool               `json:"no_limits"`
 }
 
 type disksMultiData struct {
@@ -79,6 +83,8 @@ func diskPut(c *gin.Context) {
 		return
 	}
 
+	dskOrig := audit.Snapshot(dsk)
+
 	fields := set.NewSet(
 		"name",
 		"comment",
@@ -87,6 +93,8 @@ func diskPut(c *gin.Context) {
 		"index",
 		"backup",
 		"new_size",
+		"limits",
+		"no_limits",
 	)
 
 	dsk.PreCommit()
@@ -97,6 +105,8 @@ func diskPut(c *gin.Context) {
 	dsk.DeleteProtection = dta.DeleteProtection
 	dsk.Index = dta.Index
 	dsk.Backup = dta.Backup
+	dsk.Limits = dta.Limits
+	dsk.NoLimits = dta.NoLimits
 
 	if dsk.State == disk.Available && dta.State == disk.Snapshot {
 		dsk.State = disk.Snapshot
@@ -159,6 +169,9 @@ func diskPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "disk", dsk.Id,
+		dsk.Organization, audit.Diff(dskOrig, dsk, fields))
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, dsk)
@@ -231,6 +244,8 @@ func diskPost(c *gin.Context) {
 		Backing:          dta.Backing,
 		Size:             dta.Size,
 		Backup:           dta.Backup,
+		Limits:           dta.Limits,
+		NoLimits:         dta.NoLimits,
 	}
 
 	errData, err := dsk.Validate(db)
@@ -250,6 +265,9 @@ func diskPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "disk", dsk.Id,
+		dsk.Organization, nil)
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, dsk)
@@ -286,12 +304,28 @@ func disksPut(c *gin.Context) {
 		"state": data.State,
 	}
 
+	orgs, err := auditOrgs(db, db.Disks(), data.Ids...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	err = disk.UpdateMulti(db, data.Ids, &doc)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	for _, diskId := range data.Ids {
+		auditChange(c, db, audit.ResourceUpdate, "disk", diskId,
+			orgs[diskId], []*audit.Change{
+				&audit.Change{
+					Field: "state",
+					New:   data.State,
+				},
+			})
+	}
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, nil)
@@ -331,6 +365,9 @@ func diskDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "disk", dsk.Id,
+		dsk.Organization, nil)
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, nil)
@@ -353,6 +390,12 @@ func disksDelete(c *gin.Context) {
 		return
 	}
 
+	orgs, err := auditOrgs(db, db.Disks(), dta...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	force := c.Query("force")
 	if force == "true" {
 		for _, diskId := range dta {
@@ -370,6 +413,11 @@ func disksDelete(c *gin.Context) {
 		}
 	}
 
+	for _, diskId := range dta {
+		auditChange(c, db, audit.ResourceDelete, "disk", diskId,
+			orgs[diskId], nil)
+	}
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, nil)
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/domain"
@@ -24,6 +25,14 @@ type domainData struct {
 	Type         string             `json:"type"`
 	AwsId        string             `json:"aws_id"`
 	AwsSecret    string             `json:"aws_secret"`
+	CfToken      string             `json:"cf_token"`
+	DnsServer    string             `json:"dns_server"`
+	TsigName     string             `json:"tsig_name"`
+	TsigAlgo     string             `json:"tsig_algo"`
+	TsigSecret   string             `json:"tsig_secret"`
+	PdnsUrl      string             `json:"pdns_url"`
+	PdnsServer   string             `json:"pdns_server"`
+	PdnsApiKey   string             `json:"pdns_api_key"`
 }
 
 type domainsData struct {
@@ -57,12 +66,22 @@ func domainPut(c *gin.Context) {
 		return
 	}
 
+	domnOrig := audit.Snapshot(domn)
+
 	domn.Name = data.Name
 	domn.Comment = data.Comment
 	domn.Organization = data.Organization
 	domn.Type = data.Type
 	domn.AwsId = data.AwsId
 	domn.AwsSecret = data.AwsSecret
+	domn.CfToken = data.CfToken
+	domn.DnsServer = data.DnsServer
+	domn.TsigName = data.TsigName
+	domn.TsigAlgo = data.TsigAlgo
+	domn.TsigSecret = data.TsigSecret
+	domn.PdnsUrl = data.PdnsUrl
+	domn.PdnsServer = data.PdnsServer
+	domn.PdnsApiKey = data.PdnsApiKey
 
 	fields := set.NewSet(
 		"name",
@@ -71,6 +90,14 @@ func domainPut(c *gin.Context) {
 		"type",
 		"aws_id",
 		"aws_secret",
+		"cf_token",
+		"dns_server",
+		"tsig_name",
+		"tsig_algo",
+		"tsig_secret",
+		"pdns_url",
+		"pdns_server",
+		"pdns_api_key",
 	)
 
 	errData, err := domn.Validate(db)
@@ -90,6 +117,9 @@ func domainPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "domain", domn.Id,
+		domn.Organization, audit.Diff(domnOrig, domn, fields))
+
 	event.PublishDispatch(db, "domain.change")
 
 	c.JSON(200, domn)
@@ -118,6 +148,14 @@ func domainPost(c *gin.Context) {
 		Type:         data.Type,
 		AwsId:        data.AwsId,
 		AwsSecret:    data.AwsSecret,
+		CfToken:      data.CfToken,
+		DnsServer:    data.DnsServer,
+		TsigName:     data.TsigName,
+		TsigAlgo:     data.TsigAlgo,
+		TsigSecret:   data.TsigSecret,
+		PdnsUrl:      data.PdnsUrl,
+		PdnsServer:   data.PdnsServer,
+		PdnsApiKey:   data.PdnsApiKey,
 	}
 
 	errData, err := domn.Validate(db)
@@ -137,6 +175,9 @@ func domainPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "domain", domn.Id,
+		domn.Organization, nil)
+
 	event.PublishDispatch(db, "domain.change")
 
 	c.JSON(200, domn)
@@ -155,12 +196,21 @@ func domainDelete(c *gin.Context) {
 		return
 	}
 
-	err := domain.Remove(db, domainId)
+	orgs, err := auditOrgs(db, db.Domains(), domainId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = domain.Remove(db, domainId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "domain", domainId,
+		orgs[domainId], nil)
+
 	event.PublishDispatch(db, "domain.change")
 
 	c.JSON(200, nil)
@@ -180,12 +230,23 @@ func domainsDelete(c *gin.Context) {
 		return
 	}
 
+	orgs, err := auditOrgs(db, db.Domains(), data...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	err = domain.RemoveMulti(db, data)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	for _, domainId := range data {
+		auditChange(c, db, audit.ResourceDelete, "domain", domainId,
+			orgs[domainId], nil)
+	}
+
 	event.PublishDispatch(db, "domain.change")
 
 	c.JSON(200, nil)
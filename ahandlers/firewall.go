@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/event"
@@ -23,6 +24,7 @@ type firewallData struct {
 	Organization primitive.ObjectID `json:"organization"`
 	NetworkRoles []string           `json:"network_roles"`
 	Ingress      []*firewall.Rule   `json:"ingress"`
+	Egress       []*firewall.Rule   `json:"egress"`
 }
 
 type firewallsData struct {
@@ -56,11 +58,14 @@ func firewallPut(c *gin.Context) {
 		return
 	}
 
+	fireOrig := audit.Snapshot(fire)
+
 	fire.Name = data.Name
 	fire.Comment = data.Comment
 	fire.Organization = data.Organization
 	fire.NetworkRoles = data.NetworkRoles
 	fire.Ingress = data.Ingress
+	fire.Egress = data.Egress
 
 	fields := set.NewSet(
 		"name",
@@ -68,6 +73,7 @@ func firewallPut(c *gin.Context) {
 		"organization",
 		"network_roles",
 		"ingress",
+		"egress",
 	)
 
 	errData, err := fire.Validate(db)
@@ -87,6 +93,9 @@ func firewallPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "firewall", fire.Id,
+		fire.Organization, audit.Diff(fireOrig, fire, fields))
+
 	event.PublishDispatch(db, "firewall.change")
 
 	c.JSON(200, fire)
@@ -114,6 +123,7 @@ func firewallPost(c *gin.Context) {
 		Organization: data.Organization,
 		NetworkRoles: data.NetworkRoles,
 		Ingress:      data.Ingress,
+		Egress:       data.Egress,
 	}
 
 	errData, err := fire.Validate(db)
@@ -133,6 +143,9 @@ func firewallPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "firewall", fire.Id,
+		fire.Organization, nil)
+
 	event.PublishDispatch(db, "firewall.change")
 
 	c.JSON(200, fire)
@@ -151,12 +164,21 @@ func firewallDelete(c *gin.Context) {
 		return
 	}
 
-	err := firewall.Remove(db, firewallId)
+	orgs, err := auditOrgs(db, db.Firewalls(), firewallId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = firewall.Remove(db, firewallId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "firewall", firewallId,
+		orgs[firewallId], nil)
+
 	event.PublishDispatch(db, "firewall.change")
 
 	c.JSON(200, nil)
@@ -176,12 +198,23 @@ func firewallsDelete(c *gin.Context) {
 		return
 	}
 
+	orgs, err := auditOrgs(db, db.Firewalls(), data...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	err = firewall.RemoveMulti(db, data)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	for _, firewallId := range data {
+		auditChange(c, db, audit.ResourceDelete, "firewall", firewallId,
+			orgs[firewallId], nil)
+	}
+
 	event.PublishDispatch(db, "firewall.change")
 
 	c.JSON(200, nil)
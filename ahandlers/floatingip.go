@@ -0,0 +1,275 @@
+package ahandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/floatingip"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type floatingIpData struct {
+	Id           primitive.ObjectID `json:"id"`
+	Name         string             `json:"name"`
+	Comment      string             `json:"comment"`
+	Organization primitive.ObjectID `json:"organization"`
+	Zone         primitive.ObjectID `json:"zone"`
+	Block        primitive.ObjectID `json:"block"`
+	Instance     primitive.ObjectID `json:"instance"`
+}
+
+type floatingIpsData struct {
+	FloatingIps []*floatingip.FloatingIp `json:"floating_ips"`
+	Count       int64                    `json:"count"`
+}
+
+func floatingIpPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &floatingIpData{}
+
+	fipId, ok := utils.ParseObjectId(c.Param("fip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fip, err := floatingip.Get(db, fipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fipOrig := audit.Snapshot(fip)
+
+	fip.Name = data.Name
+	fip.Comment = data.Comment
+	fip.Instance = data.Instance
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"instance",
+	)
+
+	errData, err := fip.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = fip.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceUpdate, "floating_ip", fip.Id,
+		fip.Organization, audit.Diff(fipOrig, fip, fields))
+
+	event.PublishDispatch(db, "floating_ip.change")
+
+	c.JSON(200, fip)
+}
+
+func floatingIpPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &floatingIpData{
+		Name: "New Floating IP",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "ahandler: Failed to bind"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fip := &floatingip.FloatingIp{
+		Name:         data.Name,
+		Comment:      data.Comment,
+		Organization: data.Organization,
+		Zone:         data.Zone,
+		Block:        data.Block,
+		Instance:     data.Instance,
+	}
+
+	errData, err := fip.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = fip.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData, err = fip.Allocate(db)
+	if err != nil || errData != nil {
+		_ = floatingip.Remove(db, fip.Id)
+
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+		} else {
+			c.JSON(400, errData)
+		}
+		return
+	}
+
+	err = fip.CommitFields(db, set.NewSet("block", "address"))
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceCreate, "floating_ip", fip.Id,
+		fip.Organization, nil)
+
+	event.PublishDispatch(db, "floating_ip.change")
+
+	c.JSON(200, fip)
+}
+
+func floatingIpDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	fipId, ok := utils.ParseObjectId(c.Param("fip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	orgs, err := auditOrgs(db, db.FloatingIps(), fipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = floatingip.Remove(db, fipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceDelete, "floating_ip", fipId,
+		orgs[fipId], nil)
+
+	event.PublishDispatch(db, "floating_ip.change")
+
+	c.JSON(200, nil)
+}
+
+func floatingIpGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	fipId, ok := utils.ParseObjectId(c.Param("fip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	fip, err := floatingip.Get(db, fipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, fip)
+}
+
+func floatingIpsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	fipId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = fipId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	address := strings.TrimSpace(c.Query("address"))
+	if address != "" {
+		query["address"] = address
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	zne, ok := utils.ParseObjectId(c.Query("zone"))
+	if ok {
+		query["zone"] = zne
+	}
+
+	instId, ok := utils.ParseObjectId(c.Query("instance"))
+	if ok {
+		query["instance"] = instId
+	}
+
+	fips, count, err := floatingip.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &floatingIpsData{
+		FloatingIps: fips,
+		Count:       count,
+	}
+
+	c.JSON(200, data)
+}
@@ -35,6 +35,7 @@ func Register(engine *gin.Engine) {
 
 	engine.NoRoute(middlewear.NotFound)
 
+	csrfGroup.GET("/audit", auditsChangesGet)
 	csrfGroup.GET("/audit/:user_id", auditsGet)
 
 	engine.GET("/auth/state", authStateGet)
@@ -117,17 +118,32 @@ func Register(engine *gin.Engine) {
 	csrfGroup.DELETE("/firewall", firewallsDelete)
 	csrfGroup.DELETE("/firewall/:firewall_id", firewallDelete)
 
+	csrfGroup.GET("/floating_ip", floatingIpsGet)
+	csrfGroup.GET("/floating_ip/:fip_id", floatingIpGet)
+	csrfGroup.PUT("/floating_ip/:fip_id", floatingIpPut)
+	csrfGroup.POST("/floating_ip", floatingIpPost)
+	csrfGroup.DELETE("/floating_ip/:fip_id", floatingIpDelete)
+
 	csrfGroup.GET("/image", imagesGet)
 	csrfGroup.GET("/image/:image_id", imageGet)
+	csrfGroup.GET("/image/:image_id/download", imageDownloadGet)
 	csrfGroup.PUT("/image/:image_id", imagePut)
 	csrfGroup.DELETE("/image", imagesDelete)
 	csrfGroup.DELETE("/image/:image_id", imageDelete)
 
+	csrfGroup.POST("/image_upload", imageUploadPost)
+	csrfGroup.GET("/image_upload/:upload_id", imageUploadGet)
+	csrfGroup.PUT("/image_upload/:upload_id", imageUploadPut)
+	csrfGroup.DELETE("/image_upload/:upload_id", imageUploadDelete)
+
 	csrfGroup.GET("/instance", instancesGet)
 	csrfGroup.PUT("/instance", instancesPut)
 	csrfGroup.GET("/instance/:instance_id", instanceGet)
 	csrfGroup.GET("/instance/:instance_id/vnc", instanceVncGet)
+	csrfGroup.GET("/instance/:instance_id/serial", instanceSerialGet)
 	csrfGroup.PUT("/instance/:instance_id", instancePut)
+	csrfGroup.PUT("/instance/:instance_id/migrate", instanceMigratePut)
+	csrfGroup.DELETE("/instance/:instance_id/migrate", instanceMigrateDelete)
 	csrfGroup.POST("/instance", instancePost)
 	csrfGroup.DELETE("/instance", instancesDelete)
 	csrfGroup.DELETE("/instance/:instance_id", instanceDelete)
@@ -146,10 +162,17 @@ func Register(engine *gin.Engine) {
 
 	csrfGroup.GET("/organization", organizationsGet)
 	csrfGroup.GET("/organization/:org_id", organizationGet)
+	csrfGroup.GET("/organization/:org_id/quota", organizationQuotaGet)
 	csrfGroup.PUT("/organization/:org_id", organizationPut)
 	csrfGroup.POST("/organization", organizationPost)
 	csrfGroup.DELETE("/organization/:org_id", organizationDelete)
 
+	csrfGroup.GET("/peering", peeringsGet)
+	csrfGroup.GET("/peering/:peering_id", peeringGet)
+	csrfGroup.PUT("/peering/:peering_id", peeringPut)
+	csrfGroup.POST("/peering", peeringPost)
+	csrfGroup.DELETE("/peering/:peering_id", peeringDelete)
+
 	csrfGroup.GET("/policy", policiesGet)
 	csrfGroup.GET("/policy/:policy_id", policyGet)
 	csrfGroup.PUT("/policy/:policy_id", policyPut)
@@ -162,6 +185,20 @@ func Register(engine *gin.Engine) {
 	csrfGroup.GET("/settings", settingsGet)
 	csrfGroup.PUT("/settings", settingsPut)
 
+	csrfGroup.GET("/signing_key", signingKeysGet)
+	csrfGroup.GET("/signing_key/:key_id", signingKeyGet)
+	csrfGroup.PUT("/signing_key/:key_id", signingKeyPut)
+	csrfGroup.POST("/signing_key", signingKeyPost)
+	csrfGroup.DELETE("/signing_key", signingKeysDelete)
+	csrfGroup.DELETE("/signing_key/:key_id", signingKeyDelete)
+
+	csrfGroup.GET("/snapshot_policy", snapshotPoliciesGet)
+	csrfGroup.GET("/snapshot_policy/:policy_id", snapshotPolicyGet)
+	csrfGroup.PUT("/snapshot_policy/:policy_id", snapshotPolicyPut)
+	csrfGroup.POST("/snapshot_policy", snapshotPolicyPost)
+	csrfGroup.DELETE("/snapshot_policy", snapshotPoliciesDelete)
+	csrfGroup.DELETE("/snapshot_policy/:policy_id", snapshotPolicyDelete)
+
 	csrfGroup.GET("/storage", storagesGet)
 	csrfGroup.GET("/storage/:store_id", storageGet)
 	csrfGroup.PUT("/storage/:store_id", storagePut)
@@ -172,8 +209,18 @@ func Register(engine *gin.Engine) {
 	csrfGroup.GET("/subscription/update", subscriptionUpdateGet)
 	csrfGroup.POST("/subscription", subscriptionPost)
 
+	csrfGroup.GET("/template", templatesGet)
+	csrfGroup.GET("/template/:template_id", templateGet)
+	csrfGroup.PUT("/template/:template_id", templatePut)
+	csrfGroup.POST("/template", templatePost)
+	csrfGroup.POST("/template/:template_id/launch", templateLaunchPost)
+	csrfGroup.DELETE("/template", templatesDelete)
+	csrfGroup.DELETE("/template/:template_id", templateDelete)
+
 	csrfGroup.PUT("/theme", themePut)
 
+	csrfGroup.GET("/usage", usageGet)
+
 	csrfGroup.GET("/user", usersGet)
 	csrfGroup.GET("/user/:user_id", userGet)
 	csrfGroup.PUT("/user/:user_id", userPut)
@@ -9,12 +9,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
 	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -23,6 +26,7 @@ type imageData struct {
 	Name         string             `json:"name"`
 	Comment        string             `json:"comment"`
 	Organization primitive.ObjectID `json:"organization"`
+	NetConfig    string             `json:"net_config"`
 }
 
 type imagesData struct {
@@ -56,14 +60,18 @@ func imagePut(c *gin.Context) {
 		return
 	}
 
+	imgOrig := audit.Snapshot(img)
+
 	img.Name = dta.Name
 	img.Comment = dta.Comment
 	img.Organization = dta.Organization
+	img.NetConfig = dta.NetConfig
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"organization",
+		"net_config",
 	)
 
 	errData, err := img.Validate(db)
@@ -83,6 +91,9 @@ func imagePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "image", img.Id,
+		img.Organization, audit.Diff(imgOrig, img, fields))
+
 	event.PublishDispatch(db, "image.change")
 
 	c.JSON(200, img)
@@ -101,12 +112,21 @@ func imageDelete(c *gin.Context) {
 		return
 	}
 
-	err := data.DeleteImage(db, imageId)
+	orgs, err := auditOrgs(db, db.Images(), imageId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	err = data.DeleteImage(db, imageId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceDelete, "image", imageId,
+		orgs[imageId], nil)
+
 	event.PublishDispatch(db, "image.change")
 
 	c.JSON(200, nil)
@@ -126,12 +146,23 @@ func imagesDelete(c *gin.Context) {
 		return
 	}
 
+	orgs, err := auditOrgs(db, db.Images(), dta...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	err = data.DeleteImages(db, dta)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	for _, imageId := range dta {
+		auditChange(c, db, audit.ResourceDelete, "image", imageId,
+			orgs[imageId], nil)
+	}
+
 	event.PublishDispatch(db, "image.change")
 
 	c.JSON(200, nil)
@@ -157,6 +188,42 @@ func imageGet(c *gin.Context) {
 	c.JSON(200, img)
 }
 
+func imageDownloadGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	imageId, ok := utils.ParseObjectId(c.Param("image_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	img, err := image.Get(db, imageId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if img.Type != storage.Private {
+		errData := &errortypes.ErrorData{
+			Error:   "image_download_invalid",
+			Message: "Only private images can be downloaded",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = data.ExportImage(db, img, c.Writer)
+	if err != nil {
+		if c.Writer.Written() {
+			c.Error(err)
+			c.Abort()
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+}
+
 func imagesGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 
@@ -0,0 +1,170 @@
+package ahandlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/data"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/upload"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type imageUploadData struct {
+	Name         string             `json:"name"`
+	Comment      string             `json:"comment"`
+	Organization primitive.ObjectID `json:"organization"`
+	Datacenter   primitive.ObjectID `json:"datacenter"`
+	Filename     string             `json:"filename"`
+	Size         int64              `json:"size"`
+	Checksum     string             `json:"checksum"`
+}
+
+func imageUploadPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &imageUploadData{}
+
+	err := c.Bind(dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	upld := &upload.Upload{
+		Name:         dta.Name,
+		Comment:      dta.Comment,
+		Organization: dta.Organization,
+		Datacenter:   dta.Datacenter,
+		Node:         node.Self.Id,
+		Filename:     dta.Filename,
+		Size:         dta.Size,
+		Checksum:     dta.Checksum,
+		Timestamp:    time.Now(),
+	}
+
+	errData, err := upld.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = upld.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, upld)
+}
+
+func imageUploadGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	upldId, ok := utils.ParseObjectId(c.Param("upload_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	upld, err := upload.Get(db, upldId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, upld)
+}
+
+func imageUploadPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	upldId, ok := utils.ParseObjectId(c.Param("upload_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	upld, err := upload.Get(db, upldId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	img, errData, err := data.WriteUpload(db, upld, offset, c.Request.Body)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	if img == nil {
+		c.JSON(200, upld)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceCreate, "image", img.Id,
+		img.Organization, nil)
+
+	c.JSON(200, img)
+}
+
+func imageUploadDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	upldId, ok := utils.ParseObjectId(c.Param("upload_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	upld, err := upload.Get(db, upldId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = data.DeleteUpload(db, upld)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
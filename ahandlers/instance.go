@@ -10,6 +10,7 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/aggregate"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -20,6 +21,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/iscsi"
 	"github.com/pritunl/pritunl-cloud/iso"
+	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/pci"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/usb"
@@ -44,12 +46,15 @@ type instanceData struct {
 	RootEnabled         bool               `json:"root_enabled"`
 	Uefi                bool               `json:"uefi"`
 	SecureBoot          bool               `json:"secure_boot"`
+	Tpm                 bool               `json:"tpm"`
+	DedicatedCpus       bool               `json:"dedicated_cpus"`
 	DeleteProtection    bool               `json:"delete_protection"`
 	SkipSourceDestCheck bool               `json:"skip_source_dest_check"`
 	InitDiskSize        int                `json:"init_disk_size"`
 	Memory              int                `json:"memory"`
 	Processors          int                `json:"processors"`
 	NetworkRoles        []string           `json:"network_roles"`
+	AntiAffinity        []string           `json:"anti_affinity"`
 	Isos                []*iso.Iso         `json:"isos"`
 	UsbDevices          []*usb.Device      `json:"usb_devices"`
 	PciDevices          []*pci.Device      `json:"pci_devices"`
@@ -60,9 +65,15 @@ type instanceData struct {
 	Gui                 bool               `json:"gui"`
 	NoPublicAddress     bool               `json:"no_public_address"`
 	NoHostAddress       bool               `json:"no_host_address"`
+	BandwidthIngress    int                `json:"bandwidth_ingress"`
+	BandwidthEgress     int                `json:"bandwidth_egress"`
 	Count               int                `json:"count"`
 }
 
+type instanceMigrateData struct {
+	Node primitive.ObjectID `json:"node"`
+}
+
 type instanceMultiData struct {
 	Ids   []primitive.ObjectID `json:"ids"`
 	State string               `json:"state"`
@@ -99,6 +110,8 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	instOrig := audit.Snapshot(inst)
+
 	inst.PreCommit()
 
 	inst.Name = dta.Name
@@ -111,11 +124,14 @@ func instancePut(c *gin.Context) {
 	}
 	inst.Uefi = dta.Uefi
 	inst.SecureBoot = dta.SecureBoot
+	inst.Tpm = dta.Tpm
+	inst.DedicatedCpus = dta.DedicatedCpus
 	inst.DeleteProtection = dta.DeleteProtection
 	inst.SkipSourceDestCheck = dta.SkipSourceDestCheck
 	inst.Memory = dta.Memory
 	inst.Processors = dta.Processors
 	inst.NetworkRoles = dta.NetworkRoles
+	inst.AntiAffinity = dta.AntiAffinity
 	inst.Isos = dta.Isos
 	inst.UsbDevices = dta.UsbDevices
 	inst.PciDevices = dta.PciDevices
@@ -128,6 +144,8 @@ func instancePut(c *gin.Context) {
 	inst.Domain = dta.Domain
 	inst.NoPublicAddress = dta.NoPublicAddress
 	inst.NoHostAddress = dta.NoHostAddress
+	inst.BandwidthIngress = dta.BandwidthIngress
+	inst.BandwidthEgress = dta.BandwidthEgress
 
 	fields := set.NewSet(
 		"unix_id",
@@ -141,11 +159,14 @@ func instancePut(c *gin.Context) {
 		"restart_block_ip",
 		"uefi",
 		"secure_boot",
+		"tpm",
+		"dedicated_cpus",
 		"delete_protection",
 		"skip_source_dest_check",
 		"memory",
 		"processors",
 		"network_roles",
+		"anti_affinity",
 		"isos",
 		"usb_devices",
 		"pci_devices",
@@ -163,6 +184,8 @@ func instancePut(c *gin.Context) {
 		"domain",
 		"no_public_address",
 		"no_host_address",
+		"bandwidth_ingress",
+		"bandwidth_egress",
 	)
 
 	errData, err := inst.Validate(db)
@@ -188,6 +211,9 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "instance", inst.Id,
+		inst.Organization, audit.Diff(instOrig, inst, fields))
+
 	event.PublishDispatch(db, "instance.change")
 	if dskChange {
 		event.PublishDispatch(db, "disk.change")
@@ -196,6 +222,103 @@ func instancePut(c *gin.Context) {
 	c.JSON(200, inst)
 }
 
+func instanceMigratePut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &instanceMigrateData{}
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	instOrig := audit.Snapshot(inst)
+
+	errData, err := inst.Migrate(db, dta.Node)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	fields := set.NewSet(
+		"migrate_node", "migrate_state", "migrate_port")
+
+	err = inst.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceUpdate, "instance", inst.Id,
+		inst.Organization, audit.Diff(instOrig, inst, fields))
+
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, inst)
+}
+
+func instanceMigrateDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if inst.MigrateState == instance.MigratePending ||
+		inst.MigrateState == instance.MigrateReady {
+
+		instOrig := audit.Snapshot(inst)
+		fields := set.NewSet("migrate_state")
+
+		inst.MigrateState = instance.MigrateFailed
+		err = inst.CommitFields(db, fields)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		auditChange(c, db, audit.ResourceUpdate, "instance", inst.Id,
+			inst.Organization, audit.Diff(instOrig, inst, fields))
+
+		event.PublishDispatch(db, "instance.change")
+	}
+
+	c.JSON(200, inst)
+}
+
 func instancePost(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -254,6 +377,30 @@ func instancePost(c *gin.Context) {
 
 			return
 		}
+
+		if !img.Signed {
+			org, err := organization.Get(db, dta.Organization)
+			if err != nil {
+				utils.AbortWithError(c, 500, err)
+				return
+			}
+
+			if org.RequireSignedImages {
+				errData := &errortypes.ErrorData{
+					Error:   "image_unsigned",
+					Message: "Organization requires signed images",
+				}
+				c.JSON(400, errData)
+				return
+			}
+		}
+
+		if dta.Processors == 0 && img.Processors > 0 {
+			dta.Processors = img.Processors
+		}
+		if dta.Memory == 0 && img.Memory > 0 {
+			dta.Memory = img.Memory
+		}
 	}
 
 	insts := []*instance.Instance{}
@@ -282,6 +429,8 @@ func instancePost(c *gin.Context) {
 			ImageBacking:        dta.ImageBacking,
 			Uefi:                dta.Uefi,
 			SecureBoot:          dta.SecureBoot,
+			Tpm:                 dta.Tpm,
+			DedicatedCpus:       dta.DedicatedCpus,
 			DeleteProtection:    dta.DeleteProtection,
 			SkipSourceDestCheck: dta.SkipSourceDestCheck,
 			Name:                name,
@@ -290,6 +439,7 @@ func instancePost(c *gin.Context) {
 			Memory:              dta.Memory,
 			Processors:          dta.Processors,
 			NetworkRoles:        dta.NetworkRoles,
+			AntiAffinity:        dta.AntiAffinity,
 			Isos:                dta.Isos,
 			UsbDevices:          dta.UsbDevices,
 			PciDevices:          dta.PciDevices,
@@ -302,6 +452,8 @@ func instancePost(c *gin.Context) {
 			Domain:              dta.Domain,
 			NoPublicAddress:     dta.NoPublicAddress,
 			NoHostAddress:       dta.NoHostAddress,
+			BandwidthIngress:    dta.BandwidthIngress,
+			BandwidthEgress:     dta.BandwidthEgress,
 		}
 
 		errData, err := inst.Validate(db)
@@ -321,6 +473,9 @@ func instancePost(c *gin.Context) {
 			return
 		}
 
+		auditChange(c, db, audit.ResourceCreate, "instance", inst.Id,
+			inst.Organization, nil)
+
 		insts = append(insts, inst)
 	}
 
@@ -362,6 +517,16 @@ func instancesPut(c *gin.Context) {
 		return
 	}
 
+	for _, instId := range dta.Ids {
+		auditChange(c, db, audit.ResourceUpdate, "instance", instId,
+			primitive.NilObjectID, []*audit.Change{
+				&audit.Change{
+					Field: "state",
+					New:   dta.State,
+				},
+			})
+	}
+
 	event.PublishDispatch(db, "instance.change")
 
 	c.JSON(200, nil)
@@ -401,6 +566,9 @@ func instanceDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "instance", inst.Id,
+		inst.Organization, nil)
+
 	event.PublishDispatch(db, "instance.change")
 
 	c.JSON(200, nil)
@@ -420,6 +588,12 @@ func instancesDelete(c *gin.Context) {
 		return
 	}
 
+	orgs, err := auditOrgs(db, db.Instances(), dta...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	force := c.Query("force")
 	if force == "true" {
 		for _, instId := range dta {
@@ -437,6 +611,11 @@ func instancesDelete(c *gin.Context) {
 		}
 	}
 
+	for _, instId := range dta {
+		auditChange(c, db, audit.ResourceDelete, "instance", instId,
+			orgs[instId], nil)
+	}
+
 	event.PublishDispatch(db, "instance.change")
 
 	c.JSON(200, nil)
@@ -617,3 +796,33 @@ func instanceVncGet(c *gin.Context) {
 		return
 	}
 }
+
+func instanceSerialGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = inst.SerialConnect(db, c.Writer, c.Request)
+	if err != nil {
+		if _, ok := err.(*instance.SerialDialError); ok {
+			utils.AbortWithStatus(c, 504)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/block"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -30,6 +31,8 @@ type nodeData struct {
 	Port                 int                     `json:"port"`
 	NoRedirectServer     bool                    `json:"no_redirect_server"`
 	Protocol             string                  `json:"protocol"`
+	MetricsPort          int                     `json:"metrics_port"`
+	MetricsToken         string                  `json:"metrics_token"`
 	Hypervisor           string                  `json:"hypervisor"`
 	Vga                  string                  `json:"vga"`
 	VgaRender            string                  `json:"vga_render"`
@@ -58,6 +61,7 @@ type nodeData struct {
 	PciPassthrough       bool                    `json:"pci_passthrough"`
 	Hugepages            bool                    `json:"hugepages"`
 	HugepagesSize        int                     `json:"hugepages_size"`
+	DedicatedCpus        []int                   `json:"dedicated_cpus"`
 	ForwardedForHeader   string                  `json:"forwarded_for_header"`
 	ForwardedProtoHeader string                  `json:"forwarded_proto_header"`
 	Firewall             bool                    `json:"firewall"`
@@ -108,12 +112,16 @@ func nodePut(c *gin.Context) {
 		return
 	}
 
+	ndeOrig := audit.Snapshot(nde)
+
 	nde.Name = data.Name
 	nde.Comment = data.Comment
 	nde.Types = data.Types
 	nde.Port = data.Port
 	nde.NoRedirectServer = data.NoRedirectServer
 	nde.Protocol = data.Protocol
+	nde.MetricsPort = data.MetricsPort
+	nde.MetricsToken = data.MetricsToken
 	nde.Hypervisor = data.Hypervisor
 	nde.Vga = data.Vga
 	nde.VgaRender = data.VgaRender
@@ -141,6 +149,7 @@ func nodePut(c *gin.Context) {
 	nde.PciPassthrough = data.PciPassthrough
 	nde.Hugepages = data.Hugepages
 	nde.HugepagesSize = data.HugepagesSize
+	nde.DedicatedCpus = data.DedicatedCpus
 	nde.ForwardedForHeader = data.ForwardedForHeader
 	nde.ForwardedProtoHeader = data.ForwardedProtoHeader
 	nde.Firewall = data.Firewall
@@ -156,6 +165,8 @@ func nodePut(c *gin.Context) {
 		"port",
 		"no_redirect_server",
 		"protocol",
+		"metrics_port",
+		"metrics_token",
 		"hypervisor",
 		"vga",
 		"vga_render",
@@ -183,6 +194,7 @@ func nodePut(c *gin.Context) {
 		"pci_passthrough",
 		"hugepages",
 		"hugepages_size",
+		"dedicated_cpus",
 		"forwarded_for_header",
 		"forwarded_proto_header",
 		"firewall",
@@ -220,6 +232,9 @@ func nodePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "node", nde.Id,
+		primitive.NilObjectID, audit.Diff(ndeOrig, nde, fields))
+
 	event.PublishDispatch(db, "node.change")
 
 	c.JSON(200, nde)
@@ -250,6 +265,9 @@ func nodeOperationPut(c *gin.Context) {
 		return
 	}
 
+	ndeOrig := audit.Snapshot(nde)
+	fields := set.NewSet("operation")
+
 	nde.Operation = node.Restart
 
 	errData, err := nde.Validate(db)
@@ -263,12 +281,15 @@ func nodeOperationPut(c *gin.Context) {
 		return
 	}
 
-	err = nde.CommitFields(db, set.NewSet("operation"))
+	err = nde.CommitFields(db, fields)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "node", nde.Id,
+		primitive.NilObjectID, audit.Diff(ndeOrig, nde, fields))
+
 	event.PublishDispatch(db, "node.change")
 
 	c.JSON(200, nde)
@@ -300,6 +321,8 @@ func nodeInitPost(c *gin.Context) {
 		return
 	}
 
+	ndeOrig := audit.Snapshot(nde)
+
 	fields := set.NewSet(
 		"zone",
 		"network_mode",
@@ -335,14 +358,20 @@ func nodeInitPost(c *gin.Context) {
 		return
 	}
 
+	zneOrig := audit.Snapshot(zne)
+	zneFields := set.NewSet("network_mode")
+
 	zne.NetworkMode = zone.VxlanVlan
 
-	err = zne.CommitFields(db, set.NewSet("network_mode"))
+	err = zne.CommitFields(db, zneFields)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "zone", zne.Id,
+		primitive.NilObjectID, audit.Diff(zneOrig, zne, zneFields))
+
 	event.PublishDispatch(db, "zone.change")
 
 	_, hostnet, err := net.ParseCIDR(data.HostNetwork)
@@ -394,8 +423,9 @@ func nodeInitPost(c *gin.Context) {
 	nde.HostNat = true
 	nde.HostBlock = hostBlck.Id
 
+	var publicBlck *block.Block
 	if data.Provider == "phoenixnap" {
-		publicBlck := &block.Block{
+		publicBlck = &block.Block{
 			Name:    nde.Name + "-public",
 			Type:    block.IPv4,
 			Subnets: data.BlockSubnets,
@@ -471,6 +501,17 @@ func nodeInitPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "block", hostBlck.Id,
+		primitive.NilObjectID, nil)
+
+	if publicBlck != nil {
+		auditChange(c, db, audit.ResourceCreate, "block",
+			publicBlck.Id, primitive.NilObjectID, nil)
+	}
+
+	auditChange(c, db, audit.ResourceUpdate, "node", nde.Id,
+		primitive.NilObjectID, audit.Diff(ndeOrig, nde, fields))
+
 	event.PublishDispatch(db, "node.change")
 	event.PublishDispatch(db, "block.change")
 
@@ -496,6 +537,9 @@ func nodeDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "node", nodeId,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "node.change")
 
 	c.JSON(200, nil)
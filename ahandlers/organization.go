@@ -4,18 +4,24 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/organization"
+	"github.com/pritunl/pritunl-cloud/quota"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
 type organizationData struct {
-	Id      primitive.ObjectID `json:"id"`
-	Name    string             `json:"name"`
-	Comment string             `json:"comment"`
-	Roles   []string           `json:"roles"`
+	Id                  primitive.ObjectID `json:"id"`
+	Name                string             `json:"name"`
+	Comment             string             `json:"comment"`
+	Roles               []string           `json:"roles"`
+	RequireSignedImages bool               `json:"require_signed_images"`
+	Quota               organization.Quota `json:"quota"`
+	DiskLimits          vm.DiskLimits      `json:"disk_limits"`
 }
 
 func organizationPut(c *gin.Context) {
@@ -44,14 +50,22 @@ func organizationPut(c *gin.Context) {
 		return
 	}
 
+	orgOrig := audit.Snapshot(org)
+
 	org.Name = data.Name
 	org.Comment = data.Comment
 	org.Roles = data.Roles
+	org.RequireSignedImages = data.RequireSignedImages
+	org.Quota = data.Quota
+	org.DiskLimits = data.DiskLimits
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"roles",
+		"require_signed_images",
+		"quota",
+		"disk_limits",
 	)
 
 	errData, err := org.Validate(db)
@@ -71,6 +85,9 @@ func organizationPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "organization", org.Id,
+		org.Id, audit.Diff(orgOrig, org, fields))
+
 	event.PublishDispatch(db, "organization.change")
 
 	c.JSON(200, org)
@@ -93,9 +110,12 @@ func organizationPost(c *gin.Context) {
 	}
 
 	org := &organization.Organization{
-		Name:    data.Name,
-		Comment: data.Comment,
-		Roles:   data.Roles,
+		Name:                data.Name,
+		Comment:             data.Comment,
+		Roles:               data.Roles,
+		RequireSignedImages: data.RequireSignedImages,
+		Quota:               data.Quota,
+		DiskLimits:          data.DiskLimits,
 	}
 
 	errData, err := org.Validate(db)
@@ -115,6 +135,9 @@ func organizationPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "organization", org.Id,
+		org.Id, nil)
+
 	event.PublishDispatch(db, "organization.change")
 
 	c.JSON(200, org)
@@ -139,6 +162,9 @@ func organizationDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "organization", orgId,
+		orgId, nil)
+
 	event.PublishDispatch(db, "organization.change")
 
 	c.JSON(200, nil)
@@ -162,6 +188,24 @@ func organizationGet(c *gin.Context) {
 	c.JSON(200, org)
 }
 
+func organizationQuotaGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	orgId, ok := utils.ParseObjectId(c.Param("org_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	status, err := quota.GetStatus(db, orgId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, status)
+}
+
 func organizationsGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 
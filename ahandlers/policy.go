@@ -4,6 +4,7 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/event"
@@ -54,6 +55,8 @@ func policyPut(c *gin.Context) {
 		return
 	}
 
+	polcyOrig := audit.Snapshot(polcy)
+
 	polcy.Name = data.Name
 	polcy.Comment = data.Comment
 	polcy.Disabled = data.Disabled
@@ -93,6 +96,9 @@ func policyPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "policy", polcy.Id,
+		primitive.NilObjectID, audit.Diff(polcyOrig, polcy, fields))
+
 	event.PublishDispatch(db, "policy.change")
 
 	c.JSON(200, polcy)
@@ -143,6 +149,9 @@ func policyPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "policy", polcy.Id,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "policy.change")
 
 	c.JSON(200, polcy)
@@ -167,6 +176,9 @@ func policyDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "policy", polcyId,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "policy.change")
 
 	c.JSON(200, nil)
@@ -4,6 +4,7 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/event"
@@ -55,6 +56,8 @@ func settingsPut(c *gin.Context) {
 		return
 	}
 
+	authOrig := audit.Snapshot(settings.Auth)
+
 	fields := set.NewSet(
 		"providers",
 		"secondary_providers",
@@ -108,6 +111,10 @@ func settingsPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "settings",
+		primitive.NilObjectID, primitive.NilObjectID,
+		audit.Diff(authOrig, settings.Auth, fields))
+
 	event.PublishDispatch(db, "settings.change")
 
 	data = getSettingsData()
@@ -0,0 +1,298 @@
+package ahandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/snapshot"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type snapshotPolicyData struct {
+	Id             primitive.ObjectID   `json:"id"`
+	Name           string               `json:"name"`
+	Comment        string               `json:"comment"`
+	Organization   primitive.ObjectID   `json:"organization"`
+	Type           string               `json:"type"`
+	Schedule       string               `json:"schedule"`
+	Disks          []primitive.ObjectID `json:"disks"`
+	NetworkRoles   []string             `json:"network_roles"`
+	RetentionCount int                  `json:"retention_count"`
+	RetentionAge   int                  `json:"retention_age"`
+}
+
+type snapshotPoliciesData struct {
+	Policies []*snapshot.Policy `json:"policies"`
+	Count    int64              `json:"count"`
+}
+
+func snapshotPolicyPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &snapshotPolicyData{}
+
+	policyId, ok := utils.ParseObjectId(c.Param("policy_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	pol, err := snapshot.Get(db, policyId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	polOrig := audit.Snapshot(pol)
+
+	pol.Name = data.Name
+	pol.Comment = data.Comment
+	pol.Organization = data.Organization
+	pol.Type = data.Type
+	pol.Schedule = data.Schedule
+	pol.Disks = data.Disks
+	pol.NetworkRoles = data.NetworkRoles
+	pol.RetentionCount = data.RetentionCount
+	pol.RetentionAge = data.RetentionAge
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"organization",
+		"type",
+		"schedule",
+		"disks",
+		"network_roles",
+		"retention_count",
+		"retention_age",
+	)
+
+	errData, err := pol.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = pol.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceUpdate, "snapshot_policy", pol.Id,
+		pol.Organization, audit.Diff(polOrig, pol, fields))
+
+	event.PublishDispatch(db, "snapshot_policy.change")
+
+	c.JSON(200, pol)
+}
+
+func snapshotPolicyPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &snapshotPolicyData{
+		Name:     "New Snapshot Policy",
+		Type:     snapshot.Snapshot,
+		Schedule: "0 0 * * *",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	pol := &snapshot.Policy{
+		Name:           data.Name,
+		Comment:        data.Comment,
+		Organization:   data.Organization,
+		Type:           data.Type,
+		Schedule:       data.Schedule,
+		Disks:          data.Disks,
+		NetworkRoles:   data.NetworkRoles,
+		RetentionCount: data.RetentionCount,
+		RetentionAge:   data.RetentionAge,
+	}
+
+	errData, err := pol.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = pol.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceCreate, "snapshot_policy", pol.Id,
+		pol.Organization, nil)
+
+	event.PublishDispatch(db, "snapshot_policy.change")
+
+	c.JSON(200, pol)
+}
+
+func snapshotPolicyDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	policyId, ok := utils.ParseObjectId(c.Param("policy_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	orgs, err := auditOrgs(db, db.SnapshotPolicies(), policyId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = snapshot.Remove(db, policyId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceDelete, "snapshot_policy", policyId,
+		orgs[policyId], nil)
+
+	event.PublishDispatch(db, "snapshot_policy.change")
+
+	c.JSON(200, nil)
+}
+
+func snapshotPoliciesDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	orgs, err := auditOrgs(db, db.SnapshotPolicies(), data...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = snapshot.RemoveMulti(db, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, snapshotId := range data {
+		auditChange(c, db, audit.ResourceDelete, "snapshot_policy",
+			snapshotId, orgs[snapshotId], nil)
+	}
+
+	event.PublishDispatch(db, "snapshot_policy.change")
+
+	c.JSON(200, nil)
+}
+
+func snapshotPolicyGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	policyId, ok := utils.ParseObjectId(c.Param("policy_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	pol, err := snapshot.Get(db, policyId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, pol)
+}
+
+func snapshotPoliciesGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	policyId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = policyId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	networkRole := strings.TrimSpace(c.Query("network_role"))
+	if networkRole != "" {
+		query["network_roles"] = networkRole
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	pols, count, err := snapshot.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &snapshotPoliciesData{
+		Policies: pols,
+		Count:    count,
+	}
+
+	c.JSON(200, data)
+}
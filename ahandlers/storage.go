@@ -4,6 +4,7 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -18,6 +19,8 @@ type storageData struct {
 	Name      string             `json:"name"`
 	Comment   string             `json:"comment"`
 	Type      string             `json:"type"`
+	Backend   string             `json:"backend"`
+	Path      string             `json:"path"`
 	Endpoint  string             `json:"endpoint"`
 	Bucket    string             `json:"bucket"`
 	AccessKey string             `json:"access_key"`
@@ -51,9 +54,13 @@ func storagePut(c *gin.Context) {
 		return
 	}
 
+	storeOrig := audit.Snapshot(store)
+
 	store.Name = dta.Name
 	store.Comment = dta.Comment
 	store.Type = dta.Type
+	store.Backend = dta.Backend
+	store.Path = dta.Path
 	store.Endpoint = dta.Endpoint
 	store.Bucket = dta.Bucket
 	store.AccessKey = dta.AccessKey
@@ -64,6 +71,8 @@ func storagePut(c *gin.Context) {
 		"name",
 		"comment",
 		"type",
+		"backend",
+		"path",
 		"endpoint",
 		"bucket",
 		"access_key",
@@ -88,6 +97,9 @@ func storagePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "storage", store.Id,
+		primitive.NilObjectID, audit.Diff(storeOrig, store, fields))
+
 	go func() {
 		db := database.GetDatabase()
 		defer db.Close()
@@ -127,6 +139,8 @@ func storagePost(c *gin.Context) {
 		Name:      dta.Name,
 		Comment:   dta.Comment,
 		Type:      dta.Type,
+		Backend:   dta.Backend,
+		Path:      dta.Path,
 		Endpoint:  dta.Endpoint,
 		Bucket:    dta.Bucket,
 		AccessKey: dta.AccessKey,
@@ -151,6 +165,9 @@ func storagePost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "storage", store.Id,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "storage.change")
 
 	c.JSON(200, store)
@@ -175,6 +192,9 @@ func storageDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "storage", storeId,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "storage.change")
 
 	c.JSON(200, nil)
@@ -0,0 +1,105 @@
+package ahandlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/organization"
+	"github.com/pritunl/pritunl-cloud/usage"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func usageGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	orgId, _ := utils.ParseObjectId(c.Query("organization"))
+
+	endTime := time.Now().UTC().Truncate(time.Hour).Add(time.Hour)
+	end := c.Query("end")
+	if end != "" {
+		parsed, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			utils.AbortWithStatus(c, 400)
+			return
+		}
+		endTime = parsed
+	}
+
+	startTime := endTime.AddDate(0, -1, 0)
+	start := c.Query("start")
+	if start != "" {
+		parsed, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			utils.AbortWithStatus(c, 400)
+			return
+		}
+		startTime = parsed
+	}
+
+	hourly, err := usage.GetHourly(db, orgId, startTime, endTime)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	orgs, err := organization.GetAll(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	orgNames := map[string]string{}
+	for _, org := range orgs {
+		orgNames[org.Id.Hex()] = org.Name
+	}
+
+	for _, hour := range hourly {
+		hour.OrganizationName = orgNames[hour.Organization.Hex()]
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(200, hourly)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf(
+		"attachment; filename=\"usage-%s.csv\"",
+		startTime.Format("2006-01-02")))
+	c.Status(200)
+
+	formatHours := func(val float64) string {
+		return strconv.FormatFloat(val, 'f', 4, 64)
+	}
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{
+		"organization",
+		"organization_name",
+		"period",
+		"instance_hours",
+		"processor_hours",
+		"memory_gb_hours",
+		"disk_gb_hours",
+		"backup_gb_hours",
+	})
+
+	for _, hour := range hourly {
+		writer.Write([]string{
+			hour.Organization.Hex(),
+			hour.OrganizationName,
+			hour.Period.UTC().Format(time.RFC3339),
+			formatHours(hour.InstanceHours),
+			formatHours(hour.ProcessorHours),
+			formatHours(hour.MemoryGbHours),
+			formatHours(hour.DiskGbHours),
+			formatHours(hour.BackupGbHours),
+		})
+	}
+
+	writer.Flush()
+}
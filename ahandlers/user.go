@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/event"
@@ -90,6 +91,8 @@ func userPut(c *gin.Context) {
 		return
 	}
 
+	usrOrig := audit.Snapshot(usr)
+
 	showSecret := false
 	if usr.Type != data.Type {
 		if data.Type == user.Api {
@@ -173,6 +176,9 @@ func userPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "user", usr.Id,
+		primitive.NilObjectID, audit.Diff(usrOrig, usr, fields))
+
 	event.PublishDispatch(db, "user.change")
 
 	if !showSecret {
@@ -240,6 +246,9 @@ func userPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "user", usr.Id,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "user.change")
 
 	c.JSON(200, usr)
@@ -349,6 +358,11 @@ func usersDelete(c *gin.Context) {
 		return
 	}
 
+	for _, userId := range data {
+		auditChange(c, db, audit.ResourceDelete, "user", userId,
+			primitive.NilObjectID, nil)
+	}
+
 	event.PublishDispatch(db, "user.change")
 
 	c.JSON(200, nil)
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/errortypes"
@@ -19,14 +20,21 @@ import (
 )
 
 type vpcData struct {
-	Id           primitive.ObjectID `json:"id"`
-	Name         string             `json:"name"`
-	Comment      string             `json:"comment"`
-	Network      string             `json:"network"`
-	Subnets      []*vpc.Subnet      `json:"subnets"`
-	Organization primitive.ObjectID `json:"organization"`
-	Datacenter   primitive.ObjectID `json:"datacenter"`
-	Routes       []*vpc.Route       `json:"routes"`
+	Id               primitive.ObjectID `json:"id"`
+	Name             string             `json:"name"`
+	Comment          string             `json:"comment"`
+	Network          string             `json:"network"`
+	Network6         string             `json:"network6"`
+	Block6           primitive.ObjectID `json:"block6"`
+	Subnets          []*vpc.Subnet      `json:"subnets"`
+	Organization     primitive.ObjectID `json:"organization"`
+	Datacenter       primitive.ObjectID `json:"datacenter"`
+	Routes           []*vpc.Route       `json:"routes"`
+	DnsServers       []string           `json:"dns_servers"`
+	SearchDomains    []string           `json:"search_domains"`
+	NatGateway       *vpc.NatGateway    `json:"nat_gateway"`
+	BandwidthIngress int                `json:"bandwidth_ingress"`
+	BandwidthEgress  int                `json:"bandwidth_egress"`
 }
 
 type vpcsData struct {
@@ -60,18 +68,34 @@ func vpcPut(c *gin.Context) {
 		return
 	}
 
+	vcOrig := audit.Snapshot(vc)
+
 	vc.PreCommit()
 
 	vc.Name = data.Name
 	vc.Comment = data.Comment
+	vc.Network6 = data.Network6
+	vc.Block6 = data.Block6
 	vc.Routes = data.Routes
 	vc.Subnets = data.Subnets
+	vc.DnsServers = data.DnsServers
+	vc.SearchDomains = data.SearchDomains
+	vc.NatGateway = data.NatGateway
+	vc.BandwidthIngress = data.BandwidthIngress
+	vc.BandwidthEgress = data.BandwidthEgress
 
 	fields := set.NewSet(
 		"name",
 		"comment",
+		"network6",
+		"block6",
 		"routes",
 		"subnets",
+		"dns_servers",
+		"search_domains",
+		"nat_gateway",
+		"bandwidth_ingress",
+		"bandwidth_egress",
 	)
 
 	errData, err := vc.Validate(db)
@@ -102,6 +126,9 @@ func vpcPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "vpc", vc.Id,
+		vc.Organization, audit.Diff(vcOrig, vc, fields))
+
 	event.PublishDispatch(db, "vpc.change")
 
 	vc.Json()
@@ -129,13 +156,20 @@ func vpcPost(c *gin.Context) {
 	}
 
 	vc := &vpc.Vpc{
-		Name:         data.Name,
-		Comment:      data.Comment,
-		Network:      data.Network,
-		Subnets:      data.Subnets,
-		Organization: data.Organization,
-		Datacenter:   data.Datacenter,
-		Routes:       data.Routes,
+		Name:             data.Name,
+		Comment:          data.Comment,
+		Network:          data.Network,
+		Network6:         data.Network6,
+		Block6:           data.Block6,
+		Subnets:          data.Subnets,
+		Organization:     data.Organization,
+		Datacenter:       data.Datacenter,
+		Routes:           data.Routes,
+		DnsServers:       data.DnsServers,
+		SearchDomains:    data.SearchDomains,
+		NatGateway:       data.NatGateway,
+		BandwidthIngress: data.BandwidthIngress,
+		BandwidthEgress:  data.BandwidthEgress,
 	}
 
 	vc.InitVpc()
@@ -157,6 +191,23 @@ func vpcPost(c *gin.Context) {
 		return
 	}
 
+	if vc.NatGateway != nil {
+		err = vc.AllocateNatGateway(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		err = vc.CommitFields(db, set.NewSet("nat_gateway"))
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	auditChange(c, db, audit.ResourceCreate, "vpc", vc.Id,
+		vc.Organization, nil)
+
 	event.PublishDispatch(db, "vpc.change")
 
 	vc.Json()
@@ -177,12 +228,21 @@ func vpcDelete(c *gin.Context) {
 		return
 	}
 
-	err := vpc.Remove(db, vpcId)
+	orgs, err := auditOrgs(db, db.Vpcs(), vpcId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	err = vpc.Remove(db, vpcId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceDelete, "vpc", vpcId,
+		orgs[vpcId], nil)
+
 	event.PublishDispatch(db, "vpc.change")
 
 	c.JSON(200, nil)
@@ -202,12 +262,23 @@ func vpcsDelete(c *gin.Context) {
 		return
 	}
 
+	orgs, err := auditOrgs(db, db.Vpcs(), data...)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	err = vpc.RemoveMulti(db, data)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	for _, vpcId := range data {
+		auditChange(c, db, audit.ResourceDelete, "vpc", vpcId,
+			orgs[vpcId], nil)
+	}
+
 	event.PublishDispatch(db, "vpc.change")
 
 	c.JSON(200, nil)
@@ -277,6 +348,8 @@ func vpcRoutesPut(c *gin.Context) {
 		return
 	}
 
+	vcOrig := audit.Snapshot(vc)
+
 	vc.Routes = data
 
 	fields := set.NewSet(
@@ -300,6 +373,9 @@ func vpcRoutesPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "vpc", vc.Id,
+		vc.Organization, audit.Diff(vcOrig, vc, fields))
+
 	event.PublishDispatch(db, "vpc.change")
 
 	vc.Json()
@@ -4,6 +4,7 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/event"
@@ -17,6 +18,7 @@ type zoneData struct {
 	Name        string             `json:"name"`
 	Comment     string             `json:"comment"`
 	NetworkMode string             `json:"network_mode"`
+	Placement   string             `json:"placement"`
 }
 
 func zonePut(c *gin.Context) {
@@ -45,14 +47,18 @@ func zonePut(c *gin.Context) {
 		return
 	}
 
+	zneOrig := audit.Snapshot(zne)
+
 	zne.Name = data.Name
 	zne.Comment = data.Comment
 	zne.NetworkMode = data.NetworkMode
+	zne.Placement = data.Placement
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"network_mode",
+		"placement",
 	)
 
 	errData, err := zne.Validate(db)
@@ -72,6 +78,9 @@ func zonePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "zone", zne.Id,
+		primitive.NilObjectID, audit.Diff(zneOrig, zne, fields))
+
 	event.PublishDispatch(db, "zone.change")
 
 	c.JSON(200, zne)
@@ -98,6 +107,7 @@ func zonePost(c *gin.Context) {
 		Name:        data.Name,
 		Comment:     data.Comment,
 		NetworkMode: data.NetworkMode,
+		Placement:   data.Placement,
 	}
 
 	errData, err := zne.Validate(db)
@@ -117,6 +127,9 @@ func zonePost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "zone", zne.Id,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "zone.change")
 
 	c.JSON(200, zne)
@@ -141,6 +154,9 @@ func zoneDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "zone", zoneId,
+		primitive.NilObjectID, nil)
+
 	event.PublishDispatch(db, "zone.change")
 
 	c.JSON(200, nil)
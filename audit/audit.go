@@ -12,13 +12,23 @@ import (
 
 type Fields map[string]interface{}
 
+type Change struct {
+	Field string      `bson:"f" json:"field"`
+	Old   interface{} `bson:"o" json:"old"`
+	New   interface{} `bson:"n" json:"new"`
+}
+
 type Audit struct {
-	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	User      primitive.ObjectID `bson:"u" json:"user"`
-	Timestamp time.Time          `bson:"t" json:"timestamp"`
-	Type      string             `bson:"y" json:"type"`
-	Fields    Fields             `bson:"f" json:"fields"`
-	Agent     *agent.Agent       `bson:"a" json:"agent"`
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	User         primitive.ObjectID `bson:"u" json:"user"`
+	Organization primitive.ObjectID `bson:"o,omitempty" json:"organization"`
+	Timestamp    time.Time          `bson:"t" json:"timestamp"`
+	Type         string             `bson:"y" json:"type"`
+	Resource     string             `bson:"r,omitempty" json:"resource"`
+	ResourceId   primitive.ObjectID `bson:"i,omitempty" json:"resource_id"`
+	Changes      []*Change          `bson:"c,omitempty" json:"changes"`
+	Fields       Fields             `bson:"f" json:"fields"`
+	Agent        *agent.Agent       `bson:"a" json:"agent"`
 }
 
 func (a *Audit) Insert(db *database.Database) (err error) {
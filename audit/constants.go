@@ -41,4 +41,15 @@ const (
 	OneLoginDeny         = "one_login_deny"
 	OktaApprove          = "okta_approve"
 	OktaDeny             = "okta_deny"
+
+	ResourceCreate = "resource_create"
+	ResourceUpdate = "resource_update"
+	ResourceDelete = "resource_delete"
 )
+
+var sensitiveFields = []string{
+	"secret",
+	"password",
+	"token",
+	"key",
+}
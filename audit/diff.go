@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/sirupsen/logrus"
+)
+
+const redacted = "********"
+
+func normalize(val interface{}) interface{} {
+	switch v := val.(type) {
+	case primitive.D:
+		doc := bson.M{}
+		for _, elem := range v {
+			doc[elem.Key] = normalize(elem.Value)
+		}
+		return doc
+	case bson.M:
+		doc := bson.M{}
+		for key, elem := range v {
+			doc[key] = normalize(elem)
+		}
+		return doc
+	case primitive.A:
+		arr := []interface{}{}
+		for _, elem := range v {
+			arr = append(arr, normalize(elem))
+		}
+		return arr
+	}
+
+	return val
+}
+
+func isSensitive(field string) bool {
+	for _, name := range sensitiveFields {
+		if strings.Contains(field, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func Snapshot(obj interface{}) (snap bson.M) {
+	snap = bson.M{}
+
+	data, err := bson.Marshal(obj)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("audit: Failed to marshal snapshot")
+		return
+	}
+
+	doc := bson.M{}
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("audit: Failed to unmarshal snapshot")
+		return
+	}
+
+	snap = normalize(doc).(bson.M)
+
+	return
+}
+
+func redact(val interface{}) interface{} {
+	switch v := val.(type) {
+	case bson.M:
+		doc := bson.M{}
+		for key, elem := range v {
+			if isSensitive(key) {
+				doc[key] = redacted
+			} else {
+				doc[key] = redact(elem)
+			}
+		}
+		return doc
+	case []interface{}:
+		arr := []interface{}{}
+		for _, elem := range v {
+			arr = append(arr, redact(elem))
+		}
+		return arr
+	}
+
+	return val
+}
+
+func Diff(before bson.M, after interface{}, fields set.Set) (
+	changes []*Change) {
+
+	changes = []*Change{}
+	afterSnap := Snapshot(after)
+
+	for fieldInf := range fields.Iter() {
+		field := fieldInf.(string)
+
+		oldVal := before[field]
+		newVal := afterSnap[field]
+
+		if reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+
+		if isSensitive(field) {
+			oldVal = redacted
+			newVal = redacted
+		} else {
+			oldVal = redact(oldVal)
+			newVal = redact(newVal)
+		}
+
+		changes = append(changes, &Change{
+			Field: field,
+			Old:   oldVal,
+			New:   newVal,
+		})
+	}
+
+	return
+}
@@ -83,6 +83,58 @@ func GetAll(db *database.Database, userId primitive.ObjectID,
 	return
 }
 
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (audits []*Audit, count int64, err error) {
+
+	coll := db.Audits()
+	audits = []*Audit{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	maxPage := count / pageCount
+	if count == pageCount {
+		maxPage = 0
+	}
+	page = utils.Min64(page, maxPage)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(db, query, &options.FindOptions{
+		Sort: &bson.D{
+			{"t", -1},
+		},
+		Skip:  &skip,
+		Limit: &pageCount,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		adt := &Audit{}
+		err = cursor.Decode(adt)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		audits = append(audits, adt)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func New(db *database.Database, r *http.Request,
 	userId primitive.ObjectID, typ string, fields Fields) (
 	err error) {
@@ -111,3 +163,40 @@ func New(db *database.Database, r *http.Request,
 
 	return
 }
+
+func NewChange(db *database.Database, r *http.Request,
+	userId, orgId primitive.ObjectID, typ, resource string,
+	resourceId primitive.ObjectID, changes []*Change) (err error) {
+
+	if settings.System.Demo {
+		return
+	}
+
+	if typ == ResourceUpdate && len(changes) == 0 {
+		return
+	}
+
+	agnt, err := agent.Parse(db, r)
+	if err != nil {
+		return
+	}
+
+	adt := &Audit{
+		User:         userId,
+		Organization: orgId,
+		Timestamp:    time.Now(),
+		Type:         typ,
+		Resource:     resource,
+		ResourceId:   resourceId,
+		Changes:      changes,
+		Fields:       Fields{},
+		Agent:        agnt,
+	}
+
+	err = adt.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
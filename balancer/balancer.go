@@ -1,6 +1,7 @@
 package balancer
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
@@ -10,6 +11,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
 )
 
 type Domain struct {
@@ -50,13 +52,63 @@ type Balancer struct {
 	Backends        []*Backend           `bson:"backends" json:"backends"`
 	States          map[string]*State    `bson:"states" json:"states"`
 	CheckPath       string               `bson:"check_path" json:"check_path"`
+	ListenPort      int                  `bson:"listen_port" json:"listen_port"`
+	ProxyProtocol   bool                 `bson:"proxy_protocol" json:"proxy_protocol"`
+}
+
+func (b *Balancer) IsStream() bool {
+	return b.Type == Tcp || b.Type == Udp
+}
+
+// reservedPort checks if the listen port conflicts with the web, redirect
+// or metrics ports used by the nodes.
+func (b *Balancer) reservedPort(db *database.Database) (
+	reserved bool, err error) {
+
+	if b.ListenPort == 80 || b.ListenPort == 443 {
+		reserved = true
+		return
+	}
+
+	nodes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	for _, nde := range nodes {
+		if b.ListenPort == nde.Port || b.ListenPort == nde.MetricsPort {
+			reserved = true
+			return
+		}
+	}
+
+	return
 }
 
 func (b *Balancer) Validate(db *database.Database) (
 	errData *errortypes.ErrorData, err error) {
 
-	if b.Type == "" {
+	switch b.Type {
+	case Http:
+		b.ListenPort = 0
+		b.ProxyProtocol = false
+		break
+	case Tcp, Udp:
+		b.Certificates = []primitive.ObjectID{}
+		b.ClientAuthority = primitive.NilObjectID
+		b.WebSockets = false
+		b.Domains = []*Domain{}
+		b.CheckPath = ""
+		break
+	case "":
 		b.Type = Http
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "balancer_type_invalid",
+			Message: "Invalid balancer type",
+		}
+		return
 	}
 
 	if b.Domains == nil {
@@ -76,7 +128,9 @@ func (b *Balancer) Validate(db *database.Database) (
 	}
 
 	for _, backend := range b.Backends {
-		if backend.Protocol != "http" && backend.Protocol != "https" {
+		if b.IsStream() {
+			backend.Protocol = b.Type
+		} else if backend.Protocol != "http" && backend.Protocol != "https" {
 			errData = &errortypes.ErrorData{
 				Error:   "balancer_protocol_invalid",
 				Message: "Invalid balancer backend protocol",
@@ -118,6 +172,78 @@ func (b *Balancer) Validate(db *database.Database) (
 			return
 		}
 
+		if b.Backends == nil || len(b.Backends) == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "backend_required",
+				Message: "Missing required backend",
+			}
+			return
+		}
+
+		if b.IsStream() {
+			if b.ListenPort < 1 || b.ListenPort > 65535 {
+				errData = &errortypes.ErrorData{
+					Error:   "listen_port_invalid",
+					Message: "Invalid balancer listen port",
+				}
+				return
+			}
+
+			if b.ListenPort < settings.Router.StreamPortMin ||
+				b.ListenPort > settings.Router.StreamPortMax {
+
+				errData = &errortypes.ErrorData{
+					Error: "listen_port_range",
+					Message: fmt.Sprintf(
+						"Balancer listen port must be between %d and %d",
+						settings.Router.StreamPortMin,
+						settings.Router.StreamPortMax,
+					),
+				}
+				return
+			}
+
+			reserved, e := b.reservedPort(db)
+			if e != nil {
+				err = e
+				return
+			}
+
+			if reserved {
+				errData = &errortypes.ErrorData{
+					Error:   "listen_port_reserved",
+					Message: "Balancer listen port is reserved by node",
+				}
+				return
+			}
+
+			coll := db.Balancers()
+			count, e := coll.CountDocuments(db, &bson.M{
+				"_id": &bson.M{
+					"$ne": b.Id,
+				},
+				"state":       true,
+				"datacenter":  b.Datacenter,
+				"type":        b.Type,
+				"listen_port": b.ListenPort,
+			})
+			if e != nil {
+				err = database.ParseError(e)
+				return
+			}
+
+			if count > 0 {
+				errData = &errortypes.ErrorData{
+					Error: "listen_port_conflict",
+					Message: "Listen port conflicts with another " +
+						"load balancer in same datacenter",
+				}
+				return
+			}
+
+			return
+		}
+
 		if b.Domains == nil || len(b.Domains) == 0 {
 			errData = &errortypes.ErrorData{
 				Error:   "domain_required",
@@ -134,14 +260,6 @@ func (b *Balancer) Validate(db *database.Database) (
 			return
 		}
 
-		if b.Backends == nil || len(b.Backends) == 0 {
-			errData = &errortypes.ErrorData{
-				Error:   "backend_required",
-				Message: "Missing required backend",
-			}
-			return
-		}
-
 		domains := []string{}
 		for _, domain := range b.Domains {
 			domains = append(domains, domain.Domain)
@@ -247,7 +365,9 @@ func (b *Balancer) CommitFields(db *database.Database, fields set.Set) (
 
 	coll := db.Balancers()
 
-	if b.State && (fields.Contains("state") || fields.Contains("domains")) {
+	if b.State && !b.IsStream() &&
+		(fields.Contains("state") || fields.Contains("domains")) {
+
 		domains := []string{}
 		for _, domain := range b.Domains {
 			domains = append(domains, domain.Domain)
@@ -2,4 +2,6 @@ package balancer
 
 const (
 	Http = "http"
+	Tcp  = "tcp"
+	Udp  = "udp"
 )
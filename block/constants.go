@@ -3,6 +3,8 @@ package block
 const (
 	External = "external"
 	Host     = "host"
+	Floating = "floating"
+	Nat      = "nat"
 	IPv4     = "ipv4"
 	IPv6     = "ipv6"
 )
@@ -94,6 +94,7 @@ func Remove(db *database.Database, blockId primitive.ObjectID) (err error) {
 	ipColl := db.BlocksIp()
 	instColl := db.Instances()
 	nodeColl := db.Nodes()
+	fipColl := db.FloatingIps()
 
 	cursor, err := ipColl.Find(db, &bson.M{
 		"block": blockId,
@@ -134,6 +135,14 @@ func Remove(db *database.Database, blockId primitive.ObjectID) (err error) {
 		}
 	}
 
+	_, err = fipColl.DeleteMany(db, &bson.M{
+		"block": blockId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
 	_, err = nodeColl.UpdateMany(db, &bson.M{
 		"host_block": blockId,
 	}, &bson.M{"$set": &bson.M{
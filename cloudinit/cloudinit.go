@@ -17,6 +17,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/authority"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
@@ -39,23 +40,46 @@ const netConfigTmpl = `version: 1
 config:
   - type: physical
     name: eth0
-    mac_address: {{.Mac}}{{.Mtu}}
+    mac_address: {{.Mac}}{{if .Mtu}}
+    mtu: {{.Mtu}}{{end}}
     subnets:
       - type: static
         address: {{.Address}}
         netmask: {{.Netmask}}
         network: {{.Network}}
-        gateway: {{.Gateway}}
-        dns_nameservers:
-          - 8.8.8.8
-          - 8.8.4.4
+        gateway: {{.Gateway}}{{if .DnsServers}}
+        dns_nameservers:{{range .DnsServers}}
+          - {{.}}{{end}}{{end}}{{if .SearchDomains}}
+        dns_search:{{range .SearchDomains}}
+          - {{.}}{{end}}{{end}}
       - type: static
         address: {{.Address6}}
-        gateway: {{.Gateway6}}
+        gateway: {{.Gateway6}}{{if .DnsServers6}}
+        dns_nameservers:{{range .DnsServers6}}
+          - {{.}}{{end}}{{end}}{{if .SearchDomains}}
+        dns_search:{{range .SearchDomains}}
+          - {{.}}{{end}}{{end}}
 `
 
-const netMtu = `
-    mtu: %d`
+const netConfigV2Tmpl = `version: 2
+ethernets:
+  eth0:
+    match:
+      macaddress: {{.Mac}}
+    set-name: eth0{{if .Mtu}}
+    mtu: {{.Mtu}}{{end}}
+    addresses:
+      - {{.Address}}/{{.Cidr}}
+      - {{.Address6}}/{{.Cidr6}}
+    gateway4: {{.Gateway}}
+    gateway6: {{.Gateway6}}{{if or .DnsServers .DnsServers6 .SearchDomains}}
+    nameservers:{{if or .DnsServers .DnsServers6}}
+      addresses:{{range .DnsServers}}
+        - {{.}}{{end}}{{range .DnsServers6}}
+        - {{.}}{{end}}{{end}}{{if .SearchDomains}}
+      search:{{range .SearchDomains}}
+        - {{.}}{{end}}{{end}}{{end}}
+`
 
 const cloudConfigTmpl = `#cloud-config
 hostname: {{.Hostname}}
@@ -93,17 +117,23 @@ EOF
 var (
 	cloudConfig = template.Must(template.New("cloud").Parse(cloudConfigTmpl))
 	netConfig   = template.Must(template.New("net").Parse(netConfigTmpl))
+	netConfigV2 = template.Must(template.New("net2").Parse(netConfigV2Tmpl))
 )
 
 type netConfigData struct {
-	Mac      string
-	Mtu      string
-	Address  string
-	Netmask  string
-	Network  string
-	Gateway  string
-	Address6 string
-	Gateway6 string
+	Mac           string
+	Mtu           int
+	Address       string
+	Netmask       string
+	Network       string
+	Cidr          int
+	Gateway       string
+	Address6      string
+	Cidr6         int
+	Gateway6      string
+	DnsServers    []string
+	DnsServers6   []string
+	SearchDomains []string
 }
 
 type cloudConfigData struct {
@@ -288,17 +318,35 @@ func getNetData(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	vcNet6, err := vc.GetNetwork6()
+	if err != nil {
+		return
+	}
+
 	addr6 := vc.GetIp6(addr)
 	gatewayAddr6 := vc.GetIp6(gatewayAddr)
 
+	cidr, _ := vcNet.Mask.Size()
+	cidr6, _ := vcNet6.Mask.Size()
+	if vc.Network6 != "" {
+		// Gateway and addresses are per subnet /64 within larger networks
+		cidr6 = 64
+	}
+	dns := vc.GetDns(inst.Subnet)
+
 	data := netConfigData{
-		Mac:      adapter.MacAddress,
-		Address:  addr.String(),
-		Netmask:  net.IP(vcNet.Mask).String(),
-		Network:  vcNet.IP.String(),
-		Gateway:  gatewayAddr.String(),
-		Address6: addr6.String(),
-		Gateway6: gatewayAddr6.String(),
+		Mac:           adapter.MacAddress,
+		Address:       addr.String(),
+		Netmask:       net.IP(vcNet.Mask).String(),
+		Network:       vcNet.IP.String(),
+		Cidr:          cidr,
+		Gateway:       gatewayAddr.String(),
+		Address6:      addr6.String(),
+		Cidr6:         cidr6,
+		Gateway6:      gatewayAddr6.String(),
+		DnsServers:    dns.Servers,
+		DnsServers6:   dns.Servers6,
+		SearchDomains: dns.SearchDomains,
 	}
 
 	jumboFrames := node.Self.JumboFrames
@@ -314,11 +362,24 @@ func getNetData(db *database.Database, inst *instance.Instance,
 			mtuSize -= 54
 		}
 
-		data.Mtu = fmt.Sprintf(netMtu, mtuSize)
+		data.Mtu = mtuSize
+	}
+
+	tmpl := netConfig
+	if !inst.Image.IsZero() {
+		img, e := image.Get(db, inst.Image)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); !ok {
+				err = e
+				return
+			}
+		} else if img.NetConfig == image.NetConfigV2 {
+			tmpl = netConfigV2
+		}
 	}
 
 	output := &bytes.Buffer{}
-	err = netConfig.Execute(output, data)
+	err = tmpl.Execute(output, data)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.Wrap(err, "cloudinit: Failed to exec cloud template"),
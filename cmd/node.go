@@ -12,6 +12,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/defaults"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/metrics"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/router"
 	"github.com/pritunl/pritunl-cloud/setup"
@@ -58,6 +59,7 @@ func Node() (err error) {
 	routr.Init()
 
 	task.Init()
+	metrics.Init()
 
 	go func() {
 		err = routr.Run()
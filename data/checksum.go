@@ -0,0 +1,55 @@
+package data
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+func getChecksum(pth string) (checksum string, err error) {
+	file, err := os.Open(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to open file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to read file"),
+		}
+		return
+	}
+
+	checksum = fmt.Sprintf("%x", hash.Sum(nil))
+
+	return
+}
+
+func verifyChecksum(pth, checksum string) (err error) {
+	if checksum == "" {
+		return
+	}
+
+	fileChecksum, err := getChecksum(pth)
+	if err != nil {
+		return
+	}
+
+	if fileChecksum != checksum {
+		err = &errortypes.VerificationError{
+			errors.Newf("data: Checksum mismatch %s", fileChecksum),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,257 @@
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/ovf"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func getDiskFormat(pth string) string {
+	switch strings.ToLower(path.Ext(pth)) {
+	case ".vmdk":
+		return image.Vmdk
+	case ".vhdx":
+		return image.Vhdx
+	case ".qcow2":
+		return image.Qcow2
+	case ".img", ".raw":
+		return image.Raw
+	}
+
+	return ""
+}
+
+func qemuConvert(format, srcPth, dstPth string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil, "qemu-img",
+		"convert", "-f", format, "-O", "qcow2", srcPth, dstPth)
+	if err != nil {
+		os.Remove(dstPth)
+		return
+	}
+
+	return
+}
+
+func decompressImage(srcPth, ext, cmd string) (pth string, err error) {
+	compPth := srcPth + ext
+
+	err = utils.Exec("", "mv", srcPth, compPth)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil, cmd, "-d", compPth)
+	if err != nil {
+		os.Remove(compPth)
+		return
+	}
+
+	pth = srcPth
+
+	return
+}
+
+func extractOva(db *database.Database, img *image.Image,
+	srcPth, tmpDir string) (diskPth, format string, err error) {
+
+	err = utils.ExistsMkdir(tmpDir, 0700)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil, "tar",
+		"-xf", srcPth, "-C", tmpDir)
+	if err != nil {
+		return
+	}
+
+	files, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to read OVA directory"),
+		}
+		return
+	}
+
+	var desc *ovf.Descriptor
+	for _, file := range files {
+		if !strings.HasSuffix(strings.ToLower(file.Name()), ".ovf") {
+			continue
+		}
+
+		data, e := ioutil.ReadFile(path.Join(tmpDir, file.Name()))
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "data: Failed to read OVA descriptor"),
+			}
+			return
+		}
+
+		desc, err = ovf.Parse(data)
+		if err != nil {
+			return
+		}
+
+		break
+	}
+
+	disks := []string{}
+	if desc != nil {
+		for _, disk := range desc.Disks {
+			if getDiskFormat(disk) != "" {
+				disks = append(disks, disk)
+			}
+		}
+
+		fields := set.NewSet()
+		if img.Processors == 0 && desc.Processors > 0 {
+			img.Processors = desc.Processors
+			fields.Add("processors")
+		}
+		if img.Memory == 0 && desc.Memory > 0 {
+			img.Memory = desc.Memory
+			fields.Add("memory")
+		}
+
+		if fields.Len() > 0 {
+			e := img.CommitFields(db, fields)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"image_id": img.Id.Hex(),
+					"error":    e,
+				}).Warn("data: Failed to update image hardware hints")
+			}
+		}
+	}
+
+	if len(disks) == 0 {
+		for _, file := range files {
+			if getDiskFormat(file.Name()) != "" {
+				disks = append(disks, file.Name())
+			}
+		}
+	}
+
+	if len(disks) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("data: OVA archive does not contain a disk"),
+		}
+		return
+	}
+
+	if len(disks) > 1 {
+		logrus.WithFields(logrus.Fields{
+			"image_id": img.Id.Hex(),
+			"key":      img.Key,
+			"disk":     disks[0],
+			"disks":    len(disks),
+		}).Warn("data: Importing only first disk from OVA archive")
+	}
+
+	diskPth = path.Join(tmpDir, disks[0])
+	format = getDiskFormat(diskPth)
+
+	return
+}
+
+// convertImage converts a downloaded image to qcow2 and moves it to the
+// destination path. Source file is removed in all cases.
+func convertImage(db *database.Database, img *image.Image,
+	srcPth, dstPth string) (err error) {
+
+	format := img.Format
+	if format == "" {
+		format = image.ParseFormat(img.Key)
+	}
+
+	if format == "" || format == image.Qcow2 {
+		_, err = checkDiskFiles(format, srcPth, "")
+		if err != nil {
+			os.Remove(srcPth)
+			return
+		}
+
+		err = utils.Exec("", "mv", srcPth, dstPth)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"image_id": img.Id.Hex(),
+		"key":      img.Key,
+		"format":   format,
+	}).Info("data: Converting image to qcow2")
+
+	convPth := paths.GetImageTempPath()
+	defer os.Remove(srcPth)
+
+	extentDir := ""
+
+	switch format {
+	case image.RawGz:
+		srcPth, err = decompressImage(srcPth, ".gz", "gzip")
+		if err != nil {
+			return
+		}
+		format = image.Raw
+		break
+	case image.RawXz:
+		srcPth, err = decompressImage(srcPth, ".xz", "xz")
+		if err != nil {
+			return
+		}
+		format = image.Raw
+		break
+	case image.Ova:
+		tmpDir := paths.GetTempDir()
+		defer utils.RemoveAll(tmpDir)
+
+		srcPth, format, err = extractOva(db, img, srcPth, tmpDir)
+		if err != nil {
+			return
+		}
+		extentDir = tmpDir
+		break
+	}
+
+	switch format {
+	case image.Raw, image.Vmdk, image.Vhdx, image.Qcow2:
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("data: Unsupported image format '%s'", format),
+		}
+		return
+	}
+
+	_, err = checkDiskFiles(format, srcPth, extentDir)
+	if err != nil {
+		return
+	}
+
+	err = qemuConvert(format, srcPth, convPth)
+	if err != nil {
+		return
+	}
+
+	err = utils.Exec("", "mv", convPth, dstPth)
+	if err != nil {
+		os.Remove(convPth)
+		return
+	}
+
+	return
+}
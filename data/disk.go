@@ -15,8 +15,8 @@ func CreateDisk(db *database.Database, dsk *disk.Disk) (
 	diskPath := paths.GetDiskPath(dsk.Id)
 
 	if !dsk.Image.IsZero() {
-		backingImage, err = WriteImage(
-			db, dsk.Image, dsk.Id, dsk.Size, dsk.Backing)
+		backingImage, err = WriteImage(db, dsk.Organization,
+			dsk.Image, dsk.Id, dsk.Size, dsk.Backing)
 		if err != nil {
 			return
 		}
@@ -35,3 +35,25 @@ func CreateDisk(db *database.Database, dsk *disk.Disk) (
 
 	return
 }
+
+func CreateMigrateDisk(dsk *disk.Disk) (err error) {
+	diskPath := paths.GetDiskPath(dsk.Id)
+
+	err = utils.ExistsMkdir(paths.GetDisksPath(), 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.Exec("", "qemu-img", "create",
+		"-f", "qcow2", diskPath, fmt.Sprintf("%dG", dsk.Size))
+	if err != nil {
+		return
+	}
+
+	err = utils.Chmod(diskPath, 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/storage"
+)
+
+const exportBlockSize = 1048576
+
+// ExportImage streams the image object to the response. When the image
+// has a checksum the final block is held until the checksum is verified,
+// on a mismatch the response is left short of the content length.
+func ExportImage(db *database.Database, img *image.Image,
+	w http.ResponseWriter) (err error) {
+
+	store, err := storage.Get(db, img.Storage)
+	if err != nil {
+		return
+	}
+
+	objStore, err := getObjectStore(store)
+	if err != nil {
+		return
+	}
+
+	obj, err := objStore.Stat(img.Key)
+	if err != nil {
+		return
+	}
+
+	reader, err := objStore.Open(img.Key)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	header.Set("Content-Disposition", fmt.Sprintf(
+		"attachment; filename=\"%s\"", path.Base(img.Key)))
+	if img.Checksum != "" {
+		header.Set("X-Checksum-Sha256", img.Checksum)
+	}
+	w.WriteHeader(200)
+
+	hash := sha256.New()
+	cur := make([]byte, exportBlockSize)
+	next := make([]byte, exportBlockSize)
+	held := 0
+
+	for {
+		n, e := io.ReadFull(reader, next)
+		if n > 0 {
+			hash.Write(next[:n])
+
+			if held > 0 {
+				_, err = w.Write(cur[:held])
+				if err != nil {
+					err = &errortypes.WriteError{
+						errors.Wrap(err, "data: Failed to write export"),
+					}
+					return
+				}
+			}
+
+			cur, next = next, cur
+			held = n
+		}
+
+		if e == io.EOF || e == io.ErrUnexpectedEOF {
+			break
+		} else if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "data: Failed to read export object"),
+			}
+			return
+		}
+	}
+
+	checksum := fmt.Sprintf("%x", hash.Sum(nil))
+	if img.Checksum != "" && checksum != img.Checksum {
+		err = &errortypes.VerificationError{
+			errors.Newf("data: Export checksum mismatch %s", checksum),
+		}
+		return
+	}
+
+	if held > 0 {
+		_, err = w.Write(cur[:held])
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "data: Failed to write export"),
+			}
+			return
+		}
+	}
+
+	return
+}
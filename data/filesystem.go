@@ -0,0 +1,216 @@
+package data
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+// filesystemStore stores objects as files in a local or network mounted
+// directory. The directory must be mounted at the same path on all nodes.
+type filesystemStore struct {
+	root string
+}
+
+func (f *filesystemStore) path(key string) (pth string, err error) {
+	key = strings.TrimLeft(key, "/")
+	pth = path.Join(f.root, key)
+
+	if key == "" || !strings.HasPrefix(pth, f.root+"/") {
+		err = &errortypes.ParseError{
+			errors.Newf("data: Invalid storage key '%s'", key),
+		}
+		return
+	}
+
+	return
+}
+
+func (f *filesystemStore) info(key string, stat os.FileInfo) *objectInfo {
+	hash := md5.New()
+	hash.Write([]byte(fmt.Sprintf("%d-%d",
+		stat.Size(), stat.ModTime().UnixNano())))
+
+	return &objectInfo{
+		Key:          key,
+		Etag:         fmt.Sprintf("%x", hash.Sum(nil)),
+		Size:         stat.Size(),
+		LastModified: stat.ModTime(),
+	}
+}
+
+func (f *filesystemStore) List() (objects []*objectInfo, err error) {
+	objects = []*objectInfo{}
+
+	err = filepath.Walk(f.root, func(pth string, stat os.FileInfo,
+		e error) error {
+
+		if e != nil {
+			return e
+		}
+
+		if strings.HasPrefix(stat.Name(), ".") {
+			if stat.IsDir() && pth != f.root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !stat.Mode().IsRegular() {
+			return nil
+		}
+
+		key, e := filepath.Rel(f.root, pth)
+		if e != nil {
+			return e
+		}
+
+		objects = append(objects, f.info(filepath.ToSlash(key), stat))
+		return nil
+	})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to list storage directory"),
+		}
+		return
+	}
+
+	return
+}
+
+func (f *filesystemStore) Stat(key string) (object *objectInfo, err error) {
+	pth, err := f.path(key)
+	if err != nil {
+		return
+	}
+
+	stat, err := os.Stat(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to stat object"),
+		}
+		return
+	}
+
+	object = f.info(key, stat)
+
+	return
+}
+
+func (f *filesystemStore) Get(key, pth string) (err error) {
+	srcPth, err := f.path(key)
+	if err != nil {
+		return
+	}
+
+	err = utils.Exec("", "cp", srcPth, pth)
+	if err != nil {
+		os.Remove(pth)
+		return
+	}
+
+	return
+}
+
+func (f *filesystemStore) Open(key string) (reader io.ReadCloser, err error) {
+	pth, err := f.path(key)
+	if err != nil {
+		return
+	}
+
+	reader, err = os.Open(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to open object"),
+		}
+		return
+	}
+
+	return
+}
+
+func (f *filesystemStore) Put(key, pth, storageClass string) (err error) {
+	dstPth, err := f.path(key)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(path.Dir(dstPth), 0755)
+	if err != nil {
+		return
+	}
+
+	tmpPth := path.Join(path.Dir(dstPth), fmt.Sprintf(
+		".%s.%s", path.Base(dstPth), primitive.NewObjectID().Hex()))
+
+	err = utils.Exec("", "cp", pth, tmpPth)
+	if err != nil {
+		os.Remove(tmpPth)
+		return
+	}
+
+	err = os.Rename(tmpPth, dstPth)
+	if err != nil {
+		os.Remove(tmpPth)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write object"),
+		}
+		return
+	}
+
+	return
+}
+
+func (f *filesystemStore) Remove(key string) (err error) {
+	pth, err := f.path(key)
+	if err != nil {
+		return
+	}
+
+	err = os.Remove(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to remove object"),
+		}
+		return
+	}
+
+	return
+}
+
+func newFilesystemStore(store *storage.Storage) (
+	f *filesystemStore, err error) {
+
+	exists, err := utils.ExistsDir(store.Path)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = &errortypes.NotFoundError{
+			errors.Newf("data: Storage path '%s' not found", store.Path),
+		}
+		return
+	}
+
+	f = &filesystemStore{
+		root: path.Clean(store.Path),
+	}
+
+	return
+}
@@ -12,7 +12,6 @@ import (
 	minio "github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
-	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
 	"github.com/pritunl/pritunl-cloud/disk"
@@ -27,7 +26,6 @@ import (
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/zone"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/openpgp"
 )
 
 var (
@@ -35,8 +33,11 @@ var (
 	backingImageLock = utils.NewMultiTimeoutLock(5 * time.Minute)
 )
 
+// getImage downloads and verifies the image to the path. Existing images
+// are verified against the organization trusted signing keys. The signer
+// is returned for callers that cache the image for other organizations.
 func getImage(db *database.Database, img *image.Image,
-	pth string) (err error) {
+	orgId primitive.ObjectID, pth string) (signer string, err error) {
 
 	if imageLock.Locked(pth) {
 		logrus.WithFields(logrus.Fields{
@@ -55,6 +56,10 @@ func getImage(db *database.Database, img *image.Image,
 	}
 
 	if exists {
+		err = verifyCachedImage(db, img, orgId, pth)
+		if err != nil {
+			return
+		}
 		return
 	}
 
@@ -72,23 +77,12 @@ func getImage(db *database.Database, img *image.Image,
 		"path":       pth,
 	}).Info("data: Downloading image")
 
-	client, err := minio.New(store.Endpoint, &minio.Options{
-		Creds: credentials.NewStaticV4(
-			store.AccessKey,
-			store.SecretKey,
-			"",
-		),
-		Secure: !store.Insecure,
-	})
-	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "data: Failed to connect to storage"),
-		}
+	objStore, err := getObjectStore(store)
+	if err != nil {
 		return
 	}
 
-	err = client.FGetObject(context.Background(), store.Bucket,
-		img.Key, tmpPth, minio.GetObjectOptions{})
+	err = objStore.Get(img.Key, tmpPth)
 	if err != nil {
 		os.Remove(tmpPth)
 
@@ -98,73 +92,19 @@ func getImage(db *database.Database, img *image.Image,
 		return
 	}
 
-	if strings.Contains(store.Endpoint, "images.pritunl.com") {
-		sigPth := tmpPth + ".sig"
-		defer os.Remove(sigPth)
-
-		err = client.FGetObject(context.Background(), store.Bucket,
-			img.Key+".sig", sigPth, minio.GetObjectOptions{})
-		if err != nil {
-			os.Remove(tmpPth)
-
-			err = &errortypes.ReadError{
-				errors.Wrap(err, "data: Failed to download image signature"),
-			}
-			return
-		}
-
-		signature, e := os.Open(sigPth)
-		if e != nil {
-			os.Remove(tmpPth)
-
-			err = &errortypes.ReadError{
-				errors.Wrap(e, "data: Failed to open image signature"),
-			}
-			return
-		}
-		defer signature.Close()
-
-		tmpImg, e := os.Open(tmpPth)
-		if e != nil {
-			os.Remove(tmpPth)
-
-			err = &errortypes.ReadError{
-				errors.Wrap(e, "data: Failed to open image"),
-			}
-			return
-		}
-		defer tmpImg.Close()
-
-		keyring, e := openpgp.ReadArmoredKeyRing(
-			strings.NewReader(constants.PritunlKeyring))
-		if e != nil {
-			os.Remove(tmpPth)
-
-			err = &errortypes.ParseError{
-				errors.Wrap(e, "data: Failed to parse Pritunl keyring"),
-			}
-			return
-		}
-
-		entity, e := openpgp.CheckArmoredDetachedSignature(
-			keyring, tmpImg, signature)
-		if e != nil || entity == nil {
-			os.Remove(tmpPth)
-
-			err = &errortypes.VerificationError{
-				errors.Wrap(e, "data: Image signature verification failed"),
-			}
-			return
-		}
+	err = verifyChecksum(tmpPth, img.Checksum)
+	if err != nil {
+		os.Remove(tmpPth)
+		return
+	}
 
-		logrus.WithFields(logrus.Fields{
-			"id":         img.Id.Hex(),
-			"storage_id": store.Id.Hex(),
-			"key":        img.Key,
-		}).Info("data: Image signature successfully validated")
+	signer, err = verifyImage(db, objStore, store, img, orgId, tmpPth)
+	if err != nil {
+		os.Remove(tmpPth)
+		return
 	}
 
-	err = utils.Exec("", "mv", tmpPth, pth)
+	err = convertImage(db, img, tmpPth, pth)
 	if err != nil {
 		return
 	}
@@ -193,8 +133,9 @@ func copyBackingImage(imagePth, backingImagePth string) (err error) {
 	return
 }
 
-func WriteImage(db *database.Database, imgId, dskId primitive.ObjectID,
-	size int, backingImage bool) (backingImageName string, err error) {
+func WriteImage(db *database.Database, orgId, imgId,
+	dskId primitive.ObjectID, size int, backingImage bool) (
+	backingImageName string, err error) {
 
 	diskPath := paths.GetDiskPath(dskId)
 	diskTempPath := paths.GetDiskTempPath()
@@ -221,6 +162,11 @@ func WriteImage(db *database.Database, imgId, dskId primitive.ObjectID,
 		return
 	}
 
+	err = checkImageSigned(db, orgId, img)
+	if err != nil {
+		return
+	}
+
 	backingImagePth := path.Join(
 		backingPath,
 		fmt.Sprintf("image-%s-%s", img.Id.Hex(), img.Etag),
@@ -250,7 +196,18 @@ func WriteImage(db *database.Database, imgId, dskId primitive.ObjectID,
 		}
 
 		if !backingImageExists {
-			err = getImage(db, img, imagePth)
+			signer, e := getImage(db, img, orgId, imagePth)
+			if e != nil {
+				err = e
+				return
+			}
+
+			err = addImageSigner(imagePth, signer)
+			if err != nil {
+				return
+			}
+		} else {
+			err = verifyCachedImage(db, img, orgId, imagePth)
 			if err != nil {
 				return
 			}
@@ -330,12 +287,18 @@ func WriteImage(db *database.Database, imgId, dskId primitive.ObjectID,
 		}
 	} else {
 		if backingImage {
-			err = getImage(db, img, backingImagePth)
+			signer, e := getImage(db, img, orgId, backingImagePth)
+			if e != nil {
+				err = e
+				return
+			}
+
+			err = addImageSigner(backingImagePth, signer)
 			if err != nil {
 				return
 			}
 		} else {
-			err = getImage(db, img, diskTempPath)
+			_, err = getImage(db, img, orgId, diskTempPath)
 			if err != nil {
 				return
 			}
@@ -416,19 +379,12 @@ func DeleteImage(db *database.Database, imgId primitive.ObjectID) (err error) {
 		return
 	}
 
-	client, err := minio.New(store.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
-		Secure: !store.Insecure,
-	})
+	objStore, err := getObjectStore(store)
 	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "data: Failed to connect to storage"),
-		}
 		return
 	}
 
-	err = client.RemoveObject(context.Background(),
-		store.Bucket, img.Key, minio.RemoveObjectOptions{})
+	err = objStore.Remove(img.Key)
 	if err != nil {
 		return
 	}
@@ -471,19 +427,12 @@ func DeleteImageOrg(db *database.Database, orgId, imgId primitive.ObjectID) (
 		return
 	}
 
-	client, err := minio.New(store.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
-		Secure: !store.Insecure,
-	})
+	objStore, err := getObjectStore(store)
 	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "data: Failed to connect to storage"),
-		}
 		return
 	}
 
-	err = client.RemoveObject(context.Background(),
-		store.Bucket, img.Key, minio.RemoveObjectOptions{})
+	err = objStore.Remove(img.Key)
 	if err != nil {
 		return
 	}
@@ -510,7 +459,7 @@ func DeleteImagesOrg(db *database.Database, orgId primitive.ObjectID,
 }
 
 func CreateSnapshot(db *database.Database, dsk *disk.Disk,
-	virt *vm.VirtualMachine) (err error) {
+	virt *vm.VirtualMachine, polId primitive.ObjectID) (err error) {
 
 	dskPth := paths.GetDiskPath(dsk.Id)
 	cacheDir := node.Self.GetCachePath()
@@ -564,10 +513,16 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk,
 		Organization: dsk.Organization,
 		Type:         storage.Private,
 		Firmware:     image.Unknown,
+		Format:       image.Qcow2,
 		Storage:      store.Id,
 		Key:          fmt.Sprintf("snapshot/%s.qcow2", imgId.Hex()),
 	}
 
+	if !polId.IsZero() {
+		img.Disk = dsk.Id
+		img.Policy = polId
+	}
+
 	defer utils.Remove(tmpPath)
 
 	available := false
@@ -596,6 +551,11 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk,
 		return
 	}
 
+	img.Checksum, err = getChecksum(tmpPath)
+	if err != nil {
+		return
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"disk_id":    dsk.Id.Hex(),
 		"disk_path":  dskPth,
@@ -603,49 +563,29 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk,
 		"object_key": img.Key,
 	}).Info("data: Uploading disk snapshot")
 
-	client, err := minio.New(store.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
-		Secure: !store.Insecure,
-	})
+	objStore, err := getObjectStore(store)
 	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "data: Failed to connect to storage"),
-		}
 		return
 	}
 
-	putOpts := minio.PutObjectOptions{}
-	storageClass := storage.FormatStorageClass(dc.PrivateStorageClass)
-	if storageClass != "" {
-		putOpts.StorageClass = storageClass
-	}
-
-	_, err = client.FPutObject(context.Background(),
-		store.Bucket, img.Key, tmpPath, putOpts)
+	err = objStore.Put(img.Key, tmpPath,
+		storage.FormatStorageClass(dc.PrivateStorageClass))
 	if err != nil {
-		err = &errortypes.WriteError{
-			errors.Wrap(err, "data: Failed to write object"),
-		}
-
 		return
 	}
 
 	time.Sleep(3 * time.Second)
 
-	obj, err := client.StatObject(context.Background(),
-		store.Bucket, img.Key, minio.StatObjectOptions{})
+	obj, err := objStore.Stat(img.Key)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "data: Failed to stat object"),
-		}
 		return
 	}
 
-	img.Etag = image.GetEtag(obj)
+	img.Etag = obj.Etag
 	img.LastModified = obj.LastModified
 
 	if store.IsOracle() {
-		img.StorageClass = storage.ParseStorageClass(obj)
+		img.StorageClass = obj.StorageClass
 	} else {
 		img.StorageClass = dc.BackupStorageClass
 	}
@@ -661,7 +601,7 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk,
 }
 
 func CreateBackup(db *database.Database, dsk *disk.Disk,
-	virt *vm.VirtualMachine) (err error) {
+	virt *vm.VirtualMachine, polId primitive.ObjectID) (err error) {
 
 	dskPth := paths.GetDiskPath(dsk.Id)
 	cacheDir := node.Self.GetCachePath()
@@ -723,8 +663,10 @@ func CreateBackup(db *database.Database, dsk *disk.Disk,
 		Organization: dsk.Organization,
 		Type:         storage.Private,
 		Firmware:     image.Unknown,
+		Format:       image.Qcow2,
 		Storage:      store.Id,
 		Key:          fmt.Sprintf("backup/%s.qcow2", imgId.Hex()),
+		Policy:       polId,
 	}
 
 	defer utils.Remove(tmpPath)
@@ -755,6 +697,11 @@ func CreateBackup(db *database.Database, dsk *disk.Disk,
 		return
 	}
 
+	img.Checksum, err = getChecksum(tmpPath)
+	if err != nil {
+		return
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"disk_id":    dsk.Id.Hex(),
 		"disk_path":  dskPth,
@@ -762,49 +709,29 @@ func CreateBackup(db *database.Database, dsk *disk.Disk,
 		"object_key": img.Key,
 	}).Info("data: Uploading disk backup")
 
-	client, err := minio.New(store.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
-		Secure: !store.Insecure,
-	})
+	objStore, err := getObjectStore(store)
 	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "data: Failed to connect to storage"),
-		}
 		return
 	}
 
-	putOpts := minio.PutObjectOptions{}
-	storageClass := storage.FormatStorageClass(dc.BackupStorageClass)
-	if storageClass != "" {
-		putOpts.StorageClass = storageClass
-	}
-
-	_, err = client.FPutObject(context.Background(),
-		store.Bucket, img.Key, tmpPath, putOpts)
+	err = objStore.Put(img.Key, tmpPath,
+		storage.FormatStorageClass(dc.BackupStorageClass))
 	if err != nil {
-		err = &errortypes.WriteError{
-			errors.Wrap(err, "data: Failed to write object"),
-		}
-
 		return
 	}
 
 	time.Sleep(3 * time.Second)
 
-	obj, err := client.StatObject(context.Background(),
-		store.Bucket, img.Key, minio.StatObjectOptions{})
+	obj, err := objStore.Stat(img.Key)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "data: Failed to stat object"),
-		}
 		return
 	}
 
-	img.Etag = image.GetEtag(obj)
+	img.Etag = obj.Etag
 	img.LastModified = obj.LastModified
 
 	if store.IsOracle() {
-		img.StorageClass = storage.ParseStorageClass(obj)
+		img.StorageClass = obj.StorageClass
 	} else {
 		img.StorageClass = dc.BackupStorageClass
 	}
@@ -847,14 +774,8 @@ func RestoreBackup(db *database.Database, dsk *disk.Disk) (err error) {
 		"disk_path":  dskPth,
 	}).Info("data: Restoring disk backup")
 
-	client, err := minio.New(store.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
-		Secure: !store.Insecure,
-	})
+	objStore, err := getObjectStore(store)
 	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "data: Failed to connect to storage"),
-		}
 		return
 	}
 
@@ -863,12 +784,13 @@ func RestoreBackup(db *database.Database, dsk *disk.Disk) (err error) {
 		fmt.Sprintf("restore-%s", imgId.Hex()))
 
 	defer utils.Remove(tmpPath)
-	err = client.FGetObject(context.Background(), store.Bucket,
-		img.Key, tmpPath, minio.GetObjectOptions{})
+	err = objStore.Get(img.Key, tmpPath)
+	if err != nil {
+		return
+	}
+
+	err = verifyChecksum(tmpPath, img.Checksum)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "data: Failed to download restore image"),
-		}
 		return
 	}
 
@@ -888,6 +810,11 @@ func RestoreBackup(db *database.Database, dsk *disk.Disk) (err error) {
 func ImageAvailable(store *storage.Storage, img *image.Image) (
 	available bool, err error) {
 
+	if store.IsFilesystem() {
+		available = true
+		return
+	}
+
 	if strings.Contains(strings.ToLower(store.Endpoint), "oracle") {
 		client, e := minio.New(store.Endpoint, &minio.Options{
 			Creds: credentials.NewStaticV4(store.AccessKey,
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func getDiskInfo(format, pth string) (info *diskInfo, err error) {
+	args := []string{"info", "--output=json"}
+	if format != "" {
+		args = append(args, "-f", format)
+	}
+	args = append(args, pth)
+
+	output, err := utils.ExecOutput("", "qemu-img", args...)
+	if err != nil {
+		return
+	}
+
+	info = &diskInfo{}
+	err = json.Unmarshal([]byte(output), info)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse qemu disk info"),
+		}
+		return
+	}
+
+	return
+}
+
+func resolvePath(pth string) (resolved string, err error) {
+	resolved, err = filepath.EvalSymlinks(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to resolve disk path"),
+		}
+		return
+	}
+
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to resolve disk path"),
+		}
+		return
+	}
+
+	return
+}
+
+// checkDiskFiles inspects an untrusted disk image and returns an error if
+// it references a backing file or has extents outside of the allowed
+// directory. If dir is empty extents must reference the image itself.
+// This prevents images from reading arbitrary host files when converted
+// or attached to an instance.
+func checkDiskFiles(format, pth, dir string) (
+	info *diskInfo, err error) {
+
+	info, err = getDiskInfo(format, pth)
+	if err != nil {
+		return
+	}
+
+	err = checkDiskInfo(info, pth, dir)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func checkDiskInfo(info *diskInfo, pth, dir string) (err error) {
+	if info.BackingFilename != "" {
+		err = &errortypes.ParseError{
+			errors.New("data: Disk image references a backing file"),
+		}
+		return
+	}
+
+	if info.FormatSpecific == nil || info.FormatSpecific.Data == nil {
+		return
+	}
+
+	imgPth, err := resolvePath(pth)
+	if err != nil {
+		return
+	}
+
+	allowedDir := ""
+	if dir != "" {
+		allowedDir, err = resolvePath(dir)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, extent := range info.FormatSpecific.Data.Extents {
+		extentPth := extent.Filename
+		if !filepath.IsAbs(extentPth) {
+			extentPth = filepath.Join(filepath.Dir(pth), extentPth)
+		}
+
+		extentPth, err = resolvePath(extentPth)
+		if err != nil {
+			return
+		}
+
+		if extentPth == imgPth {
+			continue
+		}
+
+		if allowedDir == "" || !strings.HasPrefix(
+			extentPth, allowedDir+string(filepath.Separator)) {
+
+			err = &errortypes.ParseError{
+				errors.New("data: Disk image extent outside of image path"),
+			}
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,43 @@
+package data
+
+import (
+	"io"
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/storage"
+)
+
+type objectInfo struct {
+	Key          string
+	Etag         string
+	Size         int64
+	LastModified time.Time
+	StorageClass string
+}
+
+// objectStore provides the object operations shared by the storage
+// backends. Keys are slash separated paths relative to the bucket or
+// storage directory.
+type objectStore interface {
+	List() (objects []*objectInfo, err error)
+	Stat(key string) (object *objectInfo, err error)
+	Get(key, pth string) (err error)
+	Open(key string) (reader io.ReadCloser, err error)
+	Put(key, pth, storageClass string) (err error)
+	Remove(key string) (err error)
+}
+
+func getObjectStore(store *storage.Storage) (
+	objStore objectStore, err error) {
+
+	if store.IsFilesystem() {
+		objStore, err = newFilesystemStore(store)
+	} else {
+		objStore, err = newS3Store(store)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
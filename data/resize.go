@@ -14,10 +14,25 @@ import (
 )
 
 type diskInfo struct {
-	Filename    string `json:"filename"`
-	Format      string `json:"format"`
-	ActualSize  int    `json:"actual-size"`
-	VirtualSize int    `json:"virtual-size"`
+	Filename        string              `json:"filename"`
+	Format          string              `json:"format"`
+	BackingFilename string              `json:"backing-filename"`
+	ActualSize      int                 `json:"actual-size"`
+	VirtualSize     int                 `json:"virtual-size"`
+	FormatSpecific  *diskFormatSpecific `json:"format-specific"`
+}
+
+type diskFormatSpecific struct {
+	Type string                  `json:"type"`
+	Data *diskFormatSpecificData `json:"data"`
+}
+
+type diskFormatSpecificData struct {
+	Extents []*diskExtent `json:"extents"`
+}
+
+type diskExtent struct {
+	Filename string `json:"filename"`
 }
 
 func GetDiskSize(dsk *disk.Disk) (size int, err error) {
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"io"
+
+	"github.com/dropbox/godropbox/errors"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/storage"
+)
+
+type s3Store struct {
+	store  *storage.Storage
+	client *minio.Client
+}
+
+func (s *s3Store) List() (objects []*objectInfo, err error) {
+	objects = []*objectInfo{}
+
+	for object := range s.client.ListObjects(
+		context.Background(),
+		s.store.Bucket, minio.ListObjectsOptions{
+			Recursive: true,
+		},
+	) {
+		if object.Err != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(object.Err, "storage: Failed to list objects"),
+			}
+			return
+		}
+
+		objects = append(objects, &objectInfo{
+			Key:          object.Key,
+			Etag:         image.GetEtag(object),
+			Size:         object.Size,
+			LastModified: object.LastModified,
+			StorageClass: storage.ParseStorageClass(object),
+		})
+	}
+
+	return
+}
+
+func (s *s3Store) Stat(key string) (object *objectInfo, err error) {
+	obj, err := s.client.StatObject(context.Background(),
+		s.store.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to stat object"),
+		}
+		return
+	}
+
+	object = &objectInfo{
+		Key:          obj.Key,
+		Etag:         image.GetEtag(obj),
+		Size:         obj.Size,
+		LastModified: obj.LastModified,
+		StorageClass: storage.ParseStorageClass(obj),
+	}
+
+	return
+}
+
+func (s *s3Store) Get(key, pth string) (err error) {
+	err = s.client.FGetObject(context.Background(), s.store.Bucket,
+		key, pth, minio.GetObjectOptions{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to download object"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *s3Store) Open(key string) (reader io.ReadCloser, err error) {
+	reader, err = s.client.GetObject(context.Background(), s.store.Bucket,
+		key, minio.GetObjectOptions{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to get object"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *s3Store) Put(key, pth, storageClass string) (err error) {
+	putOpts := minio.PutObjectOptions{}
+	if storageClass != "" {
+		putOpts.StorageClass = storageClass
+	}
+
+	_, err = s.client.FPutObject(context.Background(),
+		s.store.Bucket, key, pth, putOpts)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write object"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *s3Store) Remove(key string) (err error) {
+	err = s.client.RemoveObject(context.Background(),
+		s.store.Bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to remove object"),
+		}
+		return
+	}
+
+	return
+}
+
+func newS3Store(store *storage.Storage) (s *s3Store, err error) {
+	client, err := minio.New(store.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
+		Secure: !store.Insecure,
+	})
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "data: Failed to connect to storage"),
+		}
+		return
+	}
+
+	s = &s3Store{
+		store:  store,
+		client: client,
+	}
+
+	return
+}
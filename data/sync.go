@@ -1,17 +1,13 @@
 package data
 
 import (
-	"context"
 	"strings"
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
-	"github.com/dropbox/godropbox/errors"
-	minio "github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/pritunl/pritunl-cloud/database"
-	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/ovf"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/sirupsen/logrus"
@@ -22,69 +18,84 @@ var (
 )
 
 func Sync(db *database.Database, store *storage.Storage) (err error) {
-	if store.Endpoint == "" {
+	if !store.IsConfigured() {
 		return
 	}
 
 	lockId := syncLock.Lock(store.Id.Hex())
 	defer syncLock.Unlock(store.Id.Hex(), lockId)
 
-	client, err := minio.New(store.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
-		Secure: !store.Insecure,
-	})
+	objStore, err := getObjectStore(store)
+	if err != nil {
+		return
+	}
+
+	ovaImages, err := image.GetStorageFormat(db, store.Id, image.Ova)
 	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "storage: Failed to connect to storage"),
-		}
 		return
 	}
 
 	images := []*image.Image{}
 	signedKeys := set.NewSet()
 	remoteKeys := set.NewSet()
-	for object := range client.ListObjects(
-		context.Background(),
-		store.Bucket, minio.ListObjectsOptions{
-			Recursive: true,
-		},
-	) {
-
-		if object.Err != nil {
-			err = &errortypes.RequestError{
-				errors.Wrap(object.Err, "storage: Failed to list objects"),
-			}
-			return
-		}
+	objects, err := objStore.List()
+	if err != nil {
+		return
+	}
 
-		if strings.HasSuffix(object.Key, ".qcow2.sig") {
-			signedKeys.Add(strings.TrimRight(object.Key, ".sig"))
-		} else if strings.HasSuffix(object.Key, ".qcow2") {
-			etag := image.GetEtag(object)
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, ".sig") ||
+			strings.HasSuffix(object.Key, ".minisig") {
+
+			sigKey := strings.TrimSuffix(
+				strings.TrimSuffix(object.Key, ".sig"), ".minisig")
+			if image.ParseFormat(sigKey) != "" {
+				signedKeys.Add(sigKey)
+			}
+		} else if format := image.ParseFormat(object.Key); format != "" {
+			etag := object.Etag
 			remoteKeys.Add(object.Key)
 
 			img := &image.Image{
 				Storage:      store.Id,
 				Key:          object.Key,
 				Firmware:     image.Unknown,
+				Format:       format,
 				Etag:         etag,
 				Type:         store.Type,
 				LastModified: object.LastModified,
 			}
 
+			// OVA descriptors are only read when the archive changes,
+			// archives without hardware hints are not read again
+			if format == image.Ova {
+				curImg := ovaImages[object.Key]
+				if curImg == nil || curImg.Etag != etag {
+
+					desc, e := getOvaDescriptor(objStore, object.Key)
+					if e != nil {
+						logrus.WithFields(logrus.Fields{
+							"bucket": store.Bucket,
+							"key":    object.Key,
+							"error":  e,
+						}).Warn("data: Failed to read OVA descriptor")
+					} else {
+						img.Processors = desc.Processors
+						img.Memory = desc.Memory
+					}
+				}
+			}
+
 			if store.IsOracle() {
-				obj, e := client.StatObject(context.Background(),
-					store.Bucket, object.Key, minio.StatObjectOptions{})
+				obj, e := objStore.Stat(object.Key)
 				if e != nil {
-					err = &errortypes.ReadError{
-						errors.Wrap(e, "storage: Failed to stat object"),
-					}
+					err = e
 					return
 				}
 
-				img.StorageClass = storage.ParseStorageClass(obj)
+				img.StorageClass = obj.StorageClass
 			} else {
-				img.StorageClass = storage.ParseStorageClass(object)
+				img.StorageClass = object.StorageClass
 			}
 
 			images = append(images, img)
@@ -140,3 +151,20 @@ func Sync(db *database.Database, store *storage.Storage) (err error) {
 
 	return
 }
+
+func getOvaDescriptor(objStore objectStore, key string) (
+	desc *ovf.Descriptor, err error) {
+
+	obj, err := objStore.Open(key)
+	if err != nil {
+		return
+	}
+	defer obj.Close()
+
+	desc, err = ovf.ReadArchive(obj)
+	if err != nil {
+		return
+	}
+
+	return
+}
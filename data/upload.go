@@ -0,0 +1,313 @@
+package data
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/upload"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	uploadLock = utils.NewMultiTimeoutLock(10 * time.Minute)
+)
+
+func getUploadPath(upldId primitive.ObjectID) string {
+	return path.Join(node.Self.GetCachePath(),
+		fmt.Sprintf("upload-%s", upldId.Hex()))
+}
+
+func writeUploadFile(pth string, offset, size int64, reader io.Reader) (
+	n int64, readErr, err error) {
+
+	file, err := os.OpenFile(pth, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to open upload file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	err = file.Truncate(offset)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to truncate upload file"),
+		}
+		return
+	}
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to seek upload file"),
+		}
+		return
+	}
+
+	n, readErr = io.Copy(file, io.LimitReader(reader, size-offset+1))
+	if offset+n > size {
+		n = 0
+		readErr = nil
+
+		err = file.Truncate(offset)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "data: Failed to truncate upload file"),
+			}
+			return
+		}
+		return
+	}
+
+	return
+}
+
+// WriteUpload writes a chunk of upload data at the offset. The offset must
+// match the current upload offset, data that was received before a failed
+// request is kept so the client can resume from the stored offset. Once
+// all data is received the upload is verified and stored as an image, an
+// empty chunk at the final offset will retry the completion.
+func WriteUpload(db *database.Database, upld *upload.Upload, offset int64,
+	reader io.Reader) (img *image.Image, errData *errortypes.ErrorData,
+	err error) {
+
+	lockId := uploadLock.Lock(upld.Id.Hex())
+	defer uploadLock.Unlock(upld.Id.Hex(), lockId)
+
+	curUpld, err := upload.Get(db, upld.Id)
+	if err != nil {
+		return
+	}
+	*upld = *curUpld
+
+	if upld.Node != node.Self.Id {
+		errData = &errortypes.ErrorData{
+			Error:   "upload_node_invalid",
+			Message: "Upload must be continued on the same node",
+		}
+		return
+	}
+
+	if offset != upld.Offset {
+		errData = &errortypes.ErrorData{
+			Error:   "upload_offset_invalid",
+			Message: "Upload offset does not match current offset",
+		}
+		return
+	}
+
+	pth := getUploadPath(upld.Id)
+
+	if offset > 0 {
+		stat, e := os.Stat(pth)
+		if e != nil || stat.Size() < offset {
+			errData = &errortypes.ErrorData{
+				Error:   "upload_data_missing",
+				Message: "Upload data is missing, upload must be restarted",
+			}
+			return
+		}
+	}
+
+	n, readErr, err := writeUploadFile(pth, offset, upld.Size, reader)
+	if err != nil {
+		return
+	}
+
+	upld.Offset = offset + n
+	upld.Timestamp = time.Now()
+
+	err = upld.CommitFields(db, set.NewSet("offset", "timestamp"))
+	if err != nil {
+		return
+	}
+
+	if readErr != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(readErr, "data: Failed to read upload data"),
+		}
+		return
+	}
+
+	if upld.Offset < upld.Size {
+		return
+	}
+
+	img, errData, err = completeUpload(db, upld, pth)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func removeUpload(db *database.Database, upld *upload.Upload, pth string) {
+	err := upload.Remove(db, upld.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"upload_id": upld.Id.Hex(),
+			"error":     err,
+		}).Error("data: Failed to remove upload")
+	}
+
+	utils.Remove(pth)
+}
+
+func completeUpload(db *database.Database, upld *upload.Upload,
+	pth string) (img *image.Image, errData *errortypes.ErrorData,
+	err error) {
+
+	checksum, err := getChecksum(pth)
+	if err != nil {
+		return
+	}
+
+	if checksum != upld.Checksum {
+		logrus.WithFields(logrus.Fields{
+			"upload_id":         upld.Id.Hex(),
+			"checksum":          upld.Checksum,
+			"received_checksum": checksum,
+		}).Warn("data: Upload checksum mismatch")
+
+		removeUpload(db, upld, pth)
+
+		errData = &errortypes.ErrorData{
+			Error:   "upload_checksum_mismatch",
+			Message: "Uploaded data does not match checksum",
+		}
+		return
+	}
+
+	info, err := getDiskInfo("", pth)
+	if err != nil {
+		return
+	}
+
+	if info.Format != upld.Format || checkDiskInfo(info, pth, "") != nil {
+		removeUpload(db, upld, pth)
+
+		errData = &errortypes.ErrorData{
+			Error:   "upload_format_mismatch",
+			Message: "Uploaded data does not match upload format",
+		}
+		return
+	}
+
+	dc, err := datacenter.Get(db, upld.Datacenter)
+	if err != nil {
+		return
+	}
+
+	if dc.PrivateStorage.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "datacenter_private_storage_missing",
+			Message: "Datacenter does not have private storage",
+		}
+		return
+	}
+
+	store, err := storage.Get(db, dc.PrivateStorage)
+	if err != nil {
+		return
+	}
+
+	imgId := primitive.NewObjectID()
+	ext := ".qcow2"
+	if upld.Format == image.Raw {
+		ext = ".img"
+	}
+
+	img = &image.Image{
+		Id:           imgId,
+		Name:         upld.Name,
+		Comment:      upld.Comment,
+		Organization: upld.Organization,
+		Type:         storage.Private,
+		Firmware:     image.Unknown,
+		Format:       upld.Format,
+		Storage:      store.Id,
+		Key:          fmt.Sprintf("upload/%s%s", imgId.Hex(), ext),
+		Checksum:     checksum,
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"upload_id":  upld.Id.Hex(),
+		"storage_id": store.Id.Hex(),
+		"object_key": img.Key,
+	}).Info("data: Storing uploaded image")
+
+	objStore, err := getObjectStore(store)
+	if err != nil {
+		return
+	}
+
+	err = objStore.Put(img.Key, pth,
+		storage.FormatStorageClass(dc.PrivateStorageClass))
+	if err != nil {
+		return
+	}
+
+	obj, err := objStore.Stat(img.Key)
+	if err != nil {
+		return
+	}
+
+	img.Etag = obj.Etag
+	img.LastModified = obj.LastModified
+
+	if store.IsOracle() {
+		img.StorageClass = obj.StorageClass
+	} else {
+		img.StorageClass = dc.PrivateStorageClass
+	}
+
+	errData, err = img.Validate(db)
+	if err != nil || errData != nil {
+		return
+	}
+
+	err = img.Insert(db)
+	if err != nil {
+		return
+	}
+
+	removeUpload(db, upld, pth)
+
+	event.PublishDispatch(db, "image.change")
+
+	return
+}
+
+func DeleteUpload(db *database.Database, upld *upload.Upload) (err error) {
+	lockId := uploadLock.Lock(upld.Id.Hex())
+	defer uploadLock.Unlock(upld.Id.Hex(), lockId)
+
+	err = upload.Remove(db, upld.Id)
+	if err != nil {
+		return
+	}
+
+	if upld.Node == node.Self.Id {
+		err = utils.Remove(getUploadPath(upld.Id))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
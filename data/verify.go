@@ -0,0 +1,342 @@
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/constants"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/organization"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/signingkey"
+	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	pritunlSigner = "pritunl"
+	signersExt    = ".signers"
+)
+
+func requireSigned(db *database.Database, orgId primitive.ObjectID) (
+	required bool, err error) {
+
+	if orgId.IsZero() {
+		return
+	}
+
+	org, err := organization.Get(db, orgId)
+	if err != nil {
+		return
+	}
+
+	required = org.RequireSignedImages
+	return
+}
+
+func checkImageSigned(db *database.Database, orgId primitive.ObjectID,
+	img *image.Image) (err error) {
+
+	required, err := requireSigned(db, orgId)
+	if err != nil {
+		return
+	}
+
+	if required && !img.Signed {
+		err = &errortypes.VerificationError{
+			errors.New("data: Organization requires signed images"),
+		}
+		return
+	}
+
+	return
+}
+
+func getSignature(objStore objectStore, img *image.Image,
+	sigPth string) (err error) {
+
+	err = objStore.Get(img.Key+".sig", sigPth)
+	if err == nil {
+		return
+	}
+
+	err = objStore.Get(img.Key+".minisig", sigPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to download image signature"),
+		}
+		return
+	}
+
+	return
+}
+
+func verifyPritunlImage(imgPth, sigPth string) (err error) {
+	signature, err := os.Open(sigPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to open image signature"),
+		}
+		return
+	}
+	defer signature.Close()
+
+	tmpImg, err := os.Open(imgPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to open image"),
+		}
+		return
+	}
+	defer tmpImg.Close()
+
+	keyring, err := openpgp.ReadArmoredKeyRing(
+		strings.NewReader(constants.PritunlKeyring))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse Pritunl keyring"),
+		}
+		return
+	}
+
+	entity, err := openpgp.CheckArmoredDetachedSignature(
+		keyring, tmpImg, signature)
+	if err != nil || entity == nil {
+		err = &errortypes.VerificationError{
+			errors.Wrap(err, "data: Image signature verification failed"),
+		}
+		return
+	}
+
+	return
+}
+
+// getVerifyKeys returns the signing keys trusted by the organization and
+// storage for the image. Official images are verified with the built in
+// keyring, skip is set when the image does not need to be verified.
+func getVerifyKeys(db *database.Database, store *storage.Storage,
+	img *image.Image, orgId primitive.ObjectID) (official bool,
+	keys []*signingkey.SigningKey, skip bool, err error) {
+
+	required, err := requireSigned(db, orgId)
+	if err != nil {
+		return
+	}
+
+	if required && !img.Signed {
+		err = &errortypes.VerificationError{
+			errors.New("data: Organization requires signed images"),
+		}
+		return
+	}
+
+	official = strings.Contains(store.Endpoint, "images.pritunl.com")
+	if official {
+		return
+	}
+
+	if !img.Signed {
+		skip = true
+		return
+	}
+
+	keys, err = signingkey.GetTrusted(db, orgId, store.Id)
+	if err != nil {
+		return
+	}
+
+	if len(keys) == 0 && !required {
+		logrus.WithFields(logrus.Fields{
+			"image_id":   img.Id.Hex(),
+			"storage_id": store.Id.Hex(),
+			"key":        img.Key,
+		}).Warn("data: No trusted signing keys for signed image")
+		skip = true
+		return
+	}
+
+	return
+}
+
+// verifyImage verifies the signature of a downloaded image. Official images
+// are verified with the built in keyring, other signed images are verified
+// with the signing keys trusted by the organization and storage. Signed
+// images without trusted keys are only rejected if the organization
+// requires signed images. The returned signer is empty if the image was
+// not verified.
+func verifyImage(db *database.Database, objStore objectStore,
+	store *storage.Storage, img *image.Image, orgId primitive.ObjectID,
+	imgPth string) (signer string, err error) {
+
+	official, keys, skip, err := getVerifyKeys(db, store, img, orgId)
+	if err != nil || skip {
+		return
+	}
+
+	sigPth := imgPth + ".sig"
+	defer os.Remove(sigPth)
+
+	err = getSignature(objStore, img, sigPth)
+	if err != nil {
+		return
+	}
+
+	if official {
+		err = verifyPritunlImage(imgPth, sigPth)
+		if err != nil {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"id":         img.Id.Hex(),
+			"storage_id": store.Id.Hex(),
+			"key":        img.Key,
+		}).Info("data: Image signature successfully validated")
+
+		signer = pritunlSigner
+		return
+	}
+
+	signKey, err := signingkey.Verify(keys, imgPth, sigPth)
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":             img.Id.Hex(),
+		"storage_id":     store.Id.Hex(),
+		"key":            img.Key,
+		"signing_key_id": signKey.Id.Hex(),
+		"fingerprint":    signKey.Fingerprint,
+	}).Info("data: Image signature successfully validated")
+
+	signer = signKey.Id.Hex()
+	return
+}
+
+func getSignersPath(imgPth string) string {
+	return imgPth + signersExt
+}
+
+func getImageSigners(imgPth string) (signers set.Set) {
+	signers = set.NewSet()
+
+	data, err := ioutil.ReadFile(getSignersPath(imgPth))
+	if err != nil {
+		return
+	}
+
+	for _, signer := range strings.Split(string(data), "\n") {
+		signer = strings.TrimSpace(signer)
+		if signer != "" {
+			signers.Add(signer)
+		}
+	}
+
+	return
+}
+
+// addImageSigner records the signer that verified a cached image so later
+// requests from other organizations can be checked against their own
+// trusted keys without downloading the image again.
+func addImageSigner(imgPth, signer string) (err error) {
+	if signer == "" {
+		return
+	}
+
+	signers := getImageSigners(imgPth)
+	if signers.Contains(signer) {
+		return
+	}
+	signers.Add(signer)
+
+	data := ""
+	for signerInf := range signers.Iter() {
+		data += signerInf.(string) + "\n"
+	}
+
+	err = utils.CreateWrite(getSignersPath(imgPth), data, 0644)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// verifyCachedImage verifies a cached image for the requesting organization.
+// The signers recorded when the image was cached are checked against the
+// organization trusted keys. If none are trusted the original image is
+// downloaded and verified again with the organization keys.
+func verifyCachedImage(db *database.Database, img *image.Image,
+	orgId primitive.ObjectID, imgPth string) (err error) {
+
+	store, err := storage.Get(db, img.Storage)
+	if err != nil {
+		return
+	}
+
+	official, keys, skip, err := getVerifyKeys(db, store, img, orgId)
+	if err != nil || skip {
+		return
+	}
+
+	signers := getImageSigners(imgPth)
+	if official {
+		if signers.Contains(pritunlSigner) {
+			return
+		}
+	} else {
+		for _, key := range keys {
+			if signers.Contains(key.Id.Hex()) {
+				return
+			}
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"image_id":   img.Id.Hex(),
+		"storage_id": store.Id.Hex(),
+		"key":        img.Key,
+	}).Info("data: Verifying cached image signature")
+
+	objStore, err := getObjectStore(store)
+	if err != nil {
+		return
+	}
+
+	tmpPth := paths.GetImageTempPath()
+	defer os.Remove(tmpPth)
+
+	err = objStore.Get(img.Key, tmpPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to download image"),
+		}
+		return
+	}
+
+	err = verifyChecksum(tmpPth, img.Checksum)
+	if err != nil {
+		return
+	}
+
+	signer, err := verifyImage(db, objStore, store, img, orgId, tmpPth)
+	if err != nil {
+		return
+	}
+
+	err = addImageSigner(imgPth, signer)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -168,6 +168,11 @@ func (d *Database) Images() (coll *Collection) {
 	return
 }
 
+func (d *Database) ImageUploads() (coll *Collection) {
+	coll = d.getCollection("image_uploads")
+	return
+}
+
 func (d *Database) Datacenters() (coll *Collection) {
 	coll = d.getCollection("datacenters")
 	return
@@ -208,6 +213,31 @@ func (d *Database) Firewalls() (coll *Collection) {
 	return
 }
 
+func (d *Database) FloatingIps() (coll *Collection) {
+	coll = d.getCollection("floating_ips")
+	return
+}
+
+func (d *Database) Usages() (coll *Collection) {
+	coll = d.getCollection("usages")
+	return
+}
+
+func (d *Database) SigningKeys() (coll *Collection) {
+	coll = d.getCollection("signing_keys")
+	return
+}
+
+func (d *Database) Templates() (coll *Collection) {
+	coll = d.getCollection("templates")
+	return
+}
+
+func (d *Database) SnapshotPolicies() (coll *Collection) {
+	coll = d.getCollection("snapshot_policies")
+	return
+}
+
 func (d *Database) Vpcs() (coll *Collection) {
 	coll = d.getCollection("vpcs")
 	return
@@ -218,6 +248,11 @@ func (d *Database) VpcsIp() (coll *Collection) {
 	return
 }
 
+func (d *Database) VpcPeerings() (coll *Collection) {
+	coll = d.getCollection("vpc_peerings")
+	return
+}
+
 func (d *Database) Authorities() (coll *Collection) {
 	coll = d.getCollection("authorities")
 	return
@@ -441,6 +476,31 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.Audits(),
+		Keys: &bson.D{
+			{"o", 1},
+			{"t", -1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Audits(),
+		Keys: &bson.D{
+			{"r", 1},
+			{"i", 1},
+			{"t", -1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Policies(),
 		Keys: &bson.D{
@@ -609,6 +669,38 @@ func addIndexes() (err error) {
 	if err != nil {
 		return
 	}
+	index = &Index{
+		Collection: db.Images(),
+		Keys: &bson.D{
+			{"policy", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.ImageUploads(),
+		Keys: &bson.D{
+			{"organization", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.ImageUploads(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 24 * time.Hour,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
 
 	index = &Index{
 		Collection: db.Disks(),
@@ -809,6 +901,57 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.VpcPeerings(),
+		Keys: &bson.D{
+			{"organization", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.VpcPeerings(),
+		Keys: &bson.D{
+			{"peer_organization", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.VpcPeerings(),
+		Keys: &bson.D{
+			{"vpc", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.VpcPeerings(),
+		Keys: &bson.D{
+			{"peer_vpc", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.VpcPeerings(),
+		Keys: &bson.D{
+			{"datacenter", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Sessions(),
 		Keys: &bson.D{
@@ -820,6 +963,62 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.FloatingIps(),
+		Keys: &bson.D{
+			{"organization", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.FloatingIps(),
+		Keys: &bson.D{
+			{"zone", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.FloatingIps(),
+		Keys: &bson.D{
+			{"instance", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Usages(),
+		Keys: &bson.D{
+			{"resource", 1},
+			{"type", 1},
+			{"period", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.Usages(),
+		Keys: &bson.D{
+			{"organization", 1},
+			{"period", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Firewalls(),
 		Keys: &bson.D{
@@ -863,6 +1062,41 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.SigningKeys(),
+		Keys: &bson.D{
+			{"organization", 1},
+			{"storage", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Templates(),
+		Keys: &bson.D{
+			{"organization", 1},
+			{"name", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SnapshotPolicies(),
+		Keys: &bson.D{
+			{"organization", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Zones(),
 		Keys: &bson.D{
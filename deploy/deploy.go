@@ -46,6 +46,12 @@ func Deploy(stat *state.State) (err error) {
 		return
 	}
 
+	natGateways := NewNatGateways(stat)
+	err = natGateways.Deploy()
+	if err != nil {
+		return
+	}
+
 	domains := NewDomains(stat)
 	err = domains.Deploy()
 	if err != nil {
@@ -1,16 +1,21 @@
 package deploy
 
 import (
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/quota"
 	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/snapshot"
 	"github.com/pritunl/pritunl-cloud/state"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
@@ -18,8 +23,10 @@ import (
 )
 
 var (
-	disksLock     = utils.NewMultiTimeoutLock(5 * time.Minute)
-	backupLimiter = utils.NewLimiter(3)
+	disksLock      = utils.NewMultiTimeoutLock(5 * time.Minute)
+	backupLimiter  = utils.NewLimiter(3)
+	policyRuns     = map[string]time.Time{}
+	policyRunsLock = sync.Mutex{}
 )
 
 type Disks struct {
@@ -79,7 +86,8 @@ func (d *Disks) snapshot(dsk *disk.Disk) {
 		}
 
 		virt := d.stat.GetVirt(dsk.Instance)
-		err := data.CreateSnapshot(db, dsk, virt)
+		err := data.CreateSnapshot(db, dsk, virt,
+			primitive.NilObjectID)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -193,7 +201,8 @@ func (d *Disks) backup(dsk *disk.Disk) {
 		}
 
 		virt := d.stat.GetVirt(dsk.Instance)
-		err := data.CreateBackup(db, dsk, virt)
+		err := data.CreateBackup(db, dsk, virt,
+			primitive.NilObjectID)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -392,7 +401,8 @@ func (d *Disks) scheduleBackup(dsk *disk.Disk) {
 		event.PublishDispatch(db, "disk.change")
 
 		virt := d.stat.GetVirt(dsk.Instance)
-		err = data.CreateBackup(db, dsk, virt)
+		err = data.CreateBackup(db, dsk, virt,
+			primitive.NilObjectID)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -414,8 +424,164 @@ func (d *Disks) scheduleBackup(dsk *disk.Disk) {
 	}()
 }
 
+func (d *Disks) schedulePolicy(dsk *disk.Disk, pol *snapshot.Policy,
+	now time.Time) {
+
+	runKey := dsk.Id.Hex() + "-" + pol.Id.Hex()
+	runTime := now.Truncate(time.Minute)
+
+	policyRunsLock.Lock()
+	lastRun := policyRuns[runKey]
+	policyRunsLock.Unlock()
+
+	if !lastRun.Before(runTime) {
+		return
+	}
+
+	if !backupLimiter.Acquire() {
+		return
+	}
+
+	acquired, lockId := disksLock.LockOpen(dsk.Id.Hex())
+	if !acquired {
+		backupLimiter.Release()
+		return
+	}
+
+	policyRunsLock.Lock()
+	policyRuns[runKey] = runTime
+	policyRunsLock.Unlock()
+
+	go func() {
+		defer func() {
+			time.Sleep(1 * time.Second)
+			disksLock.Unlock(dsk.Id.Hex(), lockId)
+			backupLimiter.Release()
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		if constants.Interrupt {
+			return
+		}
+
+		if dsk.State != disk.Available {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"disk_id":   dsk.Id.Hex(),
+			"policy_id": pol.Id.Hex(),
+			"type":      pol.Type,
+		}).Info("deploy: Running scheduled disk snapshot policy")
+
+		resvId, errData, err := quota.Reserve(
+			db, dsk.Organization, &quota.Usage{
+				Snapshots: 1,
+			})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to reserve snapshot quota")
+			return
+		}
+		if errData != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id":   dsk.Id.Hex(),
+				"policy_id": pol.Id.Hex(),
+				"error":     errData.Message,
+			}).Warn("deploy: Skipping scheduled disk snapshot policy")
+			return
+		}
+		defer func() {
+			e := quota.Release(db, dsk.Organization, resvId)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": e,
+				}).Error("deploy: Failed to release snapshot quota")
+			}
+		}()
+
+		if pol.Type == snapshot.Backup {
+			dsk.State = disk.Backup
+		} else {
+			dsk.State = disk.Snapshot
+		}
+		err = dsk.CommitFields(db, set.NewSet("state"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed update disk state")
+			time.Sleep(5 * time.Second)
+			return
+		}
+
+		event.PublishDispatch(db, "disk.change")
+
+		virt := d.stat.GetVirt(dsk.Instance)
+		if pol.Type == snapshot.Backup {
+			err = data.CreateBackup(db, dsk, virt, pol.Id)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to backup disk")
+			}
+		} else {
+			err = data.CreateSnapshot(db, dsk, virt, pol.Id)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to snapshot disk")
+			}
+		}
+
+		dsk.State = disk.Available
+		err = dsk.CommitFields(db, set.NewSet("state"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed update disk state")
+			time.Sleep(5 * time.Second)
+			return
+		}
+
+		event.PublishDispatch(db, "disk.change")
+		event.PublishDispatch(db, "image.change")
+	}()
+}
+
+func (d *Disks) cleanPolicyRuns(disks []*disk.Disk,
+	pols []*snapshot.Policy) {
+
+	diskIds := set.NewSet()
+	for _, dsk := range disks {
+		diskIds.Add(dsk.Id.Hex())
+	}
+
+	polIds := set.NewSet()
+	for _, pol := range pols {
+		polIds.Add(pol.Id.Hex())
+	}
+
+	policyRunsLock.Lock()
+	for runKey := range policyRuns {
+		keys := strings.SplitN(runKey, "-", 2)
+		if len(keys) != 2 || !diskIds.Contains(keys[0]) ||
+			!polIds.Contains(keys[1]) {
+
+			delete(policyRuns, runKey)
+		}
+	}
+	policyRunsLock.Unlock()
+}
+
 func (d *Disks) Deploy() (err error) {
 	disks := d.stat.Disks()
+	pols := d.stat.SnapshotPolicies()
+	now := time.Now()
+
+	d.cleanPolicyRuns(disks, pols)
 
 	backupHour := settings.System.DiskBackupTime
 	backupWindow := settings.System.DiskBackupWindow
@@ -449,6 +615,14 @@ func (d *Disks) Deploy() (err error) {
 			if backupActive && dsk.Backup {
 				d.scheduleBackup(dsk)
 			}
+
+			inst := d.stat.GetInstace(dsk.Instance)
+			for _, pol := range pols {
+				if pol.Scheduled(now) && pol.Match(dsk, inst) {
+					d.schedulePolicy(dsk, pol, now)
+					break
+				}
+			}
 			break
 		}
 	}
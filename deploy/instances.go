@@ -1,12 +1,15 @@
 package deploy
 
 import (
+	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/errortypes"
@@ -18,8 +21,10 @@ import (
 	"github.com/pritunl/pritunl-cloud/qemu"
 	"github.com/pritunl/pritunl-cloud/qmp"
 	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/state"
 	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/tc"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
@@ -346,6 +351,230 @@ func (s *Instances) destroy(inst *instance.Instance) {
 	}()
 }
 
+func (s *Instances) migrate(inst *instance.Instance) {
+	if !limiter.Acquire() {
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpenTimeout(
+		inst.Id.Hex(), time.Duration(
+			settings.Hypervisor.MigrateTimeout+300)*time.Second)
+	if !acquired {
+		limiter.Release()
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+			limiter.Release()
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		nde, err := node.Get(db, inst.MigrateNode)
+		if err == nil {
+			err = qemu.Migrate(db, inst, inst.Virt, nde)
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to migrate instance")
+
+			inst.MigrateState = instance.MigrateFailed
+			err = inst.CommitFields(db, set.NewSet("migrate_state"))
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to commit instance")
+			}
+
+			event.PublishDispatch(db, "instance.change")
+
+			return
+		}
+
+		updated, err := instance.SetMigrateComplete(db, inst.Id, nde.Id)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		if !updated {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+			}).Warn("deploy: Instance migration canceled, resuming")
+
+			err = qmp.MigrateResume(inst.Id)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"error":       err,
+				}).Error("deploy: Failed to resume instance")
+			}
+
+			return
+		}
+
+		err = disk.SetInstanceNode(db, inst.Id, nde.Id)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to update instance disks")
+			return
+		}
+
+		err = qemu.MigrateClean(db, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to cleanup migrated instance")
+		}
+
+		event.PublishDispatch(db, "instance.change")
+		event.PublishDispatch(db, "disk.change")
+	}()
+}
+
+func (s *Instances) migrateIncoming(inst *instance.Instance) {
+	if !limiter.Acquire() {
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpenTimeout(
+		inst.Id.Hex(), 10*time.Minute)
+	if !acquired {
+		limiter.Release()
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+			limiter.Release()
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		inst.GenerateMigratePort()
+
+		err := qemu.MigrateIncoming(db, inst, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to prepare instance migration")
+
+			inst.MigrateState = instance.MigrateFailed
+		} else {
+			inst.MigrateState = instance.MigrateReady
+		}
+
+		err = inst.CommitFields(db,
+			set.NewSet("migrate_state", "migrate_port"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Instances) migrateAbort(inst *instance.Instance) {
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := qemu.MigrateAbort(db, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to abort instance migration")
+			return
+		}
+
+		inst.MigrateNode = primitive.NilObjectID
+		inst.MigrateState = ""
+		inst.MigratePort = 0
+		err = inst.CommitFields(db, set.NewSet(
+			"migrate_node", "migrate_state", "migrate_port"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Instances) migrateFinish(inst *instance.Instance,
+	virt *vm.VirtualMachine) {
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		if virt != nil && virt.State == vm.Running {
+			err := qemu.MigrateFinish(db, inst, inst.Virt)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"error":       err,
+				}).Error("deploy: Failed to finish instance migration")
+				return
+			}
+		}
+
+		inst.MigrateNode = primitive.NilObjectID
+		inst.MigrateState = ""
+		inst.MigratePort = 0
+		err := inst.CommitFields(db, set.NewSet(
+			"migrate_node", "migrate_state", "migrate_port"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
 func (s *Instances) diskAdd(inst *instance.Instance,
 	virt *vm.VirtualMachine, addDisks vm.SortDisks) {
 
@@ -394,6 +623,81 @@ func (s *Instances) diskAdd(inst *instance.Instance,
 	}()
 }
 
+func (s *Instances) diskLimits(inst *instance.Instance,
+	virt *vm.VirtualMachine, limitDisks []*vm.Disk) {
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		err := qemu.SetDiskLimits(virt, limitDisks)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("sync: Failed to set disk limits")
+			return
+		}
+	}()
+}
+
+func (s *Instances) cpuAffinity(inst *instance.Instance,
+	virt *vm.VirtualMachine) {
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		err := qemu.UpdateCpuAffinity(virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("sync: Failed to update cpu affinity")
+			return
+		}
+	}()
+}
+
+func (s *Instances) bandwidth(inst *instance.Instance,
+	ingress, egress int) {
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		err := tc.SetLimits(vm.GetNamespace(inst.Id, 0),
+			vm.GetIface(inst.Id, 0), ingress, egress)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("sync: Failed to set network bandwidth")
+			return
+		}
+
+		store.SetBandwidth(inst.Id, ingress, egress)
+	}()
+}
+
 func (s *Instances) diskRemove(inst *instance.Instance,
 	virt *vm.VirtualMachine, remDisks vm.SortDisks) {
 
@@ -523,6 +827,8 @@ func (s *Instances) diff(db *database.Database,
 	curVirt := s.stat.GetVirt(inst.Id)
 	changed := inst.Changed(curVirt)
 	addDisks, remDisks := inst.DiskChanged(curVirt)
+	limitDisks := inst.DiskLimitsChanged(curVirt)
+	affinityChanged := qemu.CpuAffinityChanged(curVirt)
 	addUsbs, remUsbs := inst.UsbChanged(curVirt)
 
 	if instancesLock.Locked(inst.Id.Hex()) {
@@ -551,6 +857,27 @@ func (s *Instances) diff(db *database.Database,
 		s.diskAdd(inst, curVirt, addDisks)
 	}
 
+	if len(limitDisks) > 0 {
+		s.diskLimits(inst, curVirt, limitDisks)
+	}
+
+	if affinityChanged {
+		s.cpuAffinity(inst, curVirt)
+	}
+
+	if curVirt != nil && curVirt.State == vm.Running {
+		vc := s.stat.Vpc(inst.Vpc)
+		if vc != nil {
+			ingress, egress := vc.GetBandwidth(
+				inst.BandwidthIngress, inst.BandwidthEgress)
+
+			cur, ok := store.GetBandwidth(inst.Id)
+			if !ok || cur.Ingress != ingress || cur.Egress != egress {
+				s.bandwidth(inst, ingress, egress)
+			}
+		}
+	}
+
 	if len(remUsbs) > 0 {
 		s.usbRemove(inst, curVirt, remUsbs)
 	}
@@ -579,6 +906,208 @@ func (s *Instances) check(inst *instance.Instance, namespaces set.Set) (
 	return
 }
 
+func (s *Instances) floatingIp(inst *instance.Instance) {
+	fip := s.stat.FloatingIp(inst.Id)
+	if fip != nil {
+		attached := false
+		for _, blckAttch := range node.Self.Blocks {
+			if blckAttch.Block == fip.Block {
+				attached = true
+				break
+			}
+		}
+
+		if node.Self.NetworkMode != node.Static || inst.NoPublicAddress ||
+			!attached {
+
+			logrus.WithFields(logrus.Fields{
+				"instance_id":    inst.Id.Hex(),
+				"floating_ip_id": fip.Id.Hex(),
+				"address":        fip.Address,
+			}).Error("deploy: Instance cannot use attached floating ip")
+			return
+		}
+	} else if node.Self.NetworkMode != node.Static || inst.NoPublicAddress {
+		return
+	}
+
+	if len(inst.PublicIps) == 0 {
+		return
+	}
+
+	curAddr := inst.PublicIps[0]
+
+	if fip != nil {
+		if fip.Address == curAddr {
+			return
+		}
+	} else if !s.stat.IsFloatingAddr(curAddr) {
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := qemu.NetworkConfExternal(db, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to update instance floating ip")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Instances) peers(inst *instance.Instance, vc *vpc.Vpc,
+	namespace string) (err error) {
+
+	var curIfaces []string
+
+	peersStore, ok := store.GetPeers(inst.Id)
+	if !ok {
+		curIfaces, err = qemu.GetPeers(inst.Id)
+		if err != nil {
+			return
+		}
+
+		if curIfaces == nil {
+			return
+		}
+
+		store.SetPeers(inst.Id, curIfaces)
+	} else {
+		curIfaces = peersStore.Ifaces
+	}
+
+	curPeers := set.NewSet()
+	for _, iface := range curIfaces {
+		curPeers.Add(iface)
+	}
+
+	newPeers := set.NewSet()
+	peerVpcs := map[string]*vpc.Vpc{}
+	for _, peerVc := range s.stat.VpcPeers(vc.Id) {
+		iface := vm.GetIfacePeer(inst.Id, fmt.Sprintf("%s%d%s%s",
+			peerVc.Id.Hex(), peerVc.VpcId, peerVc.Network, peerVc.Network6))
+
+		newPeers.Add(iface)
+		peerVpcs[iface] = peerVc
+	}
+
+	changed := false
+	addPeers := newPeers.Copy()
+	remPeers := curPeers.Copy()
+
+	addPeers.Subtract(curPeers)
+	remPeers.Subtract(newPeers)
+
+	for ifaceInf := range remPeers.Iter() {
+		iface := ifaceInf.(string)
+		changed = true
+
+		utils.ExecCombinedOutputLogged(
+			[]string{
+				"Cannot find device",
+			},
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"del", iface,
+		)
+	}
+
+	for ifaceInf := range addPeers.Iter() {
+		iface := ifaceInf.(string)
+		peerVc := peerVpcs[iface]
+		changed = true
+
+		peerNetwork6, e := peerVc.GetNetwork6()
+		if e != nil {
+			err = e
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"File exists",
+			},
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"add", "link", vm.GetIfaceInternal(inst.Id, 0),
+			"name", iface,
+			"type", "vlan",
+			"id", strconv.Itoa(peerVc.VpcId),
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", iface, "up",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"File exists",
+			},
+			"ip", "netns", "exec", namespace,
+			"ip", "route",
+			"add", peerVc.Network,
+			"dev", iface,
+			"metric", "96",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"File exists",
+			},
+			"ip", "netns", "exec", namespace,
+			"ip", "-6", "route",
+			"add", peerNetwork6.String(),
+			"dev", iface,
+			"metric", "96",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	if changed {
+		store.RemPeers(inst.Id)
+	}
+
+	return
+}
+
+func getRouteMetric(route vpc.Route) string {
+	if route.Destination == "0.0.0.0/0" {
+		return "95"
+	}
+	return "97"
+}
+
 func (s *Instances) routes(inst *instance.Instance) (err error) {
 	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
 	if !acquired {
@@ -603,6 +1132,14 @@ func (s *Instances) routes(inst *instance.Instance) (err error) {
 
 		namespace := vm.GetNamespace(inst.Id, 0)
 
+		err = s.peers(inst, vc, namespace)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to deploy instance peers")
+			err = nil
+		}
+
 		curRoutes := set.NewSet()
 		curRoutes6 := set.NewSet()
 		newRoutes := set.NewSet()
@@ -649,6 +1186,17 @@ func (s *Instances) routes(inst *instance.Instance) (err error) {
 			}
 		}
 
+		if vc.NatGateway != nil && vc.NatGateway.Address != "" &&
+			(inst.NoPublicAddress ||
+				node.Self.NetworkMode == node.Internal) &&
+			(node.Self.HostBlock.IsZero() || inst.NoHostAddress) {
+
+			newRoutes.Add(vpc.Route{
+				Destination: "0.0.0.0/0",
+				Target:      vc.NatGateway.Address,
+			})
+		}
+
 		changed := false
 		addRoutes := newRoutes.Copy()
 		addRoutes6 := newRoutes6.Copy()
@@ -670,7 +1218,7 @@ func (s *Instances) routes(inst *instance.Instance) (err error) {
 				"ip", "route",
 				"del", route.Destination,
 				"via", route.Target,
-				"metric", "97",
+				"metric", getRouteMetric(route),
 			)
 		}
 
@@ -700,7 +1248,7 @@ func (s *Instances) routes(inst *instance.Instance) (err error) {
 				"ip", "route",
 				"add", route.Destination,
 				"via", route.Target,
-				"metric", "97",
+				"metric", getRouteMetric(route),
 			)
 		}
 
@@ -772,6 +1320,11 @@ func (s *Instances) Deploy() (err error) {
 		cpuUnits += inst.Processors
 		memoryUnits += float64(inst.Memory) / float64(1024)
 
+		if inst.MigrateState == instance.MigrateComplete {
+			s.migrateFinish(inst, curVirt)
+			continue
+		}
+
 		if curVirt == nil {
 			if inst.State == instance.Start {
 				s.create(inst)
@@ -795,6 +1348,13 @@ func (s *Instances) Deploy() (err error) {
 				continue
 			}
 
+			if inst.IsMigrating() {
+				if inst.MigrateState == instance.MigrateReady {
+					s.migrate(inst)
+				}
+				continue
+			}
+
 			valid, e := s.check(inst, namespacesSet)
 			if e != nil {
 				err = e
@@ -809,6 +1369,8 @@ func (s *Instances) Deploy() (err error) {
 				return
 			}
 
+			s.floatingIp(inst)
+
 			err = s.routes(inst)
 			if err != nil {
 				return
@@ -841,6 +1403,26 @@ func (s *Instances) Deploy() (err error) {
 		}
 	}
 
+	for _, inst := range s.stat.Migrations() {
+		if inst.Node == node.Self.Id {
+			continue
+		}
+
+		cpuUnits += inst.Processors
+		memoryUnits += float64(inst.Memory) / float64(1024)
+
+		switch inst.MigrateState {
+		case instance.MigratePending:
+			if s.stat.GetVirt(inst.Id) == nil {
+				s.migrateIncoming(inst)
+			}
+			break
+		case instance.MigrateFailed:
+			s.migrateAbort(inst)
+			break
+		}
+	}
+
 	node.Self.CpuUnitsRes = cpuUnits
 	node.Self.MemoryUnitsRes = memoryUnits
 
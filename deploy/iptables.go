@@ -19,9 +19,10 @@ func (t *Iptables) Deploy() (err error) {
 	namespaces := t.stat.Namespaces()
 	nodeFirewall := t.stat.NodeFirewall()
 	firewalls := t.stat.Firewalls()
+	firewallsEgress := t.stat.FirewallsEgress()
 
 	iptables.UpdateStateRecover(nodeSelf, instaces, namespaces,
-		nodeFirewall, firewalls)
+		nodeFirewall, firewalls, firewallsEgress)
 
 	return
 }
@@ -0,0 +1,856 @@
+package deploy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/interfaces"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	natGatewaysLock     = utils.NewMultiTimeoutLock(3 * time.Minute)
+	natGatewaysConf     = map[string]string{}
+	natGatewaysConfLock = sync.Mutex{}
+	natGatewaysBeat     = map[primitive.ObjectID]time.Time{}
+)
+
+type NatGateways struct {
+	stat *state.State
+}
+
+func getNatTransfer(vpcId int) (hostAddr, spaceAddr net.IP) {
+	base := utils.IpAddress2Int(net.ParseIP("169.254.0.0")) +
+		int64(vpcId*4)
+
+	hostAddr = utils.Int2IpAddress(base + 1)
+	spaceAddr = utils.Int2IpAddress(base + 2)
+
+	return
+}
+
+func getNatComment(namespace string) string {
+	return fmt.Sprintf("pritunl_cloud_nat_%s", namespace)
+}
+
+func getNatConf(vc *vpc.Vpc) string {
+	ngw := vc.NatGateway
+
+	return fmt.Sprintf("%d:%s:%s:%s:%s:%d", vc.VpcId, vc.Network,
+		ngw.Address, ngw.Block.Hex(), ngw.PublicAddress, ngw.ConntrackMax)
+}
+
+func (g *NatGateways) eligible(ngw *vpc.NatGateway) bool {
+	nde := g.stat.Node()
+
+	if !nde.IsHypervisor() || ngw.Zone != nde.Zone ||
+		len(nde.InternalInterfaces) == 0 {
+
+		return false
+	}
+
+	if ngw.Block.IsZero() {
+		return true
+	}
+
+	if ngw.PublicAddress == "" || nde.NetworkMode != node.Static {
+		return false
+	}
+
+	for _, blckAttch := range nde.Blocks {
+		if blckAttch.Block == ngw.Block {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *NatGateways) link(physIface, systemIface, spaceIface,
+	namespace, mtu string) (err error) {
+
+	physBridge, err := utils.IsInterfaceBridge(physIface)
+	if err != nil {
+		return
+	}
+
+	if physBridge {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"add", systemIface,
+			"type", "veth",
+			"peer", "name", spaceIface,
+		)
+		if err != nil {
+			return
+		}
+
+		if mtu != "" {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "link",
+				"set", "dev", systemIface,
+				"mtu", mtu,
+			)
+			if err != nil {
+				return
+			}
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"set", "dev", systemIface, "up",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"set", systemIface,
+			"master", physIface,
+		)
+		if err != nil {
+			return
+		}
+	} else {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"add", spaceIface,
+			"link", physIface,
+			"type", "macvlan",
+			"mode", "bridge",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	if mtu != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"set", "dev", spaceIface,
+			"mtu", mtu,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", spaceIface,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", spaceIface, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *NatGateways) external(db *database.Database, vc *vpc.Vpc,
+	namespace string) (spaceExternalIface string, err error) {
+
+	ngw := vc.NatGateway
+	nde := g.stat.Node()
+	spaceExternalIface = vm.GetIfaceNat(vc.Id, 3)
+
+	if ngw.Block.IsZero() {
+		systemExternalIface := vm.GetIfaceNat(vc.Id, 4)
+		hostAddr, spaceAddr := getNatTransfer(vc.VpcId)
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"add", systemExternalIface,
+			"type", "veth",
+			"peer", "name", spaceExternalIface,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "addr",
+			"add", hostAddr.String()+"/30",
+			"dev", systemExternalIface,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"set", "dev", systemExternalIface, "up",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "link",
+			"set", "dev", spaceExternalIface,
+			"netns", namespace,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", spaceExternalIface, "up",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "netns", "exec", namespace,
+			"ip", "addr",
+			"add", spaceAddr.String()+"/30",
+			"dev", spaceExternalIface,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "netns", "exec", namespace,
+			"ip", "route",
+			"add", "default",
+			"via", hostAddr.String(),
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"sysctl", "-w", "net.ipv4.ip_forward=1",
+		)
+		if err != nil {
+			return
+		}
+
+		hostRule := []string{
+			"POSTROUTING",
+			"-s", spaceAddr.String() + "/32",
+			"-m", "comment",
+			"--comment", getNatComment(namespace),
+			"-j", "MASQUERADE",
+		}
+
+		_, e := utils.ExecCombinedOutput(
+			"", "iptables",
+			append([]string{"-t", "nat", "-C"}, hostRule...)...,
+		)
+		if e != nil {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"iptables",
+				append([]string{"-t", "nat", "-A"}, hostRule...)...,
+			)
+			if err != nil {
+				return
+			}
+		}
+
+		spaceRule := []string{
+			"POSTROUTING",
+			"-s", vc.Network,
+			"-o", spaceExternalIface,
+			"-j", "MASQUERADE",
+		}
+
+		_, e = utils.ExecCombinedOutput(
+			"", "ip",
+			append([]string{
+				"netns", "exec", namespace,
+				"iptables", "-t", "nat", "-C",
+			}, spaceRule...)...,
+		)
+		if e != nil {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip",
+				append([]string{
+					"netns", "exec", namespace,
+					"iptables", "-t", "nat", "-A",
+				}, spaceRule...)...,
+			)
+			if err != nil {
+				return
+			}
+		}
+
+		return
+	}
+
+	blckIface := ""
+	for _, blckAttch := range nde.Blocks {
+		if blckAttch.Block == ngw.Block {
+			blckIface = blckAttch.Interface
+			break
+		}
+	}
+
+	if blckIface == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("deploy: Nat gateway block not attached to node"),
+		}
+		return
+	}
+
+	blck, err := block.Get(db, ngw.Block)
+	if err != nil {
+		return
+	}
+
+	cidr, _ := blck.GetMask().Size()
+
+	err = g.link(blckIface, vm.GetIfaceNat(vc.Id, 4),
+		spaceExternalIface, namespace, "")
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"add", fmt.Sprintf("%s/%d", ngw.PublicAddress, cidr),
+		"dev", spaceExternalIface,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "route",
+		"add", "default",
+		"via", blck.Gateway,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-t", "nat",
+		"-A", "POSTROUTING",
+		"-s", vc.Network,
+		"-o", spaceExternalIface,
+		"-j", "SNAT",
+		"--to-source", ngw.PublicAddress,
+	)
+	if err != nil {
+		return
+	}
+
+	_, _ = utils.ExecCombinedOutput(
+		"",
+		"ip", "netns", "exec", namespace,
+		"arping", "-U", "-c", "2",
+		"-I", spaceExternalIface,
+		ngw.PublicAddress,
+	)
+
+	return
+}
+
+func (g *NatGateways) create(db *database.Database, vc *vpc.Vpc,
+	namespace string) (err error) {
+
+	ngw := vc.NatGateway
+	nde := g.stat.Node()
+
+	network, err := vc.GetNetwork()
+	if err != nil {
+		return
+	}
+	cidr, _ := network.Mask.Size()
+
+	mtu := ""
+	if nde.JumboFrames || g.stat.VxLan() {
+		mtuSize := 0
+		if nde.JumboFrames {
+			mtuSize = settings.Hypervisor.JumboMtu
+		} else {
+			mtuSize = settings.Hypervisor.NormalMtu
+		}
+
+		if g.stat.VxLan() {
+			mtuSize -= 50
+		}
+
+		mtu = strconv.Itoa(mtuSize)
+	}
+
+	systemInternalIface := vm.GetIfaceNat(vc.Id, 0)
+	spaceInternalIface := vm.GetIfaceNat(vc.Id, 1)
+	spaceVlanIface := vm.GetIfaceNat(vc.Id, 2)
+
+	physicalInternalIface := interfaces.GetInternal(
+		systemInternalIface, g.stat.VxLan())
+	if physicalInternalIface == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("deploy: Nat gateway internal interface not found"),
+		}
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "add", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"sysctl", "-w", "net.ipv4.ip_forward=1",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", "lo", "up",
+	)
+	if err != nil {
+		return
+	}
+
+	err = g.link(physicalInternalIface, systemInternalIface,
+		spaceInternalIface, namespace, mtu)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"add", "link", spaceInternalIface,
+		"name", spaceVlanIface,
+		"type", "vlan",
+		"id", strconv.Itoa(vc.VpcId),
+	)
+	if err != nil {
+		return
+	}
+
+	if mtu != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", spaceVlanIface,
+			"mtu", mtu,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", spaceVlanIface, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"add", fmt.Sprintf("%s/%d", ngw.Address, cidr),
+		"dev", spaceVlanIface,
+	)
+	if err != nil {
+		return
+	}
+
+	spaceExternalIface, err := g.external(db, vc, namespace)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-A", "FORWARD",
+		"-i", spaceExternalIface,
+		"-m", "conntrack",
+		"--ctstate", "RELATED,ESTABLISHED",
+		"-j", "ACCEPT",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-A", "FORWARD",
+		"-i", spaceExternalIface,
+		"-j", "DROP",
+	)
+	if err != nil {
+		return
+	}
+
+	if ngw.ConntrackMax > 0 {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"iptables",
+			"-A", "FORWARD",
+			"-i", spaceVlanIface,
+			"-m", "conntrack",
+			"--ctstate", "NEW",
+			"-m", "connlimit",
+			"--connlimit-above", strconv.Itoa(ngw.ConntrackMax),
+			"--connlimit-mask", "0",
+			"-j", "REJECT",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, _ = utils.ExecCombinedOutput(
+		"",
+		"ip", "netns", "exec", namespace,
+		"arping", "-U", "-c", "2",
+		"-I", spaceVlanIface,
+		ngw.Address,
+	)
+
+	return
+}
+
+func (g *NatGateways) remove(namespace string) (err error) {
+	output, err := utils.ExecOutput("", "iptables", "-t", "nat", "-S")
+	if err != nil {
+		return
+	}
+
+	comment := getNatComment(namespace)
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, comment) {
+			continue
+		}
+
+		args := strings.Fields(line)
+		if len(args) < 2 || args[0] != "-A" {
+			continue
+		}
+		args[0] = "-D"
+
+		for i, arg := range args {
+			args[i] = strings.Trim(arg, "\"")
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"matching rule exist",
+			},
+			"iptables",
+			append([]string{"-t", "nat"}, args...)...,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"No such file",
+		},
+		"ip", "netns", "del", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	natGatewaysConfLock.Lock()
+	delete(natGatewaysConf, namespace)
+	natGatewaysConfLock.Unlock()
+
+	return
+}
+
+func (g *NatGateways) deploy(vc *vpc.Vpc, namespace, conf string) {
+	acquired, lockId := natGatewaysLock.LockOpen(namespace)
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			natGatewaysLock.Unlock(namespace, lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := g.remove(namespace)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"vpc_id":    vc.Id.Hex(),
+				"namespace": namespace,
+				"error":     err,
+			}).Error("deploy: Failed to remove nat gateway")
+			return
+		}
+
+		err = g.create(db, vc, namespace)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"vpc_id":    vc.Id.Hex(),
+				"namespace": namespace,
+				"error":     err,
+			}).Error("deploy: Failed to deploy nat gateway")
+
+			_ = g.remove(namespace)
+			return
+		}
+
+		natGatewaysConfLock.Lock()
+		natGatewaysConf[namespace] = conf
+		natGatewaysConfLock.Unlock()
+
+		logrus.WithFields(logrus.Fields{
+			"vpc_id":    vc.Id.Hex(),
+			"namespace": namespace,
+		}).Info("deploy: Deployed nat gateway")
+	}()
+}
+
+func (g *NatGateways) destroy(namespace string) {
+	acquired, lockId := natGatewaysLock.LockOpen(namespace)
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			natGatewaysLock.Unlock(namespace, lockId)
+		}()
+
+		err := g.remove(namespace)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"namespace": namespace,
+				"error":     err,
+			}).Error("deploy: Failed to remove nat gateway")
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"namespace": namespace,
+		}).Info("deploy: Removed nat gateway")
+	}()
+}
+
+func (g *NatGateways) Deploy() (err error) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	nde := g.stat.Node()
+	ttl := time.Duration(settings.Hypervisor.NatGatewayTtl) * time.Second
+
+	namespaces := set.NewSet()
+	for _, namespace := range g.stat.Namespaces() {
+		namespaces.Add(namespace)
+	}
+
+	curNamespaces := set.NewSet()
+	beats := map[primitive.ObjectID]time.Time{}
+	for _, vc := range g.stat.Vpcs() {
+		ngw := vc.NatGateway
+		if ngw == nil || ngw.Address == "" || !g.eligible(ngw) {
+			continue
+		}
+
+		lastBeat := natGatewaysBeat[vc.Id]
+		if vc.NatNode == nde.Id && vc.NatTimestamp.After(lastBeat) {
+			lastBeat = vc.NatTimestamp
+		}
+
+		active := vc.NatNode == nde.Id
+		if active {
+			if time.Since(lastBeat) > ttl/3 {
+				active, err = vpc.HeartbeatNatGateway(db, vc.Id, nde.Id)
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"vpc_id": vc.Id.Hex(),
+						"error":  err,
+					}).Error("deploy: Failed to heartbeat nat gateway")
+					err = nil
+
+					// Keep the gateway until the lease expires, another
+					// node may claim the gateway after the ttl
+					active = time.Since(lastBeat) <= ttl
+					if active {
+						beats[vc.Id] = lastBeat
+						curNamespaces.Add(vm.GetNamespaceNat(vc.Id))
+					}
+					continue
+				}
+
+				lastBeat = time.Now()
+			}
+		} else if vc.NatNode.IsZero() || time.Since(vc.NatTimestamp) > ttl {
+			active, err = vpc.ClaimNatGateway(
+				db, vc.Id, nde.Id, vc.NatNode, vc.NatTimestamp)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"vpc_id": vc.Id.Hex(),
+					"error":  err,
+				}).Error("deploy: Failed to claim nat gateway")
+				err = nil
+				continue
+			}
+
+			if active {
+				lastBeat = time.Now()
+			}
+
+			if active {
+				logrus.WithFields(logrus.Fields{
+					"vpc_id":        vc.Id.Hex(),
+					"prev_node_id":  vc.NatNode.Hex(),
+					"nat_timestamp": vc.NatTimestamp,
+				}).Info("deploy: Claimed nat gateway")
+			}
+		}
+
+		if !active {
+			continue
+		}
+		beats[vc.Id] = lastBeat
+
+		namespace := vm.GetNamespaceNat(vc.Id)
+		curNamespaces.Add(namespace)
+
+		if natGatewaysLock.Locked(namespace) {
+			continue
+		}
+
+		conf := getNatConf(vc)
+
+		natGatewaysConfLock.Lock()
+		curConf := natGatewaysConf[namespace]
+		natGatewaysConfLock.Unlock()
+
+		if curConf == conf && namespaces.Contains(namespace) {
+			continue
+		}
+
+		g.deploy(vc, namespace, conf)
+	}
+
+	for _, namespace := range g.stat.Namespaces() {
+		if len(namespace) != 14 || !strings.HasPrefix(namespace, "g") {
+			continue
+		}
+
+		if curNamespaces.Contains(namespace) ||
+			natGatewaysLock.Locked(namespace) {
+
+			continue
+		}
+
+		g.destroy(namespace)
+	}
+
+	natGatewaysBeat = beats
+
+	return
+}
+
+// ExpireNatGateways removes the local nat gateways that have not confirmed
+// a heartbeat within the ttl. Used when the state cannot be loaded and the
+// nat gateways are not deployed.
+func ExpireNatGateways() {
+	ttl := time.Duration(settings.Hypervisor.NatGatewayTtl) * time.Second
+	g := &NatGateways{}
+
+	for vcId, lastBeat := range natGatewaysBeat {
+		if time.Since(lastBeat) <= ttl {
+			continue
+		}
+		delete(natGatewaysBeat, vcId)
+
+		namespace := vm.GetNamespaceNat(vcId)
+
+		logrus.WithFields(logrus.Fields{
+			"vpc_id":    vcId.Hex(),
+			"namespace": namespace,
+		}).Warn("deploy: Nat gateway heartbeat expired")
+
+		g.destroy(namespace)
+	}
+}
+
+func NewNatGateways(stat *state.State) *NatGateways {
+	return &NatGateways{
+		stat: stat,
+	}
+}
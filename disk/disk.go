@@ -12,7 +12,9 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/quota"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/sirupsen/logrus"
 )
 
@@ -35,8 +37,13 @@ type Disk struct {
 	NewSize          int                `bson:"new_size" json:"new_size"`
 	Backup           bool               `bson:"backup" json:"backup"`
 	LastBackup       time.Time          `bson:"last_backup" json:"last_backup"`
+	Limits           vm.DiskLimits      `bson:"limits" json:"limits"`
+	NoLimits         bool               `bson:"no_limits" json:"no_limits"`
+	orgLimits        vm.DiskLimits      `bson:"-" json:"-"`
 	curIndex         string             `bson:"-" json:"-"`
 	curInstance      primitive.ObjectID `bson:"-" json:"-"`
+	curState         string             `bson:"-" json:"-"`
+	quotaReservation primitive.ObjectID `bson:"-" json:"-"`
 }
 
 func (d *Disk) Validate(db *database.Database) (
@@ -153,12 +160,81 @@ func (d *Disk) Validate(db *database.Database) (
 		return
 	}
 
+	errData = d.Limits.Validate()
+	if errData != nil {
+		return
+	}
+
+	errData, err = d.validateQuota(db)
+	if err != nil || errData != nil {
+		return
+	}
+
+	return
+}
+
+func (d *Disk) validateQuota(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	requested := &quota.Usage{}
+
+	if d.Id.IsZero() {
+		requested.Disk = d.Size
+	} else if d.State != d.curState {
+		switch d.State {
+		case Expand:
+			requested.Disk = d.NewSize - d.Size
+			break
+		case Snapshot, Backup:
+			requested.Snapshots = 1
+			break
+		}
+	}
+
+	d.releaseQuota(db)
+
+	d.quotaReservation, errData, err = quota.Reserve(
+		db, d.Organization, requested)
+	if err != nil || errData != nil {
+		return
+	}
+
 	return
 }
 
+func (d *Disk) releaseQuota(db *database.Database) {
+	if d.quotaReservation.IsZero() {
+		return
+	}
+
+	err := quota.Release(db, d.Organization, d.quotaReservation)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"disk_id": d.Id.Hex(),
+			"error":   err,
+		}).Error("disk: Failed to release quota reservation")
+	}
+
+	d.quotaReservation = primitive.NilObjectID
+}
+
+// GetLimits returns the effective disk limits. Disk limits override the
+// organization default limits loaded with ResolveLimits, disks marked with
+// NoLimits are never limited.
+func (d *Disk) GetLimits() vm.DiskLimits {
+	if d.NoLimits {
+		return vm.DiskLimits{}
+	}
+	if !d.Limits.IsZero() {
+		return d.Limits
+	}
+	return d.orgLimits
+}
+
 func (d *Disk) PreCommit() {
 	d.curIndex = d.Index
 	d.curInstance = d.Instance
+	d.curState = d.State
 }
 
 func (d *Disk) Commit(db *database.Database) (err error) {
@@ -169,6 +245,8 @@ func (d *Disk) Commit(db *database.Database) (err error) {
 		return
 	}
 
+	d.releaseQuota(db)
+
 	return
 }
 
@@ -182,6 +260,8 @@ func (d *Disk) CommitFields(db *database.Database, fields set.Set) (
 		return
 	}
 
+	d.releaseQuota(db)
+
 	return
 }
 
@@ -194,6 +274,8 @@ func (d *Disk) Insert(db *database.Database) (err error) {
 		return
 	}
 
+	d.releaseQuota(db)
+
 	return
 }
 
@@ -9,8 +9,14 @@ import (
 	"github.com/pritunl/mongo-go-driver/mongo/options"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
+type orgDiskLimits struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	DiskLimits vm.DiskLimits      `bson:"disk_limits"`
+}
+
 func Get(db *database.Database, diskId primitive.ObjectID) (
 	dsk *Disk, err error) {
 
@@ -473,3 +479,92 @@ func SetDeleteProtection(db *database.Database, instId primitive.ObjectID,
 
 	return
 }
+
+func SetInstanceNode(db *database.Database, instId,
+	ndeId primitive.ObjectID) (err error) {
+
+	coll := db.Disks()
+
+	_, err = coll.UpdateMany(db, &bson.M{
+		"instance": instId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"node":          ndeId,
+			"backing_image": "",
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// ResolveLimits loads the organization default limits for disks without
+// disk limits. Defaults are resolved when the disks are deployed so changes
+// to the organization defaults apply to existing disks.
+func ResolveLimits(db *database.Database, disks []*Disk) (err error) {
+	orgIds := []primitive.ObjectID{}
+	orgIdsSet := set.NewSet()
+	for _, dsk := range disks {
+		if dsk.NoLimits || !dsk.Limits.IsZero() ||
+			dsk.Organization.IsZero() ||
+			orgIdsSet.Contains(dsk.Organization) {
+
+			continue
+		}
+
+		orgIdsSet.Add(dsk.Organization)
+		orgIds = append(orgIds, dsk.Organization)
+	}
+
+	if len(orgIds) == 0 {
+		return
+	}
+
+	coll := db.Organizations()
+	orgLimits := map[primitive.ObjectID]vm.DiskLimits{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"_id": &bson.M{
+				"$in": orgIds,
+			},
+		},
+		&options.FindOptions{
+			Projection: &bson.D{
+				{"disk_limits", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		org := &orgDiskLimits{}
+		err = cursor.Decode(org)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		orgLimits[org.Id] = org.DiskLimits
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	for _, dsk := range disks {
+		dsk.orgLimits = orgLimits[dsk.Organization]
+	}
+
+	return
+}
@@ -246,3 +246,25 @@ func AwsUpsertDomain(domain *Domain, name, addr, addr6 string) (err error) {
 
 	return
 }
+
+type route53Provider struct {
+	domain *Domain
+}
+
+func (p *route53Provider) Upsert(name, addr, addr6 string) (err error) {
+	err = AwsUpsertDomain(p.domain, name, addr, addr6)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *route53Provider) Remove(name string) (err error) {
+	err = AwsUpsertDomain(p.domain, name, "", "")
+	if err != nil {
+		return
+	}
+
+	return
+}
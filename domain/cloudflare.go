@@ -0,0 +1,232 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+const cloudflareApi = "https://api.cloudflare.com/client/v4"
+
+var (
+	cloudflareClient = &http.Client{
+		Timeout: 20 * time.Second,
+	}
+)
+
+type cloudflareError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type cloudflareZone struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type cloudflareRecord struct {
+	Id      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Ttl     int    `json:"ttl"`
+}
+
+type cloudflareZonesResp struct {
+	Success bool               `json:"success"`
+	Errors  []*cloudflareError `json:"errors"`
+	Result  []*cloudflareZone  `json:"result"`
+}
+
+type cloudflareRecordsResp struct {
+	Success bool                `json:"success"`
+	Errors  []*cloudflareError  `json:"errors"`
+	Result  []*cloudflareRecord `json:"result"`
+}
+
+type cloudflareResp struct {
+	Success bool               `json:"success"`
+	Errors  []*cloudflareError `json:"errors"`
+}
+
+type cloudflareProvider struct {
+	domain *Domain
+}
+
+func (p *cloudflareProvider) request(method, path string,
+	input, output interface{}) (err error) {
+
+	var body io.Reader
+	if input != nil {
+		data, e := json.Marshal(input)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "domain: Failed to marshal Cloudflare request"),
+			}
+			return
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest(method, cloudflareApi+path, body)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Failed to create Cloudflare request"),
+		}
+		return
+	}
+
+	req.Header.Set("Authorization", "Bearer "+p.domain.CfToken)
+	req.Header.Set("Accept", "application/json")
+	if input != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := cloudflareClient.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Cloudflare request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "domain: Failed to read Cloudflare response"),
+		}
+		return
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = &errortypes.RequestError{
+			errors.Newf("domain: Cloudflare server error %d - %s",
+				resp.StatusCode, string(data)),
+		}
+		return
+	}
+
+	if output != nil {
+		err = json.Unmarshal(data, output)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "domain: Failed to parse Cloudflare response"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) getZone() (zoneId string, err error) {
+	resp := &cloudflareZonesResp{}
+
+	err = p.request("GET", "/zones?name="+url.QueryEscape(p.domain.Name),
+		nil, resp)
+	if err != nil {
+		return
+	}
+
+	for _, zone := range resp.Result {
+		if zone.Name == p.domain.Name {
+			zoneId = zone.Id
+			break
+		}
+	}
+
+	if zoneId == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("domain: Failed to find Cloudflare zone"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) update(zoneId, recordType, recordName,
+	addr string) (err error) {
+
+	resp := &cloudflareRecordsResp{}
+
+	err = p.request("GET", fmt.Sprintf(
+		"/zones/%s/dns_records?type=%s&name=%s",
+		zoneId, recordType, url.QueryEscape(recordName),
+	), nil, resp)
+	if err != nil {
+		return
+	}
+
+	matched := false
+	for _, record := range resp.Result {
+		if !matched && addr != "" && record.Content == addr {
+			matched = true
+			continue
+		}
+
+		err = p.request("DELETE", fmt.Sprintf(
+			"/zones/%s/dns_records/%s", zoneId, record.Id,
+		), nil, &cloudflareResp{})
+		if err != nil {
+			return
+		}
+	}
+
+	if addr == "" || matched {
+		return
+	}
+
+	err = p.request("POST", fmt.Sprintf(
+		"/zones/%s/dns_records", zoneId,
+	), &cloudflareRecord{
+		Type:    recordType,
+		Name:    recordName,
+		Content: addr,
+		Ttl:     60,
+	}, &cloudflareResp{})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) Upsert(name, addr, addr6 string) (err error) {
+	zoneId, err := p.getZone()
+	if err != nil {
+		return
+	}
+
+	recordName := name + "." + p.domain.Name
+
+	err = p.update(zoneId, "A", recordName, addr)
+	if err != nil {
+		return
+	}
+
+	err = p.update(zoneId, "AAAA", recordName, addr6)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) Remove(name string) (err error) {
+	err = p.Upsert(name, "", "")
+	if err != nil {
+		return
+	}
+
+	return
+}
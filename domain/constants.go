@@ -1,5 +1,12 @@
 package domain
 
 const (
-	Route53 = "route_53"
+	Route53    = "route_53"
+	Cloudflare = "cloudflare"
+	Rfc2136    = "rfc2136"
+	PowerDns   = "power_dns"
+
+	HmacSha1   = "hmac-sha1"
+	HmacSha256 = "hmac-sha256"
+	HmacSha512 = "hmac-sha512"
 )
@@ -1,6 +1,10 @@
 package domain
 
 import (
+	"encoding/base64"
+	"net"
+	"strings"
+
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -16,6 +20,14 @@ type Domain struct {
 	Type         string             `bson:"type" json:"type"`
 	AwsId        string             `bson:"aws_id" json:"aws_id"`
 	AwsSecret    string             `bson:"aws_secret" json:"aws_secret"`
+	CfToken      string             `bson:"cf_token" json:"cf_token"`
+	DnsServer    string             `bson:"dns_server" json:"dns_server"`
+	TsigName     string             `bson:"tsig_name" json:"tsig_name"`
+	TsigAlgo     string             `bson:"tsig_algo" json:"tsig_algo"`
+	TsigSecret   string             `bson:"tsig_secret" json:"tsig_secret"`
+	PdnsUrl      string             `bson:"pdns_url" json:"pdns_url"`
+	PdnsServer   string             `bson:"pdns_server" json:"pdns_server"`
+	PdnsApiKey   string             `bson:"pdns_api_key" json:"pdns_api_key"`
 }
 
 func (d *Domain) Validate(db *database.Database) (
@@ -29,8 +41,87 @@ func (d *Domain) Validate(db *database.Database) (
 		return
 	}
 
-	if d.Type != Route53 {
+	switch d.Type {
+	case Route53:
+		break
+	case Cloudflare:
+		if d.CfToken == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "cf_token_required",
+				Message: "Missing required Cloudflare API token",
+			}
+			return
+		}
+		break
+	case Rfc2136:
+		if d.DnsServer == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "dns_server_required",
+				Message: "Missing required DNS server",
+			}
+			return
+		}
+
+		dnsHost := strings.Trim(d.DnsServer, "[]")
+		if net.ParseIP(dnsHost) != nil ||
+			!strings.Contains(d.DnsServer, ":") {
+
+			d.DnsServer = net.JoinHostPort(dnsHost, "53")
+		}
+
+		_, _, e := net.SplitHostPort(d.DnsServer)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "dns_server_invalid",
+				Message: "DNS server address is invalid",
+			}
+			return
+		}
+
+		if d.TsigName != "" {
+			switch d.TsigAlgo {
+			case HmacSha1, HmacSha256, HmacSha512:
+				break
+			case "":
+				d.TsigAlgo = HmacSha256
+				break
+			default:
+				errData = &errortypes.ErrorData{
+					Error:   "tsig_algo_invalid",
+					Message: "TSIG algorithm is invalid",
+				}
+				return
+			}
+
+			_, e = base64.StdEncoding.DecodeString(d.TsigSecret)
+			if e != nil || d.TsigSecret == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "tsig_secret_invalid",
+					Message: "TSIG secret must be base64 encoded",
+				}
+				return
+			}
+		} else {
+			d.TsigAlgo = ""
+			d.TsigSecret = ""
+		}
+		break
+	case PowerDns:
+		if d.PdnsUrl == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "pdns_url_required",
+				Message: "Missing required PowerDNS API URL",
+			}
+			return
+		}
+
+		if d.PdnsServer == "" {
+			d.PdnsServer = "localhost"
+		}
+		break
+	default:
 		d.Type = Route53
+		break
 	}
 
 	return
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+var (
+	powerDnsClient = &http.Client{
+		Timeout: 20 * time.Second,
+	}
+)
+
+type powerDnsRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type powerDnsRrset struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Ttl        int               `json:"ttl,omitempty"`
+	ChangeType string            `json:"changetype"`
+	Records    []*powerDnsRecord `json:"records"`
+}
+
+type powerDnsPatch struct {
+	Rrsets []*powerDnsRrset `json:"rrsets"`
+}
+
+type powerDnsProvider struct {
+	domain *Domain
+}
+
+func (p *powerDnsProvider) getRrset(recordType, recordName,
+	addr string) (rrset *powerDnsRrset) {
+
+	if addr == "" {
+		rrset = &powerDnsRrset{
+			Name:       recordName,
+			Type:       recordType,
+			ChangeType: "DELETE",
+			Records:    []*powerDnsRecord{},
+		}
+	} else {
+		rrset = &powerDnsRrset{
+			Name:       recordName,
+			Type:       recordType,
+			Ttl:        60,
+			ChangeType: "REPLACE",
+			Records: []*powerDnsRecord{
+				&powerDnsRecord{
+					Content: addr,
+				},
+			},
+		}
+	}
+
+	return
+}
+
+func (p *powerDnsProvider) Upsert(name, addr, addr6 string) (err error) {
+	zoneName := strings.TrimRight(p.domain.Name, ".") + "."
+	recordName := name + "." + zoneName
+
+	patch := &powerDnsPatch{
+		Rrsets: []*powerDnsRrset{
+			p.getRrset("A", recordName, addr),
+			p.getRrset("AAAA", recordName, addr6),
+		},
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "domain: Failed to marshal PowerDNS request"),
+		}
+		return
+	}
+
+	reqUrl := fmt.Sprintf(
+		"%s/api/v1/servers/%s/zones/%s",
+		strings.TrimRight(p.domain.PdnsUrl, "/"),
+		url.PathEscape(p.domain.PdnsServer),
+		url.PathEscape(zoneName),
+	)
+
+	req, err := http.NewRequest("PATCH", reqUrl, bytes.NewBuffer(data))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Failed to create PowerDNS request"),
+		}
+		return
+	}
+
+	req.Header.Set("X-API-Key", p.domain.PdnsApiKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := powerDnsClient.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: PowerDNS request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		err = &errortypes.RequestError{
+			errors.Newf("domain: PowerDNS server error %d - %s",
+				resp.StatusCode, string(body)),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *powerDnsProvider) Remove(name string) (err error) {
+	err = p.Upsert(name, "", "")
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type Provider interface {
+	Upsert(name, addr, addr6 string) (err error)
+	Remove(name string) (err error)
+}
+
+func GetProvider(domn *Domain) (prov Provider, err error) {
+	switch domn.Type {
+	case Route53:
+		prov = &route53Provider{
+			domain: domn,
+		}
+		break
+	case Cloudflare:
+		prov = &cloudflareProvider{
+			domain: domn,
+		}
+		break
+	case Rfc2136:
+		prov = &rfc2136Provider{
+			domain: domn,
+		}
+		break
+	case PowerDns:
+		prov = &powerDnsProvider{
+			domain: domn,
+		}
+		break
+	default:
+		err = &errortypes.UnknownError{
+			errors.New("domain: Unknown domain type"),
+		}
+		return
+	}
+
+	return
+}
@@ -28,15 +28,13 @@ func (r *Record) Remove(db *database.Database) (err error) {
 		return
 	}
 
-	if domn.Type == Route53 {
-		err = AwsUpsertDomain(domn, r.Name, "", "")
-		if err != nil {
-			return
-		}
-	} else {
-		err = &errortypes.UnknownError{
-			errors.New("domain: Unknown domain type"),
-		}
+	prov, err := GetProvider(domn)
+	if err != nil {
+		return
+	}
+
+	err = prov.Remove(r.Name)
+	if err != nil {
 		return
 	}
 
@@ -60,15 +58,13 @@ func (r *Record) Upsert(db *database.Database, addr, addr6 string) (
 		}
 	}
 
-	if domn.Type == Route53 {
-		err = AwsUpsertDomain(domn, r.Name, addr, addr6)
-		if err != nil {
-			return
-		}
-	} else {
-		err = &errortypes.UnknownError{
-			errors.New("domain: Unknown domain type"),
-		}
+	prov, err := GetProvider(domn)
+	if err != nil {
+		return
+	}
+
+	err = prov.Upsert(r.Name, addr, addr6)
+	if err != nil {
 		return
 	}
 
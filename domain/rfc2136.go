@@ -0,0 +1,336 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+const (
+	dnsTypeA        = 1
+	dnsTypeSoa      = 6
+	dnsTypeAaaa     = 28
+	dnsTypeTsig     = 250
+	dnsClassIn      = 1
+	dnsClassAny     = 255
+	dnsOpcodeUpdate = 5
+	dnsTsigFudge    = 300
+)
+
+var dnsRcodes = map[int]string{
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+}
+
+type rfc2136Provider struct {
+	domain *Domain
+}
+
+func dnsPackName(buf []byte, name string) (out []byte, err error) {
+	name = strings.TrimRight(strings.ToLower(name), ".")
+
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				err = &errortypes.ParseError{
+					errors.Newf("domain: Invalid DNS name '%s'", name),
+				}
+				return
+			}
+
+			buf = append(buf, byte(len(label)))
+			buf = append(buf, label...)
+		}
+	}
+
+	out = append(buf, 0)
+	return
+}
+
+func dnsPackRecord(buf []byte, name string, rrType, rrClass uint16,
+	ttl uint32, rdata []byte) (out []byte, err error) {
+
+	buf, err = dnsPackName(buf, name)
+	if err != nil {
+		return
+	}
+
+	field := make([]byte, 10)
+	binary.BigEndian.PutUint16(field[0:], rrType)
+	binary.BigEndian.PutUint16(field[2:], rrClass)
+	binary.BigEndian.PutUint32(field[4:], ttl)
+	binary.BigEndian.PutUint16(field[8:], uint16(len(rdata)))
+
+	buf = append(buf, field...)
+	out = append(buf, rdata...)
+
+	return
+}
+
+func (p *rfc2136Provider) getHash() (hashFunc func() hash.Hash,
+	algo string, err error) {
+
+	switch p.domain.TsigAlgo {
+	case HmacSha1:
+		hashFunc = sha1.New
+		break
+	case HmacSha256, "":
+		hashFunc = sha256.New
+		break
+	case HmacSha512:
+		hashFunc = sha512.New
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("domain: Unknown TSIG algorithm '%s'",
+				p.domain.TsigAlgo),
+		}
+		return
+	}
+
+	algo = p.domain.TsigAlgo
+	if algo == "" {
+		algo = HmacSha256
+	}
+
+	return
+}
+
+func (p *rfc2136Provider) sign(msg []byte, msgId uint16) (
+	out []byte, err error) {
+
+	hashFunc, algo, err := p.getHash()
+	if err != nil {
+		return
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(p.domain.TsigSecret)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "domain: Failed to decode TSIG secret"),
+		}
+		return
+	}
+
+	keyName, err := dnsPackName(nil, p.domain.TsigName)
+	if err != nil {
+		return
+	}
+
+	algoName, err := dnsPackName(nil, algo)
+	if err != nil {
+		return
+	}
+
+	timeSigned := make([]byte, 8)
+	binary.BigEndian.PutUint64(timeSigned, uint64(time.Now().Unix()))
+	timeSigned = timeSigned[2:]
+
+	fudge := make([]byte, 2)
+	binary.BigEndian.PutUint16(fudge, dnsTsigFudge)
+
+	tsigVars := []byte{}
+	tsigVars = append(tsigVars, keyName...)
+	tsigVars = append(tsigVars, 0, dnsClassAny, 0, 0, 0, 0)
+	tsigVars = append(tsigVars, algoName...)
+	tsigVars = append(tsigVars, timeSigned...)
+	tsigVars = append(tsigVars, fudge...)
+	tsigVars = append(tsigVars, 0, 0, 0, 0)
+
+	mac := hmac.New(hashFunc, secret)
+	mac.Write(msg)
+	mac.Write(tsigVars)
+	macSum := mac.Sum(nil)
+
+	rdata := []byte{}
+	rdata = append(rdata, algoName...)
+	rdata = append(rdata, timeSigned...)
+	rdata = append(rdata, fudge...)
+	rdata = append(rdata, byte(len(macSum)>>8), byte(len(macSum)))
+	rdata = append(rdata, macSum...)
+	rdata = append(rdata, byte(msgId>>8), byte(msgId))
+	rdata = append(rdata, 0, 0, 0, 0)
+
+	out = append([]byte{}, msg...)
+	out, err = dnsPackRecord(out, p.domain.TsigName, dnsTypeTsig,
+		dnsClassAny, 0, rdata)
+	if err != nil {
+		return
+	}
+
+	binary.BigEndian.PutUint16(out[10:],
+		binary.BigEndian.Uint16(out[10:])+1)
+
+	return
+}
+
+func (p *rfc2136Provider) update(recordName, addr,
+	addr6 string) (err error) {
+
+	idBytes, err := utils.RandBytes(2)
+	if err != nil {
+		return
+	}
+	msgId := binary.BigEndian.Uint16(idBytes)
+
+	updates := []byte{}
+	updateCount := 0
+
+	for _, rrType := range []uint16{dnsTypeA, dnsTypeAaaa} {
+		updates, err = dnsPackRecord(updates, recordName, rrType,
+			dnsClassAny, 0, nil)
+		if err != nil {
+			return
+		}
+		updateCount += 1
+
+		var rdata []byte
+		if rrType == dnsTypeA && addr != "" {
+			ip := net.ParseIP(addr).To4()
+			if ip == nil {
+				err = &errortypes.ParseError{
+					errors.Newf("domain: Invalid IPv4 address '%s'", addr),
+				}
+				return
+			}
+			rdata = ip
+		} else if rrType == dnsTypeAaaa && addr6 != "" {
+			ip := net.ParseIP(addr6).To16()
+			if ip == nil {
+				err = &errortypes.ParseError{
+					errors.Newf("domain: Invalid IPv6 address '%s'", addr6),
+				}
+				return
+			}
+			rdata = ip
+		} else {
+			continue
+		}
+
+		updates, err = dnsPackRecord(updates, recordName, rrType,
+			dnsClassIn, 60, rdata)
+		if err != nil {
+			return
+		}
+		updateCount += 1
+	}
+
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[0:], msgId)
+	binary.BigEndian.PutUint16(msg[2:], dnsOpcodeUpdate<<11)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	binary.BigEndian.PutUint16(msg[8:], uint16(updateCount))
+
+	msg, err = dnsPackName(msg, p.domain.Name)
+	if err != nil {
+		return
+	}
+	msg = append(msg, 0, dnsTypeSoa, 0, dnsClassIn)
+	msg = append(msg, updates...)
+
+	if p.domain.TsigName != "" {
+		msg, err = p.sign(msg, msgId)
+		if err != nil {
+			return
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", p.domain.DnsServer, 10*time.Second)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "domain: Failed to connect to DNS server"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(20 * time.Second))
+
+	_, err = conn.Write(append(
+		[]byte{byte(len(msg) >> 8), byte(len(msg))}, msg...))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Failed to write DNS update"),
+		}
+		return
+	}
+
+	respLen := make([]byte, 2)
+	_, err = io.ReadFull(conn, respLen)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "domain: Failed to read DNS response"),
+		}
+		return
+	}
+
+	resp := make([]byte, binary.BigEndian.Uint16(respLen))
+	_, err = io.ReadFull(conn, resp)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "domain: Failed to read DNS response"),
+		}
+		return
+	}
+
+	if len(resp) < 12 || binary.BigEndian.Uint16(resp[0:]) != msgId {
+		err = &errortypes.ParseError{
+			errors.New("domain: Invalid DNS update response"),
+		}
+		return
+	}
+
+	rcode := int(binary.BigEndian.Uint16(resp[2:]) & 0xf)
+	if rcode != 0 {
+		rcodeName := dnsRcodes[rcode]
+		if rcodeName == "" {
+			rcodeName = "UNKNOWN"
+		}
+
+		err = &errortypes.RequestError{
+			errors.Newf("domain: DNS update failed with %s (%d)",
+				rcodeName, rcode),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *rfc2136Provider) Upsert(name, addr, addr6 string) (err error) {
+	err = p.update(name+"."+p.domain.Name, addr, addr6)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *rfc2136Provider) Remove(name string) (err error) {
+	err = p.update(name+"."+p.domain.Name, "", "")
+	if err != nil {
+		return
+	}
+
+	return
+}
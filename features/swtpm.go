@@ -0,0 +1,28 @@
+package features
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+const (
+	Swtpm = "/usr/bin/swtpm"
+)
+
+func GetSwtpmPath() (path string, err error) {
+	exists, err := utils.Exists(Swtpm)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = &errortypes.NotFoundError{
+			errors.New("features: Virtual TPM requires swtpm package"),
+		}
+		return
+	}
+
+	path = Swtpm
+	return
+}
@@ -14,9 +14,10 @@ import (
 )
 
 type Rule struct {
-	SourceIps []string `bson:"source_ips" json:"source_ips"`
-	Protocol  string   `bson:"protocol" json:"protocol"`
-	Port      string   `bson:"port" json:"port"`
+	SourceIps      []string `bson:"source_ips" json:"source_ips"`
+	DestinationIps []string `bson:"destination_ips,omitempty" json:"destination_ips"`
+	Protocol       string   `bson:"protocol" json:"protocol"`
+	Port           string   `bson:"port" json:"port"`
 }
 
 func (r *Rule) SetName(ipv6 bool) (name string) {
@@ -57,6 +58,44 @@ func (r *Rule) SetName(ipv6 bool) (name string) {
 	return
 }
 
+func (r *Rule) EgressSetName(ipv6 bool) (name string) {
+	switch r.Protocol {
+	case All:
+		if ipv6 {
+			name = "pe6_all"
+		} else {
+			name = "pe4_all"
+		}
+		break
+	case Icmp:
+		if ipv6 {
+			name = "pe6_icmp"
+		} else {
+			name = "pe4_icmp"
+		}
+		break
+	case Tcp, Udp:
+		if ipv6 {
+			name = fmt.Sprintf(
+				"pe6_%s_%s",
+				r.Protocol,
+				strings.Replace(r.Port, "-", "_", 1),
+			)
+		} else {
+			name = fmt.Sprintf(
+				"pe4_%s_%s",
+				r.Protocol,
+				strings.Replace(r.Port, "-", "_", 1),
+			)
+		}
+		break
+	default:
+		break
+	}
+
+	return
+}
+
 type Firewall struct {
 	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name         string             `bson:"name" json:"name"`
@@ -64,6 +103,7 @@ type Firewall struct {
 	Organization primitive.ObjectID `bson:"organization,omitempty" json:"organization"`
 	NetworkRoles []string           `bson:"network_roles" json:"network_roles"`
 	Ingress      []*Rule            `bson:"ingress" json:"ingress"`
+	Egress       []*Rule            `bson:"egress" json:"egress"`
 }
 
 func (f *Firewall) Validate(db *database.Database) (
@@ -77,7 +117,13 @@ func (f *Firewall) Validate(db *database.Database) (
 		f.Ingress = []*Rule{}
 	}
 
+	if f.Egress == nil {
+		f.Egress = []*Rule{}
+	}
+
 	for _, rule := range f.Ingress {
+		rule.DestinationIps = nil
+
 		switch rule.Protocol {
 		case All:
 			rule.Port = ""
@@ -168,6 +214,99 @@ func (f *Firewall) Validate(db *database.Database) (
 		}
 	}
 
+	for _, rule := range f.Egress {
+		rule.SourceIps = []string{}
+
+		switch rule.Protocol {
+		case All:
+			rule.Port = ""
+			break
+		case Icmp:
+			rule.Port = ""
+			break
+		case Tcp, Udp:
+			ports := strings.Split(rule.Port, "-")
+
+			portInt, e := strconv.Atoi(ports[0])
+			if e != nil {
+				errData = &errortypes.ErrorData{
+					Error:   "invalid_egress_rule_port",
+					Message: "Invalid egress rule port",
+				}
+				return
+			}
+
+			if portInt < 1 || portInt > 65535 {
+				errData = &errortypes.ErrorData{
+					Error:   "invalid_egress_rule_port",
+					Message: "Invalid egress rule port",
+				}
+				return
+			}
+
+			parsedPort := strconv.Itoa(portInt)
+			if len(ports) > 1 {
+				portInt2, e := strconv.Atoi(ports[1])
+				if e != nil {
+					errData = &errortypes.ErrorData{
+						Error:   "invalid_egress_rule_port",
+						Message: "Invalid egress rule port",
+					}
+					return
+				}
+
+				if portInt2 > 65535 || portInt2 <= portInt {
+					errData = &errortypes.ErrorData{
+						Error:   "invalid_egress_rule_port",
+						Message: "Invalid egress rule port",
+					}
+					return
+				}
+
+				parsedPort += "-" + strconv.Itoa(portInt2)
+			}
+
+			rule.Port = parsedPort
+
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "invalid_egress_rule_protocol",
+				Message: "Invalid egress rule protocol",
+			}
+			return
+		}
+
+		for i, destIp := range rule.DestinationIps {
+			if destIp == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "invalid_egress_rule_destination_ip",
+					Message: "Empty egress rule destination IP",
+				}
+				return
+			}
+
+			if !strings.Contains(destIp, "/") {
+				if strings.Contains(destIp, ":") {
+					destIp += "/128"
+				} else {
+					destIp += "/32"
+				}
+			}
+
+			_, destCidr, e := net.ParseCIDR(destIp)
+			if e != nil {
+				errData = &errortypes.ErrorData{
+					Error:   "invalid_egress_rule_destination_ip",
+					Message: "Invalid egress rule destination IP",
+				}
+				return
+			}
+
+			rule.DestinationIps[i] = destCidr.String()
+		}
+	}
+
 	return
 }
 
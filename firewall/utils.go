@@ -453,3 +453,87 @@ func GetAllIngress(db *database.Database, nodeSelf *node.Node,
 
 	return
 }
+
+func MergeEgress(fires []*Firewall) (rules []*Rule) {
+	rules = []*Rule{}
+	rulesMap := map[string]*Rule{}
+	rulesKey := []string{}
+
+	for _, fire := range fires {
+		for _, egress := range fire.Egress {
+			key := fmt.Sprintf("%s-%s", egress.Protocol, egress.Port)
+			rule := rulesMap[key]
+			if rule == nil {
+				rule = &Rule{
+					Protocol:       egress.Protocol,
+					Port:           egress.Port,
+					DestinationIps: egress.DestinationIps,
+				}
+				rulesMap[key] = rule
+				rulesKey = append(rulesKey, key)
+			} else {
+				destIps := set.NewSet()
+				for _, destIp := range rule.DestinationIps {
+					destIps.Add(destIp)
+				}
+
+				for _, destIp := range egress.DestinationIps {
+					if destIps.Contains(destIp) {
+						continue
+					}
+					destIps.Add(destIp)
+					rule.DestinationIps = append(rule.DestinationIps, destIp)
+				}
+			}
+		}
+	}
+
+	sort.Strings(rulesKey)
+	for _, key := range rulesKey {
+		rules = append(rules, rulesMap[key])
+	}
+
+	return
+}
+
+func GetAllEgress(db *database.Database, instances []*instance.Instance) (
+	firewalls map[string][]*Rule, err error) {
+
+	firewalls = map[string][]*Rule{}
+	for _, inst := range instances {
+		if !inst.IsActive() {
+			continue
+		}
+
+		fires, e := GetOrgRoles(db,
+			inst.Organization, inst.NetworkRoles)
+		if e != nil {
+			err = e
+			return
+		}
+
+		egress := MergeEgress(fires)
+
+		for i := range inst.Virt.NetworkAdapters {
+			namespace := vm.GetNamespace(inst.Id, i)
+
+			_, ok := firewalls[namespace]
+			if ok {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"index":       i,
+					"namespace":   namespace,
+				}).Error("firewall: Namespace conflict")
+
+				err = &errortypes.ParseError{
+					errors.New("firewall: Namespace conflict"),
+				}
+				return
+			}
+
+			firewalls[namespace] = egress
+		}
+	}
+
+	return
+}
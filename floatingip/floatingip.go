@@ -0,0 +1,317 @@
+package floatingip
+
+import (
+	"net"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/quota"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"github.com/sirupsen/logrus"
+)
+
+type FloatingIp struct {
+	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name             string             `bson:"name" json:"name"`
+	Comment          string             `bson:"comment" json:"comment"`
+	Organization     primitive.ObjectID `bson:"organization" json:"organization"`
+	Zone             primitive.ObjectID `bson:"zone" json:"zone"`
+	Block            primitive.ObjectID `bson:"block,omitempty" json:"block"`
+	Address          string             `bson:"address" json:"address"`
+	Instance         primitive.ObjectID `bson:"instance,omitempty" json:"instance"`
+	quotaReservation primitive.ObjectID `bson:"-" json:"-"`
+	nodeBlocks       set.Set            `bson:"-" json:"-"`
+}
+
+func (f *FloatingIp) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if f.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if f.Zone.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "zone_required",
+			Message: "Missing required zone",
+		}
+		return
+	}
+
+	_, err = zone.Get(db, f.Zone)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "zone_not_found",
+				Message: "Zone not found",
+			}
+		}
+		return
+	}
+
+	if f.Id.IsZero() {
+		f.releaseQuota(db)
+
+		f.quotaReservation, errData, err = quota.Reserve(
+			db, f.Organization, &quota.Usage{
+				PublicIps: 1,
+			})
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
+	if f.Instance.IsZero() {
+		return
+	}
+
+	inst, err := instance.Get(db, f.Instance)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "instance_not_found",
+				Message: "Instance not found",
+			}
+		}
+		return
+	}
+
+	if inst.Organization != f.Organization {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_not_found",
+			Message: "Instance not found",
+		}
+		return
+	}
+
+	if inst.Zone != f.Zone {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_zone_invalid",
+			Message: "Instance must be in the floating IP zone",
+		}
+		return
+	}
+
+	if inst.NoPublicAddress {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_public_address_disabled",
+			Message: "Instance does not have a public address",
+		}
+		return
+	}
+
+	nde, err := node.Get(db, inst.Node)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "instance_node_not_found",
+				Message: "Instance node not found",
+			}
+		}
+		return
+	}
+
+	if nde.NetworkMode != node.Static {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_node_network_mode_invalid",
+			Message: "Instance node must use static network mode",
+		}
+		return
+	}
+
+	nodeBlocks := set.NewSet()
+	for _, blckAttch := range nde.Blocks {
+		nodeBlocks.Add(blckAttch.Block)
+	}
+
+	if nodeBlocks.Len() == 0 ||
+		(!f.Block.IsZero() && !nodeBlocks.Contains(f.Block)) {
+
+		errData = &errortypes.ErrorData{
+			Error:   "instance_node_block_invalid",
+			Message: "Floating IP block is not attached to instance node",
+		}
+		return
+	}
+	f.nodeBlocks = nodeBlocks
+
+	coll := db.FloatingIps()
+
+	count, err := coll.CountDocuments(db, &bson.M{
+		"_id": &bson.M{
+			"$ne": f.Id,
+		},
+		"instance": f.Instance,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if count > 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_floating_ip_exists",
+			Message: "Instance already has a floating IP attached",
+		}
+		return
+	}
+
+	return
+}
+
+// Allocate reserves an address for the floating IP from the external
+// blocks attached to the nodes in the zone. Attached floating IPs are
+// limited to the blocks of the instance node.
+func (f *FloatingIp) Allocate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if f.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("floatingip: Floating IP must be inserted"),
+		}
+		return
+	}
+
+	if f.Address != "" {
+		return
+	}
+
+	nodes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	blockIds := set.NewSet()
+	for _, nde := range nodes {
+		if nde.Zone != f.Zone || nde.Blocks == nil {
+			continue
+		}
+
+		for _, blckAttch := range nde.Blocks {
+			if blockIds.Contains(blckAttch.Block) {
+				continue
+			}
+			blockIds.Add(blckAttch.Block)
+
+			if !f.Block.IsZero() && f.Block != blckAttch.Block {
+				continue
+			}
+
+			if f.nodeBlocks != nil && !f.nodeBlocks.Contains(
+				blckAttch.Block) {
+
+				continue
+			}
+
+			blck, e := block.Get(db, blckAttch.Block)
+			if e != nil {
+				if _, ok := e.(*database.NotFoundError); ok {
+					continue
+				}
+				err = e
+				return
+			}
+
+			ip, e := blck.GetIp(db, f.Id, block.Floating)
+			if e != nil {
+				if _, ok := e.(*block.BlockFull); ok {
+					continue
+				}
+				err = e
+				return
+			}
+
+			f.Block = blck.Id
+			f.Address = ip.String()
+
+			return
+		}
+	}
+
+	errData = &errortypes.ErrorData{
+		Error:   "floating_ip_unavailable",
+		Message: "No external block addresses available in zone",
+	}
+
+	return
+}
+
+func (f *FloatingIp) GetIp() net.IP {
+	return net.ParseIP(f.Address)
+}
+
+func (f *FloatingIp) Commit(db *database.Database) (err error) {
+	coll := db.FloatingIps()
+
+	err = coll.Commit(f.Id, f)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FloatingIp) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.FloatingIps()
+
+	err = coll.CommitFields(f.Id, f, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FloatingIp) Insert(db *database.Database) (err error) {
+	coll := db.FloatingIps()
+
+	if !f.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("floatingip: Floating IP already exists"),
+		}
+		return
+	}
+
+	resp, err := coll.InsertOne(db, f)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	f.Id = resp.InsertedID.(primitive.ObjectID)
+	f.releaseQuota(db)
+
+	return
+}
+
+func (f *FloatingIp) releaseQuota(db *database.Database) {
+	if f.quotaReservation.IsZero() {
+		return
+	}
+
+	err := quota.Release(db, f.Organization, f.quotaReservation)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"floating_ip_id": f.Id.Hex(),
+			"error":          err,
+		}).Error("floatingip: Failed to release quota reservation")
+	}
+
+	f.quotaReservation = primitive.NilObjectID
+}
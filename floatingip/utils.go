@@ -0,0 +1,227 @@
+package floatingip
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func Get(db *database.Database, fipId primitive.ObjectID) (
+	fip *FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fip = &FloatingIp{}
+
+	err = coll.FindOneId(fipId, fip)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, fipId primitive.ObjectID) (
+	fip *FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fip = &FloatingIp{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          fipId,
+		"organization": orgId,
+	}).Decode(fip)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	fips []*FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fips = []*FloatingIp{}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		fip := &FloatingIp{}
+		err = cursor.Decode(fip)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		fips = append(fips, fip)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (fips []*FloatingIp, count int64, err error) {
+
+	coll := db.FloatingIps()
+	fips = []*FloatingIp{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	maxPage := count / pageCount
+	if count == pageCount {
+		maxPage = 0
+	}
+	page = utils.Min64(page, maxPage)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		fip := &FloatingIp{}
+		err = cursor.Decode(fip)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		fips = append(fips, fip)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetInstance(db *database.Database, instId primitive.ObjectID) (
+	fip *FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fip = &FloatingIp{}
+
+	err = coll.FindOne(db, &bson.M{
+		"instance": instId,
+	}).Decode(fip)
+	if err != nil {
+		err = database.ParseError(err)
+		fip = nil
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	return
+}
+
+func GetZone(db *database.Database, zoneId primitive.ObjectID) (
+	fips []*FloatingIp, err error) {
+
+	fips, err = GetAll(db, &bson.M{
+		"zone": zoneId,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, fipId primitive.ObjectID) (err error) {
+	coll := db.FloatingIps()
+
+	err = block.RemoveInstanceIps(db, fipId)
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": fipId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, fipId primitive.ObjectID) (
+	err error) {
+
+	coll := db.FloatingIps()
+
+	fip, err := GetOrg(db, orgId, fipId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	err = block.RemoveInstanceIps(db, fip.Id)
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id":          fipId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
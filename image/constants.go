@@ -4,4 +4,15 @@ const (
 	Uefi    = "uefi"
 	Bios    = "bios"
 	Unknown = "unknown"
+
+	NetConfigV1 = "v1"
+	NetConfigV2 = "v2"
+
+	Qcow2 = "qcow2"
+	Raw   = "raw"
+	RawGz = "raw_gz"
+	RawXz = "raw_xz"
+	Vmdk  = "vmdk"
+	Vhdx  = "vhdx"
+	Ova   = "ova"
 )
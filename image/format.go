@@ -0,0 +1,35 @@
+package image
+
+import (
+	"strings"
+)
+
+var formatSuffixes = []struct {
+	suffix string
+	format string
+}{
+	{".qcow2", Qcow2},
+	{".img.gz", RawGz},
+	{".raw.gz", RawGz},
+	{".img.xz", RawXz},
+	{".raw.xz", RawXz},
+	{".img", Raw},
+	{".raw", Raw},
+	{".vmdk", Vmdk},
+	{".vhdx", Vhdx},
+	{".ova", Ova},
+}
+
+// ParseFormat returns the image format from the object key suffix or an
+// empty string if the key is not a supported image
+func ParseFormat(key string) string {
+	key = strings.ToLower(key)
+
+	for _, suffix := range formatSuffixes {
+		if strings.HasSuffix(key, suffix.suffix) {
+			return suffix.format
+		}
+	}
+
+	return ""
+}
@@ -17,17 +17,23 @@ import (
 type Image struct {
 	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Disk         primitive.ObjectID `bson:"disk,omitempty" json:"disk"`
+	Policy       primitive.ObjectID `bson:"policy,omitempty" json:"policy"`
 	Name         string             `bson:"name" json:"name"`
 	Comment      string             `bson:"comment" json:"comment"`
 	Organization primitive.ObjectID `bson:"organization" json:"organization"`
 	Signed       bool               `bson:"signed" json:"signed"`
 	Type         string             `bson:"type" json:"type"`
 	Firmware     string             `bson:"firmware" json:"firmware"`
+	Format       string             `bson:"format" json:"format"`
+	Processors   int                `bson:"processors" json:"processors"`
+	Memory       int                `bson:"memory" json:"memory"`
 	Storage      primitive.ObjectID `bson:"storage" json:"storage"`
 	Key          string             `bson:"key" json:"key"`
 	LastModified time.Time          `bson:"last_modified" json:"last_modified"`
 	StorageClass string             `bson:"storage_class" json:"storage_class"`
 	Etag         string             `bson:"etag" json:"etag"`
+	Checksum     string             `bson:"checksum" json:"checksum"`
+	NetConfig    string             `bson:"net_config" json:"net_config"`
 }
 
 func (i *Image) Validate(db *database.Database) (
@@ -37,6 +43,35 @@ func (i *Image) Validate(db *database.Database) (
 		i.Firmware = Unknown
 	}
 
+	if i.Format == "" {
+		i.Format = ParseFormat(i.Key)
+		if i.Format == "" {
+			i.Format = Qcow2
+		}
+	}
+
+	if i.Processors < 0 {
+		i.Processors = 0
+	}
+
+	if i.Memory < 0 {
+		i.Memory = 0
+	}
+
+	switch i.NetConfig {
+	case NetConfigV1, NetConfigV2:
+		break
+	case "":
+		i.NetConfig = NetConfigV1
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "net_config_invalid",
+			Message: "Invalid network config version",
+		}
+		return
+	}
+
 	return
 }
 
@@ -171,16 +206,19 @@ func (i *Image) Upsert(db *database.Database) (err error) {
 		&bson.M{
 			"$set": &bson.M{
 				"disk":          i.Disk,
+				"policy":        i.Policy,
 				"name":          i.Name,
 				"organization":  i.Organization,
 				"signed":        i.Signed,
 				"type":          i.Type,
 				"firmware":      i.Firmware,
+				"format":        i.Format,
 				"storage":       i.Storage,
 				"key":           i.Key,
 				"last_modified": i.LastModified,
 				"storage_class": i.StorageClass,
 				"etag":          i.Etag,
+				"checksum":      i.Checksum,
 			},
 		},
 		opts,
@@ -197,7 +235,8 @@ func (i *Image) Sync(db *database.Database) (err error) {
 	coll := db.Images()
 
 	if strings.HasPrefix(i.Key, "backup/") ||
-		strings.HasPrefix(i.Key, "snapshot/") {
+		strings.HasPrefix(i.Key, "snapshot/") ||
+		strings.HasPrefix(i.Key, "upload/") {
 
 		_, err = coll.UpdateOne(
 			db,
@@ -212,6 +251,7 @@ func (i *Image) Sync(db *database.Database) (err error) {
 					"signed":        i.Signed,
 					"type":          i.Type,
 					"firmware":      i.Firmware,
+					"format":        i.Format,
 					"etag":          i.Etag,
 					"last_modified": i.LastModified,
 					"storage_class": i.StorageClass,
@@ -228,6 +268,23 @@ func (i *Image) Sync(db *database.Database) (err error) {
 			return
 		}
 	} else {
+		update := bson.M{
+			"storage":       i.Storage,
+			"key":           i.Key,
+			"signed":        i.Signed,
+			"type":          i.Type,
+			"firmware":      i.Firmware,
+			"format":        i.Format,
+			"etag":          i.Etag,
+			"last_modified": i.LastModified,
+		}
+		if i.Processors > 0 {
+			update["processors"] = i.Processors
+		}
+		if i.Memory > 0 {
+			update["memory"] = i.Memory
+		}
+
 		opts := &options.UpdateOptions{}
 		opts.SetUpsert(true)
 		_, err = coll.UpdateOne(
@@ -237,15 +294,7 @@ func (i *Image) Sync(db *database.Database) (err error) {
 				"key":     i.Key,
 			},
 			&bson.M{
-				"$set": &bson.M{
-					"storage":       i.Storage,
-					"key":           i.Key,
-					"signed":        i.Signed,
-					"type":          i.Type,
-					"firmware":      i.Firmware,
-					"etag":          i.Etag,
-					"last_modified": i.LastModified,
-				},
+				"$set": update,
 			},
 			opts,
 		)
@@ -112,6 +112,42 @@ func Distinct(db *database.Database, storeId primitive.ObjectID) (
 	return
 }
 
+func GetStorageFormat(db *database.Database, storeId primitive.ObjectID,
+	format string) (imgs map[string]*Image, err error) {
+
+	coll := db.Images()
+	imgs = map[string]*Image{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"storage": storeId,
+		"format":  format,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		img := &Image{}
+		err = cursor.Decode(img)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		imgs[img.Key] = img
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func ExistsOrg(db *database.Database, orgId, imgId primitive.ObjectID) (
 	exists bool, err error) {
 
@@ -195,6 +231,49 @@ func GetAll(db *database.Database, query *bson.M, page, pageCount int64) (
 	return
 }
 
+func GetPolicy(db *database.Database, polId primitive.ObjectID) (
+	imgs []*Image, err error) {
+
+	coll := db.Images()
+	imgs = []*Image{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"policy": polId,
+		},
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"_id", -1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		img := &Image{}
+		err = cursor.Decode(img)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		imgs = append(imgs, img)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func GetAllNames(db *database.Database, query *bson.M) (
 	images []*Image, err error) {
 
@@ -11,6 +11,11 @@ const (
 	Cleanup   = "cleanup"
 	Restart   = "restart"
 	Destroy   = "destroy"
+
+	MigratePending  = "pending"
+	MigrateReady    = "ready"
+	MigrateComplete = "complete"
+	MigrateFailed   = "failed"
 )
 
 var (
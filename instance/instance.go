@@ -24,12 +24,15 @@ import (
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/pci"
+	"github.com/pritunl/pritunl-cloud/quota"
 	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/store"
 	"github.com/pritunl/pritunl-cloud/systemd"
 	"github.com/pritunl/pritunl-cloud/usb"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/zone"
 	"github.com/sirupsen/logrus"
 )
 
@@ -55,6 +58,8 @@ type Instance struct {
 	RestartBlockIp      bool               `bson:"restart_block_ip" json:"restart_block_ip"`
 	Uefi                bool               `bson:"uefi" json:"uefi"`
 	SecureBoot          bool               `bson:"secure_boot" json:"secure_boot"`
+	Tpm                 bool               `bson:"tpm" json:"tpm"`
+	DedicatedCpus       bool               `bson:"dedicated_cpus" json:"dedicated_cpus"`
 	DeleteProtection    bool               `bson:"delete_protection" json:"delete_protection"`
 	SkipSourceDestCheck bool               `bson:"skip_source_dest_check" json:"skip_source_dest_check"`
 	PublicIps           []string           `bson:"public_ips" json:"public_ips"`
@@ -69,7 +74,12 @@ type Instance struct {
 	NetworkNamespace    string             `bson:"network_namespace" json:"network_namespace"`
 	NoPublicAddress     bool               `bson:"no_public_address" json:"no_public_address"`
 	NoHostAddress       bool               `bson:"no_host_address" json:"no_host_address"`
+	BandwidthIngress    int                `bson:"bandwidth_ingress" json:"bandwidth_ingress"`
+	BandwidthEgress     int                `bson:"bandwidth_egress" json:"bandwidth_egress"`
 	Node                primitive.ObjectID `bson:"node" json:"node"`
+	MigrateNode         primitive.ObjectID `bson:"migrate_node,omitempty" json:"migrate_node"`
+	MigrateState        string             `bson:"migrate_state" json:"migrate_state"`
+	MigratePort         int                `bson:"migrate_port" json:"migrate_port"`
 	Domain              primitive.ObjectID `bson:"domain,omitempty" json:"domain"`
 	Name                string             `bson:"name" json:"name"`
 	Comment             string             `bson:"comment" json:"comment"`
@@ -79,6 +89,7 @@ type Instance struct {
 	Memory              int                `bson:"memory" json:"memory"`
 	Processors          int                `bson:"processors" json:"processors"`
 	NetworkRoles        []string           `bson:"network_roles" json:"network_roles"`
+	AntiAffinity        []string           `bson:"anti_affinity" json:"anti_affinity"`
 	Isos                []*iso.Iso         `bson:"isos" json:"isos"`
 	UsbDevices          []*usb.Device      `bson:"usb_devices" json:"usb_devices"`
 	PciDevices          []*pci.Device      `bson:"pci_devices" json:"pci_devices"`
@@ -98,6 +109,9 @@ type Instance struct {
 	curState            string             `bson:"-" json:"-"`
 	curNoPublicAddress  bool               `bson:"-" json:"-"`
 	curNoHostAddress    bool               `bson:"-" json:"-"`
+	curProcessors       int                `bson:"-" json:"-"`
+	curMemory           int                `bson:"-" json:"-"`
+	quotaReservation    primitive.ObjectID `bson:"-" json:"-"`
 }
 
 func (i *Instance) Validate(db *database.Database) (
@@ -136,12 +150,17 @@ func (i *Instance) Validate(db *database.Database) (
 		return
 	}
 
-	if i.Node.IsZero() {
-		errData = &errortypes.ErrorData{
-			Error:   "node_required",
-			Message: "Missing required node",
+	if i.AntiAffinity == nil {
+		i.AntiAffinity = []string{}
+	} else {
+		antiAffinity := []string{}
+		for _, tag := range i.AntiAffinity {
+			tag = utils.FilterStr(strings.TrimSpace(tag), 128)
+			if tag != "" {
+				antiAffinity = append(antiAffinity, tag)
+			}
 		}
-		return
+		i.AntiAffinity = antiAffinity
 	}
 
 	if i.Vpc.IsZero() {
@@ -214,6 +233,13 @@ func (i *Instance) Validate(db *database.Database) (
 		i.PrivateIps6 = []string{}
 	}
 
+	if i.Node.IsZero() {
+		errData, err = i.Schedule(db)
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
 	nde, err := node.Get(db, i.Node)
 	if err != nil {
 		return
@@ -237,6 +263,14 @@ func (i *Instance) Validate(db *database.Database) (
 		}
 	}
 
+	if i.DedicatedCpus && !nde.HasDedicatedCpus(i.Id, i.Processors) {
+		errData = &errortypes.ErrorData{
+			Error:   "dedicated_cpus_unavailable",
+			Message: "Node does not have enough dedicated CPUs",
+		}
+		return
+	}
+
 	if i.RootEnabled {
 		if i.RootPasswd == "" {
 			i.RootPasswd, err = utils.RandPasswd(8)
@@ -357,9 +391,70 @@ func (i *Instance) Validate(db *database.Database) (
 		i.SpicePassword = ""
 	}
 
+	if i.BandwidthIngress < 0 || i.BandwidthEgress < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "bandwidth_invalid",
+			Message: "Bandwidth limit cannot be negative",
+		}
+		return
+	}
+
+	errData, err = i.validateQuota(db)
+	if err != nil || errData != nil {
+		return
+	}
+
+	return
+}
+
+func (i *Instance) validateQuota(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	requested := &quota.Usage{}
+
+	if i.Id.IsZero() {
+		requested.Instances = 1
+		requested.Processors = i.Processors
+		requested.Memory = i.Memory
+		requested.Disk = i.InitDiskSize
+		if !i.NoPublicAddress {
+			requested.PublicIps = 1
+		}
+	} else {
+		requested.Processors = i.Processors - i.curProcessors
+		requested.Memory = i.Memory - i.curMemory
+		if i.curNoPublicAddress && !i.NoPublicAddress {
+			requested.PublicIps = 1
+		}
+	}
+
+	i.releaseQuota(db)
+
+	i.quotaReservation, errData, err = quota.Reserve(
+		db, i.Organization, requested)
+	if err != nil || errData != nil {
+		return
+	}
+
 	return
 }
 
+func (i *Instance) releaseQuota(db *database.Database) {
+	if i.quotaReservation.IsZero() {
+		return
+	}
+
+	err := quota.Release(db, i.Organization, i.quotaReservation)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": i.Id.Hex(),
+			"error":       err,
+		}).Error("instance: Failed to release quota reservation")
+	}
+
+	i.quotaReservation = primitive.NilObjectID
+}
+
 func (i *Instance) GenerateUnixId() {
 	i.UnixId = rand.Intn(55500) + 10000
 }
@@ -416,6 +511,16 @@ func (i *Instance) InitSpicePort(db *database.Database) (err error) {
 	return
 }
 
+func (i *Instance) GenerateMigratePort() {
+	// Migration 25000 - 29996
+	// Migration NBD 25001 - 29997
+	i.MigratePort = rand.Intn(2499)*2 + 25000
+}
+
+func (i *Instance) IsMigrating() bool {
+	return !i.MigrateNode.IsZero() && i.MigrateState != MigrateFailed
+}
+
 func (i *Instance) GenerateVncDisplay() {
 	// VNC 10001 - 14999
 	// VNC WebSocket 20001 - 24999
@@ -561,6 +666,143 @@ func (i *Instance) Json() {
 	}
 }
 
+func (i *Instance) Migrate(db *database.Database,
+	ndeId primitive.ObjectID) (errData *errortypes.ErrorData, err error) {
+
+	if i.IsMigrating() {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_in_progress",
+			Message: "Instance migration already in progress",
+		}
+		return
+	}
+
+	if ndeId.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_required",
+			Message: "Missing required migration node",
+		}
+		return
+	}
+
+	if ndeId == i.Node {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_invalid",
+			Message: "Instance already on migration node",
+		}
+		return
+	}
+
+	if i.State != Start || i.VmState != vm.Running {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_instance_not_running",
+			Message: "Instance must be running to migrate",
+		}
+		return
+	}
+
+	if i.Gui || len(i.UsbDevices) > 0 || len(i.PciDevices) > 0 ||
+		len(i.DriveDevices) > 0 {
+
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_devices_unsupported",
+			Message: "Cannot migrate instance with host devices",
+		}
+		return
+	}
+
+	curNde, err := node.Get(db, i.Node)
+	if err != nil {
+		return
+	}
+
+	nde, err := node.Get(db, ndeId)
+	if err != nil {
+		return
+	}
+
+	if nde.Zone != i.Zone {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_zone_invalid",
+			Message: "Migration node must be in the instance zone",
+		}
+		return
+	}
+
+	if !nde.IsHypervisor() {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_invalid",
+			Message: "Migration node is not a hypervisor",
+		}
+		return
+	}
+
+	if nde.Hypervisor != curNde.Hypervisor ||
+		nde.Hugepages != curNde.Hugepages {
+
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_incompatible",
+			Message: "Migration node hypervisor configuration mismatch",
+		}
+		return
+	}
+
+	if i.DedicatedCpus && !nde.HasDedicatedCpus(i.Id, i.Processors) {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_dedicated_cpus",
+			Message: "Migration node does not have enough dedicated CPUs",
+		}
+		return
+	}
+
+	zne, err := zone.Get(db, i.Zone)
+	if err != nil {
+		return
+	}
+
+	placement, err := node.NewPlacement(db, zne)
+	if err != nil {
+		return
+	}
+
+	if !placement.Fits(nde.Id, i.PlacementRequest()) {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_unavailable",
+			Message: "Migration node does not have enough memory or CPUs",
+		}
+		return
+	}
+
+	if nde.GetMigrateAddr() == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_address_missing",
+			Message: "Migration node missing private address",
+		}
+		return
+	}
+
+	dsks, err := disk.GetInstance(db, i.Id)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range dsks {
+		if dsk.State != disk.Available {
+			errData = &errortypes.ErrorData{
+				Error:   "migrate_disk_busy",
+				Message: "Instance disks must be available to migrate",
+			}
+			return
+		}
+	}
+
+	i.MigrateNode = nde.Id
+	i.MigrateState = MigratePending
+	i.MigratePort = 0
+
+	return
+}
+
 func (i *Instance) IsActive() bool {
 	return i.State == Start || i.VmState == vm.Running ||
 		i.VmState == vm.Starting || i.VmState == vm.Provisioning
@@ -573,6 +815,8 @@ func (i *Instance) PreCommit() {
 	i.curState = i.State
 	i.curNoPublicAddress = i.NoPublicAddress
 	i.curNoHostAddress = i.NoHostAddress
+	i.curProcessors = i.Processors
+	i.curMemory = i.Memory
 }
 
 func (i *Instance) PostCommit(db *database.Database) (
@@ -628,6 +872,8 @@ func (i *Instance) Commit(db *database.Database) (err error) {
 		return
 	}
 
+	i.releaseQuota(db)
+
 	return
 }
 
@@ -663,6 +909,8 @@ func (i *Instance) CommitFields(db *database.Database, fields set.Set) (
 		}
 	}
 
+	i.releaseQuota(db)
+
 	return
 }
 
@@ -677,9 +925,9 @@ func (i *Instance) Insert(db *database.Database) (err error) {
 	}
 
 	for n := 0; n < 2000; n++ {
-		_, err = coll.InsertOne(db, i)
-		if err != nil {
-			err = database.ParseError(err)
+		resp, e := coll.InsertOne(db, i)
+		if e != nil {
+			err = database.ParseError(e)
 			if _, ok := err.(*database.DuplicateKeyError); ok {
 				i.GenerateUnixId()
 				err = nil
@@ -688,6 +936,9 @@ func (i *Instance) Insert(db *database.Database) (err error) {
 			return
 		}
 
+		i.Id = resp.InsertedID.(primitive.ObjectID)
+		i.releaseQuota(db)
+
 		return
 	}
 
@@ -724,8 +975,12 @@ func (i *Instance) LoadVirt(disks []*disk.Disk) {
 		OracleVnicAttach: i.OracleVnicAttach,
 		Uefi:             i.Uefi,
 		SecureBoot:       i.SecureBoot,
+		Tpm:              i.Tpm,
+		DedicatedCpus:    i.DedicatedCpus,
 		NoPublicAddress:  i.NoPublicAddress,
 		NoHostAddress:    i.NoHostAddress,
+		BandwidthIngress: i.BandwidthIngress,
+		BandwidthEgress:  i.BandwidthEgress,
 		Isos:             []*vm.Iso{},
 		UsbDevices:       []*vm.UsbDevice{},
 		PciDevices:       []*vm.PciDevice{},
@@ -741,9 +996,10 @@ func (i *Instance) LoadVirt(disks []*disk.Disk) {
 			}
 
 			i.Virt.Disks = append(i.Virt.Disks, &vm.Disk{
-				Id:    dsk.Id,
-				Index: index,
-				Path:  paths.GetDiskPath(dsk.Id),
+				Id:     dsk.Id,
+				Index:  index,
+				Path:   paths.GetDiskPath(dsk.Id),
+				Limits: dsk.GetLimits(),
 			})
 		}
 	}
@@ -819,6 +1075,8 @@ func (i *Instance) Changed(curVirt *vm.VirtualMachine) bool {
 		i.Virt.Gui != curVirt.Gui ||
 		i.Virt.Uefi != curVirt.Uefi ||
 		i.Virt.SecureBoot != curVirt.SecureBoot ||
+		i.Virt.Tpm != curVirt.Tpm ||
+		i.Virt.DedicatedCpus != curVirt.DedicatedCpus ||
 		i.Virt.NoPublicAddress != curVirt.NoPublicAddress ||
 		i.Virt.NoHostAddress != curVirt.NoHostAddress {
 
@@ -945,6 +1203,36 @@ func (i *Instance) DiskChanged(curVirt *vm.VirtualMachine) (
 	return
 }
 
+func (i *Instance) DiskLimitsChanged(curVirt *vm.VirtualMachine) (
+	limitDisks []*vm.Disk) {
+
+	limitDisks = []*vm.Disk{}
+
+	if curVirt == nil || curVirt.State != vm.Running ||
+		!curVirt.DisksAvailable {
+
+		return
+	}
+
+	curDisks := set.NewSet()
+	for _, dsk := range curVirt.Disks {
+		curDisks.Add(dsk.Id)
+	}
+
+	for _, dsk := range i.Virt.Disks {
+		if !curDisks.Contains(dsk.Id) {
+			continue
+		}
+
+		limits, ok := store.GetDiskLimits(i.Id, dsk.Id)
+		if !ok || limits != dsk.Limits {
+			limitDisks = append(limitDisks, dsk)
+		}
+	}
+
+	return
+}
+
 func (i *Instance) UsbChanged(curVirt *vm.VirtualMachine) (
 	addUsbs, remUsbs []*vm.UsbDevice) {
 
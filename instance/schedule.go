@@ -0,0 +1,48 @@
+package instance
+
+import (
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+func (i *Instance) PlacementRequest() *node.PlacementRequest {
+	return &node.PlacementRequest{
+		Processors:    i.Processors,
+		Memory:        i.Memory,
+		OracleSubnet:  i.OracleSubnet,
+		UsbDevices:    i.UsbDevices,
+		PciDevices:    i.PciDevices,
+		DriveDevices:  i.DriveDevices,
+		AntiAffinity:  i.AntiAffinity,
+		DedicatedCpus: i.DedicatedCpus,
+	}
+}
+
+func (i *Instance) Schedule(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	zne, err := zone.Get(db, i.Zone)
+	if err != nil {
+		return
+	}
+
+	placement, err := node.NewPlacement(db, zne)
+	if err != nil {
+		return
+	}
+
+	nde := placement.Reserve(i.PlacementRequest())
+	if nde == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "node_unavailable",
+			Message: "No node in zone available for instance",
+		}
+		return
+	}
+
+	i.Node = nde.Id
+
+	return
+}
@@ -0,0 +1,243 @@
+package instance
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gorilla/websocket"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const serialForwardHeader = "Pritunl-Serial-Forward"
+
+func serialUpgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		HandshakeTimeout: time.Duration(
+			settings.Router.HandshakeTimeout) * time.Second,
+		ReadBufferSize:  2048,
+		WriteBufferSize: 2048,
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+}
+
+func (i *Instance) SerialConnect(db *database.Database,
+	rw http.ResponseWriter, r *http.Request) (err error) {
+
+	nde, err := node.Get(db, i.Node)
+	if err != nil {
+		return
+	}
+
+	if nde.Id == node.Self.Id {
+		err = i.serialConnectLocal(rw, r)
+	} else {
+		err = i.serialConnectRemote(nde, rw, r)
+	}
+
+	return
+}
+
+func (i *Instance) serialConnectLocal(rw http.ResponseWriter,
+	r *http.Request) (err error) {
+
+	backConn, err := net.DialTimeout(
+		"unix",
+		paths.GetSerialPath(i.Id),
+		10*time.Second,
+	)
+	if err != nil {
+		err = &SerialDialError{
+			errors.Wrap(err, "instance: Serial socket dial error"),
+		}
+		return
+	}
+	defer backConn.Close()
+
+	frontConn, err := serialUpgrader().Upgrade(rw, r, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "instance: WebSocket upgrade error"),
+		}
+		return
+	}
+	defer frontConn.Close()
+
+	wait := make(chan bool, 4)
+	go func() {
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				logrus.WithFields(logrus.Fields{
+					"panic": rec,
+				}).Error("instance: WebSocket serial back panic")
+				wait <- true
+			}
+		}()
+
+		for {
+			_, data, e := frontConn.ReadMessage()
+			if e != nil {
+				break
+			}
+
+			_, e = backConn.Write(data)
+			if e != nil {
+				break
+			}
+		}
+		wait <- true
+	}()
+	go func() {
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				logrus.WithFields(logrus.Fields{
+					"panic": rec,
+				}).Error("instance: WebSocket serial front panic")
+				wait <- true
+			}
+		}()
+
+		buf := make([]byte, 4096)
+		for {
+			n, e := backConn.Read(buf)
+			if e != nil {
+				break
+			}
+
+			e = frontConn.WriteMessage(websocket.BinaryMessage, buf[:n])
+			if e != nil {
+				break
+			}
+		}
+		wait <- true
+	}()
+	<-wait
+
+	return
+}
+
+func (i *Instance) serialConnectRemote(nde *node.Node,
+	rw http.ResponseWriter, r *http.Request) (err error) {
+
+	// Serial sockets are local to the hypervisor so the request is
+	// forwarded to the web server on the instance node
+	if r.Header.Get(serialForwardHeader) != "" {
+		err = &errortypes.NotFoundError{
+			errors.New("instance: Instance not running on forwarded node"),
+		}
+		return
+	}
+
+	if !nde.IsAdmin() && !nde.IsUser() {
+		err = &errortypes.NotFoundError{
+			errors.New("instance: Node missing web server for serial"),
+		}
+		return
+	} else if nde.PublicIps == nil || len(nde.PublicIps) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("instance: Node missing public IP for serial"),
+		}
+		return
+	}
+
+	scheme := "wss"
+	if nde.Protocol == "http" {
+		scheme = "ws"
+	}
+
+	port := nde.Port
+	if port == 0 {
+		port = 443
+	}
+
+	wsUrl := fmt.Sprintf(
+		"%s://%s:%d%s",
+		scheme,
+		nde.PublicIps[0],
+		port,
+		r.URL.RequestURI(),
+	)
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		TLSClientConfig: &tls.Config{
+			ServerName: utils.StripPort(r.Host),
+		},
+	}
+
+	header := http.Header{}
+	header.Set("Host", r.Host)
+	header.Set("Cookie", r.Header.Get("Cookie"))
+	header.Set("User-Agent", r.Header.Get("User-Agent"))
+	header.Set(serialForwardHeader, "true")
+
+	backConn, backResp, err := dialer.Dial(wsUrl, header)
+	if err != nil {
+		if backResp != nil {
+			err = &SerialDialError{
+				errors.Wrapf(err, "instance: WebSocket dial error %d",
+					backResp.StatusCode),
+			}
+		} else {
+			err = &SerialDialError{
+				errors.Wrap(err, "instance: WebSocket dial error"),
+			}
+		}
+		return
+	}
+	defer backConn.Close()
+
+	frontConn, err := serialUpgrader().Upgrade(rw, r, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "instance: WebSocket upgrade error"),
+		}
+		return
+	}
+	defer frontConn.Close()
+
+	wait := make(chan bool, 4)
+	go func() {
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				logrus.WithFields(logrus.Fields{
+					"panic": rec,
+				}).Error("instance: WebSocket serial back panic")
+				wait <- true
+			}
+		}()
+		io.Copy(backConn.UnderlyingConn(), frontConn.UnderlyingConn())
+		wait <- true
+	}()
+	go func() {
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				logrus.WithFields(logrus.Fields{
+					"panic": rec,
+				}).Error("instance: WebSocket serial front panic")
+				wait <- true
+			}
+		}()
+		io.Copy(frontConn.UnderlyingConn(), backConn.UnderlyingConn())
+		wait <- true
+	}()
+	<-wait
+
+	return
+}
@@ -329,6 +329,18 @@ func Remove(db *database.Database, instId primitive.ObjectID) (err error) {
 		return
 	}
 
+	_, err = db.FloatingIps().UpdateMany(db, &bson.M{
+		"instance": instId,
+	}, &bson.M{
+		"$unset": &bson.M{
+			"instance": "",
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
 	_, err = coll.DeleteOne(db, &bson.M{
 		"_id": instId,
 	})
@@ -507,3 +519,29 @@ func SetState(db *database.Database, instId primitive.ObjectID,
 
 	return
 }
+
+func SetMigrateComplete(db *database.Database, instId,
+	ndeId primitive.ObjectID) (updated bool, err error) {
+
+	coll := db.Instances()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":           instId,
+		"migrate_state": MigrateReady,
+	}, &bson.M{
+		"$set": &bson.M{
+			"node":          ndeId,
+			"migrate_state": MigrateComplete,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount > 0 {
+		updated = true
+	}
+
+	return
+}
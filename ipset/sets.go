@@ -37,7 +37,9 @@ func (s *Sets) Apply(curSets *Sets) (err error) {
 
 			if !created {
 				family := "inet"
-				if strings.HasPrefix(name, "pr6") {
+				if strings.HasPrefix(name, "pr6") ||
+					strings.HasPrefix(name, "pe6") {
+
 					family = "inet6"
 				}
 
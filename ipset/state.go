@@ -55,6 +55,50 @@ func (s *State) AddIngress(namespace string, ingress []*firewall.Rule) {
 	}
 }
 
+func (s *State) AddEgress(namespace string, egress []*firewall.Rule) {
+	sets := s.Namespaces[namespace]
+	if sets == nil {
+		sets = &Sets{
+			Namespace: namespace,
+			Sets:      map[string]set.Set{},
+		}
+		s.Namespaces[namespace] = sets
+	}
+
+	for _, rule := range egress {
+		name := rule.EgressSetName(false)
+		name6 := rule.EgressSetName(true)
+
+		if name == "" || name6 == "" {
+			continue
+		}
+
+		for _, destIp := range rule.DestinationIps {
+			if destIp == "0.0.0.0/0" || destIp == "::/0" {
+				continue
+			}
+
+			ruleName := ""
+			ipv6 := strings.Contains(destIp, ":")
+			if ipv6 {
+				destIp = strings.Replace(destIp, "/128", "", 1)
+				ruleName = name6
+			} else {
+				destIp = strings.Replace(destIp, "/32", "", 1)
+				ruleName = name
+			}
+
+			ruleSet := sets.Sets[ruleName]
+			if ruleSet == nil {
+				ruleSet = set.NewSet()
+				sets.Sets[ruleName] = ruleSet
+			}
+
+			ruleSet.Add(destIp)
+		}
+	}
+}
+
 func (s *State) AddSourceDestCheck(namespace, addr6 string) {
 	sets := s.Namespaces[namespace]
 	if sets == nil {
@@ -130,6 +174,39 @@ func (n *NamesState) AddIngress(namespace string, ingress []*firewall.Rule) {
 	}
 }
 
+func (n *NamesState) AddEgress(namespace string, egress []*firewall.Rule) {
+	sets := n.Namespaces[namespace]
+	if sets == nil {
+		sets = &Names{
+			Namespace: namespace,
+			Sets:      set.NewSet(),
+		}
+		n.Namespaces[namespace] = sets
+	}
+
+	for _, rule := range egress {
+		name := rule.EgressSetName(false)
+		name6 := rule.EgressSetName(true)
+
+		if name == "" || name6 == "" {
+			continue
+		}
+
+		for _, destIp := range rule.DestinationIps {
+			if destIp == "0.0.0.0/0" || destIp == "::/0" {
+				continue
+			}
+
+			ipv6 := strings.Contains(destIp, ":")
+			if ipv6 {
+				sets.Sets.Add(name6)
+			} else {
+				sets.Sets.Add(name)
+			}
+		}
+	}
+}
+
 func (n *NamesState) AddSourceDestCheck(namespace string) {
 	sets := n.Namespaces[namespace]
 	if sets == nil {
@@ -19,8 +19,8 @@ var (
 )
 
 func UpdateState(instances []*instance.Instance, namespaces []string,
-	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule) (
-	err error) {
+	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule,
+	firewallsEgress map[string][]*firewall.Rule) (err error) {
 
 	lockId := stateLock.Lock()
 	defer stateLock.Unlock(lockId)
@@ -56,6 +56,7 @@ func UpdateState(instances []*instance.Instance, namespaces []string,
 			}
 
 			newState.AddIngress(namespace, ingress)
+			newState.AddEgress(namespace, firewallsEgress[namespace])
 			if !inst.SkipSourceDestCheck {
 				newState.AddSourceDestCheck(namespace, addr6)
 			}
@@ -104,8 +105,8 @@ func applyState(oldState, newState *State, namespaces []string) (err error) {
 }
 
 func UpdateNamesState(instances []*instance.Instance,
-	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule) (
-	err error) {
+	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule,
+	firewallsEgress map[string][]*firewall.Rule) (err error) {
 
 	lockId := stateLock.Lock()
 	defer stateLock.Unlock(lockId)
@@ -136,6 +137,7 @@ func UpdateNamesState(instances []*instance.Instance,
 			}
 
 			newNamesState.AddIngress(namespace, ingress)
+			newNamesState.AddEgress(namespace, firewallsEgress[namespace])
 			if !inst.SkipSourceDestCheck {
 				newNamesState.AddSourceDestCheck(namespace)
 			}
@@ -205,8 +207,8 @@ func loadIpset(namespace string, state *State, namesState *NamesState) (
 }
 
 func Init(namespaces []string, instances []*instance.Instance,
-	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule) (
-	err error) {
+	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule,
+	firewallsEgress map[string][]*firewall.Rule) (err error) {
 
 	state := &State{
 		Namespaces: map[string]*Sets{},
@@ -230,7 +232,8 @@ func Init(namespaces []string, instances []*instance.Instance,
 	curState = state
 	curNamesState = namesState
 
-	err = UpdateState(instances, namespaces, nodeFirewall, firewalls)
+	err = UpdateState(instances, namespaces, nodeFirewall, firewalls,
+		firewallsEgress)
 	if err != nil {
 		return
 	}
@@ -239,10 +242,11 @@ func Init(namespaces []string, instances []*instance.Instance,
 }
 
 func InitNames(namespaces []string, instances []*instance.Instance,
-	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule) (
-	err error) {
+	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule,
+	firewallsEgress map[string][]*firewall.Rule) (err error) {
 
-	err = UpdateNamesState(instances, nodeFirewall, firewalls)
+	err = UpdateNamesState(instances, nodeFirewall, firewalls,
+		firewallsEgress)
 	if err != nil {
 		return
 	}
@@ -56,6 +56,15 @@ func (r *Rules) commentCommandSdc(inCmd []string) (cmd []string) {
 	return
 }
 
+func (r *Rules) commentCommandEgress(inCmd []string) (cmd []string) {
+	cmd = append(inCmd,
+		"-m", "comment",
+		"--comment", "pritunl_cloud_egress",
+	)
+
+	return
+}
+
 func (r *Rules) run(cmds [][]string, ipCmd string, ipv6 bool) (err error) {
 	iptablesCmd := getIptablesCmd(ipv6)
 
@@ -138,6 +147,16 @@ func (r *Rules) Apply() (err error) {
 		return
 	}
 
+	err = r.run(r.Egress, "-A", false)
+	if err != nil {
+		return
+	}
+
+	err = r.run(r.Egress6, "-A", true)
+	if err != nil {
+		return
+	}
+
 	err = r.run(r.Holds, "-D", false)
 	if err != nil {
 		return
@@ -437,6 +456,18 @@ func (r *Rules) Remove() (err error) {
 	}
 	r.Ingress6 = [][]string{}
 
+	err = r.run(r.Egress, "-D", false)
+	if err != nil {
+		return
+	}
+	r.Egress = [][]string{}
+
+	err = r.run(r.Egress6, "-D", true)
+	if err != nil {
+		return
+	}
+	r.Egress6 = [][]string{}
+
 	err = r.run(r.Holds, "-D", false)
 	if err != nil {
 		return
@@ -636,8 +667,8 @@ func (r *Rules) RemoveNat() (err error) {
 	return
 }
 
-func generateVirt(namespace, iface, addr, addr6 string, sourceDestCheck bool,
-	ingress []*firewall.Rule) (rules *Rules) {
+func generateVirt(namespace, iface, addr, addr6, gateway, gateway6 string,
+	sourceDestCheck bool, ingress, egress []*firewall.Rule) (rules *Rules) {
 
 	rules = &Rules{
 		Namespace:       namespace,
@@ -645,6 +676,8 @@ func generateVirt(namespace, iface, addr, addr6 string, sourceDestCheck bool,
 		SourceDestCheck: [][]string{},
 		Ingress:         [][]string{},
 		Ingress6:        [][]string{},
+		Egress:          [][]string{},
+		Egress6:         [][]string{},
 		Holds:           [][]string{},
 		Holds6:          [][]string{},
 	}
@@ -1007,6 +1040,288 @@ func generateVirt(namespace, iface, addr, addr6 string, sourceDestCheck bool,
 	)
 	rules.Ingress6 = append(rules.Ingress6, cmd)
 
+	if len(egress) == 0 || rules.Interface == "host" {
+		return
+	}
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"--physdev-is-bridged",
+		"-m", "pkttype",
+		"--pkt-type", "multicast",
+	)
+	cmd = rules.commentCommandEgress(cmd)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress = append(rules.Egress, cmd)
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"--physdev-is-bridged",
+		"-m", "pkttype",
+		"--pkt-type", "broadcast",
+	)
+	cmd = rules.commentCommandEgress(cmd)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress = append(rules.Egress, cmd)
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"--physdev-is-bridged",
+		"-m", "pkttype",
+		"--pkt-type", "multicast",
+	)
+	cmd = rules.commentCommandEgress(cmd)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress6 = append(rules.Egress6, cmd)
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"--physdev-is-bridged",
+		"-m", "conntrack",
+		"--ctstate", "RELATED,ESTABLISHED",
+	)
+	cmd = rules.commentCommandEgress(cmd)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress = append(rules.Egress, cmd)
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"--physdev-is-bridged",
+		"-m", "conntrack",
+		"--ctstate", "RELATED,ESTABLISHED",
+	)
+	cmd = rules.commentCommandEgress(cmd)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress6 = append(rules.Egress6, cmd)
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-p", "icmp",
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"--physdev-is-bridged",
+		"-m", "icmp",
+		"--icmp-type", "echo-reply",
+	)
+	cmd = rules.commentCommandEgress(cmd)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress = append(rules.Egress, cmd)
+
+	// Neighbor discovery must be allowed for ipv6 to function
+	for _, icmpType := range []string{"133", "134", "135", "136"} {
+		cmd = rules.newCommand()
+		cmd = append(cmd,
+			"-p", "ipv6-icmp",
+			"-m", "physdev",
+			"--physdev-in", rules.Interface,
+			"--physdev-is-bridged",
+			"-m", "icmp6",
+			"--icmpv6-type", icmpType,
+		)
+		cmd = rules.commentCommandEgress(cmd)
+		cmd = append(cmd,
+			"-j", "ACCEPT",
+		)
+		rules.Egress6 = append(rules.Egress6, cmd)
+	}
+
+	// Unicast dhcp lease renewals are sent to the gateway
+	if gateway != "" {
+		cmd = rules.newCommand()
+		cmd = append(cmd,
+			"-d", gateway+"/32",
+			"-p", "udp",
+			"-m", "physdev",
+			"--physdev-in", rules.Interface,
+			"--physdev-is-bridged",
+			"-m", "udp",
+			"--sport", "68",
+			"--dport", "67",
+		)
+		cmd = rules.commentCommandEgress(cmd)
+		cmd = append(cmd,
+			"-j", "ACCEPT",
+		)
+		rules.Egress = append(rules.Egress, cmd)
+	}
+
+	dhcp6Dests := []string{"fe80::/10"}
+	if gateway6 != "" {
+		dhcp6Dests = append(dhcp6Dests, gateway6+"/128")
+	}
+
+	for _, dest := range dhcp6Dests {
+		cmd = rules.newCommand()
+		cmd = append(cmd,
+			"-d", dest,
+			"-p", "udp",
+			"-m", "physdev",
+			"--physdev-in", rules.Interface,
+			"--physdev-is-bridged",
+			"-m", "udp",
+			"--sport", "546",
+			"--dport", "547",
+		)
+		cmd = rules.commentCommandEgress(cmd)
+		cmd = append(cmd,
+			"-j", "ACCEPT",
+		)
+		rules.Egress6 = append(rules.Egress6, cmd)
+	}
+
+	for _, rule := range egress {
+		all4 := false
+		all6 := false
+		set4 := false
+		set6 := false
+		setName := rule.EgressSetName(false)
+		setName6 := rule.EgressSetName(true)
+
+		if setName == "" || setName6 == "" {
+			continue
+		}
+
+		for _, destIp := range rule.DestinationIps {
+			ipv6 := strings.Contains(destIp, ":")
+
+			if destIp == "0.0.0.0/0" {
+				if all4 {
+					continue
+				}
+				all4 = true
+			} else if destIp == "::/0" {
+				if all6 {
+					continue
+				}
+				all6 = true
+			} else {
+				if ipv6 {
+					if set6 {
+						continue
+					}
+					set6 = true
+				} else {
+					if set4 {
+						continue
+					}
+					set4 = true
+				}
+			}
+
+			cmd = rules.newCommand()
+
+			switch rule.Protocol {
+			case firewall.All:
+				break
+			case firewall.Icmp:
+				if ipv6 {
+					cmd = append(cmd,
+						"-p", "ipv6-icmp",
+					)
+				} else {
+					cmd = append(cmd,
+						"-p", "icmp",
+					)
+				}
+				break
+			case firewall.Tcp, firewall.Udp:
+				cmd = append(cmd,
+					"-p", rule.Protocol,
+				)
+				break
+			default:
+				continue
+			}
+
+			if destIp != "0.0.0.0/0" && destIp != "::/0" {
+				if ipv6 {
+					cmd = append(cmd,
+						"-m", "set",
+						"--match-set", setName6, "dst",
+					)
+				} else {
+					cmd = append(cmd,
+						"-m", "set",
+						"--match-set", setName, "dst",
+					)
+				}
+			}
+
+			cmd = append(cmd,
+				"-m", "physdev",
+				"--physdev-in", rules.Interface,
+				"--physdev-is-bridged",
+			)
+
+			switch rule.Protocol {
+			case firewall.Tcp, firewall.Udp:
+				cmd = append(cmd,
+					"-m", rule.Protocol,
+					"--dport", strings.Replace(rule.Port, "-", ":", 1),
+					"-m", "conntrack",
+					"--ctstate", "NEW",
+				)
+				break
+			}
+
+			cmd = rules.commentCommandEgress(cmd)
+			cmd = append(cmd,
+				"-j", "ACCEPT",
+			)
+
+			if ipv6 {
+				rules.Egress6 = append(rules.Egress6, cmd)
+			} else {
+				rules.Egress = append(rules.Egress, cmd)
+			}
+		}
+	}
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"--physdev-is-bridged",
+	)
+	cmd = rules.commentCommandEgress(cmd)
+	cmd = append(cmd,
+		"-j", "DROP",
+	)
+	rules.Egress = append(rules.Egress, cmd)
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"--physdev-is-bridged",
+	)
+	cmd = rules.commentCommandEgress(cmd)
+	cmd = append(cmd,
+		"-j", "DROP",
+	)
+	rules.Egress6 = append(rules.Egress6, cmd)
 	return
 }
 
@@ -1,9 +1,13 @@
 package iptables
 
 import (
+	"net"
+	"strings"
+
 	"github.com/pritunl/pritunl-cloud/firewall"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/sirupsen/logrus"
 )
@@ -13,8 +17,8 @@ type State struct {
 }
 
 func LoadState(nodeSelf *node.Node, instances []*instance.Instance,
-	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule) (
-	state *State) {
+	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule,
+	firewallsEgress map[string][]*firewall.Rule) (state *State) {
 
 	nodeNetworkMode := node.Self.NetworkMode
 	if nodeNetworkMode == "" {
@@ -63,6 +67,20 @@ func LoadState(nodeSelf *node.Node, instances []*instance.Instance,
 		if inst.PrivateIps6 != nil && len(inst.PrivateIps6) != 0 {
 			addr6 = inst.PrivateIps6[0]
 		}
+
+		gateway := ""
+		gateway6 := ""
+		if inst.GatewayIps != nil && len(inst.GatewayIps) != 0 {
+			gateway = strings.Split(inst.GatewayIps[0], "/")[0]
+		}
+		if inst.GatewayIps6 != nil && len(inst.GatewayIps6) != 0 {
+			gateway6 = strings.Split(inst.GatewayIps6[0], "/")[0]
+		}
+
+		natAddr6 := addr6
+		if utils.IsGlobalIp6(net.ParseIP(addr6)) {
+			natAddr6 = ""
+		}
 		if inst.PublicIps != nil && len(inst.PublicIps) != 0 {
 			pubAddr = inst.PublicIps[0]
 		}
@@ -100,7 +118,7 @@ func LoadState(nodeSelf *node.Node, instances []*instance.Instance,
 			nodeNetworkMode != node.Oracle {
 
 			rules := generateInternal(namespace, ifaceExternal,
-				true, addr, pubAddr, addr6, pubAddr6,
+				true, addr, pubAddr, natAddr6, pubAddr6,
 				oracleAddr, ingress)
 			state.Interfaces[namespace+"-"+ifaceExternal] = rules
 		}
@@ -110,14 +128,14 @@ func LoadState(nodeSelf *node.Node, instances []*instance.Instance,
 			nodeNetworkMode6 != node.Oracle {
 
 			rules := generateInternal(namespace, ifaceExternal6,
-				true, addr, pubAddr, addr6, pubAddr6,
+				true, addr, pubAddr, natAddr6, pubAddr6,
 				oracleAddr, ingress)
 			state.Interfaces[namespace+"-"+ifaceExternal6] = rules
 		}
 
 		if nodeNetworkMode == node.Oracle {
 			rules := generateInternal(namespace, oracleIface,
-				true, addr, pubAddr, addr6, pubAddr6,
+				true, addr, pubAddr, natAddr6, pubAddr6,
 				oracleAddr, ingress)
 
 			state.Interfaces[namespace+"-"+oracleIface] = rules
@@ -129,8 +147,9 @@ func LoadState(nodeSelf *node.Node, instances []*instance.Instance,
 			state.Interfaces[namespace+"-"+ifaceHost] = rules
 		}
 
-		rules := generateVirt(namespace, iface, addr, addr6,
-			!inst.SkipSourceDestCheck, ingress)
+		rules := generateVirt(namespace, iface, addr, addr6, gateway,
+			gateway6, !inst.SkipSourceDestCheck, ingress,
+			firewallsEgress[namespace])
 		state.Interfaces[namespace+"-"+iface] = rules
 	}
 
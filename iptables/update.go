@@ -217,7 +217,13 @@ func (u *Update) reload() (err error) {
 		return
 	}
 
-	err = Init(namespaces, instances, nodeFirewall, firewalls)
+	firewallsEgress, err := firewall.GetAllEgress(db, instances)
+	if err != nil {
+		return
+	}
+
+	err = Init(namespaces, instances, nodeFirewall, firewalls,
+		firewallsEgress)
 	if err != nil {
 		return
 	}
@@ -250,9 +256,11 @@ func ApplyUpdate(newState *State, namespaces []string, recover bool) {
 
 func UpdateState(nodeSelf *node.Node, instances []*instance.Instance,
 	namespaces []string, nodeFirewall []*firewall.Rule,
-	firewalls map[string][]*firewall.Rule) {
+	firewalls map[string][]*firewall.Rule,
+	firewallsEgress map[string][]*firewall.Rule) {
 
-	newState := LoadState(nodeSelf, instances, nodeFirewall, firewalls)
+	newState := LoadState(nodeSelf, instances, nodeFirewall, firewalls,
+		firewallsEgress)
 
 	ApplyUpdate(newState, namespaces, false)
 
@@ -261,9 +269,11 @@ func UpdateState(nodeSelf *node.Node, instances []*instance.Instance,
 
 func UpdateStateRecover(nodeSelf *node.Node, instances []*instance.Instance,
 	namespaces []string, nodeFirewall []*firewall.Rule,
-	firewalls map[string][]*firewall.Rule) {
+	firewalls map[string][]*firewall.Rule,
+	firewallsEgress map[string][]*firewall.Rule) {
 
-	newState := LoadState(nodeSelf, instances, nodeFirewall, firewalls)
+	newState := LoadState(nodeSelf, instances, nodeFirewall, firewalls,
+		firewallsEgress)
 
 	ApplyUpdate(newState, namespaces, true)
 
@@ -32,6 +32,8 @@ func diffRules(a, b *Rules) bool {
 		len(a.SourceDestCheck6) != len(b.SourceDestCheck6) ||
 		len(a.Ingress) != len(b.Ingress) ||
 		len(a.Ingress6) != len(b.Ingress6) ||
+		len(a.Egress) != len(b.Egress) ||
+		len(a.Egress6) != len(b.Egress6) ||
 		len(a.Holds) != len(b.Holds) ||
 		len(a.Holds6) != len(b.Holds6) {
 
@@ -58,6 +60,16 @@ func diffRules(a, b *Rules) bool {
 			return true
 		}
 	}
+	for i := range a.Egress {
+		if diffCmd(a.Egress[i], b.Egress[i]) {
+			return true
+		}
+	}
+	for i := range a.Egress6 {
+		if diffCmd(a.Egress6[i], b.Egress6[i]) {
+			return true
+		}
+	}
 	for i := range a.Holds {
 		if diffCmd(a.Holds[i], b.Holds[i]) {
 			return true
@@ -121,8 +133,9 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 		ruleComment := strings.Contains(line, "pritunl_cloud_rule")
 		holdComment := strings.Contains(line, "pritunl_cloud_hold")
 		sdcComment := strings.Contains(line, "pritunl_cloud_sdc")
+		egressComment := strings.Contains(line, "pritunl_cloud_egress")
 
-		if !ruleComment && !holdComment && !sdcComment {
+		if !ruleComment && !holdComment && !sdcComment && !egressComment {
 			continue
 		}
 
@@ -169,6 +182,35 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 					break
 				}
 			}
+		} else if egressComment {
+			if cmd[0] != "FORWARD" {
+				logrus.WithFields(logrus.Fields{
+					"iptables_rule": line,
+				}).Error("iptables: Invalid iptables egress chain")
+
+				err = &errortypes.ParseError{
+					errors.New("iptables: Invalid iptables egress chain"),
+				}
+				return
+			}
+
+			for i, item := range cmd {
+				if item == "--physdev-in" {
+					if len(cmd) < i+2 {
+						logrus.WithFields(logrus.Fields{
+							"iptables_rule": line,
+						}).Error("iptables: Invalid iptables egress interface")
+
+						err = &errortypes.ParseError{
+							errors.New(
+								"iptables: Invalid iptables egress interface"),
+						}
+						return
+					}
+					iface = cmd[i+1]
+					break
+				}
+			}
 		} else if namespace != "0" {
 			if cmd[0] != "FORWARD" {
 				logrus.WithFields(logrus.Fields{
@@ -233,6 +275,8 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 				SourceDestCheck6: [][]string{},
 				Ingress:          [][]string{},
 				Ingress6:         [][]string{},
+				Egress:           [][]string{},
+				Egress6:          [][]string{},
 				Holds:            [][]string{},
 				Holds6:           [][]string{},
 			}
@@ -251,6 +295,12 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 			} else {
 				rules.SourceDestCheck = append(rules.SourceDestCheck, cmd)
 			}
+		} else if egressComment {
+			if ipv6 {
+				rules.Egress6 = append(rules.Egress6, cmd)
+			} else {
+				rules.Egress = append(rules.Egress, cmd)
+			}
 		} else {
 			if ipv6 {
 				rules.Ingress6 = append(rules.Ingress6, cmd)
@@ -579,8 +629,8 @@ func RecoverNode() (err error) {
 }
 
 func Init(namespaces []string, instances []*instance.Instance,
-	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule) (
-	err error) {
+	nodeFirewall []*firewall.Rule, firewalls map[string][]*firewall.Rule,
+	firewallsEgress map[string][]*firewall.Rule) (err error) {
 
 	_, err = utils.ExecCombinedOutputLogged(
 		nil, "sysctl", "-w", "net.ipv6.conf.all.accept_ra=2",
@@ -663,7 +713,8 @@ func Init(namespaces []string, instances []*instance.Instance,
 
 	curState = state
 
-	UpdateState(node.Self, instances, namespaces, nodeFirewall, firewalls)
+	UpdateState(node.Self, instances, namespaces, nodeFirewall, firewalls,
+		firewallsEgress)
 
 	return
 }
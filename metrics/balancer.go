@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/node"
+)
+
+func collectBalancers(db *database.Database, w *Writer) (err error) {
+	nodeKey := node.Self.Id.Hex()
+
+	balncs, err := balancer.GetAll(db, &bson.M{
+		"states." + nodeKey: &bson.M{
+			"$exists": true,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for _, balnc := range balncs {
+		state := balnc.States[nodeKey]
+		if state == nil {
+			continue
+		}
+
+		labels := Labels{
+			"balancer":     balnc.Id.Hex(),
+			"name":         balnc.Name,
+			"organization": balnc.Organization.Hex(),
+		}
+
+		// Requests and retries are rolling totals from the balancer
+		// state and are not monotonic
+		w.Gauge("pritunl_balancer_requests",
+			"Balancer recent requests", labels, float64(state.Requests))
+		w.Gauge("pritunl_balancer_retries",
+			"Balancer recent request retries", labels,
+			float64(state.Retries))
+		w.Gauge("pritunl_balancer_websockets",
+			"Balancer open websocket connections", labels,
+			float64(state.WebSockets))
+
+		backendStates := []struct {
+			name     string
+			backends []string
+		}{
+			{"online", state.Online},
+			{"unknown_high", state.UnknownHigh},
+			{"unknown_mid", state.UnknownMid},
+			{"unknown_low", state.UnknownLow},
+			{"offline", state.Offline},
+		}
+
+		for _, backendState := range backendStates {
+			w.Gauge("pritunl_balancer_backends",
+				"Balancer backends by health state", Labels{
+					"balancer":     balnc.Id.Hex(),
+					"name":         balnc.Name,
+					"organization": balnc.Organization.Hex(),
+					"state":        backendState.name,
+				}, float64(len(backendState.backends)))
+
+			up := 0.0
+			if backendState.name == "online" {
+				up = 1
+			}
+
+			for _, backend := range backendState.backends {
+				w.Gauge("pritunl_balancer_backend_up",
+					"Balancer backend health check online", Labels{
+						"balancer":     balnc.Id.Hex(),
+						"name":         balnc.Name,
+						"organization": balnc.Organization.Hex(),
+						"backend":      backend,
+						"state":        backendState.name,
+					}, up)
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/pritunl/pritunl-cloud/node"
+)
+
+func collectHost(w *Writer) {
+	nde := node.Self
+	labels := Labels{
+		"node": nde.Id.Hex(),
+		"name": nde.Name,
+	}
+
+	w.Gauge("pritunl_node_info",
+		"Node information", Labels{
+			"node":    nde.Id.Hex(),
+			"name":    nde.Name,
+			"version": nde.SoftwareVersion,
+		}, 1)
+	w.Gauge("pritunl_node_load1",
+		"Node one minute load average percent", labels, nde.Load1)
+	w.Gauge("pritunl_node_load5",
+		"Node five minute load average percent", labels, nde.Load5)
+	w.Gauge("pritunl_node_load15",
+		"Node fifteen minute load average percent", labels, nde.Load15)
+	w.Gauge("pritunl_node_memory_used_percent",
+		"Node memory usage percent", labels, nde.Memory)
+	w.Gauge("pritunl_node_hugepages_used_percent",
+		"Node hugepages usage percent", labels, nde.HugePagesUsed)
+	w.Gauge("pritunl_node_cpu_units",
+		"Node cpu units", labels, float64(nde.CpuUnits))
+	w.Gauge("pritunl_node_cpu_units_reserved",
+		"Node cpu units reserved by instances", labels,
+		float64(nde.CpuUnitsRes))
+	w.Gauge("pritunl_node_memory_units",
+		"Node memory units in gigabytes", labels, nde.MemoryUnits)
+	w.Gauge("pritunl_node_memory_units_reserved",
+		"Node memory units reserved by instances in gigabytes", labels,
+		nde.MemoryUnitsRes)
+	w.Gauge("pritunl_node_requests_per_minute",
+		"Node web requests in the last minute", labels,
+		float64(nde.RequestsMin))
+}
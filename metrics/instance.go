@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+const clockTicks = 100
+
+type processStats struct {
+	CpuSeconds  float64
+	MemoryBytes float64
+}
+
+type ifaceStats struct {
+	RxBytes   float64
+	TxBytes   float64
+	RxPackets float64
+	TxPackets float64
+	RxDropped float64
+	TxDropped float64
+}
+
+func getProcessStats(inst *instance.Instance) (
+	stats *processStats, err error) {
+
+	pidData, err := ioutil.ReadFile(paths.GetPidPath(inst.Id))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "metrics: Failed to read pid file"),
+		}
+		return
+	}
+
+	pid := strings.TrimSpace(string(pidData))
+
+	statData, err := ioutil.ReadFile(fmt.Sprintf("/proc/%s/stat", pid))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "metrics: Failed to read process stat"),
+		}
+		return
+	}
+
+	// Process name may contain spaces, fields are parsed after the last
+	// closing parenthesis starting with the state field
+	statStr := string(statData)
+	statStr = statStr[strings.LastIndex(statStr, ")")+1:]
+	fields := strings.Fields(statStr)
+	if len(fields) < 13 {
+		err = &errortypes.ParseError{
+			errors.New("metrics: Invalid process stat"),
+		}
+		return
+	}
+
+	utime, _ := strconv.ParseFloat(fields[11], 64)
+	stime, _ := strconv.ParseFloat(fields[12], 64)
+
+	stats = &processStats{
+		CpuSeconds: (utime + stime) / clockTicks,
+	}
+
+	statusData, err := ioutil.ReadFile(
+		fmt.Sprintf("/proc/%s/status", pid))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "metrics: Failed to read process status"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(statusData), "\n") {
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+
+		lineSpl := strings.Fields(line)
+		if len(lineSpl) < 2 {
+			break
+		}
+
+		rss, _ := strconv.ParseFloat(lineSpl[1], 64)
+		stats.MemoryBytes = rss * 1024
+		break
+	}
+
+	return
+}
+
+func readIfaceStat(iface, name string) float64 {
+	data, err := ioutil.ReadFile(path.Join(
+		"/sys/class/net", iface, "statistics", name))
+	if err != nil {
+		return 0
+	}
+
+	val, _ := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	return val
+}
+
+func getIfaceStats(iface string) (stats *ifaceStats) {
+	_, err := ioutil.ReadDir(path.Join("/sys/class/net", iface))
+	if err != nil {
+		return
+	}
+
+	stats = &ifaceStats{
+		RxBytes:   readIfaceStat(iface, "rx_bytes"),
+		TxBytes:   readIfaceStat(iface, "tx_bytes"),
+		RxPackets: readIfaceStat(iface, "rx_packets"),
+		TxPackets: readIfaceStat(iface, "tx_packets"),
+		RxDropped: readIfaceStat(iface, "rx_dropped"),
+		TxDropped: readIfaceStat(iface, "tx_dropped"),
+	}
+
+	return
+}
+
+func collectInstances(db *database.Database, w *Writer) (err error) {
+	insts, err := instance.GetAll(db, &bson.M{
+		"node": node.Self.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	for _, inst := range insts {
+		labels := Labels{
+			"instance":     inst.Id.Hex(),
+			"name":         inst.Name,
+			"organization": inst.Organization.Hex(),
+		}
+
+		running := 0.0
+		if inst.VmState == vm.Running {
+			running = 1
+		}
+
+		w.Gauge("pritunl_instance_running",
+			"Instance virtual machine running", labels, running)
+		w.Gauge("pritunl_instance_processors",
+			"Instance configured processors", labels,
+			float64(inst.Processors))
+		w.Gauge("pritunl_instance_memory_configured_bytes",
+			"Instance configured memory in bytes", labels,
+			float64(inst.Memory)*1048576)
+
+		if inst.VmState != vm.Running {
+			continue
+		}
+
+		procStats, e := getProcessStats(inst)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       e,
+			}).Warn("metrics: Failed to read instance process stats")
+		} else {
+			w.Counter("pritunl_instance_cpu_seconds_total",
+				"Instance process cpu time in seconds", labels,
+				procStats.CpuSeconds)
+			w.Gauge("pritunl_instance_memory_resident_bytes",
+				"Instance process resident memory in bytes", labels,
+				procStats.MemoryBytes)
+		}
+
+		blockStats, e := qmp.GetBlockStats(inst.Id)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       e,
+			}).Warn("metrics: Failed to query instance block stats")
+		} else {
+			for _, stat := range blockStats {
+				diskLabels := Labels{
+					"instance":     inst.Id.Hex(),
+					"name":         inst.Name,
+					"organization": inst.Organization.Hex(),
+					"disk":         stat.Disk.Hex(),
+				}
+
+				w.Counter("pritunl_instance_disk_read_bytes_total",
+					"Instance disk bytes read", diskLabels,
+					float64(stat.ReadBytes))
+				w.Counter("pritunl_instance_disk_write_bytes_total",
+					"Instance disk bytes written", diskLabels,
+					float64(stat.WriteBytes))
+				w.Counter("pritunl_instance_disk_read_operations_total",
+					"Instance disk read operations", diskLabels,
+					float64(stat.ReadOperations))
+				w.Counter("pritunl_instance_disk_write_operations_total",
+					"Instance disk write operations", diskLabels,
+					float64(stat.WriteOperations))
+				w.Counter("pritunl_instance_disk_flush_operations_total",
+					"Instance disk flush operations", diskLabels,
+					float64(stat.FlushOperations))
+				w.Counter("pritunl_instance_disk_read_seconds_total",
+					"Instance disk time spent reading in seconds",
+					diskLabels, float64(stat.ReadTimeNs)/1e9)
+				w.Counter("pritunl_instance_disk_write_seconds_total",
+					"Instance disk time spent writing in seconds",
+					diskLabels, float64(stat.WriteTimeNs)/1e9)
+			}
+		}
+
+		// Tap interface counters are from the host side, traffic received
+		// on the tap interface was transmitted by the instance. Adapter
+		// interfaces are indexed sequentially and collected until an
+		// interface does not exist.
+		for i := 0; ; i++ {
+			iface := vm.GetIface(inst.Id, i)
+			ifStats := getIfaceStats(iface)
+			if ifStats == nil {
+				break
+			}
+
+			ifaceLabels := Labels{
+				"instance":     inst.Id.Hex(),
+				"name":         inst.Name,
+				"organization": inst.Organization.Hex(),
+				"interface":    iface,
+			}
+
+			w.Counter("pritunl_instance_network_receive_bytes_total",
+				"Instance network bytes received", ifaceLabels,
+				ifStats.TxBytes)
+			w.Counter("pritunl_instance_network_transmit_bytes_total",
+				"Instance network bytes transmitted", ifaceLabels,
+				ifStats.RxBytes)
+			w.Counter("pritunl_instance_network_receive_packets_total",
+				"Instance network packets received", ifaceLabels,
+				ifStats.TxPackets)
+			w.Counter("pritunl_instance_network_transmit_packets_total",
+				"Instance network packets transmitted", ifaceLabels,
+				ifStats.RxPackets)
+			w.Counter("pritunl_instance_network_receive_dropped_total",
+				"Instance network received packets dropped", ifaceLabels,
+				ifStats.TxDropped)
+			w.Counter("pritunl_instance_network_transmit_dropped_total",
+				"Instance network transmitted packets dropped",
+				ifaceLabels, ifStats.RxDropped)
+		}
+	}
+
+	return
+}
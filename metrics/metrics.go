@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/constants"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type server struct {
+	addr   string
+	port   int
+	server *http.Server
+}
+
+func authorized(r *http.Request) bool {
+	token := node.Self.MetricsToken
+	if token == "" {
+		return false
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	reqToken := strings.TrimSpace(auth[7:])
+
+	return subtle.ConstantTimeCompare(
+		[]byte(reqToken), []byte(token)) == 1
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/metrics" {
+		utils.WriteStatus(w, 404)
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		utils.WriteStatus(w, 405)
+		return
+	}
+
+	if !authorized(r) {
+		utils.WriteStatus(w, 401)
+		return
+	}
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	wr := &Writer{}
+
+	collectHost(wr)
+	collectTasks(wr)
+
+	err := collectInstances(db, wr)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("metrics: Failed to collect instance metrics")
+	}
+
+	err = collectBalancers(db, wr)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("metrics: Failed to collect balancer metrics")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write(wr.Bytes())
+}
+
+func (s *server) start() {
+	logrus.WithFields(logrus.Fields{
+		"address": s.addr,
+		"port":    s.port,
+	}).Info("metrics: Starting metrics server")
+
+	err := s.server.ListenAndServe()
+	if err != nil {
+		if err == http.ErrServerClosed {
+			err = nil
+		} else {
+			err = &errortypes.UnknownError{
+				errors.Wrap(err, "metrics: Server listen failed"),
+			}
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("metrics: Metrics server error")
+		}
+	}
+}
+
+func (s *server) stop() {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		5*time.Second,
+	)
+	defer cancel()
+
+	s.server.Shutdown(ctx)
+	s.server.Close()
+}
+
+func newServer(addr string, port int) *server {
+	return &server{
+		addr: addr,
+		port: port,
+		server: &http.Server{
+			Addr:           net.JoinHostPort(addr, strconv.Itoa(port)),
+			ReadTimeout:    1 * time.Minute,
+			WriteTimeout:   1 * time.Minute,
+			IdleTimeout:    1 * time.Minute,
+			MaxHeaderBytes: 8192,
+			Handler:        http.HandlerFunc(handler),
+		},
+	}
+}
+
+func watch() {
+	var srv *server
+
+	for {
+		time.Sleep(1 * time.Second)
+
+		if constants.Shutdown {
+			if srv != nil {
+				srv.stop()
+			}
+			return
+		}
+
+		// The server only listens on the node internal address and is not
+		// started without a token
+		port := node.Self.MetricsPort
+		addr := node.Self.GetInternalAddr()
+		if port == 0 || node.Self.MetricsToken == "" {
+			addr = ""
+		}
+
+		if srv != nil && srv.port == port && srv.addr == addr {
+			continue
+		}
+
+		if srv != nil {
+			srv.stop()
+			srv = nil
+		}
+
+		if addr == "" {
+			continue
+		}
+
+		srv = newServer(addr, port)
+		go srv.start()
+	}
+}
+
+func Init() {
+	go watch()
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"github.com/pritunl/pritunl-cloud/task"
+)
+
+func collectTasks(w *Writer) {
+	for _, stat := range task.GetStats() {
+		labels := Labels{
+			"task": stat.Name,
+		}
+
+		w.Counter("pritunl_task_jobs_total",
+			"Task jobs run on this node by outcome", Labels{
+				"task":    stat.Name,
+				"outcome": task.Finished,
+			}, float64(stat.Finished))
+		w.Counter("pritunl_task_jobs_total",
+			"Task jobs run on this node by outcome", Labels{
+				"task":    stat.Name,
+				"outcome": task.Failed,
+			}, float64(stat.Failed))
+		w.Counter("pritunl_task_jobs_total",
+			"Task jobs run on this node by outcome", Labels{
+				"task":    stat.Name,
+				"outcome": "skipped",
+			}, float64(stat.Skipped))
+		w.Counter("pritunl_task_duration_seconds_total",
+			"Task job run time on this node in seconds", labels,
+			stat.Duration.Seconds())
+
+		lastRun := 0.0
+		if !stat.LastRun.IsZero() {
+			lastRun = float64(stat.LastRun.Unix())
+		}
+		w.Gauge("pritunl_task_last_run_timestamp_seconds",
+			"Task last job completion time on this node", labels, lastRun)
+	}
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+var labelEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\n", `\n`,
+	`"`, `\"`,
+)
+
+type Labels map[string]string
+
+type family struct {
+	name string
+	typ  string
+	help string
+	buf  bytes.Buffer
+}
+
+// Writer formats samples in the Prometheus text exposition format. Samples
+// are grouped by metric name so collectors can emit them in any order.
+type Writer struct {
+	families    []*family
+	familiesMap map[string]*family
+}
+
+func (w *Writer) family(name, typ, help string) (fam *family) {
+	if w.familiesMap == nil {
+		w.familiesMap = map[string]*family{}
+	}
+
+	fam = w.familiesMap[name]
+	if fam == nil {
+		fam = &family{
+			name: name,
+			typ:  typ,
+			help: help,
+		}
+		w.familiesMap[name] = fam
+		w.families = append(w.families, fam)
+	}
+
+	return
+}
+
+func (f *family) sample(labels Labels, val float64) {
+	f.buf.WriteString(f.name)
+
+	if len(labels) > 0 {
+		keys := []string{}
+		for key := range labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		f.buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				f.buf.WriteByte(',')
+			}
+			f.buf.WriteString(key)
+			f.buf.WriteString(`="`)
+			f.buf.WriteString(labelEscaper.Replace(labels[key]))
+			f.buf.WriteByte('"')
+		}
+		f.buf.WriteByte('}')
+	}
+
+	f.buf.WriteByte(' ')
+	switch {
+	case math.IsInf(val, 1):
+		f.buf.WriteString("+Inf")
+		break
+	case math.IsInf(val, -1):
+		f.buf.WriteString("-Inf")
+		break
+	case math.IsNaN(val):
+		f.buf.WriteString("NaN")
+		break
+	default:
+		f.buf.WriteString(strconv.FormatFloat(val, 'g', -1, 64))
+	}
+	f.buf.WriteByte('\n')
+}
+
+func (w *Writer) Gauge(name, help string, labels Labels, val float64) {
+	w.family(name, Gauge, help).sample(labels, val)
+}
+
+func (w *Writer) Counter(name, help string, labels Labels, val float64) {
+	w.family(name, Counter, help).sample(labels, val)
+}
+
+func (w *Writer) Bytes() []byte {
+	buf := bytes.Buffer{}
+
+	for _, fam := range w.families {
+		buf.WriteString(fmt.Sprintf("# HELP %s %s\n", fam.name, fam.help))
+		buf.WriteString(fmt.Sprintf("# TYPE %s %s\n", fam.name, fam.typ))
+		buf.Write(fam.buf.Bytes())
+	}
+
+	return buf.Bytes()
+}
@@ -26,6 +26,14 @@ Disallow: /
 `
 
 func Limiter(c *gin.Context) {
+	if c.Request.Method == "PUT" &&
+		c.FullPath() == "/image_upload/:upload_id" {
+
+		c.Request.Body = http.MaxBytesReader(
+			c.Writer, c.Request.Body, 128000000)
+		return
+	}
+
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 1000000)
 }
 
@@ -4,9 +4,12 @@ import (
 	"fmt"
 
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/block"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/floatingip"
 	"github.com/pritunl/pritunl-cloud/interfaces"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
 
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/vm"
@@ -20,6 +23,8 @@ func (n *NetConf) Address(db *database.Database) (err error) {
 	}
 
 	n.VlanId = vc.VpcId
+	n.BandwidthIngress, n.BandwidthEgress = vc.GetBandwidth(
+		n.Virt.BandwidthIngress, n.Virt.BandwidthEgress)
 
 	vcNet, err := vc.GetNetwork()
 	if err != nil {
@@ -39,6 +44,7 @@ func (n *NetConf) Address(db *database.Database) (err error) {
 
 	n.InternalAddr6 = vc.GetIp6(addr)
 	n.InternalGatewayAddr6 = vc.GetIp6(gatewayAddr)
+	n.InternalRouted6 = utils.IsGlobalIp6(n.InternalAddr6)
 
 	n.ExternalMacAddr = vm.GetMacAddrExternal(n.Virt.Id, vc.Id)
 	n.InternalMacAddr = vm.GetMacAddrInternal(n.Virt.Id, vc.Id)
@@ -59,6 +65,31 @@ func (n *NetConf) Address(db *database.Database) (err error) {
 			return
 		}
 
+		fip, e := floatingip.GetInstance(db, n.Virt.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if fip != nil && fip.GetIp() != nil {
+			for _, blckAttch := range node.Self.Blocks {
+				if blckAttch.Block != fip.Block {
+					continue
+				}
+
+				fipBlck, e := block.Get(db, fip.Block)
+				if e != nil {
+					err = e
+					return
+				}
+
+				blck = fipBlck
+				staticAddr = fip.GetIp()
+				externalIface = blckAttch.Interface
+				break
+			}
+		}
+
 		n.PhysicalExternalIface = externalIface
 
 		staticGateway := blck.GetGateway()
@@ -0,0 +1,19 @@
+package netconf
+
+import (
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/tc"
+)
+
+func (n *NetConf) Bandwidth(db *database.Database) (err error) {
+	err = tc.SetLimits(n.Namespace, n.VirtIface,
+		n.BandwidthIngress, n.BandwidthEgress)
+	if err != nil {
+		return
+	}
+
+	store.SetBandwidth(n.Virt.Id, n.BandwidthIngress, n.BandwidthEgress)
+
+	return
+}
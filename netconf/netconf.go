@@ -72,11 +72,15 @@ type NetConf struct {
 
 	VirtIfaceMtu string
 
+	BandwidthIngress int
+	BandwidthEgress  int
+
 	InternalAddr            net.IP
 	InternalGatewayAddr     net.IP
 	InternalGatewayAddrCidr string
 	InternalAddr6           net.IP
 	InternalGatewayAddr6    net.IP
+	InternalRouted6         bool
 
 	ExternalAddrCidr     string
 	ExternalGatewayAddr  net.IP
@@ -159,6 +163,11 @@ func (n *NetConf) Init(db *database.Database) (err error) {
 		return
 	}
 
+	err = n.Bandwidth(db)
+	if err != nil {
+		return
+	}
+
 	return
 }
 
@@ -39,6 +39,19 @@ func (n *NetConf) spaceSysctl(db *database.Database) (err error) {
 		if err != nil {
 			return
 		}
+
+		if n.InternalRouted6 {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "netns", "exec", n.Namespace,
+				"sysctl", "-w",
+				fmt.Sprintf("net.ipv6.conf.%s.proxy_ndp=1",
+					n.SpaceExternalIface6),
+			)
+			if err != nil {
+				return
+			}
+		}
 	}
 
 	return
@@ -117,6 +130,30 @@ func (n *NetConf) spaceForward(db *database.Database) (err error) {
 	return
 }
 
+// spaceProxy6 answers neighbor solicitations for the instance address on
+// the external interface, VPC addresses from a routed IPv6 network are
+// forwarded without NAT.
+func (n *NetConf) spaceProxy6(db *database.Database) (err error) {
+	if !n.InternalRouted6 || n.NetworkMode6 == node.Disabled ||
+		n.NetworkMode6 == node.Oracle {
+
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", n.Namespace,
+		"ip", "-6", "neigh",
+		"add", "proxy", n.InternalAddr6.String(),
+		"dev", n.SpaceExternalIface6,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func (n *NetConf) spaceVirt(db *database.Database) (err error) {
 	_, err = utils.ExecCombinedOutputLogged(
 		[]string{"File exists"},
@@ -187,6 +224,11 @@ func (n *NetConf) Space(db *database.Database) (err error) {
 		return
 	}
 
+	err = n.spaceProxy6(db)
+	if err != nil {
+		return
+	}
+
 	err = n.spaceVirt(db)
 	if err != nil {
 		return
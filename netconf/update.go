@@ -0,0 +1,104 @@
+package netconf
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/iproute"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateExternal replaces the static external address of a running
+// instance namespace, used when a floating IP is attached or detached.
+func (n *NetConf) UpdateExternal(db *database.Database) (err error) {
+	err = n.Iface(db)
+	if err != nil {
+		return
+	}
+
+	err = n.Address(db)
+	if err != nil {
+		return
+	}
+
+	if n.NetworkMode != node.Static {
+		return
+	}
+
+	externalAddr, _, err := net.ParseCIDR(n.ExternalAddrCidr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "netconf: Failed to parse external address"),
+		}
+		return
+	}
+
+	address, _, err := iproute.AddressGetIface(
+		n.Namespace, n.SpaceExternalIface)
+	if err != nil {
+		return
+	}
+
+	if address != nil && address.Local == externalAddr.String() {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id":   n.Virt.Id.Hex(),
+		"net_namespace": n.Namespace,
+		"address":       externalAddr.String(),
+	}).Info("netconf: Updating instance external address")
+
+	if address != nil {
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"Cannot assign requested address"},
+			"ip", "netns", "exec", n.Namespace,
+			"ip", "addr",
+			"del", fmt.Sprintf("%s/%d", address.Local, address.Prefix),
+			"dev", n.SpaceExternalIface,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", n.Namespace,
+		"ip", "addr",
+		"add", n.ExternalAddrCidr,
+		"dev", n.SpaceExternalIface,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", n.Namespace,
+		"ip", "route",
+		"replace", "default",
+		"via", n.ExternalGatewayAddr.String(),
+	)
+	if err != nil {
+		return
+	}
+
+	_, _ = utils.ExecCombinedOutput(
+		"",
+		"ip", "netns", "exec", n.Namespace,
+		"arping", "-U", "-c", "2",
+		"-I", n.SpaceExternalIface,
+		externalAddr.String(),
+	)
+
+	store.RemAddress(n.Virt.Id)
+
+	return
+}
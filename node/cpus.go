@@ -0,0 +1,276 @@
+package node
+
+import (
+	"sort"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type CpuAllocation struct {
+	Instance primitive.ObjectID `bson:"instance" json:"instance"`
+	Numa     int                `bson:"numa" json:"numa"`
+	Cpus     []int              `bson:"cpus" json:"cpus"`
+}
+
+func (n *Node) validateDedicatedCpus() (errData *errortypes.ErrorData) {
+	if n.DedicatedCpus == nil {
+		n.DedicatedCpus = []int{}
+		return
+	}
+
+	hostCpus := set.NewSet()
+	for _, nd := range n.NumaNodes {
+		for _, cpu := range nd.Cpus {
+			hostCpus.Add(cpu)
+		}
+	}
+
+	cpus := set.NewSet()
+	dedicatedCpus := []int{}
+	for _, cpu := range n.DedicatedCpus {
+		if cpus.Contains(cpu) {
+			continue
+		}
+		cpus.Add(cpu)
+
+		if cpu < 0 || (hostCpus.Len() > 0 && !hostCpus.Contains(cpu)) {
+			errData = &errortypes.ErrorData{
+				Error:   "node_dedicated_cpus_invalid",
+				Message: "Dedicated CPU does not exist on node",
+			}
+			return
+		}
+
+		dedicatedCpus = append(dedicatedCpus, cpu)
+	}
+
+	if hostCpus.Len() > 0 && len(dedicatedCpus) >= hostCpus.Len() {
+		errData = &errortypes.ErrorData{
+			Error:   "node_dedicated_cpus_host",
+			Message: "At least one CPU must remain available to the host",
+		}
+		return
+	}
+
+	sort.Ints(dedicatedCpus)
+	n.DedicatedCpus = dedicatedCpus
+
+	return
+}
+
+// GetDedicatedNuma returns the dedicated cpu pool grouped by numa node,
+// cpus without a known numa node are grouped under -1.
+func (n *Node) GetDedicatedNuma() (groups map[int][]int) {
+	groups = map[int][]int{}
+
+	cpuNuma := map[int]int{}
+	for _, nd := range n.NumaNodes {
+		for _, cpu := range nd.Cpus {
+			cpuNuma[cpu] = nd.Id
+		}
+	}
+
+	for _, cpu := range n.DedicatedCpus {
+		numaId, ok := cpuNuma[cpu]
+		if !ok {
+			numaId = -1
+		}
+
+		groups[numaId] = append(groups[numaId], cpu)
+	}
+
+	return
+}
+
+// HasDedicatedCpus checks if a single numa node has enough free dedicated
+// cpus for the instance. Cpus allocated to the instance are counted as free.
+func (n *Node) HasDedicatedCpus(instId primitive.ObjectID, count int) bool {
+	used := set.NewSet()
+	for _, alloc := range n.CpuAllocations {
+		if alloc.Instance == instId {
+			continue
+		}
+
+		for _, cpu := range alloc.Cpus {
+			used.Add(cpu)
+		}
+	}
+
+	for _, cpus := range n.GetDedicatedNuma() {
+		free := 0
+		for _, cpu := range cpus {
+			if !used.Contains(cpu) {
+				free += 1
+			}
+		}
+
+		if free >= count {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *Node) GetCpuAllocation(
+	instId primitive.ObjectID) *CpuAllocation {
+
+	for _, alloc := range n.CpuAllocations {
+		if alloc.Instance == instId {
+			return alloc
+		}
+	}
+
+	return nil
+}
+
+// AllocateCpus reserves dedicated cpus from a single numa node for the
+// instance. An existing allocation is reused when it still matches the
+// requested count and pool. The allocation is pushed only if none of the
+// cpus were claimed concurrently.
+func (n *Node) AllocateCpus(db *database.Database, instId primitive.ObjectID,
+	count int) (alloc *CpuAllocation, err error) {
+
+	coll := db.Nodes()
+
+	for i := 0; i < 10; i++ {
+		nde, e := Get(db, n.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		pool := set.NewSet()
+		for _, cpu := range nde.DedicatedCpus {
+			pool.Add(cpu)
+		}
+
+		curAlloc := nde.GetCpuAllocation(instId)
+		if curAlloc != nil {
+			valid := len(curAlloc.Cpus) == count
+			for _, cpu := range curAlloc.Cpus {
+				if !pool.Contains(cpu) {
+					valid = false
+					break
+				}
+			}
+
+			if valid {
+				alloc = curAlloc
+				return
+			}
+
+			err = n.ReleaseCpus(db, instId)
+			if err != nil {
+				return
+			}
+
+			continue
+		}
+
+		used := set.NewSet()
+		for _, a := range nde.CpuAllocations {
+			for _, cpu := range a.Cpus {
+				used.Add(cpu)
+			}
+		}
+
+		groups := nde.GetDedicatedNuma()
+		numaIds := []int{}
+		for numaId := range groups {
+			numaIds = append(numaIds, numaId)
+		}
+		sort.Ints(numaIds)
+
+		var best []int
+		bestNuma := 0
+		for _, numaId := range numaIds {
+			free := []int{}
+			for _, cpu := range groups[numaId] {
+				if !used.Contains(cpu) {
+					free = append(free, cpu)
+				}
+			}
+
+			if len(free) < count {
+				continue
+			}
+
+			if best == nil || len(free) < len(best) {
+				best = free
+				bestNuma = numaId
+			}
+		}
+
+		if best == nil {
+			err = &errortypes.NotFoundError{
+				errors.New("node: Not enough dedicated cpus available"),
+			}
+			return
+		}
+
+		newAlloc := &CpuAllocation{
+			Instance: instId,
+			Numa:     bestNuma,
+			Cpus:     best[:count],
+		}
+
+		resp, e := coll.UpdateOne(db, &bson.M{
+			"_id": n.Id,
+			"cpu_allocations.cpus": &bson.M{
+				"$nin": newAlloc.Cpus,
+			},
+			"cpu_allocations.instance": &bson.M{
+				"$ne": instId,
+			},
+		}, &bson.M{
+			"$push": &bson.M{
+				"cpu_allocations": newAlloc,
+			},
+		})
+		if e != nil {
+			err = database.ParseError(e)
+			return
+		}
+
+		if resp.MatchedCount > 0 {
+			alloc = newAlloc
+			return
+		}
+	}
+
+	err = &errortypes.DatabaseError{
+		errors.New("node: Failed to allocate dedicated cpus"),
+	}
+	return
+}
+
+// ReleaseCpus removes the instance dedicated cpu allocation. Allocations are
+// released when the virtual machine stops so stopped instances do not hold
+// cpus from the pool.
+func (n *Node) ReleaseCpus(db *database.Database,
+	instId primitive.ObjectID) (err error) {
+
+	coll := db.Nodes()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id": n.Id,
+	}, &bson.M{
+		"$pull": &bson.M{
+			"cpu_allocations": &bson.M{
+				"instance": instId,
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
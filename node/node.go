@@ -27,6 +27,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/iso"
+	"github.com/pritunl/pritunl-cloud/numa"
 	"github.com/pritunl/pritunl-cloud/pci"
 	"github.com/pritunl/pritunl-cloud/render"
 	"github.com/pritunl/pritunl-cloud/usb"
@@ -50,6 +51,8 @@ type Node struct {
 	Port                 int                  `bson:"port" json:"port"`
 	NoRedirectServer     bool                 `bson:"no_redirect_server" json:"no_redirect_server"`
 	Protocol             string               `bson:"protocol" json:"protocol"`
+	MetricsPort          int                  `bson:"metrics_port" json:"metrics_port"`
+	MetricsToken         string               `bson:"metrics_token" json:"metrics_token"`
 	Hypervisor           string               `bson:"hypervisor" json:"hypervisor"`
 	Vga                  string               `bson:"vga" json:"vga"`
 	VgaRender            string               `bson:"vga_render" json:"vga_render"`
@@ -94,6 +97,9 @@ type Node struct {
 	PciDevices           []*pci.Device        `bson:"pci_devices" json:"pci_devices"`
 	Hugepages            bool                 `bson:"hugepages" json:"hugepages"`
 	HugepagesSize        int                  `bson:"hugepages_size" json:"hugepages_size"`
+	DedicatedCpus        []int                `bson:"dedicated_cpus" json:"dedicated_cpus"`
+	CpuAllocations       []*CpuAllocation     `bson:"cpu_allocations" json:"cpu_allocations"`
+	NumaNodes            []*numa.Node         `bson:"numa_nodes" json:"numa_nodes"`
 	Firewall             bool                 `bson:"firewall" json:"firewall"`
 	NetworkRoles         []string             `bson:"network_roles" json:"network_roles"`
 	Memory               float64              `bson:"memory" json:"memory"`
@@ -142,6 +148,8 @@ func (n *Node) Copy() *Node {
 		Port:                 n.Port,
 		NoRedirectServer:     n.NoRedirectServer,
 		Protocol:             n.Protocol,
+		MetricsPort:          n.MetricsPort,
+		MetricsToken:         n.MetricsToken,
 		Hypervisor:           n.Hypervisor,
 		Vga:                  n.Vga,
 		VgaRender:            n.VgaRender,
@@ -185,6 +193,9 @@ func (n *Node) Copy() *Node {
 		PciDevices:           n.PciDevices,
 		Hugepages:            n.Hugepages,
 		HugepagesSize:        n.HugepagesSize,
+		DedicatedCpus:        n.DedicatedCpus,
+		CpuAllocations:       n.CpuAllocations,
+		NumaNodes:            n.NumaNodes,
 		Firewall:             n.Firewall,
 		NetworkRoles:         n.NetworkRoles,
 		Memory:               n.Memory,
@@ -244,6 +255,28 @@ func (n *Node) GetTempPath() string {
 	return n.TempPath
 }
 
+// GetMigrateAddr returns the internal address used for migrations. The
+// qemu migration and nbd disk streams are not encrypted and are only sent
+// over the internal network, public addresses are never used.
+func (n *Node) GetMigrateAddr() string {
+	return n.GetInternalAddr()
+}
+
+// GetInternalAddr returns the first private address of the node internal
+// interfaces or an empty string if none is available.
+func (n *Node) GetInternalAddr() string {
+	if n.PrivateIps != nil {
+		for _, iface := range n.InternalInterfaces {
+			addr := n.PrivateIps[iface]
+			if addr != "" {
+				return addr
+			}
+		}
+	}
+
+	return ""
+}
+
 func (n *Node) GetDatacenter(db *database.Database) (
 	dcId primitive.ObjectID, err error) {
 
@@ -465,6 +498,17 @@ func (n *Node) Validate(db *database.Database) (
 		return
 	}
 
+	if n.MetricsPort != 0 && (n.MetricsPort < 1 || n.MetricsPort > 65535 ||
+		n.MetricsPort == n.Port || n.MetricsPort == 80 ||
+		n.MetricsPort == 443) {
+
+		errData = &errortypes.ErrorData{
+			Error:   "node_metrics_port_invalid",
+			Message: "Invalid node metrics server port",
+		}
+		return
+	}
+
 	if n.Certificates == nil || n.Protocol != "https" {
 		n.Certificates = []primitive.ObjectID{}
 	}
@@ -675,6 +719,11 @@ func (n *Node) Validate(db *database.Database) (
 		n.HostNat = false
 	}
 
+	errData = n.validateDedicatedCpus()
+	if errData != nil {
+		return
+	}
+
 	if n.OracleHostRoute || n.NetworkMode == Oracle ||
 		n.NetworkMode6 == Oracle {
 
@@ -950,6 +999,7 @@ func (n *Node) update(db *database.Database) (err error) {
 				"available_vpcs":       n.AvailableVpcs,
 				"default_interface":    n.DefaultInterface,
 				"available_drives":     n.AvailableDrives,
+				"numa_nodes":           n.NumaNodes,
 			},
 		},
 		opts,
@@ -967,6 +1017,8 @@ func (n *Node) update(db *database.Database) (err error) {
 	n.Port = nde.Port
 	n.NoRedirectServer = nde.NoRedirectServer
 	n.Protocol = nde.Protocol
+	n.MetricsPort = nde.MetricsPort
+	n.MetricsToken = nde.MetricsToken
 	n.Hypervisor = nde.Hypervisor
 	n.Vga = nde.Vga
 	n.VgaRender = nde.VgaRender
@@ -1000,6 +1052,8 @@ func (n *Node) update(db *database.Database) (err error) {
 	n.PciPassthrough = nde.PciPassthrough
 	n.Hugepages = nde.Hugepages
 	n.HugepagesSize = nde.HugepagesSize
+	n.DedicatedCpus = nde.DedicatedCpus
+	n.CpuAllocations = nde.CpuAllocations
 	n.Firewall = nde.Firewall
 	n.NetworkRoles = nde.NetworkRoles
 	n.VirtPath = nde.VirtPath
@@ -1159,6 +1213,19 @@ func (n *Node) sync() {
 	}
 	n.AvailableRenders = renders
 
+	numaNodes, err := numa.GetNodes()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("node: Failed to get numa nodes")
+	}
+
+	if numaNodes != nil {
+		n.NumaNodes = numaNodes
+	} else {
+		n.NumaNodes = []*numa.Node{}
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		err = &errortypes.ReadError{
@@ -1353,6 +1420,12 @@ func (n *Node) Init() (err error) {
 		bsonSet["external_interfaces"] = ifaces
 	}
 
+	// Database upgrade
+	if n.CpuAllocations == nil {
+		n.CpuAllocations = []*CpuAllocation{}
+		bsonSet["cpu_allocations"] = n.CpuAllocations
+	}
+
 	opts := &options.UpdateOptions{}
 	opts.SetUpsert(true)
 
@@ -0,0 +1,425 @@
+package node
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/drive"
+	"github.com/pritunl/pritunl-cloud/pci"
+	"github.com/pritunl/pritunl-cloud/usb"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+type PlacementRequest struct {
+	Processors    int
+	Memory        int
+	OracleSubnet  string
+	UsbDevices    []*usb.Device
+	PciDevices    []*pci.Device
+	DriveDevices  []*drive.Device
+	AntiAffinity  []string
+	DedicatedCpus bool
+}
+
+type placementInstance struct {
+	Id            primitive.ObjectID `bson:"_id"`
+	Node          primitive.ObjectID `bson:"node"`
+	Processors    int                `bson:"processors"`
+	Memory        int                `bson:"memory"`
+	PciDevices    []*pci.Device      `bson:"pci_devices"`
+	DriveDevices  []*drive.Device    `bson:"drive_devices"`
+	AntiAffinity  []string           `bson:"anti_affinity"`
+	DedicatedCpus bool               `bson:"dedicated_cpus"`
+}
+
+type placementNode struct {
+	nde         *Node
+	cpuUnits    float64
+	memoryUnits float64
+	cpuRes      float64
+	memoryRes   float64
+	pciSlots    set.Set
+	drives      set.Set
+	tags        set.Set
+	dedicated   map[int]int
+	allocated   set.Set
+}
+
+func (p *placementNode) dedicatedNuma(count int) (numaId int, ok bool) {
+	for id, free := range p.dedicated {
+		if free < count {
+			continue
+		}
+
+		if !ok || free < p.dedicated[numaId] ||
+			(free == p.dedicated[numaId] && id < numaId) {
+
+			numaId = id
+			ok = true
+		}
+	}
+
+	return
+}
+
+func (p *placementNode) load(cpu, memory float64) float64 {
+	cpuLoad := (p.cpuRes + cpu) / p.cpuUnits
+	memoryLoad := (p.memoryRes + memory) / p.memoryUnits
+
+	if cpuLoad > memoryLoad {
+		return cpuLoad
+	}
+	return memoryLoad
+}
+
+func (p *placementNode) available(req *PlacementRequest,
+	memory float64) bool {
+
+	if p.memoryRes+memory > p.memoryUnits {
+		return false
+	}
+
+	for _, tag := range req.AntiAffinity {
+		if p.tags.Contains(tag) {
+			return false
+		}
+	}
+
+	if req.DedicatedCpus {
+		if _, ok := p.dedicatedNuma(req.Processors); !ok {
+			return false
+		}
+	}
+
+	if req.OracleSubnet != "" {
+		match := false
+		for _, subnet := range p.nde.OracleSubnets {
+			if subnet == req.OracleSubnet {
+				match = true
+				break
+			}
+		}
+
+		if !match {
+			return false
+		}
+	}
+
+	if len(req.PciDevices) > 0 {
+		if !p.nde.PciPassthrough {
+			return false
+		}
+
+		slots := set.NewSet()
+		for _, device := range p.nde.PciDevices {
+			slots.Add(device.Slot)
+		}
+
+		for _, device := range req.PciDevices {
+			if !slots.Contains(device.Slot) ||
+				p.pciSlots.Contains(device.Slot) {
+
+				return false
+			}
+		}
+	}
+
+	if len(req.UsbDevices) > 0 {
+		if !p.nde.UsbPassthrough {
+			return false
+		}
+
+		for _, device := range req.UsbDevices {
+			match := false
+			for _, nodeDevice := range p.nde.UsbDevices {
+				if device.Vendor != "" && device.Product != "" {
+					if device.Vendor == nodeDevice.Vendor &&
+						device.Product == nodeDevice.Product {
+
+						match = true
+						break
+					}
+				} else if device.Bus == nodeDevice.Bus &&
+					device.Address == nodeDevice.Address {
+
+					match = true
+					break
+				}
+			}
+
+			if !match {
+				return false
+			}
+		}
+	}
+
+	if len(req.DriveDevices) > 0 {
+		drives := set.NewSet()
+		for _, device := range p.nde.InstanceDrives {
+			drives.Add(device.Id)
+		}
+
+		for _, device := range req.DriveDevices {
+			if !drives.Contains(device.Id) ||
+				p.drives.Contains(device.Id) {
+
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (p *placementNode) reserve(req *PlacementRequest,
+	cpu, memory float64) {
+
+	p.cpuRes += cpu
+	p.memoryRes += memory
+
+	for _, device := range req.PciDevices {
+		p.pciSlots.Add(device.Slot)
+	}
+	for _, device := range req.DriveDevices {
+		p.drives.Add(device.Id)
+	}
+	for _, tag := range req.AntiAffinity {
+		p.tags.Add(tag)
+	}
+	if req.DedicatedCpus {
+		if numaId, ok := p.dedicatedNuma(req.Processors); ok {
+			p.dedicated[numaId] -= req.Processors
+		}
+	}
+}
+
+type Placement struct {
+	strategy string
+	nodes    []*placementNode
+}
+
+// Reserve selects a node for the request and reserves its resources. The
+// spread strategy selects the node with the lowest cpu and memory load
+// after adding the instance, the pack strategy selects the node with the
+// highest load that is not overcommitted. Memory is never overcommitted.
+// Dedicated cpu requests must fit within a single numa node of the node
+// dedicated cpu pool.
+func (p *Placement) Reserve(req *PlacementRequest) (nde *Node) {
+	cpu := float64(req.Processors)
+	memory := float64(req.Memory) / float64(1024)
+
+	var best *placementNode
+	bestLoad := 0.0
+	bestFits := false
+
+	for _, pNde := range p.nodes {
+		if !pNde.available(req, memory) {
+			continue
+		}
+
+		load := pNde.load(cpu, memory)
+
+		if p.strategy == zone.Pack {
+			fits := load <= 1
+			if best == nil || (fits && !bestFits) ||
+				(fits && load > bestLoad) ||
+				(!fits && !bestFits && load < bestLoad) {
+
+				best = pNde
+				bestLoad = load
+				bestFits = fits
+			}
+		} else if best == nil || load < bestLoad {
+			best = pNde
+			bestLoad = load
+		}
+	}
+
+	if best == nil {
+		return
+	}
+
+	best.reserve(req, cpu, memory)
+	nde = best.nde
+
+	return
+}
+
+// Fits checks if the node has the memory and cpus available for the
+// request without reserving them.
+func (p *Placement) Fits(ndeId primitive.ObjectID,
+	req *PlacementRequest) bool {
+
+	memory := float64(req.Memory) / float64(1024)
+
+	for _, pNde := range p.nodes {
+		if pNde.nde.Id != ndeId {
+			continue
+		}
+
+		if req.Processors > pNde.nde.CpuUnits {
+			return false
+		}
+
+		return pNde.available(req, memory)
+	}
+
+	return false
+}
+
+func NewPlacement(db *database.Database, zne *zone.Zone) (
+	p *Placement, err error) {
+
+	p = &Placement{
+		strategy: zne.Placement,
+		nodes:    []*placementNode{},
+	}
+
+	coll := db.Nodes()
+	nodesMap := map[primitive.ObjectID]*placementNode{}
+	nodeIds := []primitive.ObjectID{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"zone":  zne.Id,
+		"types": Hypervisor,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		nde := &Node{}
+		err = cursor.Decode(nde)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		nde.SetActive()
+		if nde.CpuUnits == 0 || nde.MemoryUnits == 0 {
+			continue
+		}
+
+		memoryUnits := nde.MemoryUnits
+		if nde.Hugepages && nde.HugepagesSize > 0 {
+			memoryUnits = float64(nde.HugepagesSize) / float64(1024)
+		}
+
+		pNde := &placementNode{
+			nde:         nde,
+			cpuUnits:    float64(nde.CpuUnits),
+			memoryUnits: memoryUnits,
+			pciSlots:    set.NewSet(),
+			drives:      set.NewSet(),
+			tags:        set.NewSet(),
+			dedicated:   map[int]int{},
+			allocated:   set.NewSet(),
+		}
+
+		used := set.NewSet()
+		for _, alloc := range nde.CpuAllocations {
+			pNde.allocated.Add(alloc.Instance)
+			for _, cpu := range alloc.Cpus {
+				used.Add(cpu)
+			}
+		}
+
+		for numaId, cpus := range nde.GetDedicatedNuma() {
+			for _, cpu := range cpus {
+				if !used.Contains(cpu) {
+					pNde.dedicated[numaId] += 1
+				}
+			}
+		}
+
+		p.nodes = append(p.nodes, pNde)
+		nodesMap[nde.Id] = pNde
+		nodeIds = append(nodeIds, nde.Id)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if len(nodeIds) == 0 {
+		return
+	}
+
+	coll = db.Instances()
+
+	instCursor, err := coll.Find(
+		db,
+		&bson.M{
+			"node": &bson.M{
+				"$in": nodeIds,
+			},
+			"state": &bson.M{
+				"$ne": "destroy",
+			},
+		},
+		&options.FindOptions{
+			Projection: &bson.D{
+				{"node", 1},
+				{"processors", 1},
+				{"memory", 1},
+				{"pci_devices", 1},
+				{"drive_devices", 1},
+				{"anti_affinity", 1},
+				{"dedicated_cpus", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer instCursor.Close(db)
+
+	for instCursor.Next(db) {
+		inst := &placementInstance{}
+		err = instCursor.Decode(inst)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		pNde := nodesMap[inst.Node]
+		if pNde == nil {
+			continue
+		}
+
+		// Allocated instances are already removed from the dedicated pool
+		dedicated := inst.DedicatedCpus && !pNde.allocated.Contains(inst.Id)
+
+		pNde.reserve(&PlacementRequest{
+			Processors:    inst.Processors,
+			PciDevices:    inst.PciDevices,
+			DriveDevices:  inst.DriveDevices,
+			AntiAffinity:  inst.AntiAffinity,
+			DedicatedCpus: dedicated,
+		}, float64(inst.Processors), float64(inst.Memory)/float64(1024))
+	}
+
+	err = instCursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	for _, pNde := range p.nodes {
+		if float64(pNde.nde.CpuUnitsRes) > pNde.cpuRes {
+			pNde.cpuRes = float64(pNde.nde.CpuUnitsRes)
+		}
+		if pNde.nde.MemoryUnitsRes > pNde.memoryRes {
+			pNde.memoryRes = pNde.nde.MemoryUnitsRes
+		}
+	}
+
+	return
+}
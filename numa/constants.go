@@ -0,0 +1,5 @@
+package numa
+
+const (
+	NodesDir = "/sys/devices/system/node"
+)
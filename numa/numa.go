@@ -0,0 +1,172 @@
+package numa
+
+import (
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+var (
+	nodes         = []*Node{}
+	lastNodesSync time.Time
+)
+
+type Node struct {
+	Id     int     `bson:"id" json:"id"`
+	Cpus   []int   `bson:"cpus" json:"cpus"`
+	Memory float64 `bson:"memory" json:"memory"`
+}
+
+func ParseCpuList(cpuList string) (cpus []int, err error) {
+	cpus = []int{}
+
+	cpuList = strings.TrimSpace(cpuList)
+	if cpuList == "" {
+		return
+	}
+
+	for _, item := range strings.Split(cpuList, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(item), "-", 2)
+
+		start, e := strconv.Atoi(bounds[0])
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrapf(e, "numa: Failed to parse cpu list '%s'",
+					cpuList),
+			}
+			return
+		}
+
+		end := start
+		if len(bounds) == 2 {
+			end, e = strconv.Atoi(bounds[1])
+			if e != nil {
+				err = &errortypes.ParseError{
+					errors.Wrapf(e, "numa: Failed to parse cpu list '%s'",
+						cpuList),
+				}
+				return
+			}
+		}
+
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	sort.Ints(cpus)
+
+	return
+}
+
+func getMemory(nodePath string) (memory float64, err error) {
+	data, err := ioutil.ReadFile(path.Join(nodePath, "meminfo"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "numa: Failed to read node meminfo"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[2] != "MemTotal:" {
+			continue
+		}
+
+		total, e := strconv.Atoi(fields[3])
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "numa: Failed to parse node meminfo"),
+			}
+			return
+		}
+
+		memory = utils.ToFixed(float64(total)/float64(1048576), 2)
+		return
+	}
+
+	return
+}
+
+func GetNodes() (nodesNew []*Node, err error) {
+	if time.Since(lastNodesSync) < 300*time.Second {
+		nodesNew = nodes
+		return
+	}
+
+	nodesNew = []*Node{}
+
+	exists, err := utils.ExistsDir(NodesDir)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return
+	}
+
+	nodeFiles, err := ioutil.ReadDir(NodesDir)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "numa: Failed to read nodes directory"),
+		}
+		return
+	}
+
+	for _, item := range nodeFiles {
+		name := item.Name()
+		if !strings.HasPrefix(name, "node") {
+			continue
+		}
+
+		id, e := strconv.Atoi(strings.TrimPrefix(name, "node"))
+		if e != nil {
+			continue
+		}
+
+		nodePath := path.Join(NodesDir, name)
+
+		cpuList, e := ioutil.ReadFile(path.Join(nodePath, "cpulist"))
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "numa: Failed to read node cpu list"),
+			}
+			return
+		}
+
+		cpus, e := ParseCpuList(string(cpuList))
+		if e != nil {
+			err = e
+			return
+		}
+
+		memory, e := getMemory(nodePath)
+		if e != nil {
+			err = e
+			return
+		}
+
+		nodesNew = append(nodesNew, &Node{
+			Id:     id,
+			Cpus:   cpus,
+			Memory: memory,
+		})
+	}
+
+	sort.Slice(nodesNew, func(i, j int) bool {
+		return nodesNew[i].Id < nodesNew[j].Id
+	})
+
+	nodes = nodesNew
+	lastNodesSync = time.Now()
+
+	return
+}
@@ -6,13 +6,26 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
 type Organization struct {
-	Id      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Roles   []string           `bson:"roles" json:"roles"`
-	Name    string             `bson:"name" json:"name"`
-	Comment string             `bson:"comment" json:"comment"`
+	Id                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Roles               []string           `bson:"roles" json:"roles"`
+	Name                string             `bson:"name" json:"name"`
+	Comment             string             `bson:"comment" json:"comment"`
+	RequireSignedImages bool               `bson:"require_signed_images" json:"require_signed_images"`
+	Quota               Quota              `bson:"quota" json:"quota"`
+	DiskLimits          vm.DiskLimits      `bson:"disk_limits" json:"disk_limits"`
+}
+
+type Quota struct {
+	Instances  int `bson:"instances" json:"instances"`
+	Processors int `bson:"processors" json:"processors"`
+	Memory     int `bson:"memory" json:"memory"`
+	Disk       int `bson:"disk" json:"disk"`
+	Snapshots  int `bson:"snapshots" json:"snapshots"`
+	PublicIps  int `bson:"public_ips" json:"public_ips"`
 }
 
 func (d *Organization) Validate(db *database.Database) (
@@ -22,6 +35,22 @@ func (d *Organization) Validate(db *database.Database) (
 		d.Roles = []string{}
 	}
 
+	if d.Quota.Instances < 0 || d.Quota.Processors < 0 ||
+		d.Quota.Memory < 0 || d.Quota.Disk < 0 ||
+		d.Quota.Snapshots < 0 || d.Quota.PublicIps < 0 {
+
+		errData = &errortypes.ErrorData{
+			Error:   "quota_invalid",
+			Message: "Organization quota cannot be negative",
+		}
+		return
+	}
+
+	errData = d.DiskLimits.Validate()
+	if errData != nil {
+		return
+	}
+
 	return
 }
 
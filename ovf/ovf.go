@@ -0,0 +1,162 @@
+package ovf
+
+import (
+	"archive/tar"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+const (
+	resourceProcessor = 3
+	resourceMemory    = 4
+	maxDescriptorSize = 4194304
+)
+
+type envelope struct {
+	References    references    `xml:"References"`
+	VirtualSystem virtualSystem `xml:"VirtualSystem"`
+}
+
+type references struct {
+	Files []file `xml:"File"`
+}
+
+type file struct {
+	Href string `xml:"href,attr"`
+}
+
+type virtualSystem struct {
+	Hardware virtualHardware `xml:"VirtualHardwareSection"`
+}
+
+type virtualHardware struct {
+	Items []item `xml:"Item"`
+}
+
+type item struct {
+	ResourceType    int    `xml:"ResourceType"`
+	VirtualQuantity string `xml:"VirtualQuantity"`
+	AllocationUnits string `xml:"AllocationUnits"`
+}
+
+type Descriptor struct {
+	Processors int
+	Memory     int
+	Disks      []string
+}
+
+// parseUnits returns the number of megabytes in one allocation unit such
+// as "byte * 2^20" or "MegaBytes"
+func parseUnits(units string) float64 {
+	units = strings.ToLower(strings.Replace(units, " ", "", -1))
+
+	switch units {
+	case "", "mb", "megabytes", "byte*2^20":
+		return 1
+	case "gb", "gigabytes", "byte*2^30":
+		return 1024
+	case "kb", "kilobytes", "byte*2^10":
+		return 1.0 / 1024
+	case "byte", "bytes":
+		return 1.0 / 1048576
+	}
+
+	if strings.HasPrefix(units, "byte*2^") {
+		exp, err := strconv.Atoi(units[7:])
+		if err == nil {
+			val := 1.0
+			for i := 0; i < exp; i++ {
+				val *= 2
+			}
+			return val / 1048576
+		}
+	}
+
+	return 1
+}
+
+func Parse(data []byte) (desc *Descriptor, err error) {
+	env := &envelope{}
+
+	err = xml.Unmarshal(data, env)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "ovf: Failed to parse descriptor"),
+		}
+		return
+	}
+
+	desc = &Descriptor{
+		Disks: []string{},
+	}
+
+	for _, fl := range env.References.Files {
+		if fl.Href != "" {
+			desc.Disks = append(desc.Disks, path.Base(fl.Href))
+		}
+	}
+
+	for _, itm := range env.VirtualSystem.Hardware.Items {
+		quantity, e := strconv.ParseFloat(
+			strings.TrimSpace(itm.VirtualQuantity), 64)
+		if e != nil || quantity <= 0 {
+			continue
+		}
+
+		switch itm.ResourceType {
+		case resourceProcessor:
+			desc.Processors = int(quantity)
+			break
+		case resourceMemory:
+			desc.Memory = int(quantity * parseUnits(itm.AllocationUnits))
+			break
+		}
+	}
+
+	return
+}
+
+// ReadArchive parses the descriptor from an OVA archive. The descriptor
+// is the first entry in the archive so only the start of the stream is
+// read.
+func ReadArchive(reader io.Reader) (desc *Descriptor, err error) {
+	tarReader := tar.NewReader(reader)
+
+	header, err := tarReader.Next()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "ovf: Failed to read archive"),
+		}
+		return
+	}
+
+	if !strings.HasSuffix(strings.ToLower(header.Name), ".ovf") {
+		err = &errortypes.ParseError{
+			errors.New("ovf: Archive missing descriptor"),
+		}
+		return
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(
+		tarReader, maxDescriptorSize))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "ovf: Failed to read descriptor"),
+		}
+		return
+	}
+
+	desc, err = Parse(data)
+	if err != nil {
+		return
+	}
+
+	return
+}
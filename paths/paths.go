@@ -65,6 +65,11 @@ func GetOvmfDir() string {
 	return path.Join(node.Self.GetVirtPath(), "ovmf")
 }
 
+func GetTpmPath(virtId primitive.ObjectID) string {
+	return path.Join(GetOvmfDir(),
+		fmt.Sprintf("%s_tpm", virtId.Hex()))
+}
+
 func GetDiskPath(diskId primitive.ObjectID) string {
 	return path.Join(GetDisksPath(),
 		fmt.Sprintf("%s.qcow2", diskId.Hex()))
@@ -98,6 +103,20 @@ func GetInitPath(instId primitive.ObjectID) string {
 		fmt.Sprintf("%s.iso", instId.Hex()))
 }
 
+func GetLogsPath() string {
+	return path.Join(node.Self.GetVirtPath(), "logs")
+}
+
+func GetSerialLogPath(instId primitive.ObjectID) string {
+	return path.Join(GetLogsPath(),
+		fmt.Sprintf("%s.serial.log", instId.Hex()))
+}
+
+func GetSerialLogRotatePath(instId primitive.ObjectID) string {
+	return path.Join(GetLogsPath(),
+		fmt.Sprintf("%s.serial.log.1", instId.Hex()))
+}
+
 func GetLeasesPath() string {
 	return path.Join(node.Self.GetVirtPath(), "leases")
 }
@@ -139,6 +158,16 @@ func GetGuestPath(virtId primitive.ObjectID) string {
 		fmt.Sprintf("%s.guest", virtId.Hex()))
 }
 
+func GetTpmSockPath(virtId primitive.ObjectID) string {
+	return path.Join(settings.Hypervisor.RunPath,
+		fmt.Sprintf("%s.tpm.sock", virtId.Hex()))
+}
+
+func GetSerialPath(virtId primitive.ObjectID) string {
+	return path.Join(settings.Hypervisor.RunPath,
+		fmt.Sprintf("%s.serial", virtId.Hex()))
+}
+
 // TODO Backward compatibility
 func GetPidPathOld(virtId primitive.ObjectID) string {
 	return path.Join(settings.Hypervisor.LibPath,
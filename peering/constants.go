@@ -0,0 +1,6 @@
+package peering
+
+const (
+	Pending = "pending"
+	Active  = "active"
+)
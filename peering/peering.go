@@ -0,0 +1,263 @@
+package peering
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+type Peering struct {
+	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name             string             `bson:"name" json:"name"`
+	Comment          string             `bson:"comment" json:"comment"`
+	State            string             `bson:"state" json:"state"`
+	Organization     primitive.ObjectID `bson:"organization" json:"organization"`
+	Datacenter       primitive.ObjectID `bson:"datacenter" json:"datacenter"`
+	Vpc              primitive.ObjectID `bson:"vpc" json:"vpc"`
+	PeerOrganization primitive.ObjectID `bson:"peer_organization" json:"peer_organization"`
+	PeerVpc          primitive.ObjectID `bson:"peer_vpc" json:"peer_vpc"`
+}
+
+func (p *Peering) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if p.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if p.Vpc.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_required",
+			Message: "Missing required VPC",
+		}
+		return
+	}
+
+	if p.PeerVpc.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_vpc_required",
+			Message: "Missing required peer VPC",
+		}
+		return
+	}
+
+	if p.Vpc == p.PeerVpc {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_vpc_invalid",
+			Message: "VPC cannot be peered with itself",
+		}
+		return
+	}
+
+	vcs, err := vpc.GetIds(db, []primitive.ObjectID{p.Vpc, p.PeerVpc})
+	if err != nil {
+		return
+	}
+
+	var vc *vpc.Vpc
+	var peerVc *vpc.Vpc
+	for _, v := range vcs {
+		if v.Id == p.Vpc {
+			vc = v
+		} else if v.Id == p.PeerVpc {
+			peerVc = v
+		}
+	}
+
+	if vc == nil || vc.Organization != p.Organization {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_not_found",
+			Message: "VPC not found",
+		}
+		return
+	}
+
+	if peerVc == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_vpc_not_found",
+			Message: "Peer VPC not found",
+		}
+		return
+	}
+
+	if vc.Datacenter != peerVc.Datacenter {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_vpc_datacenter_invalid",
+			Message: "Peer VPC must be in the same datacenter",
+		}
+		return
+	}
+
+	p.Datacenter = vc.Datacenter
+	p.PeerOrganization = peerVc.Organization
+
+	if p.PeerOrganization == p.Organization {
+		p.State = Active
+	}
+
+	switch p.State {
+	case Active:
+		break
+	case Pending, "":
+		p.State = Pending
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "peering_state_invalid",
+			Message: "Peering state is invalid",
+		}
+		return
+	}
+
+	overlaps, err := vc.Overlaps(peerVc)
+	if err != nil {
+		return
+	}
+
+	if overlaps {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_vpc_network_overlap",
+			Message: "Peer VPC network overlaps with VPC network",
+		}
+		return
+	}
+
+	peers, err := GetVpcs(db, []primitive.ObjectID{vc.Id, peerVc.Id})
+	if err != nil {
+		return
+	}
+
+	vcPeerIds := []primitive.ObjectID{}
+	peerVcPeerIds := []primitive.ObjectID{}
+	for _, peer := range peers {
+		if peer.Id == p.Id {
+			continue
+		}
+
+		if (peer.Vpc == vc.Id && peer.PeerVpc == peerVc.Id) ||
+			(peer.Vpc == peerVc.Id && peer.PeerVpc == vc.Id) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "peering_duplicate",
+				Message: "VPCs are already peered",
+			}
+			return
+		}
+
+		vcPeerId := peer.GetPeer(vc.Id)
+		if !vcPeerId.IsZero() {
+			vcPeerIds = append(vcPeerIds, vcPeerId)
+		}
+
+		peerVcPeerId := peer.GetPeer(peerVc.Id)
+		if !peerVcPeerId.IsZero() {
+			peerVcPeerIds = append(peerVcPeerIds, peerVcPeerId)
+		}
+	}
+
+	errData, err = validateOverlap(db, peerVc, vcPeerIds)
+	if err != nil || errData != nil {
+		return
+	}
+
+	errData, err = validateOverlap(db, vc, peerVcPeerIds)
+	if err != nil || errData != nil {
+		return
+	}
+
+	return
+}
+
+func validateOverlap(db *database.Database, vc *vpc.Vpc,
+	peerIds []primitive.ObjectID) (errData *errortypes.ErrorData, err error) {
+
+	if len(peerIds) == 0 {
+		return
+	}
+
+	peerVcs, err := vpc.GetIds(db, peerIds)
+	if err != nil {
+		return
+	}
+
+	for _, peerVc := range peerVcs {
+		overlaps, e := vc.Overlaps(peerVc)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if overlaps {
+			errData = &errortypes.ErrorData{
+				Error:   "peer_vpc_network_overlap",
+				Message: "VPC network overlaps with an existing peered VPC",
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// GetPeer returns the other side of the peering for the VPC or a zero id
+// if the VPC is not part of the peering.
+func (p *Peering) GetPeer(vcId primitive.ObjectID) primitive.ObjectID {
+	if p.Vpc == vcId {
+		return p.PeerVpc
+	} else if p.PeerVpc == vcId {
+		return p.Vpc
+	}
+	return primitive.NilObjectID
+}
+
+func (p *Peering) Commit(db *database.Database) (err error) {
+	coll := db.VpcPeerings()
+
+	err = coll.Commit(p.Id, p)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Peering) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.VpcPeerings()
+
+	err = coll.CommitFields(p.Id, p, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Peering) Insert(db *database.Database) (err error) {
+	coll := db.VpcPeerings()
+
+	if !p.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("peering: Peering already exists"),
+		}
+		return
+	}
+
+	resp, err := coll.InsertOne(db, p)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	p.Id = resp.InsertedID.(primitive.ObjectID)
+
+	return
+}
@@ -0,0 +1,226 @@
+package peering
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func Get(db *database.Database, peerId primitive.ObjectID) (
+	peer *Peering, err error) {
+
+	coll := db.VpcPeerings()
+	peer = &Peering{}
+
+	err = coll.FindOneId(peerId, peer)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, peerId primitive.ObjectID) (
+	peer *Peering, err error) {
+
+	coll := db.VpcPeerings()
+	peer = &Peering{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id": peerId,
+		"$or": []*bson.M{
+			&bson.M{
+				"organization": orgId,
+			},
+			&bson.M{
+				"peer_organization": orgId,
+			},
+		},
+	}).Decode(peer)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	peers []*Peering, err error) {
+
+	coll := db.VpcPeerings()
+	peers = []*Peering{}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		peer := &Peering{}
+		err = cursor.Decode(peer)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		peers = append(peers, peer)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (peers []*Peering, count int64, err error) {
+
+	coll := db.VpcPeerings()
+	peers = []*Peering{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	maxPage := count / pageCount
+	if count == pageCount {
+		maxPage = 0
+	}
+	page = utils.Min64(page, maxPage)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		peer := &Peering{}
+		err = cursor.Decode(peer)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		peers = append(peers, peer)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetVpcs(db *database.Database, vcIds []primitive.ObjectID) (
+	peers []*Peering, err error) {
+
+	peers, err = GetAll(db, &bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": &bson.M{
+					"$in": vcIds,
+				},
+			},
+			&bson.M{
+				"peer_vpc": &bson.M{
+					"$in": vcIds,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetDatacenter(db *database.Database, dcId primitive.ObjectID) (
+	peers []*Peering, err error) {
+
+	peers, err = GetAll(db, &bson.M{
+		"datacenter": dcId,
+		"state":      Active,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, peerId primitive.ObjectID) (err error) {
+	coll := db.VpcPeerings()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": peerId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, peerId primitive.ObjectID) (
+	err error) {
+
+	coll := db.VpcPeerings()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": peerId,
+		"$or": []*bson.M{
+			&bson.M{
+				"organization": orgId,
+			},
+			&bson.M{
+				"peer_organization": orgId,
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
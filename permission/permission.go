@@ -54,6 +54,13 @@ func InitVirt(virt *vm.VirtualMachine) (err error) {
 		}
 	}
 
+	if virt.Tpm {
+		err = chown(virt, paths.GetTpmPath(virt.Id))
+		if err != nil {
+			return
+		}
+	}
+
 	err = chown(virt, paths.GetInitPath(virt.Id))
 	if err != nil {
 		return
@@ -8,6 +8,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/balancer"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/node"
@@ -17,6 +18,7 @@ import (
 
 type Proxy struct {
 	Domains map[string]*Domain
+	Streams map[primitive.ObjectID]*Stream
 	lock    sync.Mutex
 }
 
@@ -43,6 +45,7 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 	domains := map[string]*Domain{}
 	domainsName := set.NewSet()
 	remDomains := []*Domain{}
+	streams := map[primitive.ObjectID]*Stream{}
 	states := []*balancerState{}
 
 	proxyProto := node.Self.Protocol
@@ -54,6 +57,17 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 			continue
 		}
 
+		if balnc.IsStream() {
+			state := p.updateStream(balnc, streams)
+			if state != nil {
+				states = append(states, &balancerState{
+					Balancer: balnc,
+					State:    state,
+				})
+			}
+			continue
+		}
+
 		onlineWeb := set.NewSet()
 		unknownHighWeb := set.NewSet()
 		unknownMidWeb := set.NewSet()
@@ -195,7 +209,14 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 		}
 	}
 
+	for balncId, strm := range p.Streams {
+		if streams[balncId] == nil {
+			strm.Close()
+		}
+	}
+
 	p.Domains = domains
+	p.Streams = streams
 	p.lock.Unlock()
 
 	for _, domain := range remDomains {
@@ -220,6 +241,63 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 	return
 }
 
+func (p *Proxy) updateStream(balnc *balancer.Balancer,
+	streams map[primitive.ObjectID]*Stream) (state *balancer.State) {
+
+	state = &balancer.State{
+		Timestamp:   time.Now(),
+		Online:      []string{},
+		UnknownHigh: []string{},
+		UnknownMid:  []string{},
+		UnknownLow:  []string{},
+		Offline:     []string{},
+	}
+
+	proxyStream := &Stream{
+		Balancer: balnc,
+		Requests: new(int32),
+		Retries:  new(int32),
+	}
+	proxyStream.CalculateHash()
+
+	curStream := p.Streams[balnc.Id]
+	if curStream != nil {
+		state.Requests += curStream.RequestsTotal
+		state.Retries += curStream.RetriesTotal
+		curStream.GetState(state)
+
+		if bytes.Equal(curStream.Hash, proxyStream.Hash) {
+			streams[balnc.Id] = curStream
+			return
+		}
+
+		proxyStream.Requests = curStream.Requests
+		proxyStream.RequestsPrev = curStream.RequestsPrev
+		proxyStream.RequestsTotal = curStream.RequestsTotal
+		proxyStream.Retries = curStream.Retries
+		proxyStream.RetriesPrev = curStream.RetriesPrev
+		proxyStream.RetriesTotal = curStream.RetriesTotal
+
+		curStream.Close()
+		delete(p.Streams, balnc.Id)
+	}
+
+	err := proxyStream.Init()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"balancer_id":   balnc.Id.Hex(),
+			"balancer_name": balnc.Name,
+			"error":         err,
+		}).Error("proxy: Failed to start stream balancer")
+		state = nil
+		return
+	}
+
+	streams[balnc.Id] = proxyStream
+
+	return
+}
+
 func (p *Proxy) syncCount() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -254,6 +332,37 @@ func (p *Proxy) syncCount() {
 		dom.RetriesPrev = retPrev
 		dom.RetriesTotal = retTotal
 	}
+
+	streams := p.Streams
+	for _, strm := range streams {
+		req := strm.Requests
+		strm.Requests = new(int32)
+		reqPrev := strm.RequestsPrev
+		reqTotal := reqPrev[0] + reqPrev[1] + reqPrev[2] +
+			reqPrev[3] + reqPrev[4]
+		reqPrev[0] = reqPrev[1]
+		reqPrev[1] = reqPrev[2]
+		reqPrev[2] = reqPrev[3]
+		reqPrev[3] = reqPrev[4]
+		reqPrev[4] = int(*req)
+		reqTotal += int(*req)
+		strm.RequestsPrev = reqPrev
+		strm.RequestsTotal = reqTotal
+
+		ret := strm.Retries
+		strm.Retries = new(int32)
+		retPrev := strm.RetriesPrev
+		retTotal := retPrev[0] + retPrev[1] + retPrev[2] +
+			retPrev[3] + retPrev[4]
+		retPrev[0] = retPrev[1]
+		retPrev[1] = retPrev[2]
+		retPrev[2] = retPrev[3]
+		retPrev[3] = retPrev[4]
+		retPrev[4] = int(*ret)
+		retTotal += int(*ret)
+		strm.RetriesPrev = retPrev
+		strm.RetriesTotal = retTotal
+	}
 }
 
 func (p *Proxy) runCounter() {
@@ -271,6 +380,11 @@ func (p *Proxy) healthCheck() {
 	for _, dom := range domains {
 		dom.Check()
 	}
+
+	streams := p.Streams
+	for _, strm := range streams {
+		strm.Check()
+	}
 }
 
 func (p *Proxy) runHealthCheck() {
@@ -282,6 +396,7 @@ func (p *Proxy) runHealthCheck() {
 
 func (p *Proxy) Init() {
 	p.Domains = map[string]*Domain{}
+	p.Streams = map[primitive.ObjectID]*Stream{}
 	go p.runCounter()
 	go p.runHealthCheck()
 }
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+var proxyProtocolSig = []byte{
+	0x0d, 0x0a, 0x0d, 0x0a, 0x00, 0x0d, 0x0a, 0x51, 0x55, 0x49, 0x54, 0x0a,
+}
+
+func parseAddr(addr net.Addr) (ip net.IP, port int) {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+		port = a.Port
+		break
+	case *net.UDPAddr:
+		ip = a.IP
+		port = a.Port
+		break
+	}
+
+	if ip == nil {
+		ip = net.IPv4zero
+	}
+
+	return
+}
+
+func proxyProtocolHeader(src, dst net.Addr, datagram bool) []byte {
+	srcIp, srcPort := parseAddr(src)
+	dstIp, dstPort := parseAddr(dst)
+
+	header := make([]byte, 16)
+	copy(header, proxyProtocolSig)
+	header[12] = 0x21
+
+	var addrs []byte
+	if srcIp.To4() != nil && (dstIp.To4() != nil || dstIp.IsUnspecified()) {
+		header[13] = 0x10
+		addrs = append(addrs, srcIp.To4()...)
+		if dstIp.To4() != nil {
+			addrs = append(addrs, dstIp.To4()...)
+		} else {
+			addrs = append(addrs, net.IPv4zero.To4()...)
+		}
+	} else {
+		header[13] = 0x20
+		addrs = append(addrs, srcIp.To16()...)
+		addrs = append(addrs, dstIp.To16()...)
+	}
+
+	if datagram {
+		header[13] |= 0x02
+	} else {
+		header[13] |= 0x01
+	}
+
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports[0:], uint16(srcPort))
+	binary.BigEndian.PutUint16(ports[2:], uint16(dstPort))
+	addrs = append(addrs, ports...)
+
+	binary.BigEndian.PutUint16(header[14:], uint16(len(addrs)))
+
+	return append(header, addrs...)
+}
@@ -0,0 +1,574 @@
+package proxy
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	streamDialTimeout = 5 * time.Second
+	streamUdpTimeout  = 60 * time.Second
+	streamUdpBuffer   = 65535
+)
+
+type StreamBackend struct {
+	Key             string
+	Address         string
+	State           int
+	LastState       time.Time
+	LastOnlineState time.Time
+}
+
+type udpSession struct {
+	conn    *net.UDPConn
+	client  net.Addr
+	backend *StreamBackend
+}
+
+type Stream struct {
+	Hash          []byte
+	Requests      *int32
+	RequestsPrev  [5]int
+	RequestsTotal int
+	Retries       *int32
+	RetriesPrev   [5]int
+	RetriesTotal  int
+	Conns         *int32
+	Lock          sync.Mutex
+	Balancer      *balancer.Balancer
+	Backends      []*StreamBackend
+	listener      net.Listener
+	packetConn    net.PacketConn
+	sessions      map[string]*udpSession
+	sessionsLock  sync.Mutex
+	closed        bool
+}
+
+func (s *Stream) CalculateHash() {
+	h := md5.New()
+
+	h.Write([]byte(s.Balancer.Id.Hex()))
+	h.Write([]byte(s.Balancer.Name))
+	h.Write([]byte(s.Balancer.Type))
+	h.Write([]byte(strconv.Itoa(s.Balancer.ListenPort)))
+	h.Write([]byte(strconv.FormatBool(s.Balancer.ProxyProtocol)))
+
+	for _, backend := range s.Balancer.Backends {
+		h.Write([]byte(backend.Protocol))
+		h.Write([]byte(backend.Hostname))
+		h.Write([]byte(strconv.Itoa(backend.Port)))
+	}
+
+	s.Hash = h.Sum(nil)
+}
+
+func (s *Stream) Init() (err error) {
+	backends := []*StreamBackend{}
+	for _, backend := range s.Balancer.Backends {
+		backends = append(backends, &StreamBackend{
+			Key: fmt.Sprintf("%s:%d", backend.Hostname, backend.Port),
+			Address: net.JoinHostPort(backend.Hostname,
+				strconv.Itoa(backend.Port)),
+			State: UnknownHigh,
+		})
+	}
+	s.Backends = backends
+	s.Conns = new(int32)
+
+	addr := fmt.Sprintf(":%d", s.Balancer.ListenPort)
+
+	switch s.Balancer.Type {
+	case balancer.Tcp:
+		listener, e := net.Listen("tcp", addr)
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrapf(e, "proxy: Failed to listen on tcp %s", addr),
+			}
+			return
+		}
+		s.listener = listener
+
+		go s.serveTcp()
+		break
+	case balancer.Udp:
+		packetConn, e := net.ListenPacket("udp", addr)
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrapf(e, "proxy: Failed to listen on udp %s", addr),
+			}
+			return
+		}
+		s.packetConn = packetConn
+		s.sessions = map[string]*udpSession{}
+
+		go s.serveUdp()
+		break
+	default:
+		err = &errortypes.UnknownError{
+			errors.Newf("proxy: Unknown stream type '%s'",
+				s.Balancer.Type),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *Stream) Close() {
+	s.Lock.Lock()
+	s.closed = true
+	s.Lock.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	if s.packetConn != nil {
+		s.packetConn.Close()
+
+		s.sessionsLock.Lock()
+		for key, session := range s.sessions {
+			session.conn.Close()
+			delete(s.sessions, key)
+		}
+		s.sessionsLock.Unlock()
+	}
+}
+
+func (s *Stream) isClosed() bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	return s.closed
+}
+
+func (s *Stream) getBackend(exclude *StreamBackend) (
+	backend *StreamBackend) {
+
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	for _, state := range []int{
+		Online,
+		UnknownHigh,
+		UnknownMid,
+		UnknownLow,
+		Offline,
+	} {
+		backends := []*StreamBackend{}
+		for _, back := range s.Backends {
+			if back.State == state && back != exclude {
+				backends = append(backends, back)
+			}
+		}
+
+		if len(backends) != 0 {
+			backend = backends[rand.Intn(len(backends))]
+			return
+		}
+	}
+
+	return
+}
+
+func (s *Stream) dial(network string) (conn net.Conn,
+	backend *StreamBackend, err error) {
+
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			atomic.AddInt32(s.Retries, 1)
+		}
+
+		backend = s.getBackend(backend)
+		if backend == nil {
+			break
+		}
+
+		conn, err = net.DialTimeout(network, backend.Address,
+			streamDialTimeout)
+		if err == nil {
+			return
+		}
+
+		s.downgradeBackend(backend)
+	}
+
+	if err == nil {
+		err = &errortypes.RequestError{
+			errors.New("proxy: No stream backends available"),
+		}
+	} else {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "proxy: Stream backend dial error"),
+		}
+	}
+
+	return
+}
+
+func (s *Stream) serveTcp() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"balancer_id": s.Balancer.Id.Hex(),
+				"error":       err,
+			}).Error("proxy: Stream accept error")
+
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		go s.handleTcp(conn)
+	}
+}
+
+func (s *Stream) handleTcp(frontConn net.Conn) {
+	defer frontConn.Close()
+
+	atomic.AddInt32(s.Requests, 1)
+	atomic.AddInt32(s.Conns, 1)
+	defer atomic.AddInt32(s.Conns, -1)
+
+	backConn, _, err := s.dial("tcp")
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"balancer_id": s.Balancer.Id.Hex(),
+			"client":      frontConn.RemoteAddr().String(),
+			"error":       err,
+		}).Error("proxy: Stream serve error")
+		return
+	}
+	defer backConn.Close()
+
+	if s.Balancer.ProxyProtocol {
+		_, err = backConn.Write(proxyProtocolHeader(
+			frontConn.RemoteAddr(), frontConn.LocalAddr(), false))
+		if err != nil {
+			return
+		}
+	}
+
+	waiter := sync.WaitGroup{}
+	waiter.Add(2)
+
+	go func() {
+		defer waiter.Done()
+		io.Copy(backConn, frontConn)
+		if tcpConn, ok := backConn.(*net.TCPConn); ok {
+			tcpConn.CloseWrite()
+		}
+	}()
+
+	go func() {
+		defer waiter.Done()
+		io.Copy(frontConn, backConn)
+		if tcpConn, ok := frontConn.(*net.TCPConn); ok {
+			tcpConn.CloseWrite()
+		}
+	}()
+
+	waiter.Wait()
+}
+
+func (s *Stream) serveUdp() {
+	buffer := make([]byte, streamUdpBuffer)
+
+	for {
+		n, client, err := s.packetConn.ReadFrom(buffer)
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"balancer_id": s.Balancer.Id.Hex(),
+				"error":       err,
+			}).Error("proxy: Stream read error")
+
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		session, err := s.getSession(client)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"balancer_id": s.Balancer.Id.Hex(),
+				"client":      client.String(),
+				"error":       err,
+			}).Error("proxy: Stream serve error")
+			continue
+		}
+
+		data := buffer[:n]
+		if s.Balancer.ProxyProtocol {
+			data = append(proxyProtocolHeader(
+				client, s.packetConn.LocalAddr(), true), data...)
+		}
+
+		session.conn.SetDeadline(time.Now().Add(streamUdpTimeout))
+		_, err = session.conn.Write(data)
+		if err != nil {
+			s.downgradeBackend(session.backend)
+		}
+	}
+}
+
+func (s *Stream) getSession(client net.Addr) (
+	session *udpSession, err error) {
+
+	key := client.String()
+
+	s.sessionsLock.Lock()
+	session = s.sessions[key]
+	s.sessionsLock.Unlock()
+
+	if session != nil {
+		return
+	}
+
+	atomic.AddInt32(s.Requests, 1)
+
+	conn, backend, err := s.dial("udp")
+	if err != nil {
+		return
+	}
+
+	session = &udpSession{
+		conn:    conn.(*net.UDPConn),
+		client:  client,
+		backend: backend,
+	}
+
+	s.sessionsLock.Lock()
+	s.sessions[key] = session
+	s.sessionsLock.Unlock()
+
+	go s.handleUdpSession(key, session)
+
+	return
+}
+
+func (s *Stream) handleUdpSession(key string, session *udpSession) {
+	atomic.AddInt32(s.Conns, 1)
+	defer atomic.AddInt32(s.Conns, -1)
+
+	defer func() {
+		session.conn.Close()
+
+		s.sessionsLock.Lock()
+		if s.sessions[key] == session {
+			delete(s.sessions, key)
+		}
+		s.sessionsLock.Unlock()
+	}()
+
+	buffer := make([]byte, streamUdpBuffer)
+
+	for {
+		session.conn.SetReadDeadline(time.Now().Add(streamUdpTimeout))
+
+		n, err := session.conn.Read(buffer)
+		if err != nil {
+			if isConnRefused(err) {
+				s.downgradeBackend(session.backend)
+			}
+			return
+		}
+
+		_, err = s.packetConn.WriteTo(buffer[:n], session.client)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Stream) checkBackend(backend *StreamBackend) {
+	var err error
+	unknown := false
+
+	switch s.Balancer.Type {
+	case balancer.Tcp:
+		err = checkTcp(backend.Address)
+		break
+	case balancer.Udp:
+		unknown, err = checkUdp(backend.Address)
+		break
+	}
+
+	if err != nil {
+		s.offlineBackend(backend)
+	} else if unknown {
+		s.unknownBackend(backend)
+	} else {
+		s.upgradeBackend(backend)
+	}
+}
+
+func (s *Stream) Check() {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	for _, backend := range s.Backends {
+		go s.checkBackend(backend)
+	}
+
+	return
+}
+
+func (s *Stream) upgradeBackend(backend *StreamBackend) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	if backend.State == Online {
+		return
+	}
+
+	if time.Since(backend.LastOnlineState) > 5*time.Second {
+		backend.State = Online
+		backend.LastOnlineState = time.Now()
+	}
+}
+
+func (s *Stream) downgradeBackend(backend *StreamBackend) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	switch backend.State {
+	case Online, UnknownHigh:
+		backend.State = UnknownMid
+		backend.LastState = time.Now()
+		break
+	case UnknownMid:
+		if time.Since(backend.LastState) > 1*time.Second {
+			backend.State = UnknownLow
+			backend.LastState = time.Now()
+		}
+		break
+	case UnknownLow:
+		if time.Since(backend.LastState) > 2*time.Second {
+			backend.State = Offline
+			backend.LastState = time.Now()
+		}
+		break
+	case Offline:
+		break
+	}
+}
+
+func (s *Stream) unknownBackend(backend *StreamBackend) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	switch backend.State {
+	case Online, UnknownHigh:
+		backend.State = UnknownMid
+		backend.LastState = time.Now()
+		break
+	}
+}
+
+func (s *Stream) offlineBackend(backend *StreamBackend) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	if backend.State != Offline {
+		backend.State = Offline
+		backend.LastState = time.Now()
+	}
+}
+
+func (s *Stream) GetState(state *balancer.State) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	for _, backend := range s.Backends {
+		switch backend.State {
+		case Online:
+			state.Online = append(state.Online, backend.Key)
+			break
+		case UnknownHigh:
+			state.UnknownHigh = append(state.UnknownHigh, backend.Key)
+			break
+		case UnknownMid:
+			state.UnknownMid = append(state.UnknownMid, backend.Key)
+			break
+		case UnknownLow:
+			state.UnknownLow = append(state.UnknownLow, backend.Key)
+			break
+		case Offline:
+			state.Offline = append(state.Offline, backend.Key)
+			break
+		}
+	}
+}
+
+func isConnRefused(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+
+	sysErr, ok := opErr.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+
+	return sysErr.Err == syscall.ECONNREFUSED
+}
+
+func checkTcp(addr string) (err error) {
+	conn, err := net.DialTimeout("tcp", addr, streamDialTimeout)
+	if err != nil {
+		return
+	}
+	conn.Close()
+
+	return
+}
+
+// checkUdp sends an empty datagram to the backend. A response confirms the
+// backend is online and a refused connection marks it offline, backends
+// that do not respond before the timeout are unknown.
+func checkUdp(addr string) (unknown bool, err error) {
+	conn, err := net.DialTimeout("udp", addr, streamDialTimeout)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte{})
+	if err != nil {
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	_, err = conn.Read(make([]byte, 1))
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			unknown = true
+			err = nil
+		}
+		return
+	}
+
+	return
+}
@@ -8,6 +8,6 @@ After=network.target
 
 [Service]%s
 Type=simple
-User=root
+User=root%s
 ExecStart=%s
 `
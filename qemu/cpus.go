@@ -0,0 +1,202 @@
+package qemu
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+func initCpus(db *database.Database, virt *vm.VirtualMachine) (err error) {
+	if !virt.DedicatedCpus {
+		virt.CpuAffinity = nil
+		virt.NumaNode = 0
+
+		err = node.Self.ReleaseCpus(db, virt.Id)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	alloc, err := node.Self.AllocateCpus(db, virt.Id, virt.Processors)
+	if err != nil {
+		return
+	}
+
+	virt.CpuAffinity = alloc.Cpus
+	virt.NumaNode = alloc.Numa
+
+	return
+}
+
+// getCpuAffinity returns the allocated cpus for dedicated virtual machines,
+// other virtual machines are kept off the node dedicated cpu pool.
+func getCpuAffinity(virt *vm.VirtualMachine) (cpus []int) {
+	if virt.DedicatedCpus {
+		cpus = virt.CpuAffinity
+		return
+	}
+
+	if len(node.Self.DedicatedCpus) == 0 {
+		return
+	}
+
+	dedicated := set.NewSet()
+	for _, cpu := range node.Self.DedicatedCpus {
+		dedicated.Add(cpu)
+	}
+
+	for _, nd := range node.Self.NumaNodes {
+		for _, cpu := range nd.Cpus {
+			if !dedicated.Contains(cpu) {
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+
+	sort.Ints(cpus)
+
+	return
+}
+
+// pinCpus records the service cpu affinity and pins each virtual cpu of
+// dedicated virtual machines. The service affinity already limits the
+// virtual machine to the allocated cpus, pinning errors are logged without
+// failing the running virtual machine.
+func pinCpus(virt *vm.VirtualMachine) {
+	store.SetCpuAffinity(virt.Id, getCpuAffinity(virt))
+
+	if !virt.DedicatedCpus {
+		return
+	}
+
+	err := pinVcpus(virt)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"id":    virt.Id.Hex(),
+			"error": err,
+		}).Error("qemu: Failed to pin virtual machine cpus")
+	}
+}
+
+func pinVcpus(virt *vm.VirtualMachine) (err error) {
+	threads, err := qmp.GetCpuThreads(virt.Id)
+	if err != nil {
+		return
+	}
+
+	for _, thread := range threads {
+		if thread.CpuIndex < 0 || thread.CpuIndex >= len(virt.CpuAffinity) {
+			err = &errortypes.ParseError{
+				errors.Newf("qemu: Invalid virtual cpu index %d",
+					thread.CpuIndex),
+			}
+			return
+		}
+
+		cpu := virt.CpuAffinity[thread.CpuIndex]
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"taskset", "-pc",
+			strconv.Itoa(cpu),
+			strconv.Itoa(thread.ThreadId),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":        virt.Id.Hex(),
+		"numa_node": virt.NumaNode,
+		"cpus":      virt.CpuAffinity,
+	}).Info("qemu: Pinned virtual machine cpus")
+
+	return
+}
+
+// CpuAffinityChanged checks if the node dedicated cpu pool changed since a
+// running non-dedicated virtual machine was started.
+func CpuAffinityChanged(virt *vm.VirtualMachine) bool {
+	if virt == nil || virt.State != vm.Running || virt.DedicatedCpus {
+		return false
+	}
+
+	curCpus, ok := store.GetCpuAffinity(virt.Id)
+	if !ok {
+		return true
+	}
+
+	cpus := getCpuAffinity(virt)
+	if len(curCpus) != len(cpus) {
+		return true
+	}
+
+	for i := range cpus {
+		if curCpus[i] != cpus[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateCpuAffinity sets the cpu affinity of all threads of a running
+// non-dedicated virtual machine to the cpus outside the dedicated pool.
+func UpdateCpuAffinity(virt *vm.VirtualMachine) (err error) {
+	cpus := getCpuAffinity(virt)
+
+	cpuList := []string{}
+	if len(cpus) == 0 {
+		cpuList = append(cpuList,
+			fmt.Sprintf("0-%d", runtime.NumCPU()-1))
+	} else {
+		for _, cpu := range cpus {
+			cpuList = append(cpuList, strconv.Itoa(cpu))
+		}
+	}
+
+	pidData, err := ioutil.ReadFile(paths.GetPidPath(virt.Id))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to read pid file"),
+		}
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"taskset", "-a", "-pc",
+		strings.Join(cpuList, ","),
+		strings.TrimSpace(string(pidData)),
+	)
+	if err != nil {
+		return
+	}
+
+	store.SetCpuAffinity(virt.Id, cpus)
+
+	logrus.WithFields(logrus.Fields{
+		"id":   virt.Id.Hex(),
+		"cpus": cpus,
+	}).Info("qemu: Updated virtual machine cpu affinity")
+
+	return
+}
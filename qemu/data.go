@@ -7,6 +7,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/hugepages"
+	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/permission"
 	"github.com/pritunl/pritunl-cloud/qmp"
@@ -35,6 +36,24 @@ func initHugepage(virt *vm.VirtualMachine) (err error) {
 	return
 }
 
+func writeTpmState(virt *vm.VirtualMachine) (err error) {
+	if !virt.Tpm {
+		return
+	}
+
+	err = utils.ExistsMkdir(paths.GetOvmfDir(), 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(paths.GetTpmPath(virt.Id), 0700)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func initPermissions(virt *vm.VirtualMachine) (err error) {
 	err = permission.InitVirt(virt)
 	if err != nil {
@@ -74,12 +93,24 @@ func writeOvmfVars(virt *vm.VirtualMachine) (err error) {
 }
 
 func writeService(virt *vm.VirtualMachine) (err error) {
+	err = writeServiceIncoming(virt, "")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func writeServiceIncoming(virt *vm.VirtualMachine, incoming string) (
+	err error) {
+
 	unitPath := paths.GetUnitPath(virt.Id)
 
 	qm, err := NewQemu(virt)
 	if err != nil {
 		return
 	}
+	qm.Incoming = incoming
 
 	output, err := qm.Marshal()
 	if err != nil {
@@ -100,21 +131,8 @@ func writeService(virt *vm.VirtualMachine) (err error) {
 }
 
 func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
-	vmPath := paths.GetVmPath(virt.Id)
 	unitName := paths.GetUnitName(virt.Id)
 	unitPath := paths.GetUnitPath(virt.Id)
-	sockPath := paths.GetSockPath(virt.Id)
-	sockQmpPath := paths.GetQmpSockPath(virt.Id)
-	// TODO Backward compatibility
-	sockPathOld := paths.GetSockPath(virt.Id)
-	guestPath := paths.GetGuestPath(virt.Id)
-	// TODO Backward compatibility
-	guestPathOld := paths.GetGuestPathOld(virt.Id)
-	pidPath := paths.GetPidPath(virt.Id)
-	// TODO Backward compatibility
-	pidPathOld := paths.GetPidPathOld(virt.Id)
-	ovmfVarsPath := paths.GetOvmfVarsPath(virt.Id)
-	hugepagesPath := paths.GetHugepagePath(virt.Id)
 
 	logrus.WithFields(logrus.Fields{
 		"id": virt.Id.Hex(),
@@ -240,6 +258,37 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		}
 	}
 
+	err = removeFiles(db, virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func removeFiles(db *database.Database, virt *vm.VirtualMachine) (
+	err error) {
+
+	vmPath := paths.GetVmPath(virt.Id)
+	unitPath := paths.GetUnitPath(virt.Id)
+	sockPath := paths.GetSockPath(virt.Id)
+	sockQmpPath := paths.GetQmpSockPath(virt.Id)
+	// TODO Backward compatibility
+	sockPathOld := paths.GetSockPath(virt.Id)
+	guestPath := paths.GetGuestPath(virt.Id)
+	// TODO Backward compatibility
+	guestPathOld := paths.GetGuestPathOld(virt.Id)
+	serialPath := paths.GetSerialPath(virt.Id)
+	serialLogPath := paths.GetSerialLogPath(virt.Id)
+	serialLogRotatePath := paths.GetSerialLogRotatePath(virt.Id)
+	pidPath := paths.GetPidPath(virt.Id)
+	// TODO Backward compatibility
+	pidPathOld := paths.GetPidPathOld(virt.Id)
+	ovmfVarsPath := paths.GetOvmfVarsPath(virt.Id)
+	tpmPath := paths.GetTpmPath(virt.Id)
+	tpmSockPath := paths.GetTpmSockPath(virt.Id)
+	hugepagesPath := paths.GetHugepagePath(virt.Id)
+
 	err = utils.RemoveAll(vmPath)
 	if err != nil {
 		return
@@ -277,6 +326,21 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		return
 	}
 
+	err = utils.RemoveAll(serialPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(serialLogPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(serialLogRotatePath)
+	if err != nil {
+		return
+	}
+
 	err = utils.RemoveAll(pidPath)
 	if err != nil {
 		return
@@ -308,11 +372,30 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		return
 	}
 
+	err = utils.RemoveAll(tpmPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(tpmSockPath)
+	if err != nil {
+		return
+	}
+
 	err = utils.RemoveAll(hugepagesPath)
 	if err != nil {
 		return
 	}
 
+	store.RemDiskLimits(virt.Id)
+	store.RemBandwidth(virt.Id)
+	store.RemCpuAffinity(virt.Id)
+
+	err = node.Self.ReleaseCpus(db, virt.Id)
+	if err != nil {
+		return
+	}
+
 	err = permission.UserDelete(virt)
 	if err != nil {
 		return
@@ -322,6 +405,7 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 	store.RemDisks(virt.Id)
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
+	store.RemPeers(virt.Id)
 
 	return
 }
@@ -1,6 +1,7 @@
 package qemu
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/pritunl/pritunl-cloud/qmp"
@@ -32,3 +33,49 @@ func UpdateVmDisk(virt *vm.VirtualMachine) (err error) {
 
 	return
 }
+
+// getThrottleOptions returns the throttle group options for the disk
+// limits, unset limits are left unlimited.
+func getThrottleOptions(limits vm.DiskLimits) (opts string) {
+	options := []struct {
+		name   string
+		value  int
+		length bool
+	}{
+		{"x-iops-read", limits.ReadIops, false},
+		{"x-iops-write", limits.WriteIops, false},
+		{"x-bps-read", limits.ReadBps, false},
+		{"x-bps-write", limits.WriteBps, false},
+		{"x-iops-read-max", limits.ReadIopsMax, true},
+		{"x-iops-write-max", limits.WriteIopsMax, true},
+		{"x-bps-read-max", limits.ReadBpsMax, true},
+		{"x-bps-write-max", limits.WriteBpsMax, true},
+	}
+
+	for _, opt := range options {
+		if opt.value <= 0 {
+			continue
+		}
+
+		opts += fmt.Sprintf(",%s=%d", opt.name, opt.value)
+		if opt.length {
+			opts += fmt.Sprintf(",%s-length=%d",
+				opt.name, limits.GetBurstLength(opt.value))
+		}
+	}
+
+	return
+}
+
+func SetDiskLimits(virt *vm.VirtualMachine, disks []*vm.Disk) (err error) {
+	for _, dsk := range disks {
+		err = qmp.SetDiskLimits(virt.Id, dsk)
+		if err != nil {
+			return
+		}
+
+		store.SetDiskLimits(virt.Id, dsk.Id, dsk.Limits)
+	}
+
+	return
+}
@@ -328,7 +328,8 @@ func SetVmState(virt *vm.VirtualMachine, state string) {
 }
 
 func GetVms(db *database.Database,
-	instMap map[primitive.ObjectID]*instance.Instance) (
+	instMap map[primitive.ObjectID]*instance.Instance,
+	migrateMap map[primitive.ObjectID]*instance.Instance) (
 	virts []*vm.VirtualMachine, err error) {
 
 	systemdPath := settings.Hypervisor.SystemdPath
@@ -383,8 +384,12 @@ func GetVms(db *database.Database,
 
 					inst.State = instance.Cleanup
 					e = virt.CommitState(db, instance.Cleanup)
-				} else {
+				} else if inst != nil {
 					e = virt.Commit(db)
+				} else if migrateMap[vmId] == nil {
+					// Units of instances migrated to another node must not
+					// overwrite the state reported by the new node
+					e = virt.CommitNode(db, node.Self.Id)
 				}
 				if e != nil {
 					logrus.WithFields(logrus.Fields{
@@ -488,6 +493,11 @@ func Create(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	err = utils.ExistsMkdir(paths.GetLogsPath(), 0755)
+	if err != nil {
+		return
+	}
+
 	err = utils.ExistsMkdir(vmPath, 0755)
 	if err != nil {
 		return
@@ -530,8 +540,8 @@ func Create(db *database.Database, inst *instance.Instance,
 				return
 			}
 		} else {
-			backingImage, err = data.WriteImage(db, virt.Image, dsk.Id,
-				inst.InitDiskSize, inst.ImageBacking)
+			backingImage, err = data.WriteImage(db, inst.Organization,
+				virt.Image, dsk.Id, inst.InitDiskSize, inst.ImageBacking)
 			if err != nil {
 				return
 			}
@@ -544,12 +554,18 @@ func Create(db *database.Database, inst *instance.Instance,
 			return
 		}
 
+		err = disk.ResolveLimits(db, []*disk.Disk{dsk})
+		if err != nil {
+			return
+		}
+
 		_ = event.PublishDispatch(db, "disk.change")
 
 		virt.Disks = append(virt.Disks, &vm.Disk{
-			Id:    dsk.Id,
-			Index: 0,
-			Path:  paths.GetDiskPath(dsk.Id),
+			Id:     dsk.Id,
+			Index:  0,
+			Path:   paths.GetDiskPath(dsk.Id),
+			Limits: dsk.GetLimits(),
 		})
 	}
 
@@ -568,6 +584,16 @@ func Create(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	err = writeTpmState(virt)
+	if err != nil {
+		return
+	}
+
+	err = initCpus(db, virt)
+	if err != nil {
+		return
+	}
+
 	err = writeService(virt)
 	if err != nil {
 		return
@@ -594,6 +620,8 @@ func Create(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	pinCpus(virt)
+
 	if virt.Vnc {
 		err = qmp.VncPassword(virt.Id, inst.VncPassword)
 		if err != nil {
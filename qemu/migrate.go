@@ -0,0 +1,395 @@
+package qemu
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/cloudinit"
+	"github.com/pritunl/pritunl-cloud/constants"
+	"github.com/pritunl/pritunl-cloud/data"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/systemd"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+func MigrateIncoming(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (err error) {
+
+	vmPath := paths.GetVmPath(virt.Id)
+	unitName := paths.GetUnitName(virt.Id)
+
+	if constants.Interrupt {
+		return
+	}
+
+	addr := node.Self.GetMigrateAddr()
+	if addr == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("qemu: Missing node migration address"),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":      virt.Id.Hex(),
+		"address": addr,
+		"port":    inst.MigratePort,
+	}).Info("qemu: Preparing virtual machine migration")
+
+	virt.UnixId = inst.UnixId
+
+	err = utils.ExistsMkdir(settings.Hypervisor.LibPath, 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(settings.Hypervisor.RunPath, 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(paths.GetLogsPath(), 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(vmPath, 0755)
+	if err != nil {
+		return
+	}
+
+	for _, vmDsk := range virt.Disks {
+		dsk, e := disk.Get(db, vmDsk.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = data.CreateMigrateDisk(dsk)
+		if err != nil {
+			return
+		}
+	}
+
+	err = cloudinit.Write(db, inst, virt, false)
+	if err != nil {
+		return
+	}
+
+	err = initHugepage(virt)
+	if err != nil {
+		return
+	}
+
+	err = writeOvmfVars(virt)
+	if err != nil {
+		return
+	}
+
+	err = writeTpmState(virt)
+	if err != nil {
+		return
+	}
+
+	err = initCpus(db, virt)
+	if err != nil {
+		return
+	}
+
+	err = writeServiceIncoming(virt,
+		qmp.GetMigrateUri(addr, inst.MigratePort))
+	if err != nil {
+		return
+	}
+
+	err = initPermissions(virt)
+	if err != nil {
+		return
+	}
+
+	err = systemd.Start(unitName)
+	if err != nil {
+		return
+	}
+
+	err = Wait(db, virt)
+	if err != nil {
+		return
+	}
+
+	pinCpus(virt)
+
+	for i := 0; i < 10; i++ {
+		err = qmp.NbdStart(virt.Id, addr, inst.MigratePort+1)
+		if err == nil {
+			break
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range virt.Disks {
+		err = qmp.NbdExport(virt.Id, dsk.Id)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func MigrateFinish(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Finishing virtual machine migration")
+
+	err = qmp.NbdStop(virt.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"id":    virt.Id.Hex(),
+			"error": err,
+		}).Warn("qemu: Failed to stop migration nbd server")
+		err = nil
+	}
+
+	err = initCpus(db, virt)
+	if err != nil {
+		return
+	}
+
+	err = writeService(virt)
+	if err != nil {
+		return
+	}
+
+	if virt.Vnc {
+		err = qmp.VncPassword(virt.Id, inst.VncPassword)
+		if err != nil {
+			return
+		}
+	}
+
+	if virt.Spice {
+		err = qmp.SetPassword(virt.Id, qmp.Spice, inst.SpicePassword)
+		if err != nil {
+			return
+		}
+	}
+
+	err = NetworkConf(db, virt)
+	if err != nil {
+		return
+	}
+
+	store.RemVirt(virt.Id)
+	store.RemDisks(virt.Id)
+	store.RemAddress(virt.Id)
+	store.RemRoutes(virt.Id)
+	store.RemPeers(virt.Id)
+
+	return
+}
+
+func MigrateAbort(db *database.Database, virt *vm.VirtualMachine) (
+	err error) {
+
+	unitName := paths.GetUnitName(virt.Id)
+	unitPath := paths.GetUnitPath(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Aborting virtual machine migration")
+
+	exists, err := utils.Exists(unitPath)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = systemd.Stop(unitName)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, dsk := range virt.Disks {
+		err = utils.RemoveAll(paths.GetDiskPath(dsk.Id))
+		if err != nil {
+			return
+		}
+	}
+
+	err = removeFiles(db, virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Migrate(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine, nde *node.Node) (err error) {
+
+	addr := nde.GetMigrateAddr()
+	if addr == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("qemu: Missing node migration address"),
+		}
+		return
+	}
+
+	if inst.MigratePort == 0 {
+		err = &errortypes.ParseError{
+			errors.New("qemu: Missing migration port"),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":      virt.Id.Hex(),
+		"node_id": nde.Id.Hex(),
+		"address": addr,
+	}).Info("qemu: Migrating virtual machine")
+
+	dskIds := []primitive.ObjectID{}
+	for _, dsk := range virt.Disks {
+		dskIds = append(dskIds, dsk.Id)
+	}
+
+	timeout := time.Duration(settings.Hypervisor.MigrateTimeout) *
+		time.Second
+	start := time.Now()
+
+	for _, dskId := range dskIds {
+		err = qmp.MirrorDisk(virt.Id, dskId, addr, inst.MigratePort+1)
+		if err != nil {
+			migrateCancel(virt, dskIds)
+			return
+		}
+	}
+
+	for {
+		ready, e := qmp.MirrorCheck(virt.Id, dskIds)
+		if e != nil {
+			err = e
+			migrateCancel(virt, dskIds)
+			return
+		}
+
+		if ready {
+			break
+		}
+
+		if time.Since(start) > timeout {
+			err = &errortypes.TimeoutError{
+				errors.New("qemu: Disk mirror timeout"),
+			}
+			migrateCancel(virt, dskIds)
+			return
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	err = qmp.Migrate(virt.Id, addr, inst.MigratePort)
+	if err != nil {
+		migrateCancel(virt, dskIds)
+		return
+	}
+
+	for {
+		complete, e := qmp.MigrateCheck(virt.Id)
+		if e != nil {
+			err = e
+			migrateCancel(virt, dskIds)
+			return
+		}
+
+		if complete {
+			break
+		}
+
+		if time.Since(start) > timeout {
+			err = &errortypes.TimeoutError{
+				errors.New("qemu: Migration timeout"),
+			}
+			migrateCancel(virt, dskIds)
+			return
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	for _, dskId := range dskIds {
+		e := qmp.MirrorCancel(virt.Id, dskId)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"id":      virt.Id.Hex(),
+				"disk_id": dskId.Hex(),
+				"error":   e,
+			}).Warn("qemu: Failed to complete disk mirror")
+		}
+	}
+
+	return
+}
+
+func migrateCancel(virt *vm.VirtualMachine, dskIds []primitive.ObjectID) {
+	_ = qmp.MigrateCancel(virt.Id)
+
+	for _, dskId := range dskIds {
+		_ = qmp.MirrorCancel(virt.Id, dskId)
+	}
+}
+
+func MigrateClean(db *database.Database, virt *vm.VirtualMachine) (
+	err error) {
+
+	unitName := paths.GetUnitName(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Removing migrated virtual machine")
+
+	err = systemd.Stop(unitName)
+	if err != nil {
+		return
+	}
+
+	time.Sleep(3 * time.Second)
+
+	err = NetworkConfClear(db, virt)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range virt.Disks {
+		err = utils.RemoveAll(paths.GetDiskPath(dsk.Id))
+		if err != nil {
+			return
+		}
+	}
+
+	err = removeFiles(db, virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -79,6 +79,18 @@ func NetworkConf(db *database.Database,
 	return
 }
 
+func NetworkConfExternal(db *database.Database,
+	virt *vm.VirtualMachine) (err error) {
+
+	nc := netconf.New(virt)
+	err = nc.UpdateExternal(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func NetworkConfClearOld(virt *vm.VirtualMachine) (err error) {
 	if len(virt.NetworkAdapters) == 0 {
 		err = &errortypes.NotFoundError{
@@ -120,6 +132,7 @@ func NetworkConfClearOld(virt *vm.VirtualMachine) (err error) {
 
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
+	store.RemPeers(virt.Id)
 
 	return
 }
@@ -1355,6 +1368,7 @@ func NetworkConfOld(db *database.Database,
 
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
+	store.RemPeers(virt.Id)
 
 	hostIps := []string{}
 	if hostStaticAddr != nil {
@@ -0,0 +1,43 @@
+package qemu
+
+import (
+	"strings"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+func GetPeers(instId primitive.ObjectID) (ifaces []string, err error) {
+	namespace := vm.GetNamespace(instId, 0)
+
+	output, _ := utils.ExecCombinedOutputLogged(
+		[]string{
+			"Cannot open network namespace",
+		},
+		"ip", "netns", "exec", namespace,
+		"ip", "-o", "link", "show",
+	)
+
+	if output == "" {
+		return
+	}
+
+	ifaces = []string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		iface := strings.Split(strings.TrimSuffix(fields[1], ":"), "@")[0]
+		if len(iface) != 14 || !strings.HasPrefix(iface, "r") {
+			continue
+		}
+
+		ifaces = append(ifaces, iface)
+	}
+
+	return
+}
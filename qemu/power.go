@@ -7,6 +7,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/qmp"
 	"github.com/pritunl/pritunl-cloud/qms"
@@ -57,6 +58,11 @@ func PowerOn(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	err = utils.ExistsMkdir(paths.GetLogsPath(), 0755)
+	if err != nil {
+		return
+	}
+
 	err = cloudinit.Write(db, inst, virt, false)
 	if err != nil {
 		return
@@ -72,6 +78,16 @@ func PowerOn(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	err = writeTpmState(virt)
+	if err != nil {
+		return
+	}
+
+	err = initCpus(db, virt)
+	if err != nil {
+		return
+	}
+
 	err = writeService(virt)
 	if err != nil {
 		return
@@ -82,6 +98,8 @@ func PowerOn(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	store.RemDiskLimits(virt.Id)
+
 	err = systemd.Start(unitName)
 	if err != nil {
 		return
@@ -92,6 +110,13 @@ func PowerOn(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	pinCpus(virt)
+
+	// Disk limits are set by the throttle groups on start
+	for _, dsk := range virt.Disks {
+		store.SetDiskLimits(virt.Id, dsk.Id, dsk.Limits)
+	}
+
 	if virt.Vnc {
 		err = qmp.VncPassword(virt.Id, inst.VncPassword)
 		if err != nil {
@@ -202,10 +227,16 @@ func PowerOff(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		return
 	}
 
+	err = node.Self.ReleaseCpus(db, virt.Id)
+	if err != nil {
+		return
+	}
+
 	time.Sleep(3 * time.Second)
 
 	store.RemVirt(virt.Id)
 	store.RemDisks(virt.Id)
+	store.RemCpuAffinity(virt.Id)
 
 	return
 }
@@ -239,10 +270,16 @@ func ForcePowerOffErr(db *database.Database, virt *vm.VirtualMachine,
 		return
 	}
 
+	err = node.Self.ReleaseCpus(db, virt.Id)
+	if err != nil {
+		return
+	}
+
 	time.Sleep(3 * time.Second)
 
 	store.RemVirt(virt.Id)
 	store.RemDisks(virt.Id)
+	store.RemCpuAffinity(virt.Id)
 
 	return
 }
@@ -275,10 +312,16 @@ func ForcePowerOff(db *database.Database, virt *vm.VirtualMachine) (
 		return
 	}
 
+	err = node.Self.ReleaseCpus(db, virt.Id)
+	if err != nil {
+		return
+	}
+
 	time.Sleep(3 * time.Second)
 
 	store.RemVirt(virt.Id)
 	store.RemDisks(virt.Id)
+	store.RemCpuAffinity(virt.Id)
 
 	return
 }
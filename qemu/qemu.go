@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"fmt"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -17,6 +18,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/usb"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
 type Disk struct {
@@ -24,6 +26,7 @@ type Disk struct {
 	Index  int
 	File   string
 	Format string
+	Limits vm.DiskLimits
 }
 
 type Network struct {
@@ -69,6 +72,10 @@ type Qemu struct {
 	Boot         string
 	Uefi         bool
 	SecureBoot   bool
+	Tpm          bool
+	Dedicated    bool
+	CpuAffinity  []int
+	NumaNode     int
 	OvmfCodePath string
 	OvmfVarsPath string
 	Memory       int
@@ -87,6 +94,7 @@ type Qemu struct {
 	PciDevices   []*PciDevice
 	DriveDevices []*DriveDevice
 	IscsiDevices []*IscsiDevice
+	Incoming     string
 }
 
 func (q *Qemu) GetDiskQueues() (queues int) {
@@ -154,6 +162,8 @@ func (q *Qemu) Marshal() (output string, err error) {
 		return
 	}
 
+	numaBind := q.Dedicated && q.NumaNode >= 0
+
 	pciPassthrough := false
 	gpuPassthrough := false
 	if node.Self.PciPassthrough && len(q.PciDevices) > 0 {
@@ -279,7 +289,7 @@ func (q *Qemu) Marshal() (output string, err error) {
 	if q.SecureBoot {
 		options += ",smm=on"
 	}
-	if q.Hugepages && memoryBackend {
+	if (q.Hugepages || numaBind) && memoryBackend {
 		options += ",memory-backend=pc.ram"
 	}
 	if q.Kvm {
@@ -335,19 +345,37 @@ func (q *Qemu) Marshal() (output string, err error) {
 	cmd = append(cmd, "-m")
 	cmd = append(cmd, fmt.Sprintf("%dM", q.Memory))
 
+	hostNodes := ""
+	if numaBind {
+		hostNodes = fmt.Sprintf(",host-nodes=%d,policy=bind", q.NumaNode)
+	}
+
 	if q.Hugepages {
-		if memoryBackend {
+		if memoryBackend || numaBind {
 			cmd = append(cmd, "-object")
 			cmd = append(cmd, fmt.Sprintf(
 				"memory-backend-file,id=pc.ram,"+
-					"size=%dM,mem-path=%s,prealloc=off,share=off,merge=on",
+					"size=%dM,mem-path=%s,prealloc=off,share=off,merge=on%s",
 				q.Memory,
 				paths.GetHugepagePath(q.Id),
+				hostNodes,
 			))
 		} else {
 			cmd = append(cmd, "-mem-path")
 			cmd = append(cmd, paths.GetHugepagePath(q.Id))
 		}
+	} else if numaBind {
+		cmd = append(cmd, "-object")
+		cmd = append(cmd, fmt.Sprintf(
+			"memory-backend-ram,id=pc.ram,size=%dM,merge=on%s",
+			q.Memory,
+			hostNodes,
+		))
+	}
+
+	if numaBind && !memoryBackend {
+		cmd = append(cmd, "-numa")
+		cmd = append(cmd, "node,memdev=pc.ram")
 	}
 
 	diskAio := settings.Hypervisor.DiskAio
@@ -369,6 +397,15 @@ func (q *Qemu) Marshal() (output string, err error) {
 		dskId := fmt.Sprintf("fd_%s", disk.Id)
 		dskFileId := fmt.Sprintf("fdf_%s", disk.Id)
 		dskDevId := fmt.Sprintf("fdd_%s", disk.Id)
+		dskThrottleId := fmt.Sprintf("fdt_%s", disk.Id)
+		dskGroupId := fmt.Sprintf("tg_%s", disk.Id)
+
+		cmd = append(cmd, "-object")
+		cmd = append(cmd, fmt.Sprintf(
+			"throttle-group,id=%s%s",
+			dskGroupId,
+			getThrottleOptions(disk.Limits),
+		))
 
 		cmd = append(cmd, "-blockdev")
 		cmd = append(cmd, fmt.Sprintf(
@@ -388,10 +425,18 @@ func (q *Qemu) Marshal() (output string, err error) {
 			dskFileId,
 		))
 
+		cmd = append(cmd, "-blockdev")
+		cmd = append(cmd, fmt.Sprintf(
+			"driver=throttle,node-name=%s,throttle-group=%s,file=%s",
+			dskThrottleId,
+			dskGroupId,
+			dskId,
+		))
+
 		cmd = append(cmd, "-device")
 		cmd = append(cmd, fmt.Sprintf(
 			"virtio-blk-pci,drive=%s,num-queues=%d,id=%s,bus=diskbus%d",
-			dskId,
+			dskThrottleId,
 			q.GetDiskQueues(),
 			dskDevId,
 			disk.Index,
@@ -528,6 +573,11 @@ func (q *Qemu) Marshal() (output string, err error) {
 	cmd = append(cmd, "-pidfile")
 	cmd = append(cmd, paths.GetPidPath(q.Id))
 
+	if q.Incoming != "" {
+		cmd = append(cmd, "-incoming")
+		cmd = append(cmd, q.Incoming)
+	}
+
 	guestPath := paths.GetGuestPath(q.Id)
 	cmd = append(cmd, "-chardev")
 	cmd = append(cmd, fmt.Sprintf(
@@ -538,6 +588,55 @@ func (q *Qemu) Marshal() (output string, err error) {
 	cmd = append(cmd,
 		"virtserialport,chardev=guest,name=org.qemu.guest_agent.0")
 
+	cmd = append(cmd, "-chardev")
+	cmd = append(cmd, fmt.Sprintf(
+		"socket,path=%s,server=on,wait=off,id=serial0,"+
+			"logfile=%s,logappend=on",
+		paths.GetSerialPath(q.Id),
+		paths.GetSerialLogPath(q.Id),
+	))
+	cmd = append(cmd, "-serial")
+	cmd = append(cmd, "chardev:serial0")
+
+	serviceOptions := ""
+	if len(q.CpuAffinity) > 0 {
+		cpus := []string{}
+		for _, cpu := range q.CpuAffinity {
+			cpus = append(cpus, strconv.Itoa(cpu))
+		}
+
+		serviceOptions += fmt.Sprintf(
+			"\nCPUAffinity=%s", strings.Join(cpus, " "))
+	}
+
+	if q.Tpm {
+		swtpmPath, e := features.GetSwtpmPath()
+		if e != nil {
+			err = e
+			return
+		}
+
+		tpmSockPath := paths.GetTpmSockPath(q.Id)
+
+		serviceOptions += fmt.Sprintf(
+			"\nExecStartPre=%s socket --tpm2 --daemon --terminate "+
+				"--runas %s --tpmstate dir=%s,mode=0600 "+
+				"--ctrl type=unixio,path=%s",
+			swtpmPath,
+			permission.GetUserName(q.Id),
+			paths.GetTpmPath(q.Id),
+			tpmSockPath,
+		)
+
+		cmd = append(cmd, "-chardev")
+		cmd = append(cmd, fmt.Sprintf(
+			"socket,id=chrtpm,path=%s", tpmSockPath))
+		cmd = append(cmd, "-tpmdev")
+		cmd = append(cmd, "emulator,id=tpm0,chardev=chrtpm")
+		cmd = append(cmd, "-device")
+		cmd = append(cmd, "tpm-crb,tpmdev=tpm0")
+	}
+
 	if !settings.Hypervisor.NoSandbox {
 		cmd = append(cmd, "-sandbox")
 		cmd = append(cmd, "on,obsolete=deny,elevateprivileges=allow,"+
@@ -595,6 +694,7 @@ func (q *Qemu) Marshal() (output string, err error) {
 		systemdTemplate,
 		q.Data,
 		compositorEnv,
+		serviceOptions,
 		strings.Join(cmd, " "),
 	)
 	return
@@ -43,11 +43,13 @@ func GetRoutes(instId primitive.ObjectID) (routes []vpc.Route,
 				continue
 			}
 
-			if fields[4] != "97" {
+			if fields[4] == "95" {
+				if fields[0] != "0.0.0.0" || fields[1] == "0.0.0.0" {
+					continue
+				}
+			} else if fields[4] != "97" {
 				continue
-			}
-
-			if fields[0] == "0.0.0.0" || fields[1] == "0.0.0.0" {
+			} else if fields[0] == "0.0.0.0" || fields[1] == "0.0.0.0" {
 				continue
 			}
 
@@ -0,0 +1,75 @@
+package qemu
+
+import (
+	"io"
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/settings"
+)
+
+func RotateSerialLog(virtId primitive.ObjectID) (err error) {
+	limit := int64(settings.Hypervisor.SerialLogSize) * 1024
+	if limit <= 0 {
+		return
+	}
+
+	logPath := paths.GetSerialLogPath(virtId)
+
+	info, err := os.Stat(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		} else {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "qemu: Failed to stat serial log"),
+			}
+		}
+		return
+	}
+
+	if info.Size() < limit {
+		return
+	}
+
+	logFile, err := os.Open(logPath)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to open serial log"),
+		}
+		return
+	}
+	defer logFile.Close()
+
+	rotateFile, err := os.OpenFile(paths.GetSerialLogRotatePath(virtId),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "qemu: Failed to open serial log rotation"),
+		}
+		return
+	}
+	defer rotateFile.Close()
+
+	_, err = io.Copy(rotateFile, logFile)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "qemu: Failed to copy serial log"),
+		}
+		return
+	}
+
+	// Qemu opens the log in append mode so truncating in place is safe
+	err = os.Truncate(logPath, 0)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "qemu: Failed to truncate serial log"),
+		}
+		return
+	}
+
+	return
+}
@@ -49,6 +49,10 @@ func NewQemu(virt *vm.VirtualMachine) (qm *Qemu, err error) {
 		Boot:         "c",
 		Uefi:         virt.Uefi,
 		SecureBoot:   virt.SecureBoot,
+		Tpm:          virt.Tpm,
+		Dedicated:    virt.DedicatedCpus,
+		CpuAffinity:  getCpuAffinity(virt),
+		NumaNode:     virt.NumaNode,
 		OvmfCodePath: ovmfCodePath,
 		OvmfVarsPath: paths.GetOvmfVarsPath(virt.Id),
 		Memory:       virt.Memory,
@@ -75,6 +79,7 @@ func NewQemu(virt *vm.VirtualMachine) (qm *Qemu, err error) {
 			Index:  disk.Index,
 			File:   disk.Path,
 			Format: "qcow2",
+			Limits: disk.Limits,
 		})
 	}
 
@@ -0,0 +1,49 @@
+package qmp
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type CpuThread struct {
+	CpuIndex int `json:"cpu-index"`
+	ThreadId int `json:"thread-id"`
+}
+
+type cpuThreadsReturn struct {
+	Return []*CpuThread  `json:"return"`
+	Error  *CommandError `json:"error"`
+}
+
+func GetCpuThreads(vmId primitive.ObjectID) (
+	threads []*CpuThread, err error) {
+
+	cmd := &Command{
+		Execute: "query-cpus-fast",
+	}
+
+	returnData := &cpuThreadsReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	if returnData.Return == nil {
+		err = &errortypes.ParseError{
+			errors.Newf("qmp: Return nil"),
+		}
+		return
+	}
+
+	threads = returnData.Return
+
+	return
+}
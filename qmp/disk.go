@@ -37,6 +37,13 @@ type blockDevCache struct {
 	Direct  bool `json:"direct"`
 }
 
+type blockDevThrottleArgs struct {
+	Driver        string `json:"driver"`
+	NodeName      string `json:"node-name"`
+	ThrottleGroup string `json:"throttle-group"`
+	File          string `json:"file"`
+}
+
 type deviceAddArgs struct {
 	Id     string `json:"id"`
 	Driver string `json:"driver"`
@@ -58,6 +65,8 @@ func AddDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 	dskId := fmt.Sprintf("fd_%s", dsk.Id.Hex())
 	dskFileId := fmt.Sprintf("fdf_%s", dsk.Id.Hex())
 	dskDevId := fmt.Sprintf("fdd_%s", dsk.Id.Hex())
+	dskThrottleId := fmt.Sprintf("fdt_%s", dsk.Id.Hex())
+	dskGroupId := fmt.Sprintf("tg_%s", dsk.Id.Hex())
 
 	logrus.WithFields(logrus.Fields{
 		"instance_id": vmId.Hex(),
@@ -152,12 +161,67 @@ func AddDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 		return
 	}
 
+	cmd = &Command{
+		Execute: "object-add",
+		Arguments: &throttleGroupArgs{
+			QomType: "throttle-group",
+			Id:      dskGroupId,
+			Limits:  newThrottleLimits(dsk.Limits),
+		},
+	}
+
+	returnData = &CommandReturn{}
+	err = conn.Send(cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil &&
+		!strings.Contains(
+			strings.ToLower(returnData.Error.Desc),
+			"duplicate",
+		) {
+
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	cmd = &Command{
+		Execute: "blockdev-add",
+		Arguments: &blockDevThrottleArgs{
+			Driver:        "throttle",
+			NodeName:      dskThrottleId,
+			ThrottleGroup: dskGroupId,
+			File:          dskId,
+		},
+	}
+
+	returnData = &CommandReturn{}
+	err = conn.Send(cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil &&
+		!strings.Contains(
+			strings.ToLower(returnData.Error.Desc),
+			"duplicate",
+		) {
+
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
 	cmd = &Command{
 		Execute: "device_add",
 		Arguments: &deviceAddArgs{
 			Id:     dskDevId,
 			Driver: "virtio-blk-pci",
-			Drive:  dskId,
+			Drive:  dskThrottleId,
 			Bus:    fmt.Sprintf("diskbus%d", dsk.Index),
 		},
 	}
@@ -184,6 +248,8 @@ func RemoveDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 	dskId := fmt.Sprintf("fd_%s", dsk.Id.Hex())
 	dskFileId := fmt.Sprintf("fdf_%s", dsk.Id.Hex())
 	dskDevId := fmt.Sprintf("fdd_%s", dsk.Id.Hex())
+	dskThrottleId := fmt.Sprintf("fdt_%s", dsk.Id.Hex())
+	dskGroupId := fmt.Sprintf("tg_%s", dsk.Id.Hex())
 
 	logrus.WithFields(logrus.Fields{
 		"instance_id": vmId.Hex(),
@@ -265,6 +331,33 @@ func RemoveDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 		}
 	}
 
+	cmd = &Command{
+		Execute: "blockdev-del",
+		Arguments: &CommandNode{
+			NodeName: dskThrottleId,
+		},
+	}
+
+	returnData = &CommandReturn{}
+	err = conn.Send(cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"process of unplug") && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"not found") && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"failed to find") {
+
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
 	cmd = &Command{
 		Execute: "blockdev-del",
 		Arguments: &CommandNode{
@@ -319,6 +412,33 @@ func RemoveDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 		return
 	}
 
+	cmd = &Command{
+		Execute: "object-del",
+		Arguments: &CommandId{
+			Id: dskGroupId,
+		},
+	}
+
+	returnData = &CommandReturn{}
+	err = conn.Send(cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"process of unplug") && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"not found") && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"failed to find") {
+
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
 	return
 }
 
@@ -411,7 +531,8 @@ func GetDisks(vmId primitive.ObjectID) (disks []*vm.Disk, err error) {
 		var idSpl []string
 		if strings.HasPrefix(disk.Device, "disk_") {
 			idSpl = strings.Split(disk.Device, "_")
-		} else if strings.HasPrefix(disk.Inserted.NodeName, "fd_") {
+		} else if strings.HasPrefix(disk.Inserted.NodeName, "fd_") ||
+			strings.HasPrefix(disk.Inserted.NodeName, "fdt_") {
 			idSpl = strings.Split(disk.Inserted.NodeName, "_")
 		} else {
 			continue
@@ -0,0 +1,100 @@
+package qmp
+
+import (
+	"fmt"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+type throttleLimits struct {
+	IopsRead           int `json:"iops-read"`
+	IopsWrite          int `json:"iops-write"`
+	BpsRead            int `json:"bps-read"`
+	BpsWrite           int `json:"bps-write"`
+	IopsReadMax        int `json:"iops-read-max"`
+	IopsWriteMax       int `json:"iops-write-max"`
+	BpsReadMax         int `json:"bps-read-max"`
+	BpsWriteMax        int `json:"bps-write-max"`
+	IopsReadMaxLength  int `json:"iops-read-max-length"`
+	IopsWriteMaxLength int `json:"iops-write-max-length"`
+	BpsReadMaxLength   int `json:"bps-read-max-length"`
+	BpsWriteMaxLength  int `json:"bps-write-max-length"`
+}
+
+type throttleGroupArgs struct {
+	QomType string          `json:"qom-type"`
+	Id      string          `json:"id"`
+	Limits  *throttleLimits `json:"limits"`
+}
+
+type qomSetArgs struct {
+	Path     string      `json:"path"`
+	Property string      `json:"property"`
+	Value    interface{} `json:"value"`
+}
+
+func newThrottleLimits(limits vm.DiskLimits) *throttleLimits {
+	return &throttleLimits{
+		IopsRead:           limits.ReadIops,
+		IopsWrite:          limits.WriteIops,
+		BpsRead:            limits.ReadBps,
+		BpsWrite:           limits.WriteBps,
+		IopsReadMax:        limits.ReadIopsMax,
+		IopsWriteMax:       limits.WriteIopsMax,
+		BpsReadMax:         limits.ReadBpsMax,
+		BpsWriteMax:        limits.WriteBpsMax,
+		IopsReadMaxLength:  limits.GetBurstLength(limits.ReadIopsMax),
+		IopsWriteMaxLength: limits.GetBurstLength(limits.WriteIopsMax),
+		BpsReadMaxLength:   limits.GetBurstLength(limits.ReadBpsMax),
+		BpsWriteMaxLength:  limits.GetBurstLength(limits.WriteBpsMax),
+	}
+}
+
+// SetDiskLimits updates the limits of the disk throttle group on a running
+// virtual machine. The throttle group is created with the disk when the
+// virtual machine is started or the disk is connected.
+func SetDiskLimits(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
+	limits := dsk.Limits
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id":    vmId.Hex(),
+		"disk_id":        dsk.Id.Hex(),
+		"read_iops":      limits.ReadIops,
+		"write_iops":     limits.WriteIops,
+		"read_bps":       limits.ReadBps,
+		"write_bps":      limits.WriteBps,
+		"read_iops_max":  limits.ReadIopsMax,
+		"write_iops_max": limits.WriteIopsMax,
+		"read_bps_max":   limits.ReadBpsMax,
+		"write_bps_max":  limits.WriteBpsMax,
+		"burst_length":   limits.BurstLength,
+	}).Info("qmp: Setting virtual disk limits")
+
+	cmd := &Command{
+		Execute: "qom-set",
+		Arguments: &qomSetArgs{
+			Path:     fmt.Sprintf("/objects/tg_%s", dsk.Id.Hex()),
+			Property: "limits",
+			Value:    newThrottleLimits(limits),
+		},
+	}
+
+	returnData := &CommandReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	return
+}
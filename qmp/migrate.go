@@ -0,0 +1,334 @@
+package qmp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+type driveMirrorArgs struct {
+	JobId  string `json:"job-id"`
+	Device string `json:"device"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	Sync   string `json:"sync"`
+	Mode   string `json:"mode"`
+}
+
+type blockJobCancelArgs struct {
+	Device string `json:"device"`
+}
+
+type migrateArgs struct {
+	Uri string `json:"uri"`
+}
+
+type migrateStatus struct {
+	Status    string `json:"status"`
+	ErrorDesc string `json:"error-desc"`
+}
+
+type migrateStatusReturn struct {
+	Return *migrateStatus `json:"return"`
+	Error  *CommandError  `json:"error"`
+}
+
+type nbdServerAddrData struct {
+	Host string `json:"host"`
+	Port string `json:"port"`
+}
+
+type nbdServerAddr struct {
+	Type string            `json:"type"`
+	Data nbdServerAddrData `json:"data"`
+}
+
+type nbdServerStartArgs struct {
+	Addr nbdServerAddr `json:"addr"`
+}
+
+type blockExportAddArgs struct {
+	Type     string `json:"type"`
+	Id       string `json:"id"`
+	NodeName string `json:"node-name"`
+	Name     string `json:"name"`
+	Writable bool   `json:"writable"`
+}
+
+func getMirrorJobId(dskId primitive.ObjectID) string {
+	return fmt.Sprintf("mirror_%s", dskId.Hex())
+}
+
+func GetMigrateUri(addr string, port int) string {
+	return "tcp:" + net.JoinHostPort(addr, strconv.Itoa(port))
+}
+
+func runMigrateCommand(vmId primitive.ObjectID, cmd *Command) (err error) {
+	returnData := &CommandReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	return
+}
+
+func NbdStart(vmId primitive.ObjectID, addr string, port int) (err error) {
+	cmd := &Command{
+		Execute: "nbd-server-start",
+		Arguments: &nbdServerStartArgs{
+			Addr: nbdServerAddr{
+				Type: "inet",
+				Data: nbdServerAddrData{
+					Host: addr,
+					Port: strconv.Itoa(port),
+				},
+			},
+		},
+	}
+
+	err = runMigrateCommand(vmId, cmd)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func NbdExport(vmId, dskId primitive.ObjectID) (err error) {
+	dskNodeId := fmt.Sprintf("fd_%s", dskId.Hex())
+
+	cmd := &Command{
+		Execute: "block-export-add",
+		Arguments: &blockExportAddArgs{
+			Type:     "nbd",
+			Id:       fmt.Sprintf("nbd_%s", dskId.Hex()),
+			NodeName: dskNodeId,
+			Name:     dskNodeId,
+			Writable: true,
+		},
+	}
+
+	err = runMigrateCommand(vmId, cmd)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func NbdStop(vmId primitive.ObjectID) (err error) {
+	cmd := &Command{
+		Execute: "nbd-server-stop",
+	}
+
+	err = runMigrateCommand(vmId, cmd)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MirrorDisk(vmId, dskId primitive.ObjectID, addr string, port int) (
+	err error) {
+
+	dskNodeId := fmt.Sprintf("fd_%s", dskId.Hex())
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"disk_id":     dskId.Hex(),
+		"address":     addr,
+	}).Info("qmp: Mirroring disk")
+
+	cmd := &Command{
+		Execute: "drive-mirror",
+		Arguments: &driveMirrorArgs{
+			JobId:  getMirrorJobId(dskId),
+			Device: dskNodeId,
+			Target: fmt.Sprintf("nbd://%s/%s",
+				net.JoinHostPort(addr, strconv.Itoa(port)), dskNodeId),
+			Format: "raw",
+			Sync:   "full",
+			Mode:   "existing",
+		},
+	}
+
+	err = runMigrateCommand(vmId, cmd)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MirrorCheck(vmId primitive.ObjectID, dskIds []primitive.ObjectID) (
+	ready bool, err error) {
+
+	cmd := &Command{
+		Execute: "query-jobs",
+	}
+
+	returnData := &JobStatusReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	if returnData.Return == nil {
+		err = &errortypes.ParseError{
+			errors.Newf("qmp: Return nil"),
+		}
+		return
+	}
+
+	statuses := map[string]string{}
+	for _, status := range returnData.Return {
+		if status.Type == "mirror" {
+			statuses[status.Id] = status.Status
+		}
+	}
+
+	for _, dskId := range dskIds {
+		status, ok := statuses[getMirrorJobId(dskId)]
+		if !ok || status == "aborting" || status == "concluded" ||
+			status == "null" {
+
+			err = &errortypes.ApiError{
+				errors.Newf("qmp: Disk mirror job failed %s", dskId.Hex()),
+			}
+			return
+		}
+
+		if status != "ready" {
+			return
+		}
+	}
+
+	ready = true
+
+	return
+}
+
+func MirrorCancel(vmId, dskId primitive.ObjectID) (err error) {
+	cmd := &Command{
+		Execute: "block-job-cancel",
+		Arguments: &blockJobCancelArgs{
+			Device: getMirrorJobId(dskId),
+		},
+	}
+
+	err = runMigrateCommand(vmId, cmd)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Migrate(vmId primitive.ObjectID, addr string, port int) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"address":     addr,
+	}).Info("qmp: Migrating virtual machine")
+
+	cmd := &Command{
+		Execute: "migrate",
+		Arguments: &migrateArgs{
+			Uri: GetMigrateUri(addr, port),
+		},
+	}
+
+	err = runMigrateCommand(vmId, cmd)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MigrateCheck(vmId primitive.ObjectID) (complete bool, err error) {
+	cmd := &Command{
+		Execute: "query-migrate",
+	}
+
+	returnData := &migrateStatusReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	if returnData.Return == nil {
+		err = &errortypes.ParseError{
+			errors.Newf("qmp: Return nil"),
+		}
+		return
+	}
+
+	switch strings.ToLower(returnData.Return.Status) {
+	case "completed":
+		complete = true
+		break
+	case "failed", "cancelled", "cancelling":
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Migration failed %s",
+				returnData.Return.ErrorDesc),
+		}
+		break
+	}
+
+	return
+}
+
+func MigrateCancel(vmId primitive.ObjectID) (err error) {
+	cmd := &Command{
+		Execute: "migrate_cancel",
+	}
+
+	err = runMigrateCommand(vmId, cmd)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MigrateResume(vmId primitive.ObjectID) (err error) {
+	cmd := &Command{
+		Execute: "cont",
+	}
+
+	err = runMigrateCommand(vmId, cmd)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,97 @@
+package qmp
+
+import (
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type blockStatsReturn struct {
+	Return []blockStatsDevice `json:"return"`
+	Error  *CommandError      `json:"error"`
+}
+
+type blockStatsDevice struct {
+	Device   string          `json:"device"`
+	NodeName string          `json:"node-name"`
+	Stats    blockStatsStats `json:"stats"`
+}
+
+type blockStatsStats struct {
+	RdBytes         int64 `json:"rd_bytes"`
+	WrBytes         int64 `json:"wr_bytes"`
+	RdOperations    int64 `json:"rd_operations"`
+	WrOperations    int64 `json:"wr_operations"`
+	FlushOperations int64 `json:"flush_operations"`
+	RdTotalTimeNs   int64 `json:"rd_total_time_ns"`
+	WrTotalTimeNs   int64 `json:"wr_total_time_ns"`
+}
+
+type BlockStats struct {
+	Disk            primitive.ObjectID
+	ReadBytes       int64
+	WriteBytes      int64
+	ReadOperations  int64
+	WriteOperations int64
+	FlushOperations int64
+	ReadTimeNs      int64
+	WriteTimeNs     int64
+}
+
+func GetBlockStats(vmId primitive.ObjectID) (
+	stats []*BlockStats, err error) {
+
+	cmd := &Command{
+		Execute: "query-blockstats",
+	}
+
+	returnData := &blockStatsReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	stats = []*BlockStats{}
+	for _, device := range returnData.Return {
+		var idSpl []string
+		if strings.HasPrefix(device.Device, "disk_") {
+			idSpl = strings.Split(device.Device, "_")
+		} else if strings.HasPrefix(device.NodeName, "fd_") {
+			idSpl = strings.Split(device.NodeName, "_")
+		} else {
+			continue
+		}
+
+		if len(idSpl) < 2 {
+			continue
+		}
+
+		dskId, ok := utils.ParseObjectId(idSpl[1])
+		if !ok {
+			continue
+		}
+
+		stats = append(stats, &BlockStats{
+			Disk:            dskId,
+			ReadBytes:       device.Stats.RdBytes,
+			WriteBytes:      device.Stats.WrBytes,
+			ReadOperations:  device.Stats.RdOperations,
+			WriteOperations: device.Stats.WrOperations,
+			FlushOperations: device.Stats.FlushOperations,
+			ReadTimeNs:      device.Stats.RdTotalTimeNs,
+			WriteTimeNs:     device.Stats.WrTotalTimeNs,
+		})
+	}
+
+	return
+}
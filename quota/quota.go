@@ -0,0 +1,504 @@
+package quota
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/organization"
+)
+
+const reservationTtl = 5 * time.Minute
+
+type Usage struct {
+	Instances  int `bson:"instances" json:"instances"`
+	Processors int `bson:"processors" json:"processors"`
+	Memory     int `bson:"memory" json:"memory"`
+	Disk       int `bson:"disk" json:"disk"`
+	Snapshots  int `bson:"snapshots" json:"snapshots"`
+	PublicIps  int `bson:"public_ips" json:"public_ips"`
+}
+
+// Reservation holds requested usage that has passed the quota check but
+// is not yet stored.
+type Reservation struct {
+	Id        primitive.ObjectID `bson:"id" json:"id"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+	Usage     *Usage             `bson:"usage" json:"usage"`
+}
+
+// orgQuota is decoded separately from the organization to keep the quota
+// reservation fields out of organization commits.
+type orgQuota struct {
+	Id           primitive.ObjectID `bson:"_id"`
+	Quota        organization.Quota `bson:"quota"`
+	Version      int                `bson:"quota_version"`
+	Reservations []*Reservation     `bson:"quota_reservations"`
+}
+
+type Status struct {
+	Organization primitive.ObjectID  `json:"organization"`
+	Quota        *organization.Quota `json:"quota"`
+	Usage        *Usage              `json:"usage"`
+}
+
+// UploadSize returns the disk quota usage in gigabytes of an image upload.
+func UploadSize(size int64) int {
+	return int((size + 1073741823) / 1073741824)
+}
+
+func GetUsage(db *database.Database, orgId primitive.ObjectID) (
+	usage *Usage, err error) {
+
+	usage = &Usage{}
+
+	coll := db.Instances()
+
+	cursor, err := coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"instances": &bson.M{
+					"$sum": 1,
+				},
+				"processors": &bson.M{
+					"$sum": "$processors",
+				},
+				"memory": &bson.M{
+					"$sum": "$memory",
+				},
+				"public_ips": &bson.M{
+					"$sum": &bson.M{
+						"$cond": []interface{}{
+							"$no_public_address", 0, 1,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		err = cursor.Decode(usage)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	coll = db.Disks()
+
+	diskCursor, err := coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"disk": &bson.M{
+					"$sum": "$size",
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer diskCursor.Close(db)
+
+	for diskCursor.Next(db) {
+		diskUsage := &Usage{}
+		err = diskCursor.Decode(diskUsage)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		usage.Disk = diskUsage.Disk
+	}
+
+	err = diskCursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	// Root disks are created by the node after the instance is inserted,
+	// count the initial disk size of instances without disks until then
+	diskInstIds := []primitive.ObjectID{}
+
+	diskInstIdsInf, err := coll.Distinct(db, "instance", &bson.M{
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	for _, diskInstIdInf := range diskInstIdsInf {
+		if diskInstId, ok := diskInstIdInf.(primitive.ObjectID); ok {
+			diskInstIds = append(diskInstIds, diskInstId)
+		}
+	}
+
+	coll = db.Instances()
+
+	pendingCursor, err := coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+				"_id": &bson.M{
+					"$nin": diskInstIds,
+				},
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"disk": &bson.M{
+					"$sum": "$init_disk_size",
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer pendingCursor.Close(db)
+
+	for pendingCursor.Next(db) {
+		pendingUsage := &Usage{}
+		err = pendingCursor.Decode(pendingUsage)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		usage.Disk += pendingUsage.Disk
+	}
+
+	err = pendingCursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	coll = db.Images()
+
+	snapshots, err := coll.CountDocuments(db, &bson.M{
+		"organization": orgId,
+		"disk": &bson.M{
+			"$exists": true,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	usage.Snapshots = int(snapshots)
+
+	coll = db.ImageUploads()
+
+	uploadCursor, err := coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"size": &bson.M{
+					"$sum": "$size",
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer uploadCursor.Close(db)
+
+	for uploadCursor.Next(db) {
+		uploadUsage := &struct {
+			Size int64 `bson:"size"`
+		}{}
+		err = uploadCursor.Decode(uploadUsage)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		usage.Disk += UploadSize(uploadUsage.Size)
+	}
+
+	err = uploadCursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	coll = db.FloatingIps()
+
+	floatingIps, err := coll.CountDocuments(db, &bson.M{
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	usage.PublicIps += int(floatingIps)
+
+	return
+}
+
+func GetStatus(db *database.Database, orgId primitive.ObjectID) (
+	status *Status, err error) {
+
+	org, err := organization.Get(db, orgId)
+	if err != nil {
+		return
+	}
+
+	usage, err := GetUsage(db, orgId)
+	if err != nil {
+		return
+	}
+
+	status = &Status{
+		Organization: org.Id,
+		Quota:        &org.Quota,
+		Usage:        usage,
+	}
+
+	return
+}
+
+func exceeded(limit, used, requested int) bool {
+	return limit > 0 && requested > 0 && used+requested > limit
+}
+
+func positive(val int) int {
+	if val > 0 {
+		return val
+	}
+	return 0
+}
+
+func (u *Usage) add(usage *Usage) {
+	u.Instances += positive(usage.Instances)
+	u.Processors += positive(usage.Processors)
+	u.Memory += positive(usage.Memory)
+	u.Disk += positive(usage.Disk)
+	u.Snapshots += positive(usage.Snapshots)
+	u.PublicIps += positive(usage.PublicIps)
+}
+
+func (u *Usage) empty() bool {
+	return u.Instances <= 0 && u.Processors <= 0 && u.Memory <= 0 &&
+		u.Disk <= 0 && u.Snapshots <= 0 && u.PublicIps <= 0
+}
+
+func check(quota organization.Quota, usage, requested *Usage) (
+	errData *errortypes.ErrorData) {
+
+	if exceeded(quota.Instances, usage.Instances, requested.Instances) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_instances_exceeded",
+			Message: "Organization instance quota exceeded",
+		}
+		return
+	}
+
+	if exceeded(quota.Processors, usage.Processors, requested.Processors) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_processors_exceeded",
+			Message: "Organization processor quota exceeded",
+		}
+		return
+	}
+
+	if exceeded(quota.Memory, usage.Memory, requested.Memory) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_memory_exceeded",
+			Message: "Organization memory quota exceeded",
+		}
+		return
+	}
+
+	if exceeded(quota.Disk, usage.Disk, requested.Disk) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_disk_exceeded",
+			Message: "Organization disk quota exceeded",
+		}
+		return
+	}
+
+	if exceeded(quota.Snapshots, usage.Snapshots, requested.Snapshots) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_snapshots_exceeded",
+			Message: "Organization snapshot quota exceeded",
+		}
+		return
+	}
+
+	if exceeded(quota.PublicIps, usage.PublicIps, requested.PublicIps) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_public_ips_exceeded",
+			Message: "Organization public IP quota exceeded",
+		}
+		return
+	}
+
+	return
+}
+
+// Reserve checks the requested usage against the organization quota and
+// reserves it until the resource is stored and the reservation released.
+// Reservations are added with a conditional update on the organization
+// quota version, any concurrent reservation or release causes the usage
+// to be calculated again. Unreleased reservations expire after
+// reservationTtl. A zero reservation id is returned if nothing was
+// reserved.
+func Reserve(db *database.Database, orgId primitive.ObjectID,
+	requested *Usage) (resvId primitive.ObjectID,
+	errData *errortypes.ErrorData, err error) {
+
+	if orgId.IsZero() || requested.empty() {
+		return
+	}
+
+	coll := db.Organizations()
+
+	for i := 0; i < 20; i++ {
+		org := &orgQuota{}
+		err = coll.FindOneId(orgId, org)
+		if err != nil {
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+			}
+			return
+		}
+
+		if org.Quota == (organization.Quota{}) {
+			return
+		}
+
+		usage, e := GetUsage(db, orgId)
+		if e != nil {
+			err = e
+			return
+		}
+
+		now := time.Now()
+		reservations := []*Reservation{}
+		for _, resv := range org.Reservations {
+			if resv.Usage == nil || now.Sub(resv.Timestamp) > reservationTtl {
+				continue
+			}
+
+			usage.add(resv.Usage)
+			reservations = append(reservations, resv)
+		}
+
+		errData = check(org.Quota, usage, requested)
+		if errData != nil {
+			return
+		}
+
+		resv := &Reservation{
+			Id:        primitive.NewObjectID(),
+			Timestamp: now,
+			Usage:     requested,
+		}
+		reservations = append(reservations, resv)
+
+		query := bson.M{
+			"_id":           orgId,
+			"quota_version": org.Version,
+		}
+		if org.Version == 0 {
+			query["quota_version"] = &bson.M{
+				"$in": []interface{}{0, nil},
+			}
+		}
+
+		resp, e := coll.UpdateOne(db, query, &bson.M{
+			"$set": &bson.M{
+				"quota_reservations": reservations,
+			},
+			"$inc": &bson.M{
+				"quota_version": 1,
+			},
+		})
+		if e != nil {
+			err = database.ParseError(e)
+			return
+		}
+
+		if resp.MatchedCount > 0 {
+			resvId = resv.Id
+			return
+		}
+	}
+
+	err = &errortypes.DatabaseError{
+		errors.New("quota: Failed to reserve organization quota"),
+	}
+	return
+}
+
+// Release removes a reservation after the reserved resource has been
+// stored and is included in the organization usage.
+func Release(db *database.Database, orgId,
+	resvId primitive.ObjectID) (err error) {
+
+	if orgId.IsZero() || resvId.IsZero() {
+		return
+	}
+
+	coll := db.Organizations()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id": orgId,
+	}, &bson.M{
+		"$pull": &bson.M{
+			"quota_reservations": &bson.M{
+				"id": resvId,
+			},
+		},
+		"$inc": &bson.M{
+			"quota_version": 1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -27,8 +27,12 @@ type hypervisor struct {
 	HostNetworkName    string `bson:"host_network_name" default:"pritunlhost0"`
 	StartTimeout       int    `bson:"start_timeout" default:"45"`
 	StopTimeout        int    `bson:"stop_timeout" default:"180"`
+	MigrateTimeout     int    `bson:"migrate_timeout" default:"3600"`
 	RefreshRate        int    `bson:"refresh_rate" default:"90"`
 	SplashTime         int    `bson:"splash_time" default:"60"`
+	NatGatewayTtl      int    `bson:"nat_gateway_ttl" default:"30"`
+	UsageRate          int    `bson:"usage_rate" default:"60"`
+	SerialLogSize      int    `bson:"serial_log_size" default:"1024"`
 }
 
 func newHypervisor() interface{} {
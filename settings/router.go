@@ -17,6 +17,8 @@ type router struct {
 	ContinueTimeout     int    `bson:"continue_timeout" default:"10"`
 	MaxHeaderBytes      int    `bson:"max_header_bytes" default:"4194304"`
 	SkipVerify          bool   `bson:"skip_verify"`
+	StreamPortMin       int    `bson:"stream_port_min" default:"1024"`
+	StreamPortMax       int    `bson:"stream_port_max" default:"65535"`
 }
 
 func newRouter() interface{} {
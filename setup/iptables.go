@@ -39,17 +39,25 @@ func Iptables() (err error) {
 		return
 	}
 
-	err = ipset.Init(namespaces, instances, nodeFirewall, firewalls)
+	firewallsEgress, err := firewall.GetAllEgress(db, instances)
 	if err != nil {
 		return
 	}
 
-	err = iptables.Init(namespaces, instances, nodeFirewall, firewalls)
+	err = ipset.Init(namespaces, instances, nodeFirewall, firewalls,
+		firewallsEgress)
 	if err != nil {
 		return
 	}
 
-	err = ipset.InitNames(namespaces, instances, nodeFirewall, firewalls)
+	err = iptables.Init(namespaces, instances, nodeFirewall, firewalls,
+		firewallsEgress)
+	if err != nil {
+		return
+	}
+
+	err = ipset.InitNames(namespaces, instances, nodeFirewall, firewalls,
+		firewallsEgress)
 	if err != nil {
 		return
 	}
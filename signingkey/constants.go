@@ -0,0 +1,6 @@
+package signingkey
+
+const (
+	OpenPgp = "openpgp"
+	Ed25519 = "ed25519"
+)
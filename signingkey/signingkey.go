@@ -0,0 +1,123 @@
+package signingkey
+
+import (
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type SigningKey struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Comment      string             `bson:"comment" json:"comment"`
+	Type         string             `bson:"type" json:"type"`
+	Organization primitive.ObjectID `bson:"organization,omitempty" json:"organization"`
+	Storage      primitive.ObjectID `bson:"storage,omitempty" json:"storage"`
+	PublicKey    string             `bson:"public_key" json:"public_key"`
+	Fingerprint  string             `bson:"fingerprint" json:"fingerprint"`
+}
+
+func (s *SigningKey) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	s.PublicKey = strings.TrimSpace(s.PublicKey)
+
+	if s.PublicKey == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "public_key_required",
+			Message: "Missing required public key",
+		}
+		return
+	}
+
+	switch s.Type {
+	case OpenPgp:
+		keyring, e := parseOpenPgp(s.PublicKey)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "public_key_invalid",
+				Message: "Failed to parse OpenPGP public key",
+			}
+			return
+		}
+
+		fingerprints := []string{}
+		for _, entity := range keyring {
+			fingerprints = append(fingerprints, strings.ToUpper(
+				entity.PrimaryKey.KeyIdString()))
+		}
+		s.Fingerprint = strings.Join(fingerprints, ",")
+
+		break
+	case Ed25519:
+		key, e := parseEd25519(s.PublicKey)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "public_key_invalid",
+				Message: "Failed to parse ed25519 public key",
+			}
+			return
+		}
+
+		s.Fingerprint = key.fingerprint()
+
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "type_invalid",
+			Message: "Invalid signing key type",
+		}
+		return
+	}
+
+	return
+}
+
+func (s *SigningKey) Commit(db *database.Database) (err error) {
+	coll := db.SigningKeys()
+
+	err = coll.Commit(s.Id, s)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *SigningKey) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.SigningKeys()
+
+	err = coll.CommitFields(s.Id, s, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *SigningKey) Insert(db *database.Database) (err error) {
+	coll := db.SigningKeys()
+
+	if !s.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("signingkey: Signing key already exists"),
+		}
+		return
+	}
+
+	resp, err := coll.InsertOne(db, s)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	s.Id = resp.InsertedID.(primitive.ObjectID)
+
+	return
+}
@@ -0,0 +1,284 @@
+package signingkey
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func Get(db *database.Database, keyId primitive.ObjectID) (
+	key *SigningKey, err error) {
+
+	coll := db.SigningKeys()
+	key = &SigningKey{}
+
+	err = coll.FindOneId(keyId, key)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, keyId primitive.ObjectID) (
+	key *SigningKey, err error) {
+
+	coll := db.SigningKeys()
+	key = &SigningKey{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          keyId,
+		"organization": orgId,
+	}).Decode(key)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	keys []*SigningKey, err error) {
+
+	coll := db.SigningKeys()
+	keys = []*SigningKey{}
+
+	cursor, err := coll.Find(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		key := &SigningKey{}
+		err = cursor.Decode(key)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		keys = append(keys, key)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// GetTrusted returns the keys trusted for images booted by the
+// organization from the storage. Global keys have no organization or
+// storage, storage keys apply to all organizations when the organization
+// is unset.
+func GetTrusted(db *database.Database, orgId, storeId primitive.ObjectID) (
+	keys []*SigningKey, err error) {
+
+	coll := db.SigningKeys()
+	keys = []*SigningKey{}
+
+	query := []*bson.M{
+		&bson.M{
+			"organization": &bson.M{
+				"$exists": false,
+			},
+			"storage": &bson.M{
+				"$exists": false,
+			},
+		},
+	}
+
+	if !orgId.IsZero() {
+		query = append(query, &bson.M{
+			"organization": orgId,
+			"storage": &bson.M{
+				"$exists": false,
+			},
+		})
+	}
+
+	if !storeId.IsZero() {
+		query = append(query, &bson.M{
+			"organization": &bson.M{
+				"$exists": false,
+			},
+			"storage": storeId,
+		})
+
+		if !orgId.IsZero() {
+			query = append(query, &bson.M{
+				"organization": orgId,
+				"storage":      storeId,
+			})
+		}
+	}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"$or": query,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		key := &SigningKey{}
+		err = cursor.Decode(key)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		keys = append(keys, key)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (keys []*SigningKey, count int64, err error) {
+
+	coll := db.SigningKeys()
+	keys = []*SigningKey{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	maxPage := count / pageCount
+	if count == pageCount {
+		maxPage = 0
+	}
+	page = utils.Min64(page, maxPage)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		key := &SigningKey{}
+		err = cursor.Decode(key)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		keys = append(keys, key)
+		key = &SigningKey{}
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, keyId primitive.ObjectID) (err error) {
+	coll := db.SigningKeys()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": keyId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, keyId primitive.ObjectID) (
+	err error) {
+
+	coll := db.SigningKeys()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id":          keyId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database,
+	keyIds []primitive.ObjectID) (err error) {
+
+	coll := db.SigningKeys()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": keyIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId primitive.ObjectID,
+	keyIds []primitive.ObjectID) (err error) {
+
+	coll := db.SigningKeys()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": keyIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
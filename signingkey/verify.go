@@ -0,0 +1,323 @@
+package signingkey
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	minisignComment        = "untrusted comment:"
+	minisignTrustedComment = "trusted comment: "
+)
+
+type ed25519Key struct {
+	keyId []byte
+	key   ed25519.PublicKey
+}
+
+func (k *ed25519Key) fingerprint() string {
+	if k.keyId != nil {
+		keyId := make([]byte, len(k.keyId))
+		for i := range k.keyId {
+			keyId[i] = k.keyId[len(k.keyId)-1-i]
+		}
+		return strings.ToUpper(hex.EncodeToString(keyId))
+	}
+
+	hash := sha256.Sum256(k.key)
+	return strings.ToUpper(hex.EncodeToString(hash[:8]))
+}
+
+func parseOpenPgp(data string) (keyring openpgp.EntityList, err error) {
+	keyring, err = openpgp.ReadArmoredKeyRing(strings.NewReader(data))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "signingkey: Failed to parse OpenPGP key"),
+		}
+		return
+	}
+
+	return
+}
+
+// parseEd25519 parses a minisign public key, a PEM encoded PKIX public key
+// as used by cosign or a base64 encoded raw public key
+func parseEd25519(data string) (key *ed25519Key, err error) {
+	if strings.HasPrefix(data, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(data))
+		if block == nil {
+			err = &errortypes.ParseError{
+				errors.New("signingkey: Failed to decode PEM key"),
+			}
+			return
+		}
+
+		pubKey, e := x509.ParsePKIXPublicKey(block.Bytes)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "signingkey: Failed to parse PEM key"),
+			}
+			return
+		}
+
+		edKey, ok := pubKey.(ed25519.PublicKey)
+		if !ok {
+			err = &errortypes.ParseError{
+				errors.New("signingkey: PEM key is not ed25519"),
+			}
+			return
+		}
+
+		key = &ed25519Key{
+			key: edKey,
+		}
+		return
+	}
+
+	lines := strings.Split(data, "\n")
+	keyData := strings.TrimSpace(lines[len(lines)-1])
+
+	keyByt, err := base64.StdEncoding.DecodeString(keyData)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "signingkey: Failed to decode key"),
+		}
+		return
+	}
+
+	switch len(keyByt) {
+	case ed25519.PublicKeySize:
+		key = &ed25519Key{
+			key: ed25519.PublicKey(keyByt),
+		}
+		break
+	case 10 + ed25519.PublicKeySize:
+		if string(keyByt[:2]) != "Ed" {
+			err = &errortypes.ParseError{
+				errors.New("signingkey: Unknown minisign key algorithm"),
+			}
+			return
+		}
+
+		key = &ed25519Key{
+			keyId: keyByt[2:10],
+			key:   ed25519.PublicKey(keyByt[10:]),
+		}
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.New("signingkey: Invalid ed25519 key length"),
+		}
+		return
+	}
+
+	return
+}
+
+func verifyOpenPgp(keys []*SigningKey, imgPth string, sig []byte) (
+	signer *SigningKey, err error) {
+
+	for _, key := range keys {
+		if key.Type != OpenPgp {
+			continue
+		}
+
+		keyring, e := parseOpenPgp(key.PublicKey)
+		if e != nil {
+			continue
+		}
+
+		img, e := os.Open(imgPth)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "signingkey: Failed to open image"),
+			}
+			return
+		}
+
+		var entity *openpgp.Entity
+		if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+			entity, e = openpgp.CheckArmoredDetachedSignature(
+				keyring, img, bytes.NewReader(sig))
+		} else {
+			entity, e = openpgp.CheckDetachedSignature(
+				keyring, img, bytes.NewReader(sig))
+		}
+		img.Close()
+
+		if e == nil && entity != nil {
+			signer = key
+			return
+		}
+	}
+
+	err = &errortypes.VerificationError{
+		errors.New("signingkey: No trusted OpenPGP key matches signature"),
+	}
+	return
+}
+
+func hashImage(imgPth string) (hash []byte, err error) {
+	img, err := os.Open(imgPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "signingkey: Failed to open image"),
+		}
+		return
+	}
+	defer img.Close()
+
+	hasher, err := blake2b.New512(nil)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "signingkey: Failed to create hash"),
+		}
+		return
+	}
+
+	_, err = io.Copy(hasher, img)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "signingkey: Failed to read image"),
+		}
+		return
+	}
+
+	hash = hasher.Sum(nil)
+	return
+}
+
+// verifyMinisign verifies a prehashed minisign signature. Legacy
+// signatures sign the entire file and are not supported for disk images.
+func verifyMinisign(keys []*SigningKey, imgPth string, sig []byte) (
+	signer *SigningKey, err error) {
+
+	lines := strings.Split(strings.TrimSpace(string(sig)), "\n")
+	if len(lines) < 4 ||
+		!strings.HasPrefix(lines[2], minisignTrustedComment) {
+
+		err = &errortypes.ParseError{
+			errors.New("signingkey: Invalid minisign signature"),
+		}
+		return
+	}
+
+	sigByt, err := base64.StdEncoding.DecodeString(
+		strings.TrimSpace(lines[1]))
+	if err != nil || len(sigByt) != 10+ed25519.SignatureSize {
+		err = &errortypes.ParseError{
+			errors.New("signingkey: Invalid minisign signature data"),
+		}
+		return
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(
+		strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		err = &errortypes.ParseError{
+			errors.New("signingkey: Invalid minisign global signature"),
+		}
+		return
+	}
+
+	if string(sigByt[:2]) != "ED" {
+		err = &errortypes.VerificationError{
+			errors.New("signingkey: Minisign signature must be prehashed"),
+		}
+		return
+	}
+
+	sigKeyId := sigByt[2:10]
+	sigData := sigByt[10:]
+	trustedComment := []byte(strings.TrimRight(
+		strings.TrimPrefix(lines[2], minisignTrustedComment), "\r"))
+
+	var hash []byte
+	for _, key := range keys {
+		if key.Type != Ed25519 {
+			continue
+		}
+
+		edKey, e := parseEd25519(key.PublicKey)
+		if e != nil {
+			continue
+		}
+
+		if edKey.keyId != nil && !bytes.Equal(edKey.keyId, sigKeyId) {
+			continue
+		}
+
+		if hash == nil {
+			hash, err = hashImage(imgPth)
+			if err != nil {
+				return
+			}
+		}
+
+		if !ed25519.Verify(edKey.key, hash, sigData) {
+			continue
+		}
+
+		if !ed25519.Verify(edKey.key,
+			append(append([]byte{}, sigData...), trustedComment...),
+			globalSig) {
+
+			continue
+		}
+
+		signer = key
+		return
+	}
+
+	err = &errortypes.VerificationError{
+		errors.New("signingkey: No trusted ed25519 key matches signature"),
+	}
+	return
+}
+
+// Verify checks the detached signature of an image against the trusted
+// keys. Minisign signatures are detected by the comment header, all other
+// signatures are parsed as OpenPGP.
+func Verify(keys []*SigningKey, imgPth, sigPth string) (
+	signer *SigningKey, err error) {
+
+	sig, err := ioutil.ReadFile(sigPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "signingkey: Failed to read signature"),
+		}
+		return
+	}
+
+	if len(keys) == 0 {
+		err = &errortypes.VerificationError{
+			errors.New("signingkey: No trusted signing keys"),
+		}
+		return
+	}
+
+	if strings.HasPrefix(string(sig), minisignComment) {
+		signer, err = verifyMinisign(keys, imgPth, sig)
+	} else {
+		signer, err = verifyOpenPgp(keys, imgPth, sig)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
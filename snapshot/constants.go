@@ -0,0 +1,6 @@
+package snapshot
+
+const (
+	Snapshot = "snapshot"
+	Backup   = "backup"
+)
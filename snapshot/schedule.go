@@ -0,0 +1,147 @@
+package snapshot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type schedule struct {
+	mins       []bool
+	hours      []bool
+	days       []bool
+	months     []bool
+	weekdays   []bool
+	anyDay     bool
+	anyWeekday bool
+}
+
+func parseField(field string, min, max int) (vals []bool, err error) {
+	vals = make([]bool, max+1)
+
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		start := min
+		end := max
+
+		if strings.Contains(item, "/") {
+			parts := strings.SplitN(item, "/", 2)
+			item = parts[0]
+
+			step, err = strconv.Atoi(parts[1])
+			if err != nil || step < 1 {
+				err = &errortypes.ParseError{
+					errors.Newf("snapshot: Invalid schedule step '%s'",
+						parts[1]),
+				}
+				return
+			}
+		}
+
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Newf("snapshot: Invalid schedule value '%s'",
+						bounds[0]),
+				}
+				return
+			}
+
+			if len(bounds) > 1 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					err = &errortypes.ParseError{
+						errors.Newf("snapshot: Invalid schedule value '%s'",
+							bounds[1]),
+					}
+					return
+				}
+			} else if step == 1 {
+				end = start
+			}
+		}
+
+		if start < min || end > max || start > end {
+			err = &errortypes.ParseError{
+				errors.Newf("snapshot: Schedule value out of range '%s'",
+					field),
+			}
+			return
+		}
+
+		for i := start; i <= end; i += step {
+			vals[i] = true
+		}
+	}
+
+	return
+}
+
+func parseSchedule(spec string) (sched *schedule, err error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		err = &errortypes.ParseError{
+			errors.New("snapshot: Schedule must have five fields"),
+		}
+		return
+	}
+
+	sched = &schedule{
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}
+
+	sched.mins, err = parseField(fields[0], 0, 59)
+	if err != nil {
+		return
+	}
+
+	sched.hours, err = parseField(fields[1], 0, 23)
+	if err != nil {
+		return
+	}
+
+	sched.days, err = parseField(fields[2], 1, 31)
+	if err != nil {
+		return
+	}
+
+	sched.months, err = parseField(fields[3], 1, 12)
+	if err != nil {
+		return
+	}
+
+	sched.weekdays, err = parseField(fields[4], 0, 7)
+	if err != nil {
+		return
+	}
+
+	if sched.weekdays[7] {
+		sched.weekdays[0] = true
+	}
+
+	return
+}
+
+func (s *schedule) match(t time.Time) bool {
+	if !s.mins[t.Minute()] || !s.hours[t.Hour()] ||
+		!s.months[int(t.Month())] {
+
+		return false
+	}
+
+	day := s.days[t.Day()]
+	weekday := s.weekdays[int(t.Weekday())]
+
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
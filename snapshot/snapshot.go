@@ -0,0 +1,183 @@
+package snapshot
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+)
+
+type Policy struct {
+	Id             primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name           string               `bson:"name" json:"name"`
+	Comment        string               `bson:"comment" json:"comment"`
+	Organization   primitive.ObjectID   `bson:"organization,omitempty" json:"organization"`
+	Type           string               `bson:"type" json:"type"`
+	Schedule       string               `bson:"schedule" json:"schedule"`
+	Disks          []primitive.ObjectID `bson:"disks" json:"disks"`
+	NetworkRoles   []string             `bson:"network_roles" json:"network_roles"`
+	RetentionCount int                  `bson:"retention_count" json:"retention_count"`
+	RetentionAge   int                  `bson:"retention_age" json:"retention_age"`
+	sched          *schedule            `bson:"-" json:"-"`
+}
+
+func (p *Policy) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if p.Disks == nil {
+		p.Disks = []primitive.ObjectID{}
+	}
+
+	if p.NetworkRoles == nil {
+		p.NetworkRoles = []string{}
+	}
+
+	switch p.Type {
+	case Snapshot, Backup:
+		break
+	case "":
+		p.Type = Snapshot
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "invalid_type",
+			Message: "Invalid snapshot policy type",
+		}
+		return
+	}
+
+	sched, e := parseSchedule(p.Schedule)
+	if e != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "invalid_schedule",
+			Message: "Invalid snapshot policy schedule",
+		}
+		return
+	}
+	p.sched = sched
+
+	if p.RetentionCount < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "invalid_retention_count",
+			Message: "Invalid snapshot policy retention count",
+		}
+		return
+	}
+
+	if p.RetentionAge < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "invalid_retention_age",
+			Message: "Invalid snapshot policy retention age",
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Policy) Match(dsk *disk.Disk, inst *instance.Instance) bool {
+	for _, dskId := range p.Disks {
+		if dskId == dsk.Id {
+			return true
+		}
+	}
+
+	if p.Organization.IsZero() && len(p.NetworkRoles) == 0 {
+		return false
+	}
+
+	if !p.Organization.IsZero() && p.Organization != dsk.Organization {
+		return false
+	}
+
+	if len(p.NetworkRoles) == 0 {
+		return true
+	}
+
+	if inst == nil {
+		return false
+	}
+
+	roles := set.NewSet()
+	for _, role := range p.NetworkRoles {
+		roles.Add(role)
+	}
+
+	for _, role := range inst.NetworkRoles {
+		if roles.Contains(role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Policy) Scheduled(t time.Time) bool {
+	if p.sched == nil {
+		sched, err := parseSchedule(p.Schedule)
+		if err != nil {
+			return false
+		}
+		p.sched = sched
+	}
+
+	return p.sched.match(t.UTC())
+}
+
+func (p *Policy) Expired(timestamp time.Time) bool {
+	if p.RetentionAge == 0 {
+		return false
+	}
+
+	return time.Since(timestamp) > time.Duration(p.RetentionAge)*24*time.Hour
+}
+
+func (p *Policy) Commit(db *database.Database) (err error) {
+	coll := db.SnapshotPolicies()
+
+	err = coll.Commit(p.Id, p)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Policy) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.SnapshotPolicies()
+
+	err = coll.CommitFields(p.Id, p, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Policy) Insert(db *database.Database) (err error) {
+	coll := db.SnapshotPolicies()
+
+	if !p.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("snapshot: Policy already exists"),
+		}
+		return
+	}
+
+	resp, err := coll.InsertOne(db, p)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	p.Id = resp.InsertedID.(primitive.ObjectID)
+
+	return
+}
@@ -0,0 +1,183 @@
+package snapshot
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func Get(db *database.Database, polId primitive.ObjectID) (
+	pol *Policy, err error) {
+
+	coll := db.SnapshotPolicies()
+	pol = &Policy{}
+
+	err = coll.FindOneId(polId, pol)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	pols []*Policy, err error) {
+
+	coll := db.SnapshotPolicies()
+	pols = []*Policy{}
+
+	cursor, err := coll.Find(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		pol := &Policy{}
+		err = cursor.Decode(pol)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		pols = append(pols, pol)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (pols []*Policy, count int64, err error) {
+
+	coll := db.SnapshotPolicies()
+	pols = []*Policy{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	maxPage := count / pageCount
+	if count == pageCount {
+		maxPage = 0
+	}
+	page = utils.Min64(page, maxPage)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		pol := &Policy{}
+		err = cursor.Decode(pol)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		pols = append(pols, pol)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// releaseImages detaches the images created by removed policies, the
+// images are kept as regular images and are no longer pruned by retention.
+func releaseImages(db *database.Database, polIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.Images()
+
+	_, err = coll.UpdateMany(db, &bson.M{
+		"policy": &bson.M{
+			"$in": polIds,
+		},
+	}, &bson.M{
+		"$unset": &bson.M{
+			"policy": "",
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, polId primitive.ObjectID) (err error) {
+	coll := db.SnapshotPolicies()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": polId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	err = releaseImages(db, []primitive.ObjectID{polId})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, polIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.SnapshotPolicies()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": polIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	err = releaseImages(db, polIds)
+	if err != nil {
+		return
+	}
+
+	return
+}
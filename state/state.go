@@ -13,9 +13,12 @@ import (
 	"github.com/pritunl/pritunl-cloud/domain"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/firewall"
+	"github.com/pritunl/pritunl-cloud/floatingip"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/peering"
 	"github.com/pritunl/pritunl-cloud/qemu"
+	"github.com/pritunl/pritunl-cloud/snapshot"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
@@ -36,14 +39,20 @@ type State struct {
 	interfacesSet    set.Set
 	nodeFirewall     []*firewall.Rule
 	firewalls        map[string][]*firewall.Rule
+	firewallsEgress  map[string][]*firewall.Rule
 	disks            []*disk.Disk
+	snapshotPolicies []*snapshot.Policy
 	virtsMap         map[primitive.ObjectID]*vm.VirtualMachine
 	instances        []*instance.Instance
 	instancesMap     map[primitive.ObjectID]*instance.Instance
+	migrations       []*instance.Instance
 	instanceDisks    map[primitive.ObjectID][]*disk.Disk
 	domainRecordsMap map[primitive.ObjectID][]*domain.Record
 	vpcs             []*vpc.Vpc
 	vpcsMap          map[primitive.ObjectID]*vpc.Vpc
+	vpcPeersMap      map[primitive.ObjectID][]*vpc.Vpc
+	floatingIpsMap   map[primitive.ObjectID]*floatingip.FloatingIp
+	floatingAddrs    set.Set
 	addInstances     set.Set
 	remInstances     set.Set
 	running          []string
@@ -89,6 +98,10 @@ func (s *State) Instances() []*instance.Instance {
 	return s.instances
 }
 
+func (s *State) Migrations() []*instance.Instance {
+	return s.migrations
+}
+
 func (s *State) NodeFirewall() []*firewall.Rule {
 	return s.nodeFirewall
 }
@@ -97,6 +110,10 @@ func (s *State) Firewalls() map[string][]*firewall.Rule {
 	return s.firewalls
 }
 
+func (s *State) FirewallsEgress() map[string][]*firewall.Rule {
+	return s.firewallsEgress
+}
+
 func (s *State) DomainRecords(instId primitive.ObjectID) []*domain.Record {
 	return s.domainRecordsMap[instId]
 }
@@ -109,6 +126,10 @@ func (s *State) Disks() []*disk.Disk {
 	return s.disks
 }
 
+func (s *State) SnapshotPolicies() []*snapshot.Policy {
+	return s.snapshotPolicies
+}
+
 func (s *State) GetInstaceDisks(instId primitive.ObjectID) []*disk.Disk {
 	return s.instanceDisks[instId]
 }
@@ -121,6 +142,18 @@ func (s *State) Vpcs() []*vpc.Vpc {
 	return s.vpcs
 }
 
+func (s *State) VpcPeers(vpcId primitive.ObjectID) []*vpc.Vpc {
+	return s.vpcPeersMap[vpcId]
+}
+
+func (s *State) FloatingIp(instId primitive.ObjectID) *floatingip.FloatingIp {
+	return s.floatingIpsMap[instId]
+}
+
+func (s *State) IsFloatingAddr(addr string) bool {
+	return s.floatingAddrs.Contains(addr)
+}
+
 func (s *State) DiskInUse(instId, dskId primitive.ObjectID) bool {
 	curVirt := s.virtsMap[instId]
 
@@ -226,6 +259,11 @@ func (s *State) init() (err error) {
 	if err != nil {
 		return
 	}
+
+	err = disk.ResolveLimits(db, disks)
+	if err != nil {
+		return
+	}
 	s.disks = disks
 
 	instanceDisks := map[primitive.ObjectID][]*disk.Disk{}
@@ -238,6 +276,12 @@ func (s *State) init() (err error) {
 	}
 	s.instanceDisks = instanceDisks
 
+	snapshotPolicies, err := snapshot.GetAll(db, &bson.M{})
+	if err != nil {
+		return
+	}
+	s.snapshotPolicies = snapshotPolicies
+
 	instances, err := instance.GetAllVirtMapped(db, &bson.M{
 		"node": s.nodeSelf.Id,
 	}, instanceDisks)
@@ -251,7 +295,34 @@ func (s *State) init() (err error) {
 	}
 	s.instancesMap = instancesMap
 
-	curVirts, err := qemu.GetVms(db, instancesMap)
+	migrations, err := instance.GetAll(db, &bson.M{
+		"migrate_node": s.nodeSelf.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	migrationsMap := map[primitive.ObjectID]*instance.Instance{}
+	for _, inst := range migrations {
+		migrationsMap[inst.Id] = inst
+
+		dsks, e := disk.GetInstance(db, inst.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = disk.ResolveLimits(db, dsks)
+		if err != nil {
+			return
+		}
+
+		inst.LoadVirt(dsks)
+		instId.Add(inst.Id)
+	}
+	s.migrations = migrations
+
+	curVirts, err := qemu.GetVms(db, instancesMap, migrationsMap)
 	if err != nil {
 		return
 	}
@@ -275,6 +346,12 @@ func (s *State) init() (err error) {
 	s.nodeFirewall = nodeFirewall
 	s.firewalls = firewalls
 
+	firewallsEgress, err := firewall.GetAllEgress(db, instances)
+	if err != nil {
+		return
+	}
+	s.firewallsEgress = firewallsEgress
+
 	vpcs := []*vpc.Vpc{}
 	vpcsMap := map[primitive.ObjectID]*vpc.Vpc{}
 	if !s.nodeDatacenter.IsZero() {
@@ -290,6 +367,48 @@ func (s *State) init() (err error) {
 	s.vpcs = vpcs
 	s.vpcsMap = vpcsMap
 
+	vpcPeersMap := map[primitive.ObjectID][]*vpc.Vpc{}
+	if !s.nodeDatacenter.IsZero() {
+		peers, e := peering.GetDatacenter(db, s.nodeDatacenter)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, peer := range peers {
+			vc := vpcsMap[peer.Vpc]
+			peerVc := vpcsMap[peer.PeerVpc]
+			if vc == nil || peerVc == nil {
+				continue
+			}
+
+			vpcPeersMap[vc.Id] = append(vpcPeersMap[vc.Id], peerVc)
+			vpcPeersMap[peerVc.Id] = append(vpcPeersMap[peerVc.Id], vc)
+		}
+	}
+	s.vpcPeersMap = vpcPeersMap
+
+	floatingIpsMap := map[primitive.ObjectID]*floatingip.FloatingIp{}
+	floatingAddrs := set.NewSet()
+	if !s.nodeSelf.Zone.IsZero() {
+		fips, e := floatingip.GetZone(db, s.nodeSelf.Zone)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, fip := range fips {
+			if fip.Address != "" {
+				floatingAddrs.Add(fip.Address)
+			}
+			if !fip.Instance.IsZero() {
+				floatingIpsMap[fip.Instance] = fip
+			}
+		}
+	}
+	s.floatingIpsMap = floatingIpsMap
+	s.floatingAddrs = floatingAddrs
+
 	recrds, err := domain.GetRecordAll(db, &bson.M{
 		"node": s.nodeSelf.Id,
 	})
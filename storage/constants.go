@@ -4,6 +4,9 @@ const (
 	Public  = "public"
 	Private = "private"
 
+	S3         = "s3"
+	Filesystem = "filesystem"
+
 	AwsStandard         = "aws_standard"
 	AwsInfrequentAccess = "aws_infrequent_access"
 	AwsGlacier          = "aws_glacier"
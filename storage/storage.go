@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"path"
 	"strings"
 
 	"github.com/dropbox/godropbox/container/set"
@@ -15,15 +16,29 @@ type Storage struct {
 	Name      string             `bson:"name" json:"name"`
 	Comment   string             `bson:"comment" json:"comment"`
 	Type      string             `bson:"type" json:"type"`
+	Backend   string             `bson:"backend" json:"backend"`
 	Endpoint  string             `bson:"endpoint" json:"endpoint"`
 	Bucket    string             `bson:"bucket" json:"bucket"`
 	AccessKey string             `bson:"access_key" json:"access_key"`
 	SecretKey string             `bson:"secret_key" json:"secret_key"`
 	Insecure  bool               `bson:"insecure" json:"insecure"`
+	Path      string             `bson:"path" json:"path"`
 }
 
 func (s *Storage) IsOracle() bool {
-	return strings.Contains(strings.ToLower(s.Endpoint), "oracle")
+	return s.Backend != Filesystem &&
+		strings.Contains(strings.ToLower(s.Endpoint), "oracle")
+}
+
+func (s *Storage) IsFilesystem() bool {
+	return s.Backend == Filesystem
+}
+
+func (s *Storage) IsConfigured() bool {
+	if s.Backend == Filesystem {
+		return s.Path != ""
+	}
+	return s.Endpoint != ""
 }
 
 func (s *Storage) Validate(db *database.Database) (
@@ -33,6 +48,47 @@ func (s *Storage) Validate(db *database.Database) (
 		s.Type = Public
 	}
 
+	if s.Backend == "" {
+		s.Backend = S3
+	}
+
+	switch s.Backend {
+	case S3:
+		s.Path = ""
+		break
+	case Filesystem:
+		s.Path = strings.TrimSpace(s.Path)
+		if s.Path == "" || !path.IsAbs(s.Path) {
+			errData = &errortypes.ErrorData{
+				Error:   "storage_path_invalid",
+				Message: "Storage path must be an absolute path",
+			}
+			return
+		}
+		s.Path = path.Clean(s.Path)
+
+		if s.Path == "/" {
+			errData = &errortypes.ErrorData{
+				Error:   "storage_path_invalid",
+				Message: "Storage path cannot be the root directory",
+			}
+			return
+		}
+
+		s.Endpoint = ""
+		s.Bucket = ""
+		s.AccessKey = ""
+		s.SecretKey = ""
+		s.Insecure = false
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "storage_backend_invalid",
+			Message: "Invalid storage backend",
+		}
+		return
+	}
+
 	return
 }
 
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+var (
+	bandwidthStores     = map[primitive.ObjectID]BandwidthStore{}
+	bandwidthStoresLock = sync.Mutex{}
+)
+
+type BandwidthStore struct {
+	Ingress int
+	Egress  int
+}
+
+func GetBandwidth(virtId primitive.ObjectID) (
+	bandwidthStore BandwidthStore, ok bool) {
+
+	bandwidthStoresLock.Lock()
+	bandwidthStore, ok = bandwidthStores[virtId]
+	bandwidthStoresLock.Unlock()
+
+	return
+}
+
+func SetBandwidth(virtId primitive.ObjectID, ingress, egress int) {
+	bandwidthStoresLock.Lock()
+	bandwidthStores[virtId] = BandwidthStore{
+		Ingress: ingress,
+		Egress:  egress,
+	}
+	bandwidthStoresLock.Unlock()
+}
+
+func RemBandwidth(virtId primitive.ObjectID) {
+	bandwidthStoresLock.Lock()
+	delete(bandwidthStores, virtId)
+	bandwidthStoresLock.Unlock()
+}
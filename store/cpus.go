@@ -0,0 +1,32 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+var (
+	cpuAffinityStores     = map[primitive.ObjectID][]int{}
+	cpuAffinityStoresLock = sync.Mutex{}
+)
+
+func GetCpuAffinity(virtId primitive.ObjectID) (cpus []int, ok bool) {
+	cpuAffinityStoresLock.Lock()
+	cpus, ok = cpuAffinityStores[virtId]
+	cpuAffinityStoresLock.Unlock()
+
+	return
+}
+
+func SetCpuAffinity(virtId primitive.ObjectID, cpus []int) {
+	cpuAffinityStoresLock.Lock()
+	cpuAffinityStores[virtId] = cpus
+	cpuAffinityStoresLock.Unlock()
+}
+
+func RemCpuAffinity(virtId primitive.ObjectID) {
+	cpuAffinityStoresLock.Lock()
+	delete(cpuAffinityStores, virtId)
+	cpuAffinityStoresLock.Unlock()
+}
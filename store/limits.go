@@ -0,0 +1,45 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+var (
+	diskLimitsStores     = map[primitive.ObjectID]map[primitive.ObjectID]vm.DiskLimits{}
+	diskLimitsStoresLock = sync.Mutex{}
+)
+
+func GetDiskLimits(virtId, diskId primitive.ObjectID) (
+	limits vm.DiskLimits, ok bool) {
+
+	diskLimitsStoresLock.Lock()
+	disksLimits := diskLimitsStores[virtId]
+	if disksLimits != nil {
+		limits, ok = disksLimits[diskId]
+	}
+	diskLimitsStoresLock.Unlock()
+
+	return
+}
+
+func SetDiskLimits(virtId, diskId primitive.ObjectID,
+	limits vm.DiskLimits) {
+
+	diskLimitsStoresLock.Lock()
+	disksLimits := diskLimitsStores[virtId]
+	if disksLimits == nil {
+		disksLimits = map[primitive.ObjectID]vm.DiskLimits{}
+		diskLimitsStores[virtId] = disksLimits
+	}
+	disksLimits[diskId] = limits
+	diskLimitsStoresLock.Unlock()
+}
+
+func RemDiskLimits(virtId primitive.ObjectID) {
+	diskLimitsStoresLock.Lock()
+	delete(diskLimitsStores, virtId)
+	diskLimitsStoresLock.Unlock()
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+var (
+	peersStores     = map[primitive.ObjectID]PeersStore{}
+	peersStoresLock = sync.Mutex{}
+)
+
+type PeersStore struct {
+	Ifaces    []string
+	Timestamp time.Time
+}
+
+func GetPeers(instId primitive.ObjectID) (peersStore PeersStore, ok bool) {
+	peersStoresLock.Lock()
+	peersStore, ok = peersStores[instId]
+	peersStoresLock.Unlock()
+
+	if ok {
+		peersStore.Ifaces = append([]string{}, peersStore.Ifaces...)
+	}
+
+	return
+}
+
+func SetPeers(instId primitive.ObjectID, ifaces []string) {
+	peersStoresLock.Lock()
+	peersStores[instId] = PeersStore{
+		Ifaces:    append([]string{}, ifaces...),
+		Timestamp: time.Now(),
+	}
+	peersStoresLock.Unlock()
+}
+
+func RemPeers(instId primitive.ObjectID) {
+	peersStoresLock.Lock()
+	delete(peersStores, instId)
+	peersStoresLock.Unlock()
+}
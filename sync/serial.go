@@ -0,0 +1,17 @@
+package sync
+
+import (
+	"github.com/pritunl/pritunl-cloud/qemu"
+	"github.com/pritunl/pritunl-cloud/state"
+)
+
+func rotateSerialLogs(stat *state.State) (err error) {
+	for _, inst := range stat.Instances() {
+		err = qemu.RotateSerialLog(inst.Id)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
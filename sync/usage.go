@@ -0,0 +1,134 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/usage"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+var (
+	usageLast time.Time
+)
+
+func sampleUsage(stat *state.State) (err error) {
+	rate := time.Duration(settings.Hypervisor.UsageRate) * time.Second
+	if rate <= 0 {
+		return
+	}
+
+	now := time.Now()
+	if usageLast.IsZero() {
+		usageLast = now
+		return
+	}
+
+	elapsed := now.Sub(usageLast)
+	if elapsed < rate {
+		return
+	}
+
+	// Gaps from a stopped service are not billed beyond one extra period
+	if elapsed > 2*rate {
+		elapsed = 2 * rate
+	}
+	seconds := int64(elapsed / time.Second)
+	period := now.UTC().Truncate(time.Hour)
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	ndeId := stat.Node().Id
+
+	for _, inst := range stat.Instances() {
+		virt := stat.GetVirt(inst.Id)
+		if virt == nil || virt.State != vm.Running {
+			continue
+		}
+
+		rec := &usage.Record{
+			Organization: inst.Organization,
+			Node:         ndeId,
+			Type:         usage.Instance,
+			Resource:     inst.Id,
+			Name:         inst.Name,
+			Period:       period,
+			Timestamp:    now,
+			Processors:   inst.Processors,
+			Memory:       inst.Memory,
+		}
+
+		err = rec.Sample(db, seconds)
+		if err != nil {
+			return
+		}
+	}
+
+	disks := stat.Disks()
+	diskIds := []primitive.ObjectID{}
+
+	for _, dsk := range disks {
+		diskIds = append(diskIds, dsk.Id)
+
+		rec := &usage.Record{
+			Organization: dsk.Organization,
+			Node:         ndeId,
+			Type:         usage.Disk,
+			Resource:     dsk.Id,
+			Name:         dsk.Name,
+			Period:       period,
+			Timestamp:    now,
+			Size:         dsk.Size,
+		}
+
+		err = rec.Sample(db, seconds)
+		if err != nil {
+			return
+		}
+	}
+
+	// Last sample time is only advanced once all records are written so a
+	// failed sample is retried with the full elapsed time
+	if len(diskIds) == 0 {
+		usageLast = now
+		return
+	}
+
+	backups, err := usage.GetBackups(db, diskIds)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range disks {
+		count := backups[dsk.Id]
+		if count == 0 {
+			continue
+		}
+
+		// Backup images do not store a size so the source disk size is
+		// used as an upper bound for each backup
+		rec := &usage.Record{
+			Organization: dsk.Organization,
+			Node:         ndeId,
+			Type:         usage.Backup,
+			Resource:     dsk.Id,
+			Name:         dsk.Name,
+			Period:       period,
+			Timestamp:    now,
+			Size:         dsk.Size * count,
+		}
+
+		err = rec.Sample(db, seconds)
+		if err != nil {
+			return
+		}
+	}
+
+	usageLast = now
+
+	return
+}
@@ -17,14 +17,32 @@ import (
 func deployState() (err error) {
 	stat, err := state.GetState()
 	if err != nil {
+		deploy.ExpireNatGateways()
 		return
 	}
 
 	err = deploy.Deploy(stat)
 	if err != nil {
+		deploy.ExpireNatGateways()
 		return
 	}
 
+	err = sampleUsage(stat)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("sync: Failed to sample usage")
+		err = nil
+	}
+
+	err = rotateSerialLogs(stat)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("sync: Failed to rotate serial logs")
+		err = nil
+	}
+
 	return
 }
 
@@ -34,7 +52,8 @@ func syncNodeFirewall() {
 
 	if !node.Self.Firewall {
 		iptables.UpdateState(node.Self, []*instance.Instance{},
-			[]string{}, nil, map[string][]*firewall.Rule{})
+			[]string{}, nil, map[string][]*firewall.Rule{},
+			map[string][]*firewall.Rule{})
 		return
 	}
 
@@ -50,7 +69,8 @@ func syncNodeFirewall() {
 		ingress := firewall.MergeIngress(fires)
 
 		iptables.UpdateStateRecover(node.Self, []*instance.Instance{},
-			[]string{}, ingress, map[string][]*firewall.Rule{})
+			[]string{}, ingress, map[string][]*firewall.Rule{},
+			map[string][]*firewall.Rule{})
 
 		break
 	}
@@ -56,7 +56,8 @@ func backingCleanHandler(db *database.Database) (err error) {
 			if len(keys) != 3 {
 				continue
 			}
-			key := fmt.Sprintf("%s-%s", keys[1], keys[2])
+			key := fmt.Sprintf("%s-%s", keys[1],
+				strings.TrimSuffix(keys[2], ".signers"))
 
 			if !diskKeys.Contains(key) {
 				if time.Since(item.ModTime()) > 5*time.Minute {
@@ -14,6 +14,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/upload"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -33,6 +34,11 @@ func cacheCleanHandler(db *database.Database) (err error) {
 		return
 	}
 
+	upldIds, err := upload.GetAllIds(db)
+	if err != nil {
+		return
+	}
+
 	exists, err := utils.ExistsDir(cacheDir)
 	if !exists {
 		return
@@ -59,7 +65,8 @@ func cacheCleanHandler(db *database.Database) (err error) {
 				os.Remove(pth)
 				continue
 			}
-			key := fmt.Sprintf("%s-%s", keys[1], keys[2])
+			key := fmt.Sprintf("%s-%s", keys[1],
+				strings.TrimSuffix(keys[2], ".signers"))
 
 			if !imageKeys.Contains(key) {
 				if time.Since(item.ModTime()) > 5*time.Minute {
@@ -71,6 +78,20 @@ func cacheCleanHandler(db *database.Database) (err error) {
 					continue
 				}
 			}
+		} else if strings.HasPrefix(name, "upload-") {
+			upldId, ok := utils.ParseObjectId(
+				strings.TrimPrefix(name, "upload-"))
+			if ok && upldIds.Contains(upldId) {
+				continue
+			}
+
+			if time.Since(item.ModTime()) > 1*time.Hour {
+				logrus.WithFields(logrus.Fields{
+					"path": pth,
+				}).Info("task: Removing expired image upload")
+				os.Remove(pth)
+				continue
+			}
 		}
 	}
 
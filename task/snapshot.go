@@ -0,0 +1,81 @@
+package task
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/data"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/snapshot"
+	"github.com/sirupsen/logrus"
+)
+
+var snapshotPrune = &Task{
+	Name: "snapshot_prune",
+	Hours: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23},
+	Mins:    []int{7, 22, 37, 52},
+	Handler: snapshotPruneHandler,
+}
+
+func snapshotPruneHandler(db *database.Database) (err error) {
+	pols, err := snapshot.GetAll(db, &bson.M{})
+	if err != nil {
+		return
+	}
+
+	removed := false
+	for _, pol := range pols {
+		if pol.RetentionCount == 0 && pol.RetentionAge == 0 {
+			continue
+		}
+
+		imgs, e := image.GetPolicy(db, pol.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		counts := map[primitive.ObjectID]int{}
+		for _, img := range imgs {
+			counts[img.Disk] += 1
+
+			if (pol.RetentionCount == 0 ||
+				counts[img.Disk] <= pol.RetentionCount) &&
+				!pol.Expired(img.Id.Timestamp()) {
+
+				continue
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"policy_id": pol.Id.Hex(),
+				"disk_id":   img.Disk.Hex(),
+				"image_id":  img.Id.Hex(),
+				"image_key": img.Key,
+			}).Info("task: Removing expired snapshot")
+
+			e = data.DeleteImage(db, img.Id)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"policy_id": pol.Id.Hex(),
+					"image_id":  img.Id.Hex(),
+					"error":     e,
+				}).Error("task: Failed to remove expired snapshot")
+				continue
+			}
+
+			removed = true
+		}
+	}
+
+	if removed {
+		event.PublishDispatch(db, "image.change")
+	}
+
+	return
+}
+
+func init() {
+	register(snapshotPrune)
+}
@@ -0,0 +1,68 @@
+package task
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	stats     = map[string]*Stats{}
+	statsLock = sync.Mutex{}
+)
+
+type Stats struct {
+	Name     string
+	Finished int64
+	Failed   int64
+	Skipped  int64
+	Duration time.Duration
+	LastRun  time.Time
+}
+
+func recordStats(name, state string, duration time.Duration) {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+
+	stat := stats[name]
+	if stat == nil {
+		stat = &Stats{
+			Name: name,
+		}
+		stats[name] = stat
+	}
+
+	switch state {
+	case Finished:
+		stat.Finished += 1
+		break
+	case Failed:
+		stat.Failed += 1
+		break
+	default:
+		stat.Skipped += 1
+		return
+	}
+
+	stat.Duration += duration
+	stat.LastRun = time.Now()
+}
+
+func GetStats() (allStats []*Stats) {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+
+	allStats = []*Stats{}
+	for _, task := range registry {
+		stat := stats[task.Name]
+		if stat == nil {
+			allStats = append(allStats, &Stats{
+				Name: task.Name,
+			})
+		} else {
+			statCopy := *stat
+			allStats = append(allStats, &statCopy)
+		}
+	}
+
+	return
+}
@@ -59,19 +59,23 @@ func (t *Task) run(now time.Time) {
 	}
 
 	if !reserved {
+		recordStats(t.Name, "", 0)
 		return
 	}
 
+	start := time.Now()
 	err = t.Handler(db)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"task":  t.Name,
 			"error": err,
 		}).Error("task: Task failed")
+		recordStats(t.Name, Failed, time.Since(start))
 		job.Failed(db)
 		return
 	}
 
+	recordStats(t.Name, Finished, time.Since(start))
 	job.Finished(db)
 }
 
@@ -0,0 +1,95 @@
+package tc
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+const minBurst = 32768
+
+// Burst sized to hold 10ms of traffic at the configured rate
+func getBurst(rate int) string {
+	burst := rate * 1000000 / 8 / 100
+	if burst < minBurst {
+		burst = minBurst
+	}
+	return strconv.Itoa(burst)
+}
+
+var delIgnores = []string{
+	"No such file",
+	"Cannot find specified qdisc",
+	"Cannot delete qdisc with handle of zero",
+	"Invalid handle",
+}
+
+func exec(namespace string, ignores []string, args ...string) (err error) {
+	if namespace != "" {
+		args = append([]string{"netns", "exec", namespace, "tc"}, args...)
+		_, err = utils.ExecCombinedOutputLogged(ignores, "ip", args...)
+	} else {
+		_, err = utils.ExecCombinedOutputLogged(ignores, "tc", args...)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Ingress shapes packets transmitted on the interface and egress polices
+// packets received on it, matching the guest direction on a tap device.
+// Rates are in megabits per second and zero removes the limit.
+func SetLimits(namespace, iface string, ingress, egress int) (err error) {
+	if ingress > 0 {
+		err = exec(namespace, nil,
+			"qdisc", "replace", "dev", iface, "root",
+			"tbf",
+			"rate", fmt.Sprintf("%dmbit", ingress),
+			"burst", getBurst(ingress),
+			"latency", "50ms",
+		)
+		if err != nil {
+			return
+		}
+	} else {
+		err = exec(namespace, delIgnores,
+			"qdisc", "del", "dev", iface, "root",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	err = exec(namespace, delIgnores,
+		"qdisc", "del", "dev", iface, "ingress",
+	)
+	if err != nil {
+		return
+	}
+
+	if egress > 0 {
+		err = exec(namespace, nil,
+			"qdisc", "add", "dev", iface, "handle", "ffff:", "ingress",
+		)
+		if err != nil {
+			return
+		}
+
+		err = exec(namespace, nil,
+			"filter", "add", "dev", iface, "parent", "ffff:",
+			"matchall",
+			"action", "police",
+			"rate", fmt.Sprintf("%dmbit", egress),
+			"burst", getBurst(egress),
+			"conform-exceed", "drop/ok",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
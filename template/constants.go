@@ -0,0 +1,5 @@
+package template
+
+const (
+	MaxLaunch = 100
+)
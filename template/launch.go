@@ -0,0 +1,113 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"github.com/sirupsen/logrus"
+)
+
+func (t *Template) Launch(db *database.Database, name string, count int) (
+	insts []*instance.Instance, errData *errortypes.ErrorData, err error) {
+
+	if count < 1 || count > MaxLaunch {
+		errData = &errortypes.ErrorData{
+			Error:   "launch_count_invalid",
+			Message: fmt.Sprintf("Launch count must be 1-%d", MaxLaunch),
+		}
+		return
+	}
+
+	if name == "" {
+		name = t.Name + "-%d"
+	}
+
+	errData, err = t.Validate(db)
+	if err != nil || errData != nil {
+		return
+	}
+
+	zne, err := zone.Get(db, t.Zone)
+	if err != nil {
+		return
+	}
+
+	placement, err := node.NewPlacement(db, zne)
+	if err != nil {
+		return
+	}
+
+	insts = []*instance.Instance{}
+	for i := 0; i < count; i++ {
+		instName := ""
+		if strings.Contains(name, "%") {
+			instName = fmt.Sprintf(name, i+1)
+		} else {
+			instName = name
+		}
+
+		inst := t.NewInstance(primitive.NilObjectID, instName)
+
+		nde := placement.Reserve(inst.PlacementRequest())
+		if nde == nil {
+			insts = nil
+			errData = &errortypes.ErrorData{
+				Error:   "launch_capacity_unavailable",
+				Message: "Not enough node capacity in zone for launch group",
+			}
+			return
+		}
+
+		inst.Node = nde.Id
+
+		errData, err = inst.Validate(db)
+		if err != nil || errData != nil {
+			insts = nil
+			return
+		}
+
+		insts = append(insts, inst)
+	}
+
+	instIds := []primitive.ObjectID{}
+	for _, inst := range insts {
+		err = inst.Insert(db)
+		if err != nil {
+			insts = nil
+			rollbackLaunch(db, instIds)
+			return
+		}
+
+		instIds = append(instIds, inst.Id)
+	}
+
+	return
+}
+
+// rollbackLaunch removes the instances already inserted by a failed launch
+// so a partial launch group is not left behind.
+func rollbackLaunch(db *database.Database, instIds []primitive.ObjectID) {
+	if len(instIds) == 0 {
+		return
+	}
+
+	_, err := db.Instances().DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": instIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		logrus.WithFields(logrus.Fields{
+			"instance_ids": instIds,
+			"error":        err,
+		}).Error("template: Failed to rollback launch instances")
+	}
+}
@@ -0,0 +1,265 @@
+package template
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/drive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/iscsi"
+	"github.com/pritunl/pritunl-cloud/iso"
+	"github.com/pritunl/pritunl-cloud/pci"
+	"github.com/pritunl/pritunl-cloud/usb"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+type Template struct {
+	Id                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name                string             `bson:"name" json:"name"`
+	Comment             string             `bson:"comment" json:"comment"`
+	Organization        primitive.ObjectID `bson:"organization" json:"organization"`
+	Zone                primitive.ObjectID `bson:"zone" json:"zone"`
+	Vpc                 primitive.ObjectID `bson:"vpc" json:"vpc"`
+	Subnet              primitive.ObjectID `bson:"subnet" json:"subnet"`
+	OracleSubnet        string             `bson:"oracle_subnet" json:"oracle_subnet"`
+	Image               primitive.ObjectID `bson:"image" json:"image"`
+	ImageBacking        bool               `bson:"image_backing" json:"image_backing"`
+	Domain              primitive.ObjectID `bson:"domain,omitempty" json:"domain"`
+	Uefi                bool               `bson:"uefi" json:"uefi"`
+	SecureBoot          bool               `bson:"secure_boot" json:"secure_boot"`
+	Tpm                 bool               `bson:"tpm" json:"tpm"`
+	DedicatedCpus       bool               `bson:"dedicated_cpus" json:"dedicated_cpus"`
+	DeleteProtection    bool               `bson:"delete_protection" json:"delete_protection"`
+	SkipSourceDestCheck bool               `bson:"skip_source_dest_check" json:"skip_source_dest_check"`
+	RootEnabled         bool               `bson:"root_enabled" json:"root_enabled"`
+	InitDiskSize        int                `bson:"init_disk_size" json:"init_disk_size"`
+	Memory              int                `bson:"memory" json:"memory"`
+	Processors          int                `bson:"processors" json:"processors"`
+	NetworkRoles        []string           `bson:"network_roles" json:"network_roles"`
+	AntiAffinity        []string           `bson:"anti_affinity" json:"anti_affinity"`
+	Isos                []*iso.Iso         `bson:"isos" json:"isos"`
+	UsbDevices          []*usb.Device      `bson:"usb_devices" json:"usb_devices"`
+	PciDevices          []*pci.Device      `bson:"pci_devices" json:"pci_devices"`
+	DriveDevices        []*drive.Device    `bson:"drive_devices" json:"drive_devices"`
+	IscsiDevices        []*iscsi.Device    `bson:"iscsi_devices" json:"iscsi_devices"`
+	Vnc                 bool               `bson:"vnc" json:"vnc"`
+	Spice               bool               `bson:"spice" json:"spice"`
+	Gui                 bool               `bson:"gui" json:"gui"`
+	NoPublicAddress     bool               `bson:"no_public_address" json:"no_public_address"`
+	NoHostAddress       bool               `bson:"no_host_address" json:"no_host_address"`
+}
+
+func (t *Template) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if t.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if t.Zone.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "zone_required",
+			Message: "Missing required zone",
+		}
+		return
+	}
+
+	if t.Vpc.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_required",
+			Message: "Missing required VPC",
+		}
+		return
+	}
+
+	if t.Subnet.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_subnet_required",
+			Message: "Missing required VPC subnet",
+		}
+		return
+	}
+
+	vc, err := vpc.Get(db, t.Vpc)
+	if err != nil {
+		return
+	}
+
+	if vc.Organization != t.Organization {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_organization_invalid",
+			Message: "VPC not in template organization",
+		}
+		return
+	}
+
+	sub := vc.GetSubnet(t.Subnet)
+	if sub == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_subnet_missing",
+			Message: "VPC subnet does not exist",
+		}
+		return
+	}
+
+	if t.InitDiskSize != 0 && t.InitDiskSize < 10 {
+		errData = &errortypes.ErrorData{
+			Error:   "init_disk_size_invalid",
+			Message: "Disk size below minimum",
+		}
+		return
+	}
+
+	if t.Memory < 256 {
+		t.Memory = 256
+	}
+
+	if t.Processors < 1 {
+		t.Processors = 1
+	}
+
+	if t.NetworkRoles == nil {
+		t.NetworkRoles = []string{}
+	}
+
+	if t.AntiAffinity == nil {
+		t.AntiAffinity = []string{}
+	}
+
+	if t.Isos == nil {
+		t.Isos = []*iso.Iso{}
+	}
+
+	if t.UsbDevices == nil {
+		t.UsbDevices = []*usb.Device{}
+	}
+
+	if t.PciDevices == nil {
+		t.PciDevices = []*pci.Device{}
+	}
+
+	if t.DriveDevices == nil {
+		t.DriveDevices = []*drive.Device{}
+	}
+
+	if t.IscsiDevices == nil {
+		t.IscsiDevices = []*iscsi.Device{}
+	}
+
+	return
+}
+
+func (t *Template) NewInstance(nodeId primitive.ObjectID, name string) (
+	inst *instance.Instance) {
+
+	inst = &instance.Instance{
+		State:               instance.Start,
+		Organization:        t.Organization,
+		Zone:                t.Zone,
+		Vpc:                 t.Vpc,
+		Subnet:              t.Subnet,
+		OracleSubnet:        t.OracleSubnet,
+		Node:                nodeId,
+		Image:               t.Image,
+		ImageBacking:        t.ImageBacking,
+		Uefi:                t.Uefi,
+		SecureBoot:          t.SecureBoot,
+		Tpm:                 t.Tpm,
+		DedicatedCpus:       t.DedicatedCpus,
+		DeleteProtection:    t.DeleteProtection,
+		SkipSourceDestCheck: t.SkipSourceDestCheck,
+		Name:                name,
+		Comment:             t.Comment,
+		InitDiskSize:        t.InitDiskSize,
+		Memory:              t.Memory,
+		Processors:          t.Processors,
+		NetworkRoles:        append([]string{}, t.NetworkRoles...),
+		AntiAffinity:        append([]string{}, t.AntiAffinity...),
+		Isos:                []*iso.Iso{},
+		UsbDevices:          []*usb.Device{},
+		PciDevices:          []*pci.Device{},
+		DriveDevices:        []*drive.Device{},
+		IscsiDevices:        []*iscsi.Device{},
+		RootEnabled:         t.RootEnabled,
+		Vnc:                 t.Vnc,
+		Spice:               t.Spice,
+		Gui:                 t.Gui,
+		Domain:              t.Domain,
+		NoPublicAddress:     t.NoPublicAddress,
+		NoHostAddress:       t.NoHostAddress,
+	}
+
+	for _, is := range t.Isos {
+		isoCopy := *is
+		inst.Isos = append(inst.Isos, &isoCopy)
+	}
+	for _, device := range t.UsbDevices {
+		deviceCopy := *device
+		inst.UsbDevices = append(inst.UsbDevices, &deviceCopy)
+	}
+	for _, device := range t.PciDevices {
+		deviceCopy := *device
+		inst.PciDevices = append(inst.PciDevices, &deviceCopy)
+	}
+	for _, device := range t.DriveDevices {
+		deviceCopy := *device
+		inst.DriveDevices = append(inst.DriveDevices, &deviceCopy)
+	}
+	for _, device := range t.IscsiDevices {
+		deviceCopy := *device
+		inst.IscsiDevices = append(inst.IscsiDevices, &deviceCopy)
+	}
+
+	return
+}
+
+func (t *Template) Commit(db *database.Database) (err error) {
+	coll := db.Templates()
+
+	err = coll.Commit(t.Id, t)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (t *Template) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.Templates()
+
+	err = coll.CommitFields(t.Id, t, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (t *Template) Insert(db *database.Database) (err error) {
+	coll := db.Templates()
+
+	if !t.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("template: Template already exists"),
+		}
+		return
+	}
+
+	resp, err := coll.InsertOne(db, t)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	t.Id = resp.InsertedID.(primitive.ObjectID)
+
+	return
+}
@@ -0,0 +1,208 @@
+package template
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func Get(db *database.Database, tmplId primitive.ObjectID) (
+	tmpl *Template, err error) {
+
+	coll := db.Templates()
+	tmpl = &Template{}
+
+	err = coll.FindOneId(tmplId, tmpl)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, tmplId primitive.ObjectID) (
+	tmpl *Template, err error) {
+
+	coll := db.Templates()
+	tmpl = &Template{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          tmplId,
+		"organization": orgId,
+	}).Decode(tmpl)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	tmpls []*Template, err error) {
+
+	coll := db.Templates()
+	tmpls = []*Template{}
+
+	cursor, err := coll.Find(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		tmpl := &Template{}
+		err = cursor.Decode(tmpl)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		tmpls = append(tmpls, tmpl)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (tmpls []*Template, count int64, err error) {
+
+	coll := db.Templates()
+	tmpls = []*Template{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	maxPage := count / pageCount
+	if count == pageCount {
+		maxPage = 0
+	}
+	page = utils.Min64(page, maxPage)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		tmpl := &Template{}
+		err = cursor.Decode(tmpl)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		tmpls = append(tmpls, tmpl)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, tmplId primitive.ObjectID) (err error) {
+	coll := db.Templates()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": tmplId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, tmplId primitive.ObjectID) (
+	err error) {
+
+	coll := db.Templates()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id":          tmplId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, tmplIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.Templates()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": tmplIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId primitive.ObjectID,
+	tmplIds []primitive.ObjectID) (err error) {
+
+	coll := db.Templates()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": tmplIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
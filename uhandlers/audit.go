@@ -0,0 +1,53 @@
+package uhandlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/authorizer"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/sirupsen/logrus"
+)
+
+// auditChange records a change that has already been applied, failures
+// are logged and do not fail the request.
+func auditChange(c *gin.Context, db *database.Database, typ,
+	resource string, resourceId primitive.ObjectID,
+	changes []*audit.Change) {
+
+	err := newAuditChange(c, db, typ, resource, resourceId, changes)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"type":        typ,
+			"resource":    resource,
+			"resource_id": resourceId.Hex(),
+			"error":       err,
+		}).Error("audit: Failed to record change")
+	}
+}
+
+func newAuditChange(c *gin.Context, db *database.Database, typ,
+	resource string, resourceId primitive.ObjectID,
+	changes []*audit.Change) (err error) {
+
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		return
+	}
+
+	userId := primitive.NilObjectID
+	if usr != nil {
+		userId = usr.Id
+	}
+
+	err = audit.NewChange(db, c.Request, userId, userOrg, typ, resource,
+		resourceId, changes)
+	if err != nil {
+		return
+	}
+
+	return
+}
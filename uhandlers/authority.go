@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/authority"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -59,6 +60,8 @@ func authorityPut(c *gin.Context) {
 		return
 	}
 
+	fireOrig := audit.Snapshot(fire)
+
 	fire.Name = data.Name
 	fire.Comment = data.Comment
 	fire.Type = data.Type
@@ -95,6 +98,9 @@ func authorityPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "authority", fire.Id,
+		audit.Diff(fireOrig, fire, fields))
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, fire)
@@ -145,6 +151,8 @@ func authorityPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "authority", fire.Id, nil)
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, fire)
@@ -170,6 +178,9 @@ func authorityDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "authority", authorityId,
+		nil)
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, nil)
@@ -196,6 +207,11 @@ func authoritiesDelete(c *gin.Context) {
 		return
 	}
 
+	for _, authorityId := range data {
+		auditChange(c, db, audit.ResourceDelete, "authority",
+			authorityId, nil)
+	}
+
 	event.PublishDispatch(db, "authority.change")
 
 	c.JSON(200, nil)
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/balancer"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
@@ -18,17 +19,19 @@ import (
 )
 
 type balancerData struct {
-	Id           primitive.ObjectID   `json:"id"`
-	Name         string               `json:"name"`
-	Comment      string               `json:"comment"`
-	State        bool                 `json:"state"`
-	Type         string               `json:"type"`
-	Datacenter   primitive.ObjectID   `json:"datacenter"`
-	Certificates []primitive.ObjectID `json:"certificates"`
-	WebSockets   bool                 `json:"websockets"`
-	Domains      []*balancer.Domain   `json:"domains"`
-	Backends     []*balancer.Backend  `json:"backends"`
-	CheckPath    string               `json:"check_path"`
+	Id            primitive.ObjectID   `json:"id"`
+	Name          string               `json:"name"`
+	Comment       string               `json:"comment"`
+	State         bool                 `json:"state"`
+	Type          string               `json:"type"`
+	Datacenter    primitive.ObjectID   `json:"datacenter"`
+	Certificates  []primitive.ObjectID `json:"certificates"`
+	WebSockets    bool                 `json:"websockets"`
+	Domains       []*balancer.Domain   `json:"domains"`
+	Backends      []*balancer.Backend  `json:"backends"`
+	CheckPath     string               `json:"check_path"`
+	ListenPort    int                  `json:"listen_port"`
+	ProxyProtocol bool                 `json:"proxy_protocol"`
 }
 
 type balancersData struct {
@@ -63,6 +66,8 @@ func balancerPut(c *gin.Context) {
 		return
 	}
 
+	balncOrig := audit.Snapshot(balnc)
+
 	balnc.Name = data.Name
 	balnc.Comment = data.Comment
 	balnc.State = data.State
@@ -73,6 +78,8 @@ func balancerPut(c *gin.Context) {
 	balnc.Domains = data.Domains
 	balnc.Backends = data.Backends
 	balnc.CheckPath = data.CheckPath
+	balnc.ListenPort = data.ListenPort
+	balnc.ProxyProtocol = data.ProxyProtocol
 
 	exists, err := datacenter.ExistsOrg(db, userOrg, balnc.Datacenter)
 	if err != nil {
@@ -95,6 +102,8 @@ func balancerPut(c *gin.Context) {
 		"domains",
 		"backends",
 		"check_path",
+		"listen_port",
+		"proxy_protocol",
 	)
 
 	errData, err := balnc.Validate(db)
@@ -114,6 +123,9 @@ func balancerPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "balancer", balnc.Id,
+		audit.Diff(balncOrig, balnc, fields))
+
 	event.PublishDispatch(db, "balancer.change")
 
 	balnc.Json()
@@ -139,17 +151,19 @@ func balancerPost(c *gin.Context) {
 	}
 
 	balnc := &balancer.Balancer{
-		Name:         data.Name,
-		Comment:      data.Comment,
-		State:        data.State,
-		Type:         data.Type,
-		Organization: userOrg,
-		Datacenter:   data.Datacenter,
-		Certificates: data.Certificates,
-		WebSockets:   data.WebSockets,
-		Domains:      data.Domains,
-		Backends:     data.Backends,
-		CheckPath:    data.CheckPath,
+		Name:          data.Name,
+		Comment:       data.Comment,
+		State:         data.State,
+		Type:          data.Type,
+		Organization:  userOrg,
+		Datacenter:    data.Datacenter,
+		Certificates:  data.Certificates,
+		WebSockets:    data.WebSockets,
+		Domains:       data.Domains,
+		Backends:      data.Backends,
+		CheckPath:     data.CheckPath,
+		ListenPort:    data.ListenPort,
+		ProxyProtocol: data.ProxyProtocol,
 	}
 
 	exists, err := datacenter.ExistsOrg(db, userOrg, balnc.Datacenter)
@@ -179,6 +193,8 @@ func balancerPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "balancer", balnc.Id, nil)
+
 	event.PublishDispatch(db, "balancer.change")
 
 	balnc.Json()
@@ -206,6 +222,8 @@ func balancerDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "balancer", balancerId, nil)
+
 	event.PublishDispatch(db, "balancer.change")
 
 	c.JSON(200, nil)
@@ -232,6 +250,11 @@ func balancersDelete(c *gin.Context) {
 		return
 	}
 
+	for _, balancerId := range data {
+		auditChange(c, db, audit.ResourceDelete, "balancer", balancerId,
+			nil)
+	}
+
 	event.PublishDispatch(db, "balancer.change")
 
 	c.JSON(200, nil)
@@ -4,6 +4,7 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/certificate"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -46,6 +47,8 @@ func certificatePut(c *gin.Context) {
 		return
 	}
 
+	certOrig := audit.Snapshot(cert)
+
 	cert.Name = data.Name
 	cert.Comment = data.Comment
 	cert.Key = data.Key
@@ -81,6 +84,9 @@ func certificatePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "certificate", cert.Id,
+		audit.Diff(certOrig, cert, fields))
+
 	event.PublishDispatch(db, "certificate.change")
 
 	c.JSON(200, cert)
@@ -132,6 +138,8 @@ func certificatePost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "certificate", cert.Id, nil)
+
 	event.PublishDispatch(db, "certificate.change")
 
 	c.JSON(200, cert)
@@ -157,6 +165,8 @@ func certificateDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "certificate", certId, nil)
+
 	event.PublishDispatch(db, "certificate.change")
 
 	c.JSON(200, nil)
@@ -11,6 +11,7 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/aggregate"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
@@ -83,6 +84,8 @@ func diskPut(c *gin.Context) {
 		return
 	}
 
+	dskOrig := audit.Snapshot(dsk)
+
 	fields := set.NewSet(
 		"name",
 		"comment",
@@ -174,6 +177,9 @@ func diskPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "disk", dsk.Id,
+		audit.Diff(dskOrig, dsk, fields))
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, dsk)
@@ -299,6 +305,8 @@ func diskPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "disk", dsk.Id, nil)
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, dsk)
@@ -342,6 +350,16 @@ func disksPut(c *gin.Context) {
 		return
 	}
 
+	for _, diskId := range data.Ids {
+		auditChange(c, db, audit.ResourceUpdate, "disk", diskId,
+			[]*audit.Change{
+				&audit.Change{
+					Field: "state",
+					New:   data.State,
+				},
+			})
+	}
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, nil)
@@ -382,6 +400,8 @@ func diskDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "disk", diskId, nil)
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, nil)
@@ -411,6 +431,10 @@ func disksDelete(c *gin.Context) {
 		return
 	}
 
+	for _, diskId := range dta {
+		auditChange(c, db, audit.ResourceDelete, "disk", diskId, nil)
+	}
+
 	event.PublishDispatch(db, "disk.change")
 
 	c.JSON(200, nil)
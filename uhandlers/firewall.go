@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/errortypes"
@@ -24,6 +25,7 @@ type firewallData struct {
 	Comment      string             `json:"comment"`
 	NetworkRoles []string           `json:"network_roles"`
 	Ingress      []*firewall.Rule   `json:"ingress"`
+	Egress       []*firewall.Rule   `json:"egress"`
 }
 
 type firewallsData struct {
@@ -61,16 +63,20 @@ func firewallPut(c *gin.Context) {
 		return
 	}
 
+	fireOrig := audit.Snapshot(fire)
+
 	fire.Name = data.Name
 	fire.Comment = data.Comment
 	fire.NetworkRoles = data.NetworkRoles
 	fire.Ingress = data.Ingress
+	fire.Egress = data.Egress
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"network_roles",
 		"ingress",
+		"egress",
 	)
 
 	errData, err := fire.Validate(db)
@@ -90,6 +96,9 @@ func firewallPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "firewall", fire.Id,
+		audit.Diff(fireOrig, fire, fields))
+
 	event.PublishDispatch(db, "firewall.change")
 
 	c.JSON(200, fire)
@@ -121,6 +130,7 @@ func firewallPost(c *gin.Context) {
 		Organization: userOrg,
 		NetworkRoles: data.NetworkRoles,
 		Ingress:      data.Ingress,
+		Egress:       data.Egress,
 	}
 
 	errData, err := fire.Validate(db)
@@ -140,6 +150,8 @@ func firewallPost(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceCreate, "firewall", fire.Id, nil)
+
 	event.PublishDispatch(db, "firewall.change")
 
 	c.JSON(200, fire)
@@ -165,6 +177,8 @@ func firewallDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "firewall", firewallId, nil)
+
 	event.PublishDispatch(db, "firewall.change")
 
 	c.JSON(200, nil)
@@ -194,6 +208,11 @@ func firewallsDelete(c *gin.Context) {
 		return
 	}
 
+	for _, firewallId := range data {
+		auditChange(c, db, audit.ResourceDelete, "firewall", firewallId,
+			nil)
+	}
+
 	event.PublishDispatch(db, "firewall.change")
 
 	c.JSON(200, nil)
@@ -105,16 +105,29 @@ func Register(engine *gin.Engine) {
 	orgGroup.DELETE("/firewall", firewallsDelete)
 	orgGroup.DELETE("/firewall/:firewall_id", firewallDelete)
 
+	orgGroup.GET("/floating_ip", floatingIpsGet)
+	orgGroup.GET("/floating_ip/:fip_id", floatingIpGet)
+	orgGroup.PUT("/floating_ip/:fip_id", floatingIpPut)
+	orgGroup.POST("/floating_ip", floatingIpPost)
+	orgGroup.DELETE("/floating_ip/:fip_id", floatingIpDelete)
+
 	orgGroup.GET("/image", imagesGet)
 	orgGroup.GET("/image/:image_id", imageGet)
+	orgGroup.GET("/image/:image_id/download", imageDownloadGet)
 	orgGroup.PUT("/image/:image_id", imagePut)
 	orgGroup.DELETE("/image", imagesDelete)
 	orgGroup.DELETE("/image/:image_id", imageDelete)
 
+	orgGroup.POST("/image_upload", imageUploadPost)
+	orgGroup.GET("/image_upload/:upload_id", imageUploadGet)
+	orgGroup.PUT("/image_upload/:upload_id", imageUploadPut)
+	orgGroup.DELETE("/image_upload/:upload_id", imageUploadDelete)
+
 	orgGroup.GET("/instance", instancesGet)
 	orgGroup.PUT("/instance", instancesPut)
 	orgGroup.GET("/instance/:instance_id", instanceGet)
 	orgGroup.GET("/instance/:instance_id/vnc", instanceVncGet)
+	orgGroup.GET("/instance/:instance_id/serial", instanceSerialGet)
 	orgGroup.PUT("/instance/:instance_id", instancePut)
 	orgGroup.POST("/instance", instancePost)
 	orgGroup.DELETE("/instance", instancesDelete)
@@ -125,6 +138,29 @@ func Register(engine *gin.Engine) {
 	orgGroup.GET("/node", nodesGet)
 
 	csrfGroup.GET("/organization", organizationsGet)
+	orgGroup.GET("/organization/quota", organizationQuotaGet)
+
+	orgGroup.GET("/peering", peeringsGet)
+	orgGroup.GET("/peering/:peering_id", peeringGet)
+	orgGroup.PUT("/peering/:peering_id", peeringPut)
+	orgGroup.PUT("/peering/:peering_id/accept", peeringAcceptPut)
+	orgGroup.POST("/peering", peeringPost)
+	orgGroup.DELETE("/peering/:peering_id", peeringDelete)
+
+	orgGroup.GET("/signing_key", signingKeysGet)
+	orgGroup.GET("/signing_key/:key_id", signingKeyGet)
+	orgGroup.PUT("/signing_key/:key_id", signingKeyPut)
+	orgGroup.POST("/signing_key", signingKeyPost)
+	orgGroup.DELETE("/signing_key", signingKeysDelete)
+	orgGroup.DELETE("/signing_key/:key_id", signingKeyDelete)
+
+	orgGroup.GET("/template", templatesGet)
+	orgGroup.GET("/template/:template_id", templateGet)
+	orgGroup.PUT("/template/:template_id", templatePut)
+	orgGroup.POST("/template", templatePost)
+	orgGroup.POST("/template/:template_id/launch", templateLaunchPost)
+	orgGroup.DELETE("/template", templatesDelete)
+	orgGroup.DELETE("/template/:template_id", templateDelete)
 
 	csrfGroup.PUT("/theme", themePut)
 
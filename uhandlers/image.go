@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
@@ -17,13 +18,15 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
 type imageData struct {
-	Id      primitive.ObjectID `json:"id"`
-	Name    string             `json:"name"`
-	Comment string             `json:"comment"`
+	Id        primitive.ObjectID `json:"id"`
+	Name      string             `json:"name"`
+	Comment   string             `json:"comment"`
+	NetConfig string             `json:"net_config"`
 }
 
 type imagesData struct {
@@ -61,12 +64,16 @@ func imagePut(c *gin.Context) {
 		return
 	}
 
+	imgOrig := audit.Snapshot(img)
+
 	img.Name = dta.Name
 	img.Comment = dta.Comment
+	img.NetConfig = dta.NetConfig
 
 	fields := set.NewSet(
 		"name",
 		"comment",
+		"net_config",
 	)
 
 	errData, err := img.Validate(db)
@@ -86,6 +93,9 @@ func imagePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "image", img.Id,
+		audit.Diff(imgOrig, img, fields))
+
 	event.PublishDispatch(db, "image.change")
 
 	c.JSON(200, img)
@@ -111,6 +121,8 @@ func imageDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "image", imageId, nil)
+
 	event.PublishDispatch(db, "image.change")
 
 	c.JSON(200, nil)
@@ -137,6 +149,10 @@ func imagesDelete(c *gin.Context) {
 		return
 	}
 
+	for _, imageId := range dta {
+		auditChange(c, db, audit.ResourceDelete, "image", imageId, nil)
+	}
+
 	event.PublishDispatch(db, "image.change")
 
 	c.JSON(200, nil)
@@ -163,6 +179,43 @@ func imageGet(c *gin.Context) {
 	c.JSON(200, img)
 }
 
+func imageDownloadGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	imageId, ok := utils.ParseObjectId(c.Param("image_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	img, err := image.GetOrg(db, userOrg, imageId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if img.Type != storage.Private {
+		errData := &errortypes.ErrorData{
+			Error:   "image_download_invalid",
+			Message: "Only private images can be downloaded",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = data.ExportImage(db, img, c.Writer)
+	if err != nil {
+		if c.Writer.Written() {
+			c.Error(err)
+			c.Abort()
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+}
+
 func imagesGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 	userOrg := c.MustGet("organization").(primitive.ObjectID)
@@ -11,6 +11,7 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/aggregate"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
@@ -24,6 +25,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/iscsi"
 	"github.com/pritunl/pritunl-cloud/iso"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/pci"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/usb"
@@ -49,12 +51,15 @@ type instanceData struct {
 	RootEnabled         bool               `json:"root_enabled"`
 	Uefi                bool               `json:"uefi"`
 	SecureBoot          bool               `json:"secure_boot"`
+	Tpm                 bool               `json:"tpm"`
+	DedicatedCpus       bool               `json:"dedicated_cpus"`
 	DeleteProtection    bool               `json:"delete_protection"`
 	SkipSourceDestCheck bool               `json:"skip_source_dest_check"`
 	InitDiskSize        int                `json:"init_disk_size"`
 	Memory              int                `json:"memory"`
 	Processors          int                `json:"processors"`
 	NetworkRoles        []string           `json:"network_roles"`
+	AntiAffinity        []string           `json:"anti_affinity"`
 	Isos                []*iso.Iso         `json:"isos"`
 	UsbDevices          []*usb.Device      `json:"usb_devices"`
 	PciDevices          []*pci.Device      `json:"pci_devices"`
@@ -108,6 +113,8 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	instOrig := audit.Snapshot(inst)
+
 	exists, err := vpc.ExistsOrg(db, userOrg, dta.Vpc)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -142,11 +149,14 @@ func instancePut(c *gin.Context) {
 	}
 	inst.Uefi = dta.Uefi
 	inst.SecureBoot = dta.SecureBoot
+	inst.Tpm = dta.Tpm
+	inst.DedicatedCpus = dta.DedicatedCpus
 	inst.DeleteProtection = dta.DeleteProtection
 	inst.SkipSourceDestCheck = dta.SkipSourceDestCheck
 	inst.Memory = dta.Memory
 	inst.Processors = dta.Processors
 	inst.NetworkRoles = dta.NetworkRoles
+	inst.AntiAffinity = dta.AntiAffinity
 	inst.Isos = dta.Isos
 	inst.UsbDevices = dta.UsbDevices
 	inst.PciDevices = dta.PciDevices
@@ -172,11 +182,14 @@ func instancePut(c *gin.Context) {
 		"restart_block_ip",
 		"uefi",
 		"secure_boot",
+		"tpm",
+		"dedicated_cpus",
 		"delete_protection",
 		"skip_source_dest_check",
 		"memory",
 		"processors",
 		"network_roles",
+		"anti_affinity",
 		"isos",
 		"usb_devices",
 		"pci_devices",
@@ -219,6 +232,9 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "instance", inst.Id,
+		audit.Diff(instOrig, inst, fields))
+
 	event.PublishDispatch(db, "instance.change")
 	if dskChange {
 		event.PublishDispatch(db, "disk.change")
@@ -263,15 +279,17 @@ func instancePost(c *gin.Context) {
 		return
 	}
 
-	nde, err := node.Get(db, dta.Node)
-	if err != nil {
-		utils.AbortWithError(c, 500, err)
-		return
-	}
+	if !dta.Node.IsZero() {
+		nde, err := node.Get(db, dta.Node)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
 
-	if nde.Zone != zne.Id {
-		utils.AbortWithStatus(c, 405)
-		return
+		if nde.Zone != zne.Id {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
 	}
 
 	exists, err = vpc.ExistsOrg(db, userOrg, dta.Vpc)
@@ -338,6 +356,30 @@ func instancePost(c *gin.Context) {
 
 			return
 		}
+
+		if !img.Signed {
+			org, err := organization.Get(db, userOrg)
+			if err != nil {
+				utils.AbortWithError(c, 500, err)
+				return
+			}
+
+			if org.RequireSignedImages {
+				errData := &errortypes.ErrorData{
+					Error:   "image_unsigned",
+					Message: "Organization requires signed images",
+				}
+				c.JSON(400, errData)
+				return
+			}
+		}
+
+		if dta.Processors == 0 && img.Processors > 0 {
+			dta.Processors = img.Processors
+		}
+		if dta.Memory == 0 && img.Memory > 0 {
+			dta.Memory = img.Memory
+		}
 	}
 
 	insts := []*instance.Instance{}
@@ -366,6 +408,8 @@ func instancePost(c *gin.Context) {
 			ImageBacking:        dta.ImageBacking,
 			Uefi:                dta.Uefi,
 			SecureBoot:          dta.SecureBoot,
+			Tpm:                 dta.Tpm,
+			DedicatedCpus:       dta.DedicatedCpus,
 			DeleteProtection:    dta.DeleteProtection,
 			SkipSourceDestCheck: dta.SkipSourceDestCheck,
 			Name:                name,
@@ -374,6 +418,7 @@ func instancePost(c *gin.Context) {
 			Memory:              dta.Memory,
 			Processors:          dta.Processors,
 			NetworkRoles:        dta.NetworkRoles,
+			AntiAffinity:        dta.AntiAffinity,
 			Isos:                dta.Isos,
 			UsbDevices:          dta.UsbDevices,
 			PciDevices:          dta.PciDevices,
@@ -405,6 +450,9 @@ func instancePost(c *gin.Context) {
 			return
 		}
 
+		auditChange(c, db, audit.ResourceCreate, "instance", inst.Id,
+			nil)
+
 		insts = append(insts, inst)
 	}
 
@@ -450,6 +498,16 @@ func instancesPut(c *gin.Context) {
 		return
 	}
 
+	for _, instId := range dta.Ids {
+		auditChange(c, db, audit.ResourceUpdate, "instance", instId,
+			[]*audit.Change{
+				&audit.Change{
+					Field: "state",
+					New:   dta.State,
+				},
+			})
+	}
+
 	event.PublishDispatch(db, "instance.change")
 
 	c.JSON(200, nil)
@@ -490,6 +548,9 @@ func instanceDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "instance", instanceId,
+		nil)
+
 	event.PublishDispatch(db, "instance.change")
 
 	c.JSON(200, nil)
@@ -519,6 +580,11 @@ func instancesDelete(c *gin.Context) {
 		return
 	}
 
+	for _, instId := range dta {
+		auditChange(c, db, audit.ResourceDelete, "instance", instId,
+			nil)
+	}
+
 	event.PublishDispatch(db, "instance.change")
 
 	c.JSON(200, nil)
@@ -700,3 +766,34 @@ func instanceVncGet(c *gin.Context) {
 		return
 	}
 }
+
+func instanceSerialGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	inst, err := instance.GetOrg(db, userOrg, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = inst.SerialConnect(db, c.Writer, c.Request)
+	if err != nil {
+		if _, ok := err.(*instance.SerialDialError); ok {
+			utils.AbortWithStatus(c, 504)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+}
@@ -2,9 +2,11 @@ package uhandlers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/authorizer"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/organization"
+	"github.com/pritunl/pritunl-cloud/quota"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -26,3 +28,16 @@ func organizationsGet(c *gin.Context) {
 
 	c.JSON(200, orgs)
 }
+
+func organizationQuotaGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	status, err := quota.GetStatus(db, userOrg)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, status)
+}
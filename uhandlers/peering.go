@@ -0,0 +1,317 @@
+package uhandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/peering"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type peeringData struct {
+	Id      primitive.ObjectID `json:"id"`
+	Name    string             `json:"name"`
+	Comment string             `json:"comment"`
+	Vpc     primitive.ObjectID `json:"vpc"`
+	PeerVpc primitive.ObjectID `json:"peer_vpc"`
+}
+
+type peeringsData struct {
+	Peerings []*peering.Peering `json:"peerings"`
+	Count    int64              `json:"count"`
+}
+
+func peeringPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &peeringData{}
+
+	peerId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	peer, err := peering.GetOrg(db, userOrg, peerId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if peer.Organization != userOrg {
+		utils.AbortWithStatus(c, 405)
+		return
+	}
+
+	peerOrig := audit.Snapshot(peer)
+
+	peer.Name = data.Name
+	peer.Comment = data.Comment
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+	)
+
+	errData, err := peer.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = peer.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceUpdate, "peering", peer.Id,
+		audit.Diff(peerOrig, peer, fields))
+
+	event.PublishDispatch(db, "peering.change")
+
+	c.JSON(200, peer)
+}
+
+func peeringAcceptPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	peerId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	peer, err := peering.GetOrg(db, userOrg, peerId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if peer.PeerOrganization != userOrg {
+		utils.AbortWithStatus(c, 405)
+		return
+	}
+
+	peerOrig := audit.Snapshot(peer)
+
+	peer.State = peering.Active
+
+	fields := set.NewSet(
+		"state",
+	)
+
+	errData, err := peer.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = peer.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceUpdate, "peering", peer.Id,
+		audit.Diff(peerOrig, peer, fields))
+
+	event.PublishDispatch(db, "peering.change")
+
+	c.JSON(200, peer)
+}
+
+func peeringPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &peeringData{
+		Name: "New Peering",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	peer := &peering.Peering{
+		Name:         data.Name,
+		Comment:      data.Comment,
+		Organization: userOrg,
+		Vpc:          data.Vpc,
+		PeerVpc:      data.PeerVpc,
+	}
+
+	errData, err := peer.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = peer.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceCreate, "peering", peer.Id, nil)
+
+	event.PublishDispatch(db, "peering.change")
+
+	c.JSON(200, peer)
+}
+
+func peeringDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	peerId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := peering.RemoveOrg(db, userOrg, peerId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceDelete, "peering", peerId, nil)
+
+	event.PublishDispatch(db, "peering.change")
+
+	c.JSON(200, nil)
+}
+
+func peeringGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	peerId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	peer, err := peering.GetOrg(db, userOrg, peerId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, peer)
+}
+
+func peeringsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"organization": userOrg,
+			},
+			&bson.M{
+				"peer_organization": userOrg,
+			},
+		},
+	}
+
+	peerId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = peerId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	state := strings.TrimSpace(c.Query("state"))
+	if state != "" {
+		query["state"] = state
+	}
+
+	vpcId, ok := utils.ParseObjectId(c.Query("vpc"))
+	if ok {
+		query["$and"] = []*bson.M{
+			&bson.M{
+				"$or": []*bson.M{
+					&bson.M{
+						"vpc": vpcId,
+					},
+					&bson.M{
+						"peer_vpc": vpcId,
+					},
+				},
+			},
+		}
+	}
+
+	peers, count, err := peering.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &peeringsData{
+		Peerings: peers,
+		Count:    count,
+	}
+
+	c.JSON(200, data)
+}
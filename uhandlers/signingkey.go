@@ -0,0 +1,280 @@
+package uhandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/signingkey"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type signingKeyData struct {
+	Id        primitive.ObjectID `json:"id"`
+	Name      string             `json:"name"`
+	Comment   string             `json:"comment"`
+	Type      string             `json:"type"`
+	PublicKey string             `json:"public_key"`
+}
+
+type signingKeysData struct {
+	SigningKeys []*signingkey.SigningKey `json:"signing_keys"`
+	Count       int64                    `json:"count"`
+}
+
+func signingKeyPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &signingKeyData{}
+
+	keyId, ok := utils.ParseObjectId(c.Param("key_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	key, err := signingkey.GetOrg(db, userOrg, keyId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	keyOrig := audit.Snapshot(key)
+
+	key.Name = data.Name
+	key.Comment = data.Comment
+	key.Type = data.Type
+	key.PublicKey = data.PublicKey
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"type",
+		"public_key",
+		"fingerprint",
+	)
+
+	errData, err := key.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = key.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceUpdate, "signing_key", key.Id,
+		audit.Diff(keyOrig, key, fields))
+
+	event.PublishDispatch(db, "signing_key.change")
+
+	c.JSON(200, key)
+}
+
+func signingKeyPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &signingKeyData{
+		Name: "New Signing Key",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	key := &signingkey.SigningKey{
+		Name:         data.Name,
+		Comment:      data.Comment,
+		Type:         data.Type,
+		Organization: userOrg,
+		PublicKey:    data.PublicKey,
+	}
+
+	errData, err := key.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = key.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceCreate, "signing_key", key.Id,
+		nil)
+
+	event.PublishDispatch(db, "signing_key.change")
+
+	c.JSON(200, key)
+}
+
+func signingKeyDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	keyId, ok := utils.ParseObjectId(c.Param("key_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := signingkey.RemoveOrg(db, userOrg, keyId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceDelete, "signing_key", keyId,
+		nil)
+
+	event.PublishDispatch(db, "signing_key.change")
+
+	c.JSON(200, nil)
+}
+
+func signingKeysDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = signingkey.RemoveMultiOrg(db, userOrg, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, keyId := range data {
+		auditChange(c, db, audit.ResourceDelete, "signing_key",
+			keyId, nil)
+	}
+
+	event.PublishDispatch(db, "signing_key.change")
+
+	c.JSON(200, nil)
+}
+
+func signingKeyGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	keyId, ok := utils.ParseObjectId(c.Param("key_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	key, err := signingkey.GetOrg(db, userOrg, keyId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, key)
+}
+
+func signingKeysGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{
+		"organization": userOrg,
+	}
+
+	keyId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = keyId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	typ := strings.TrimSpace(c.Query("type"))
+	if typ != "" {
+		query["type"] = typ
+	}
+
+	keys, count, err := signingkey.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &signingKeysData{
+		SigningKeys: keys,
+		Count:       count,
+	}
+
+	c.JSON(200, data)
+}
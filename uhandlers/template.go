@@ -0,0 +1,570 @@
+package uhandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/data"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/domain"
+	"github.com/pritunl/pritunl-cloud/drive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/iscsi"
+	"github.com/pritunl/pritunl-cloud/iso"
+	"github.com/pritunl/pritunl-cloud/pci"
+	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/template"
+	"github.com/pritunl/pritunl-cloud/usb"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+type templateData struct {
+	Id                  primitive.ObjectID `json:"id"`
+	Name                string             `json:"name"`
+	Comment             string             `json:"comment"`
+	Zone                primitive.ObjectID `json:"zone"`
+	Vpc                 primitive.ObjectID `json:"vpc"`
+	Subnet              primitive.ObjectID `json:"subnet"`
+	OracleSubnet        string             `json:"oracle_subnet"`
+	Image               primitive.ObjectID `json:"image"`
+	ImageBacking        bool               `json:"image_backing"`
+	Domain              primitive.ObjectID `json:"domain"`
+	Uefi                bool               `json:"uefi"`
+	SecureBoot          bool               `json:"secure_boot"`
+	Tpm                 bool               `json:"tpm"`
+	DedicatedCpus       bool               `json:"dedicated_cpus"`
+	DeleteProtection    bool               `json:"delete_protection"`
+	SkipSourceDestCheck bool               `json:"skip_source_dest_check"`
+	RootEnabled         bool               `json:"root_enabled"`
+	InitDiskSize        int                `json:"init_disk_size"`
+	Memory              int                `json:"memory"`
+	Processors          int                `json:"processors"`
+	NetworkRoles        []string           `json:"network_roles"`
+	AntiAffinity        []string           `json:"anti_affinity"`
+	Isos                []*iso.Iso         `json:"isos"`
+	UsbDevices          []*usb.Device      `json:"usb_devices"`
+	PciDevices          []*pci.Device      `json:"pci_devices"`
+	DriveDevices        []*drive.Device    `json:"drive_devices"`
+	IscsiDevices        []*iscsi.Device    `json:"iscsi_devices"`
+	Vnc                 bool               `json:"vnc"`
+	Spice               bool               `json:"spice"`
+	Gui                 bool               `json:"gui"`
+	NoPublicAddress     bool               `json:"no_public_address"`
+	NoHostAddress       bool               `json:"no_host_address"`
+}
+
+type templateLaunchData struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type templatesData struct {
+	Templates []*template.Template `json:"templates"`
+	Count     int64                `json:"count"`
+}
+
+func templateCheckOrg(c *gin.Context, db *database.Database,
+	userOrg primitive.ObjectID, dta *templateData) bool {
+
+	if !dta.Zone.IsZero() {
+		zne, err := zone.Get(db, dta.Zone)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return false
+		}
+
+		exists, err := datacenter.ExistsOrg(db, userOrg, zne.Datacenter)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return false
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return false
+		}
+	}
+
+	if !dta.Vpc.IsZero() {
+		exists, err := vpc.ExistsOrg(db, userOrg, dta.Vpc)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return false
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return false
+		}
+	}
+
+	if !dta.Domain.IsZero() {
+		exists, err := domain.ExistsOrg(db, userOrg, dta.Domain)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return false
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return false
+		}
+	}
+
+	if !dta.Image.IsZero() {
+		_, err := image.GetOrgPublic(db, userOrg, dta.Image)
+		if err != nil {
+			if _, ok := err.(*database.NotFoundError); ok {
+				errData := &errortypes.ErrorData{
+					Error:   "image_not_found",
+					Message: "Image not found",
+				}
+				c.JSON(400, errData)
+			} else {
+				utils.AbortWithError(c, 500, err)
+			}
+			return false
+		}
+	}
+
+	return true
+}
+
+func templatePut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	dta := &templateData{}
+
+	templateId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !templateCheckOrg(c, db, userOrg, dta) {
+		return
+	}
+
+	tmpl, err := template.GetOrg(db, userOrg, templateId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	tmplOrig := audit.Snapshot(tmpl)
+
+	tmpl.Name = dta.Name
+	tmpl.Comment = dta.Comment
+	tmpl.Zone = dta.Zone
+	tmpl.Vpc = dta.Vpc
+	tmpl.Subnet = dta.Subnet
+	tmpl.OracleSubnet = dta.OracleSubnet
+	tmpl.Image = dta.Image
+	tmpl.ImageBacking = dta.ImageBacking
+	tmpl.Domain = dta.Domain
+	tmpl.Uefi = dta.Uefi
+	tmpl.SecureBoot = dta.SecureBoot
+	tmpl.Tpm = dta.Tpm
+	tmpl.DedicatedCpus = dta.DedicatedCpus
+	tmpl.DeleteProtection = dta.DeleteProtection
+	tmpl.SkipSourceDestCheck = dta.SkipSourceDestCheck
+	tmpl.RootEnabled = dta.RootEnabled
+	tmpl.InitDiskSize = dta.InitDiskSize
+	tmpl.Memory = dta.Memory
+	tmpl.Processors = dta.Processors
+	tmpl.NetworkRoles = dta.NetworkRoles
+	tmpl.AntiAffinity = dta.AntiAffinity
+	tmpl.Isos = dta.Isos
+	tmpl.UsbDevices = dta.UsbDevices
+	tmpl.PciDevices = dta.PciDevices
+	tmpl.DriveDevices = dta.DriveDevices
+	tmpl.IscsiDevices = dta.IscsiDevices
+	tmpl.Vnc = dta.Vnc
+	tmpl.Spice = dta.Spice
+	tmpl.Gui = dta.Gui
+	tmpl.NoPublicAddress = dta.NoPublicAddress
+	tmpl.NoHostAddress = dta.NoHostAddress
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"zone",
+		"vpc",
+		"subnet",
+		"oracle_subnet",
+		"image",
+		"image_backing",
+		"domain",
+		"uefi",
+		"secure_boot",
+		"tpm",
+		"dedicated_cpus",
+		"delete_protection",
+		"skip_source_dest_check",
+		"root_enabled",
+		"init_disk_size",
+		"memory",
+		"processors",
+		"network_roles",
+		"anti_affinity",
+		"isos",
+		"usb_devices",
+		"pci_devices",
+		"drive_devices",
+		"iscsi_devices",
+		"vnc",
+		"spice",
+		"gui",
+		"no_public_address",
+		"no_host_address",
+	)
+
+	errData, err := tmpl.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = tmpl.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceUpdate, "template", tmpl.Id,
+		audit.Diff(tmplOrig, tmpl, fields))
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, tmpl)
+}
+
+func templatePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	dta := &templateData{
+		Name: "New Template",
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !templateCheckOrg(c, db, userOrg, dta) {
+		return
+	}
+
+	tmpl := &template.Template{
+		Name:                dta.Name,
+		Comment:             dta.Comment,
+		Organization:        userOrg,
+		Zone:                dta.Zone,
+		Vpc:                 dta.Vpc,
+		Subnet:              dta.Subnet,
+		OracleSubnet:        dta.OracleSubnet,
+		Image:               dta.Image,
+		ImageBacking:        dta.ImageBacking,
+		Domain:              dta.Domain,
+		Uefi:                dta.Uefi,
+		SecureBoot:          dta.SecureBoot,
+		Tpm:                 dta.Tpm,
+		DedicatedCpus:       dta.DedicatedCpus,
+		DeleteProtection:    dta.DeleteProtection,
+		SkipSourceDestCheck: dta.SkipSourceDestCheck,
+		RootEnabled:         dta.RootEnabled,
+		InitDiskSize:        dta.InitDiskSize,
+		Memory:              dta.Memory,
+		Processors:          dta.Processors,
+		NetworkRoles:        dta.NetworkRoles,
+		AntiAffinity:        dta.AntiAffinity,
+		Isos:                dta.Isos,
+		UsbDevices:          dta.UsbDevices,
+		PciDevices:          dta.PciDevices,
+		DriveDevices:        dta.DriveDevices,
+		IscsiDevices:        dta.IscsiDevices,
+		Vnc:                 dta.Vnc,
+		Spice:               dta.Spice,
+		Gui:                 dta.Gui,
+		NoPublicAddress:     dta.NoPublicAddress,
+		NoHostAddress:       dta.NoHostAddress,
+	}
+
+	errData, err := tmpl.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = tmpl.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceCreate, "template", tmpl.Id, nil)
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, tmpl)
+}
+
+func templateLaunchPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	dta := &templateLaunchData{}
+
+	templateId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	tmpl, err := template.GetOrg(db, userOrg, templateId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !tmpl.Image.IsZero() {
+		img, err := image.GetOrgPublic(db, userOrg, tmpl.Image)
+		if err != nil {
+			if _, ok := err.(*database.NotFoundError); ok {
+				errData := &errortypes.ErrorData{
+					Error:   "image_not_found",
+					Message: "Image not found",
+				}
+				c.JSON(400, errData)
+			} else {
+				utils.AbortWithError(c, 500, err)
+			}
+			return
+		}
+
+		store, err := storage.Get(db, img.Storage)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		available, err := data.ImageAvailable(store, img)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+		if !available {
+			if store.IsOracle() {
+				errData := &errortypes.ErrorData{
+					Error:   "image_not_available",
+					Message: "Image not restored from archive",
+				}
+				c.JSON(400, errData)
+			} else {
+				errData := &errortypes.ErrorData{
+					Error:   "image_not_available",
+					Message: "Image not restored from glacier",
+				}
+				c.JSON(400, errData)
+			}
+
+			return
+		}
+	}
+
+	insts, errData, err := tmpl.Launch(db, dta.Name, dta.Count)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	for _, inst := range insts {
+		auditChange(c, db, audit.ResourceCreate, "instance", inst.Id,
+			nil)
+	}
+
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, insts)
+}
+
+func templateDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	templateId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := template.RemoveOrg(db, userOrg, templateId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	auditChange(c, db, audit.ResourceDelete, "template", templateId, nil)
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, nil)
+}
+
+func templatesDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	dta := []primitive.ObjectID{}
+
+	err := c.Bind(&dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = template.RemoveMultiOrg(db, userOrg, dta)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, templateId := range dta {
+		auditChange(c, db, audit.ResourceDelete, "template", templateId,
+			nil)
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, nil)
+}
+
+func templateGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	templateId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	tmpl, err := template.GetOrg(db, userOrg, templateId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, tmpl)
+}
+
+func templatesGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{
+		"organization": userOrg,
+	}
+
+	templateId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = templateId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	zone, ok := utils.ParseObjectId(c.Query("zone"))
+	if ok {
+		query["zone"] = zone
+	}
+
+	templates, count, err := template.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	dta := &templatesData{
+		Templates: templates,
+		Count:     count,
+	}
+
+	c.JSON(200, dta)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
 	"github.com/pritunl/pritunl-cloud/demo"
@@ -18,13 +19,16 @@ import (
 )
 
 type vpcData struct {
-	Id         primitive.ObjectID `json:"id"`
-	Name       string             `json:"name"`
-	Comment    string             `json:"comment"`
-	Network    string             `json:"network"`
-	Subnets    []*vpc.Subnet      `json:"subnets"`
-	Datacenter primitive.ObjectID `json:"datacenter"`
-	Routes     []*vpc.Route       `json:"routes"`
+	Id            primitive.ObjectID `json:"id"`
+	Name          string             `json:"name"`
+	Comment       string             `json:"comment"`
+	Network       string             `json:"network"`
+	Subnets       []*vpc.Subnet      `json:"subnets"`
+	Datacenter    primitive.ObjectID `json:"datacenter"`
+	Routes        []*vpc.Route       `json:"routes"`
+	DnsServers    []string           `json:"dns_servers"`
+	SearchDomains []string           `json:"search_domains"`
+	NatGateway    *vpc.NatGateway    `json:"nat_gateway"`
 }
 
 type vpcsData struct {
@@ -69,6 +73,8 @@ func vpcPut(c *gin.Context) {
 		return
 	}
 
+	vcOrig := audit.Snapshot(vc)
+
 	if vc.Organization != userOrg {
 		utils.AbortWithStatus(c, 405)
 		return
@@ -80,12 +86,31 @@ func vpcPut(c *gin.Context) {
 	vc.Comment = data.Comment
 	vc.Routes = data.Routes
 	vc.Subnets = data.Subnets
+	vc.DnsServers = data.DnsServers
+	vc.SearchDomains = data.SearchDomains
+
+	if data.NatGateway != nil {
+		ngw := &vpc.NatGateway{
+			Zone:         data.NatGateway.Zone,
+			Subnet:       data.NatGateway.Subnet,
+			ConntrackMax: data.NatGateway.ConntrackMax,
+		}
+		if vc.NatGateway != nil {
+			ngw.Block = vc.NatGateway.Block
+		}
+		vc.NatGateway = ngw
+	} else {
+		vc.NatGateway = nil
+	}
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"routes",
 		"subnets",
+		"dns_servers",
+		"search_domains",
+		"nat_gateway",
 	)
 
 	errData, err := vc.Validate(db)
@@ -116,6 +141,9 @@ func vpcPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "vpc", vc.Id,
+		audit.Diff(vcOrig, vc, fields))
+
 	event.PublishDispatch(db, "vpc.change")
 
 	vc.Json()
@@ -151,13 +179,23 @@ func vpcPost(c *gin.Context) {
 	}
 
 	vc := &vpc.Vpc{
-		Name:         data.Name,
-		Comment:      data.Comment,
-		Network:      data.Network,
-		Subnets:      data.Subnets,
-		Organization: userOrg,
-		Datacenter:   data.Datacenter,
-		Routes:       data.Routes,
+		Name:          data.Name,
+		Comment:       data.Comment,
+		Network:       data.Network,
+		Subnets:       data.Subnets,
+		Organization:  userOrg,
+		Datacenter:    data.Datacenter,
+		Routes:        data.Routes,
+		DnsServers:    data.DnsServers,
+		SearchDomains: data.SearchDomains,
+	}
+
+	if data.NatGateway != nil {
+		vc.NatGateway = &vpc.NatGateway{
+			Zone:         data.NatGateway.Zone,
+			Subnet:       data.NatGateway.Subnet,
+			ConntrackMax: data.NatGateway.ConntrackMax,
+		}
 	}
 
 	vc.InitVpc()
@@ -179,6 +217,22 @@ func vpcPost(c *gin.Context) {
 		return
 	}
 
+	if vc.NatGateway != nil {
+		err = vc.AllocateNatGateway(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		err = vc.CommitFields(db, set.NewSet("nat_gateway"))
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	auditChange(c, db, audit.ResourceCreate, "vpc", vc.Id, nil)
+
 	event.PublishDispatch(db, "vpc.change")
 
 	vc.Json()
@@ -216,6 +270,8 @@ func vpcDelete(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceDelete, "vpc", vpcId, nil)
+
 	event.PublishDispatch(db, "vpc.change")
 
 	c.JSON(200, nil)
@@ -254,6 +310,10 @@ func vpcsDelete(c *gin.Context) {
 		return
 	}
 
+	for _, vpcId := range data {
+		auditChange(c, db, audit.ResourceDelete, "vpc", vpcId, nil)
+	}
+
 	event.PublishDispatch(db, "vpc.change")
 
 	c.JSON(200, nil)
@@ -326,6 +386,8 @@ func vpcRoutesPut(c *gin.Context) {
 		return
 	}
 
+	vcOrig := audit.Snapshot(vc)
+
 	vc.Routes = data
 
 	fields := set.NewSet(
@@ -349,6 +411,9 @@ func vpcRoutesPut(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, db, audit.ResourceUpdate, "vpc", vc.Id,
+		audit.Diff(vcOrig, vc, fields))
+
 	event.PublishDispatch(db, "vpc.change")
 
 	vc.Json()
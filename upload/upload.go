@@ -0,0 +1,216 @@
+package upload
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/quota"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	checksumReg = regexp.MustCompile("^[a-f0-9]{64}$")
+)
+
+type Upload struct {
+	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name             string             `bson:"name" json:"name"`
+	Comment          string             `bson:"comment" json:"comment"`
+	Organization     primitive.ObjectID `bson:"organization" json:"organization"`
+	Datacenter       primitive.ObjectID `bson:"datacenter" json:"datacenter"`
+	Node             primitive.ObjectID `bson:"node" json:"node"`
+	Filename         string             `bson:"filename" json:"filename"`
+	Format           string             `bson:"format" json:"format"`
+	Size             int64              `bson:"size" json:"size"`
+	Offset           int64              `bson:"offset" json:"offset"`
+	Checksum         string             `bson:"checksum" json:"checksum"`
+	Timestamp        time.Time          `bson:"timestamp" json:"timestamp"`
+	quotaReservation primitive.ObjectID `bson:"-" json:"-"`
+}
+
+func (u *Upload) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if u.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if u.Datacenter.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "datacenter_required",
+			Message: "Missing required datacenter",
+		}
+		return
+	}
+
+	dc, err := datacenter.Get(db, u.Datacenter)
+	if err != nil {
+		return
+	}
+
+	if dc.PrivateStorage.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "datacenter_private_storage_missing",
+			Message: "Datacenter does not have private storage",
+		}
+		return
+	}
+
+	u.Filename = strings.TrimSpace(u.Filename)
+	u.Format = image.ParseFormat(u.Filename)
+
+	switch u.Format {
+	case image.Qcow2, image.Raw:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "upload_format_invalid",
+			Message: "Upload must be a qcow2 or raw image",
+		}
+		return
+	}
+
+	if u.Name == "" {
+		u.Name = u.Filename
+	}
+
+	if u.Size <= 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "upload_size_invalid",
+			Message: "Upload size is invalid",
+		}
+		return
+	}
+
+	// Upload max size is in gigabytes
+	maxSize := int64(settings.System.UploadMaxSize) * 1073741824
+	if maxSize > 0 && u.Size > maxSize {
+		errData = &errortypes.ErrorData{
+			Error:   "upload_size_limit",
+			Message: "Upload size exceeds maximum upload size",
+		}
+		return
+	}
+
+	if u.Offset < 0 || u.Offset > u.Size {
+		errData = &errortypes.ErrorData{
+			Error:   "upload_offset_invalid",
+			Message: "Upload offset is invalid",
+		}
+		return
+	}
+
+	u.Checksum = strings.ToLower(strings.TrimSpace(u.Checksum))
+	if !checksumReg.MatchString(u.Checksum) {
+		errData = &errortypes.ErrorData{
+			Error:   "upload_checksum_invalid",
+			Message: "Upload checksum must be a SHA-256 hex digest",
+		}
+		return
+	}
+
+	if u.Id.IsZero() && settings.System.UploadMaxConcurrent > 0 {
+		count, e := CountOrg(db, u.Organization)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if count >= settings.System.UploadMaxConcurrent {
+			errData = &errortypes.ErrorData{
+				Error:   "upload_concurrent_limit",
+				Message: "Organization has too many uploads in progress",
+			}
+			return
+		}
+	}
+
+	if u.Id.IsZero() {
+		u.releaseQuota(db)
+
+		u.quotaReservation, errData, err = quota.Reserve(
+			db, u.Organization, &quota.Usage{
+				Disk: quota.UploadSize(u.Size),
+			})
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (u *Upload) Commit(db *database.Database) (err error) {
+	coll := db.ImageUploads()
+
+	err = coll.Commit(u.Id, u)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *Upload) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.ImageUploads()
+
+	err = coll.CommitFields(u.Id, u, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *Upload) Insert(db *database.Database) (err error) {
+	coll := db.ImageUploads()
+
+	if !u.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("upload: Upload already exists"),
+		}
+		return
+	}
+
+	resp, err := coll.InsertOne(db, u)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	u.Id = resp.InsertedID.(primitive.ObjectID)
+	u.releaseQuota(db)
+
+	return
+}
+
+func (u *Upload) releaseQuota(db *database.Database) {
+	if u.quotaReservation.IsZero() {
+		return
+	}
+
+	err := quota.Release(db, u.Organization, u.quotaReservation)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"upload_id": u.Id.Hex(),
+			"error":     err,
+		}).Error("upload: Failed to release quota reservation")
+	}
+
+	u.quotaReservation = primitive.NilObjectID
+}
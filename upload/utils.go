@@ -0,0 +1,115 @@
+package upload
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+)
+
+func Get(db *database.Database, upldId primitive.ObjectID) (
+	upld *Upload, err error) {
+
+	coll := db.ImageUploads()
+	upld = &Upload{}
+
+	err = coll.FindOneId(upldId, upld)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, upldId primitive.ObjectID) (
+	upld *Upload, err error) {
+
+	coll := db.ImageUploads()
+	upld = &Upload{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          upldId,
+		"organization": orgId,
+	}).Decode(upld)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func CountOrg(db *database.Database, orgId primitive.ObjectID) (
+	count int, err error) {
+
+	coll := db.ImageUploads()
+
+	n, err := coll.CountDocuments(db, &bson.M{
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	count = int(n)
+
+	return
+}
+
+func GetAllIds(db *database.Database) (upldIds set.Set, err error) {
+	coll := db.ImageUploads()
+	upldIds = set.NewSet()
+
+	ids, err := coll.Distinct(db, "_id", &bson.M{})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	for _, id := range ids {
+		upldIds.Add(id.(primitive.ObjectID))
+	}
+
+	return
+}
+
+func Remove(db *database.Database, upldId primitive.ObjectID) (err error) {
+	coll := db.ImageUploads()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": upldId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, upldId primitive.ObjectID) (
+	err error) {
+
+	coll := db.ImageUploads()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id":          upldId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
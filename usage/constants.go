@@ -0,0 +1,7 @@
+package usage
+
+const (
+	Instance = "instance"
+	Disk     = "disk"
+	Backup   = "backup"
+)
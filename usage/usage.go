@@ -0,0 +1,70 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+)
+
+type Record struct {
+	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Organization     primitive.ObjectID `bson:"organization" json:"organization"`
+	Node             primitive.ObjectID `bson:"node" json:"node"`
+	Type             string             `bson:"type" json:"type"`
+	Resource         primitive.ObjectID `bson:"resource" json:"resource"`
+	Name             string             `bson:"name" json:"name"`
+	Period           time.Time          `bson:"period" json:"period"`
+	Timestamp        time.Time          `bson:"timestamp" json:"timestamp"`
+	Seconds          int64              `bson:"seconds" json:"seconds"`
+	Processors       int                `bson:"processors" json:"processors"`
+	Memory           int                `bson:"memory" json:"memory"`
+	Size             int                `bson:"size" json:"size"`
+	ProcessorSeconds int64              `bson:"processor_seconds" json:"processor_seconds"`
+	MemorySeconds    int64              `bson:"memory_seconds" json:"memory_seconds"`
+	SizeSeconds      int64              `bson:"size_seconds" json:"size_seconds"`
+}
+
+func (r *Record) Sample(db *database.Database, seconds int64) (
+	err error) {
+
+	coll := db.Usages()
+
+	opts := &options.UpdateOptions{}
+	opts.SetUpsert(true)
+
+	_, err = coll.UpdateOne(
+		db,
+		&bson.M{
+			"resource": r.Resource,
+			"type":     r.Type,
+			"period":   r.Period,
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"organization": r.Organization,
+				"node":         r.Node,
+				"name":         r.Name,
+				"timestamp":    r.Timestamp,
+				"processors":   r.Processors,
+				"memory":       r.Memory,
+				"size":         r.Size,
+			},
+			"$inc": &bson.M{
+				"seconds":           seconds,
+				"processor_seconds": int64(r.Processors) * seconds,
+				"memory_seconds":    int64(r.Memory) * seconds,
+				"size_seconds":      int64(r.Size) * seconds,
+			},
+		},
+		opts,
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
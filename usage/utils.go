@@ -0,0 +1,172 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+)
+
+type Hourly struct {
+	Organization     primitive.ObjectID `bson:"organization" json:"organization"`
+	OrganizationName string             `bson:"-" json:"organization_name"`
+	Period           time.Time          `bson:"period" json:"period"`
+	InstanceSeconds  int64              `bson:"instance_seconds" json:"-"`
+	ProcessorSeconds int64              `bson:"processor_seconds" json:"-"`
+	MemorySeconds    int64              `bson:"memory_seconds" json:"-"`
+	DiskSeconds      int64              `bson:"disk_seconds" json:"-"`
+	BackupSeconds    int64              `bson:"backup_seconds" json:"-"`
+	InstanceHours    float64            `bson:"-" json:"instance_hours"`
+	ProcessorHours   float64            `bson:"-" json:"processor_hours"`
+	MemoryGbHours    float64            `bson:"-" json:"memory_gb_hours"`
+	DiskGbHours      float64            `bson:"-" json:"disk_gb_hours"`
+	BackupGbHours    float64            `bson:"-" json:"backup_gb_hours"`
+}
+
+func (h *Hourly) calculate() {
+	h.InstanceHours = float64(h.InstanceSeconds) / 3600
+	h.ProcessorHours = float64(h.ProcessorSeconds) / 3600
+	h.MemoryGbHours = float64(h.MemorySeconds) / 1024 / 3600
+	h.DiskGbHours = float64(h.DiskSeconds) / 3600
+	h.BackupGbHours = float64(h.BackupSeconds) / 3600
+}
+
+func sumType(typ, field string) *bson.M {
+	return &bson.M{
+		"$sum": &bson.M{
+			"$cond": []interface{}{
+				&bson.M{
+					"$eq": []interface{}{"$type", typ},
+				},
+				field,
+				0,
+			},
+		},
+	}
+}
+
+func GetHourly(db *database.Database, orgId primitive.ObjectID,
+	start, end time.Time) (hourly []*Hourly, err error) {
+
+	coll := db.Usages()
+	hourly = []*Hourly{}
+
+	query := bson.M{
+		"period": &bson.M{
+			"$gte": start,
+			"$lt":  end,
+		},
+	}
+	if !orgId.IsZero() {
+		query["organization"] = orgId
+	}
+
+	cursor, err := coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$match": &query,
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": &bson.M{
+					"organization": "$organization",
+					"period":       "$period",
+				},
+				"organization": &bson.M{
+					"$first": "$organization",
+				},
+				"period": &bson.M{
+					"$first": "$period",
+				},
+				"instance_seconds":  sumType(Instance, "$seconds"),
+				"processor_seconds": sumType(Instance, "$processor_seconds"),
+				"memory_seconds":    sumType(Instance, "$memory_seconds"),
+				"disk_seconds":      sumType(Disk, "$size_seconds"),
+				"backup_seconds":    sumType(Backup, "$size_seconds"),
+			},
+		},
+		&bson.M{
+			"$sort": &bson.D{
+				{"organization", 1},
+				{"period", 1},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		hour := &Hourly{}
+		err = cursor.Decode(hour)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		hour.calculate()
+		hourly = append(hourly, hour)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetBackups(db *database.Database, diskIds []primitive.ObjectID) (
+	backups map[primitive.ObjectID]int, err error) {
+
+	coll := db.Images()
+	backups = map[primitive.ObjectID]int{}
+
+	cursor, err := coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"disk": &bson.M{
+					"$in": diskIds,
+				},
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": "$disk",
+				"count": &bson.M{
+					"$sum": 1,
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		doc := &struct {
+			Disk  primitive.ObjectID `bson:"_id"`
+			Count int                `bson:"count"`
+		}{}
+		err = cursor.Decode(doc)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		backups[doc.Disk] = doc.Count
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
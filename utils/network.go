@@ -134,6 +134,13 @@ func NetworkContains(x, y *net.IPNet) bool {
 	return x.Contains(y.IP) && x.Contains(GetLastIpAddress(y))
 }
 
+// IsGlobalIp6 returns true for globally routed IPv6 addresses, unique
+// local addresses are not global.
+func IsGlobalIp6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil && ip.IsGlobalUnicast() &&
+		!ip.IsPrivate()
+}
+
 func ParseIpMask(mask string) net.IPMask {
 	maskIp := net.ParseIP(mask)
 	if maskIp == nil {
@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type DiskLimits struct {
+	ReadIops     int `bson:"read_iops" json:"read_iops"`
+	WriteIops    int `bson:"write_iops" json:"write_iops"`
+	ReadBps      int `bson:"read_bps" json:"read_bps"`
+	WriteBps     int `bson:"write_bps" json:"write_bps"`
+	ReadIopsMax  int `bson:"read_iops_max" json:"read_iops_max"`
+	WriteIopsMax int `bson:"write_iops_max" json:"write_iops_max"`
+	ReadBpsMax   int `bson:"read_bps_max" json:"read_bps_max"`
+	WriteBpsMax  int `bson:"write_bps_max" json:"write_bps_max"`
+	BurstLength  int `bson:"burst_length" json:"burst_length"`
+}
+
+func (l *DiskLimits) IsZero() bool {
+	return *l == DiskLimits{}
+}
+
+// GetBurstLength returns the burst length in seconds for a limit with the
+// max value. Limits without a max value use the minimum length of one.
+func (l *DiskLimits) GetBurstLength(max int) int {
+	if max > 0 && l.BurstLength > 0 {
+		return l.BurstLength
+	}
+	return 1
+}
+
+func validBurst(base, max int) bool {
+	if max == 0 {
+		return true
+	}
+	return base > 0 && max >= base
+}
+
+func (l *DiskLimits) Validate() (errData *errortypes.ErrorData) {
+	if l.ReadIops < 0 || l.WriteIops < 0 || l.ReadBps < 0 ||
+		l.WriteBps < 0 || l.ReadIopsMax < 0 || l.WriteIopsMax < 0 ||
+		l.ReadBpsMax < 0 || l.WriteBpsMax < 0 || l.BurstLength < 0 {
+
+		errData = &errortypes.ErrorData{
+			Error:   "disk_limits_negative",
+			Message: "Disk limits cannot be negative",
+		}
+		return
+	}
+
+	if !validBurst(l.ReadIops, l.ReadIopsMax) ||
+		!validBurst(l.WriteIops, l.WriteIopsMax) ||
+		!validBurst(l.ReadBps, l.ReadBpsMax) ||
+		!validBurst(l.WriteBps, l.WriteBpsMax) {
+
+		errData = &errortypes.ErrorData{
+			Error:   "disk_limits_burst_invalid",
+			Message: "Disk burst limit must be greater than base limit",
+		}
+		return
+	}
+
+	if l.BurstLength != 0 && l.ReadIopsMax == 0 && l.WriteIopsMax == 0 &&
+		l.ReadBpsMax == 0 && l.WriteBpsMax == 0 {
+
+		l.BurstLength = 0
+	}
+
+	return
+}
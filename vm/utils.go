@@ -164,6 +164,27 @@ func GetIfaceVlan(id primitive.ObjectID, n int) string {
 	return fmt.Sprintf("x%s%d", strings.ToLower(hashSum), n)
 }
 
+func GetIfacePeer(id primitive.ObjectID, peerKey string) string {
+	hash := md5.New()
+	hash.Write([]byte(id.Hex() + peerKey))
+	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
+	return fmt.Sprintf("r%s0", strings.ToLower(hashSum))
+}
+
+func GetIfaceNat(id primitive.ObjectID, n int) string {
+	hash := md5.New()
+	hash.Write([]byte(id.Hex()))
+	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
+	return fmt.Sprintf("w%s%d", strings.ToLower(hashSum), n)
+}
+
+func GetNamespaceNat(id primitive.ObjectID) string {
+	hash := md5.New()
+	hash.Write([]byte(id.Hex()))
+	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
+	return fmt.Sprintf("g%s0", strings.ToLower(hashSum))
+}
+
 func GetNamespace(id primitive.ObjectID, n int) string {
 	hash := md5.New()
 	hash.Write([]byte(id.Hex()))
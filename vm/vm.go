@@ -34,8 +34,14 @@ type VirtualMachine struct {
 	OraclePublicIp      string             `json:"oracle_public_ip"`
 	Uefi                bool               `json:"uefi"`
 	SecureBoot          bool               `json:"secure_boot"`
+	Tpm                 bool               `json:"tpm"`
+	DedicatedCpus       bool               `json:"dedicated_cpus"`
+	CpuAffinity         []int              `json:"cpu_affinity"`
+	NumaNode            int                `json:"numa_node"`
 	NoPublicAddress     bool               `json:"no_public_address"`
 	NoHostAddress       bool               `json:"no_host_address"`
+	BandwidthIngress    int                `json:"bandwidth_ingress"`
+	BandwidthEgress     int                `json:"bandwidth_egress"`
 	Isos                []*Iso             `json:"isos"`
 	UsbDevices          []*UsbDevice       `json:"usb_devices"`
 	UsbDevicesAvailable bool               `json:"-"`
@@ -45,9 +51,10 @@ type VirtualMachine struct {
 }
 
 type Disk struct {
-	Id    primitive.ObjectID `json:"id"`
-	Index int                `json:"index"`
-	Path  string             `json:"path"`
+	Id     primitive.ObjectID `json:"id"`
+	Index  int                `json:"index"`
+	Path   string             `json:"path"`
+	Limits DiskLimits         `json:"limits"`
 }
 
 type Iso struct {
@@ -96,9 +103,10 @@ func (d *Disk) GetId() primitive.ObjectID {
 
 func (d *Disk) Copy() (dsk *Disk) {
 	dsk = &Disk{
-		Id:    d.Id,
-		Index: d.Index,
-		Path:  d.Path,
+		Id:     d.Id,
+		Index:  d.Index,
+		Path:   d.Path,
+		Limits: d.Limits,
 	}
 
 	return
@@ -114,6 +122,36 @@ type NetworkAdapter struct {
 }
 
 func (v *VirtualMachine) Commit(db *database.Database) (err error) {
+	err = v.commit(db, &bson.M{
+		"_id": v.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// CommitNode commits the virtual machine state only if the instance is
+// still assigned to the node. Used for units left on a node after the
+// instance has been migrated to another node.
+func (v *VirtualMachine) CommitNode(db *database.Database,
+	nodeId primitive.ObjectID) (err error) {
+
+	err = v.commit(db, &bson.M{
+		"_id":  v.Id,
+		"node": nodeId,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (v *VirtualMachine) commit(db *database.Database, query *bson.M) (
+	err error) {
+
 	coll := db.Instances()
 
 	addrs := []string{}
@@ -128,7 +166,7 @@ func (v *VirtualMachine) Commit(db *database.Database) (err error) {
 		}
 	}
 
-	err = coll.UpdateId(v.Id, &bson.M{
+	_, err = coll.UpdateOne(db, query, &bson.M{
 		"$set": &bson.M{
 			"vm_state":     v.State,
 			"vm_timestamp": v.Timestamp,
@@ -138,11 +176,7 @@ func (v *VirtualMachine) Commit(db *database.Database) (err error) {
 	})
 	if err != nil {
 		err = database.ParseError(err)
-		if _, ok := err.(*database.NotFoundError); ok {
-			err = nil
-		} else {
-			return
-		}
+		return
 	}
 
 	return
@@ -0,0 +1,140 @@
+package vpc
+
+import (
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+var (
+	defaultDnsServers = []string{
+		"8.8.8.8",
+		"8.8.4.4",
+	}
+	searchDomainRe = regexp.MustCompile(
+		`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*` +
+			`[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+type Dns struct {
+	Servers       []string
+	Servers6      []string
+	SearchDomains []string
+}
+
+func validateDns(servers, searchDomains []string) (
+	newServers, newSearchDomains []string, errData *errortypes.ErrorData) {
+
+	newServers = []string{}
+	newSearchDomains = []string{}
+
+	serversSet := set.NewSet()
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+
+		ip := net.ParseIP(server)
+		if ip == nil || ip.IsUnspecified() {
+			errData = &errortypes.ErrorData{
+				Error:   "dns_server_invalid",
+				Message: "DNS server address invalid",
+			}
+			return
+		}
+		server = ip.String()
+
+		if serversSet.Contains(server) {
+			continue
+		}
+		serversSet.Add(server)
+
+		newServers = append(newServers, server)
+	}
+
+	if len(newServers) > 6 {
+		errData = &errortypes.ErrorData{
+			Error:   "dns_servers_limit",
+			Message: "Cannot have more than 6 DNS servers",
+		}
+		return
+	}
+
+	domainsSet := set.NewSet()
+	for _, domain := range searchDomains {
+		domain = strings.Trim(
+			strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" {
+			continue
+		}
+
+		if len(domain) > 253 || !searchDomainRe.MatchString(domain) {
+			errData = &errortypes.ErrorData{
+				Error:   "search_domain_invalid",
+				Message: "DNS search domain invalid",
+			}
+			return
+		}
+
+		if domainsSet.Contains(domain) {
+			continue
+		}
+		domainsSet.Add(domain)
+
+		newSearchDomains = append(newSearchDomains, domain)
+	}
+
+	if len(newSearchDomains) > 6 {
+		errData = &errortypes.ErrorData{
+			Error:   "search_domains_limit",
+			Message: "Cannot have more than 6 DNS search domains",
+		}
+		return
+	}
+
+	return
+}
+
+func (v *Vpc) GetDns(subId primitive.ObjectID) (dns *Dns) {
+	servers := v.DnsServers
+	searchDomains := v.SearchDomains
+
+	sub := v.GetSubnet(subId)
+	if sub != nil {
+		if len(sub.DnsServers) > 0 {
+			servers = sub.DnsServers
+		}
+		if len(sub.SearchDomains) > 0 {
+			searchDomains = sub.SearchDomains
+		}
+	}
+
+	if len(servers) == 0 {
+		servers = defaultDnsServers
+	}
+
+	dns = &Dns{
+		Servers:       []string{},
+		Servers6:      []string{},
+		SearchDomains: []string{},
+	}
+
+	for _, server := range servers {
+		if strings.Contains(server, ":") {
+			dns.Servers6 = append(dns.Servers6, server)
+		} else {
+			dns.Servers = append(dns.Servers, server)
+		}
+	}
+
+	if searchDomains != nil {
+		dns.SearchDomains = searchDomains
+	}
+
+	return
+}
@@ -0,0 +1,170 @@
+package vpc
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+type NatGateway struct {
+	Zone          primitive.ObjectID `bson:"zone" json:"zone"`
+	Subnet        primitive.ObjectID `bson:"subnet" json:"subnet"`
+	Block         primitive.ObjectID `bson:"block,omitempty" json:"block"`
+	ConntrackMax  int                `bson:"conntrack_max" json:"conntrack_max"`
+	Address       string             `bson:"address" json:"address"`
+	PublicAddress string             `bson:"public_address" json:"public_address"`
+}
+
+func (v *Vpc) validateNatGateway(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	ngw := v.NatGateway
+	if ngw == nil {
+		return
+	}
+
+	if ngw.Zone.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "nat_gateway_zone_required",
+			Message: "Missing required NAT gateway zone",
+		}
+		return
+	}
+
+	zne, err := zone.Get(db, ngw.Zone)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "nat_gateway_zone_invalid",
+				Message: "NAT gateway zone does not exist",
+			}
+		}
+		return
+	}
+
+	if zne.Datacenter != v.Datacenter {
+		errData = &errortypes.ErrorData{
+			Error:   "nat_gateway_zone_invalid",
+			Message: "NAT gateway zone not in VPC datacenter",
+		}
+		return
+	}
+
+	if ngw.Subnet.IsZero() && len(v.Subnets) > 0 {
+		ngw.Subnet = v.Subnets[0].Id
+	}
+
+	if ngw.Subnet.IsZero() || v.GetSubnet(ngw.Subnet) == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "nat_gateway_subnet_invalid",
+			Message: "NAT gateway subnet does not exist",
+		}
+		return
+	}
+
+	if !ngw.Block.IsZero() {
+		blck, e := block.Get(db, ngw.Block)
+		if e != nil {
+			err = e
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+				errData = &errortypes.ErrorData{
+					Error:   "nat_gateway_block_invalid",
+					Message: "NAT gateway block does not exist",
+				}
+			}
+			return
+		}
+
+		if blck.Type != block.IPv4 {
+			errData = &errortypes.ErrorData{
+				Error:   "nat_gateway_block_invalid",
+				Message: "NAT gateway block is not an IPv4 block",
+			}
+			return
+		}
+	}
+
+	if ngw.ConntrackMax < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "nat_gateway_conntrack_max_invalid",
+			Message: "NAT gateway connection limit invalid",
+		}
+		return
+	}
+
+	ngw.Address = ""
+	ngw.PublicAddress = ""
+
+	curNgw := v.curNatGateway
+	if curNgw != nil {
+		if curNgw.Subnet == ngw.Subnet {
+			ngw.Address = curNgw.Address
+		}
+		if curNgw.Block == ngw.Block {
+			ngw.PublicAddress = curNgw.PublicAddress
+		}
+	}
+
+	return
+}
+
+func (v *Vpc) AllocateNatGateway(db *database.Database) (err error) {
+	ngw := v.NatGateway
+	curNgw := v.curNatGateway
+
+	if curNgw != nil && curNgw.Address != "" &&
+		(ngw == nil || ngw.Address == "") {
+
+		err = RemoveInstanceIp(db, v.Id, v.Id)
+		if err != nil {
+			return
+		}
+	}
+
+	if curNgw != nil && curNgw.PublicAddress != "" &&
+		(ngw == nil || ngw.PublicAddress == "") {
+
+		err = block.RemoveInstanceIpsType(db, v.Id, block.Nat)
+		if err != nil {
+			return
+		}
+	}
+
+	if ngw == nil {
+		return
+	}
+
+	if ngw.Address == "" {
+		addr, _, e := v.GetIp(db, ngw.Subnet, v.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		ngw.Address = addr.String()
+	}
+
+	if ngw.PublicAddress == "" && !ngw.Block.IsZero() {
+		blck, e := block.Get(db, ngw.Block)
+		if e != nil {
+			err = e
+			return
+		}
+
+		addr, e := blck.GetIp(db, v.Id, block.Nat)
+		if e != nil {
+			err = e
+			return
+		}
+
+		ngw.PublicAddress = addr.String()
+	}
+
+	v.curNatGateway = ngw
+
+	return
+}
@@ -0,0 +1,141 @@
+package vpc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+const (
+	blockNetwork6Size  = 56
+	subnetNetwork6Size = 64
+	maxNetwork6Search  = 65536
+)
+
+func getDefaultNetwork6(vcId primitive.ObjectID) string {
+	netHash := md5.New()
+	netHash.Write(vcId[:])
+	netHashSum := fmt.Sprintf("%x", netHash.Sum(nil))[:12]
+
+	ip := fmt.Sprintf("fd97%s", netHashSum)
+	ipBuf := bytes.Buffer{}
+
+	for i, run := range ip {
+		if i%4 == 0 && i != 0 && i != len(ip)-1 {
+			ipBuf.WriteRune(':')
+		}
+		ipBuf.WriteRune(run)
+	}
+
+	return ipBuf.String() + "::/64"
+}
+
+func networkOverlaps(x, y *net.IPNet) bool {
+	return x.Contains(y.IP) || y.Contains(x.IP)
+}
+
+// allocateNetwork6 returns the first network of the size within the parent
+// network that does not overlap the used networks.
+func allocateNetwork6(parent *net.IPNet, size int,
+	used []*net.IPNet) (network *net.IPNet) {
+
+	parentSize, bits := parent.Mask.Size()
+	if size < parentSize || size > bits {
+		return
+	}
+
+	start, _ := utils.IpAddress2BigInt(parent.IP)
+	count := new(big.Int).Lsh(big.NewInt(1), uint(size-parentSize))
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-size))
+	mask := net.CIDRMask(size, bits)
+
+	for i := int64(0); i < maxNetwork6Search; i++ {
+		index := big.NewInt(i)
+		if index.Cmp(count) >= 0 {
+			break
+		}
+
+		ip := utils.BigInt2IpAddress(
+			new(big.Int).Add(start, new(big.Int).Mul(index, step)), bits)
+		candidate := &net.IPNet{
+			IP:   ip.Mask(mask),
+			Mask: mask,
+		}
+
+		overlap := false
+		for _, usedNet := range used {
+			if networkOverlaps(candidate, usedNet) {
+				overlap = true
+				break
+			}
+		}
+
+		if !overlap {
+			network = candidate
+			return
+		}
+	}
+
+	return
+}
+
+func getUsedNetworks6(db *database.Database, vcId primitive.ObjectID) (
+	networks []*net.IPNet, err error) {
+
+	coll := db.Vpcs()
+	networks = []*net.IPNet{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"_id": &bson.M{
+				"$ne": vcId,
+			},
+			"network6": &bson.M{
+				"$nin": []interface{}{nil, ""},
+			},
+		},
+		&options.FindOptions{
+			Projection: &bson.D{
+				{"network6", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		vc := &Vpc{}
+		err = cursor.Decode(vc)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		_, network, e := net.ParseCIDR(vc.Network6)
+		if e != nil {
+			continue
+		}
+
+		networks = append(networks, network)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -10,9 +10,12 @@ import (
 )
 
 type Subnet struct {
-	Id      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name    string             `bson:"name" json:"name"`
-	Network string             `bson:"network" json:"network"`
+	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name          string             `bson:"name" json:"name"`
+	Network       string             `bson:"network" json:"network"`
+	Network6      string             `bson:"network6" json:"network6"`
+	DnsServers    []string           `bson:"dns_servers" json:"dns_servers"`
+	SearchDomains []string           `bson:"search_domains" json:"search_domains"`
 }
 
 func (s *Subnet) GetNetwork() (network *net.IPNet, err error) {
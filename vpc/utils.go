@@ -5,8 +5,10 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/block"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"time"
 )
 
 func Get(db *database.Database, vcId primitive.ObjectID) (
@@ -320,6 +322,34 @@ func Remove(db *database.Database, vcId primitive.ObjectID) (err error) {
 		return
 	}
 
+	coll = db.VpcPeerings()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": vcId,
+			},
+			&bson.M{
+				"peer_vpc": vcId,
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	coll = db.BlocksIp()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"instance": vcId,
+		"type":     block.Nat,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
 	coll = db.Vpcs()
 
 	_, err = coll.DeleteOne(db, &bson.M{
@@ -351,6 +381,34 @@ func RemoveOrg(db *database.Database, orgId, vcId primitive.ObjectID) (
 		return
 	}
 
+	coll = db.VpcPeerings()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": vcId,
+			},
+			&bson.M{
+				"peer_vpc": vcId,
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	coll = db.BlocksIp()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"instance": vcId,
+		"type":     block.Nat,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
 	coll = db.Vpcs()
 
 	_, err = coll.DeleteOne(db, &bson.M{
@@ -383,6 +441,40 @@ func RemoveMulti(db *database.Database, vcIds []primitive.ObjectID) (err error)
 		return
 	}
 
+	coll = db.VpcPeerings()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": &bson.M{
+					"$in": vcIds,
+				},
+			},
+			&bson.M{
+				"peer_vpc": &bson.M{
+					"$in": vcIds,
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	coll = db.BlocksIp()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"instance": &bson.M{
+			"$in": vcIds,
+		},
+		"type": block.Nat,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
 	coll = db.Vpcs()
 
 	_, err = coll.DeleteMany(db, &bson.M{
@@ -452,3 +544,62 @@ func RemoveInstanceIp(db *database.Database, instId,
 
 	return
 }
+
+func ClaimNatGateway(db *database.Database, vcId, ndeId,
+	curNdeId primitive.ObjectID, curTimestamp time.Time) (
+	claimed bool, err error) {
+
+	coll := db.Vpcs()
+
+	query := bson.M{
+		"_id": vcId,
+	}
+	if curNdeId.IsZero() {
+		query["nat_node"] = nil
+	} else {
+		query["nat_node"] = curNdeId
+		query["nat_timestamp"] = curTimestamp
+	}
+
+	resp, err := coll.UpdateOne(db, &query, &bson.M{
+		"$set": &bson.M{
+			"nat_node":      ndeId,
+			"nat_timestamp": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount > 0 {
+		claimed = true
+	}
+
+	return
+}
+
+func HeartbeatNatGateway(db *database.Database, vcId,
+	ndeId primitive.ObjectID) (active bool, err error) {
+
+	coll := db.Vpcs()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":      vcId,
+		"nat_node": ndeId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"nat_timestamp": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount > 0 {
+		active = true
+	}
+
+	return
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/block"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/requires"
@@ -16,6 +17,7 @@ import (
 	"math/rand"
 	"net"
 	"strings"
+	"time"
 )
 
 type Route struct {
@@ -25,17 +27,26 @@ type Route struct {
 }
 
 type Vpc struct {
-	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name         string             `bson:"name" json:"name"`
-	Comment      string             `bson:"comment" json:"comment"`
-	VpcId        int                `bson:"vpc_id" json:"vpc_id"`
-	Network      string             `bson:"network" json:"network"`
-	Network6     string             `bson:"-" json:"network6"`
-	Subnets      []*Subnet          `bson:"subnets" json:"subnets"`
-	Organization primitive.ObjectID `bson:"organization" json:"organization"`
-	Datacenter   primitive.ObjectID `bson:"datacenter" json:"datacenter"`
-	Routes       []*Route           `bson:"routes" json:"routes"`
-	curSubnets   []*Subnet          `bson:"-" json:"-"`
+	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name             string             `bson:"name" json:"name"`
+	Comment          string             `bson:"comment" json:"comment"`
+	VpcId            int                `bson:"vpc_id" json:"vpc_id"`
+	Network          string             `bson:"network" json:"network"`
+	Network6         string             `bson:"network6" json:"network6"`
+	Block6           primitive.ObjectID `bson:"block6,omitempty" json:"block6"`
+	Subnets          []*Subnet          `bson:"subnets" json:"subnets"`
+	Organization     primitive.ObjectID `bson:"organization" json:"organization"`
+	Datacenter       primitive.ObjectID `bson:"datacenter" json:"datacenter"`
+	Routes           []*Route           `bson:"routes" json:"routes"`
+	DnsServers       []string           `bson:"dns_servers" json:"dns_servers"`
+	SearchDomains    []string           `bson:"search_domains" json:"search_domains"`
+	NatGateway       *NatGateway        `bson:"nat_gateway" json:"nat_gateway"`
+	NatNode          primitive.ObjectID `bson:"nat_node,omitempty" json:"nat_node"`
+	NatTimestamp     time.Time          `bson:"nat_timestamp" json:"nat_timestamp"`
+	BandwidthIngress int                `bson:"bandwidth_ingress" json:"bandwidth_ingress"`
+	BandwidthEgress  int                `bson:"bandwidth_egress" json:"bandwidth_egress"`
+	curSubnets       []*Subnet          `bson:"-" json:"-"`
+	curNatGateway    *NatGateway        `bson:"-" json:"-"`
 }
 
 func (v *Vpc) Validate(db *database.Database) (
@@ -65,6 +76,14 @@ func (v *Vpc) Validate(db *database.Database) (
 		return
 	}
 
+	if v.BandwidthIngress < 0 || v.BandwidthEgress < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "bandwidth_invalid",
+			Message: "Bandwidth limit cannot be negative",
+		}
+		return
+	}
+
 	network, e := v.GetNetwork()
 	if e != nil {
 		errData = &errortypes.ErrorData{
@@ -74,8 +93,20 @@ func (v *Vpc) Validate(db *database.Database) (
 		return
 	}
 
+	v.Network6 = strings.TrimSpace(v.Network6)
+	if v.Network6 == getDefaultNetwork6(v.Id) {
+		v.Network6 = ""
+	}
+
+	if !v.Block6.IsZero() {
+		errData, err = v.allocateBlock6(db)
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
 	network6, e := v.GetNetwork6()
-	if e != nil {
+	if e != nil || network6.IP.To4() != nil {
 		errData = &errortypes.ErrorData{
 			Error:   "network_invalid6",
 			Message: "IPv6 network address invalid",
@@ -83,8 +114,43 @@ func (v *Vpc) Validate(db *database.Database) (
 		return
 	}
 
+	if v.Network6 != "" {
+		cidr6, _ := network6.Mask.Size()
+		if cidr6 < 32 || cidr6 > 64 {
+			errData = &errortypes.ErrorData{
+				Error:   "network_size_invalid6",
+				Message: "IPv6 network size must be between /32 and /64",
+			}
+			return
+		}
+
+		v.Network6 = network6.String()
+
+		usedNetworks6, e := getUsedNetworks6(db, v.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, usedNet := range usedNetworks6 {
+			if networkOverlaps(network6, usedNet) {
+				errData = &errortypes.ErrorData{
+					Error:   "network_overlap6",
+					Message: "IPv6 network overlaps with another VPC",
+				}
+				return
+			}
+		}
+	}
+
 	v.Network = network.String()
 
+	v.DnsServers, v.SearchDomains, errData = validateDns(
+		v.DnsServers, v.SearchDomains)
+	if errData != nil {
+		return
+	}
+
 	if v.Subnets == nil {
 		v.Subnets = []*Subnet{}
 	}
@@ -127,6 +193,12 @@ func (v *Vpc) Validate(db *database.Database) (
 
 		sub.Network = subNetwork.String()
 
+		sub.DnsServers, sub.SearchDomains, errData = validateDns(
+			sub.DnsServers, sub.SearchDomains)
+		if errData != nil {
+			return
+		}
+
 		if !utils.NetworkContains(network, subNetwork) {
 			errData = &errortypes.ErrorData{
 				Error:   "subnet_network_range_invalid",
@@ -155,6 +227,11 @@ func (v *Vpc) Validate(db *database.Database) (
 	}
 	v.Subnets = subs
 
+	errData = v.allocateSubnets6(network6)
+	if errData != nil {
+		return
+	}
+
 	for _, sub := range v.Subnets {
 		subStart, subStop, e := sub.GetIndexRange()
 		if e != nil {
@@ -259,6 +336,11 @@ func (v *Vpc) Validate(db *database.Database) (
 		}
 	}
 
+	errData, err = v.validateNatGateway(db)
+	if err != nil || errData != nil {
+		return
+	}
+
 	return
 }
 
@@ -268,6 +350,13 @@ func (v *Vpc) PreCommit() {
 	} else {
 		v.curSubnets = v.Subnets
 	}
+
+	if v.NatGateway == nil {
+		v.curNatGateway = nil
+	} else {
+		curNgw := *v.NatGateway
+		v.curNatGateway = &curNgw
+	}
 }
 
 func (v *Vpc) PostCommit(db *database.Database) (
@@ -311,25 +400,164 @@ func (v *Vpc) PostCommit(db *database.Database) (
 		}
 	}
 
+	err = v.AllocateNatGateway(db)
+	if err != nil {
+		return
+	}
+
 	return
 }
 
-func (v *Vpc) Json() {
-	netHash := md5.New()
-	netHash.Write(v.Id[:])
-	netHashSum := fmt.Sprintf("%x", netHash.Sum(nil))[:12]
+func (v *Vpc) allocateBlock6(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
 
-	ip := fmt.Sprintf("fd97%s", netHashSum)
-	ipBuf := bytes.Buffer{}
+	blck, err := block.Get(db, v.Block6)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "block6_invalid",
+				Message: "IPv6 block does not exist",
+			}
+		}
+		return
+	}
 
-	for i, run := range ip {
-		if i%4 == 0 && i != 0 && i != len(ip)-1 {
-			ipBuf.WriteRune(':')
+	if blck.Type != block.IPv6 {
+		errData = &errortypes.ErrorData{
+			Error:   "block6_invalid",
+			Message: "Block is not an IPv6 block",
 		}
-		ipBuf.WriteRune(run)
+		return
+	}
+
+	blockNets := []*net.IPNet{}
+	for _, subnet6 := range blck.Subnets6 {
+		_, blockNet, e := net.ParseCIDR(subnet6)
+		if e != nil {
+			continue
+		}
+		blockNets = append(blockNets, blockNet)
+	}
+
+	if v.Network6 != "" {
+		_, curNet, e := net.ParseCIDR(v.Network6)
+		if e == nil {
+			for _, blockNet := range blockNets {
+				if utils.NetworkContains(blockNet, curNet) {
+					return
+				}
+			}
+		}
+	}
+
+	usedNetworks6, err := getUsedNetworks6(db, v.Id)
+	if err != nil {
+		return
+	}
+
+	for _, blockNet := range blockNets {
+		size := blockNetwork6Size
+		blockSize, _ := blockNet.Mask.Size()
+		if blockSize > size {
+			size = blockSize
+		}
+
+		network6 := allocateNetwork6(blockNet, size, usedNetworks6)
+		if network6 != nil {
+			v.Network6 = network6.String()
+			return
+		}
+	}
+
+	errData = &errortypes.ErrorData{
+		Error:   "block6_full",
+		Message: "IPv6 block has no available networks",
+	}
+
+	return
+}
+
+func (v *Vpc) allocateSubnets6(network6 *net.IPNet) (
+	errData *errortypes.ErrorData) {
+
+	if v.Network6 == "" {
+		for _, sub := range v.Subnets {
+			sub.Network6 = ""
+		}
+		return
+	}
+
+	usedNetworks6 := []*net.IPNet{}
+	for _, sub := range v.Subnets {
+		sub.Network6 = strings.TrimSpace(sub.Network6)
+		if sub.Network6 == "" {
+			continue
+		}
+
+		_, subNetwork6, e := net.ParseCIDR(sub.Network6)
+		if e != nil || subNetwork6.IP.To4() != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "subnet_network_invalid6",
+				Message: "Subnet IPv6 network address invalid",
+			}
+			return
+		}
+
+		cidr6, _ := subNetwork6.Mask.Size()
+		if cidr6 != subnetNetwork6Size {
+			errData = &errortypes.ErrorData{
+				Error:   "subnet_network_size_invalid6",
+				Message: "Subnet IPv6 network must be a /64",
+			}
+			return
+		}
+
+		if !utils.NetworkContains(network6, subNetwork6) {
+			sub.Network6 = ""
+			continue
+		}
+
+		for _, usedNet := range usedNetworks6 {
+			if networkOverlaps(subNetwork6, usedNet) {
+				errData = &errortypes.ErrorData{
+					Error:   "subnet_network_range_overlap6",
+					Message: "VPC cannot have overlapping IPv6 subnets",
+				}
+				return
+			}
+		}
+
+		sub.Network6 = subNetwork6.String()
+		usedNetworks6 = append(usedNetworks6, subNetwork6)
+	}
+
+	for _, sub := range v.Subnets {
+		if sub.Network6 != "" {
+			continue
+		}
+
+		subNetwork6 := allocateNetwork6(
+			network6, subnetNetwork6Size, usedNetworks6)
+		if subNetwork6 == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "network_full6",
+				Message: "VPC IPv6 network has no available subnets",
+			}
+			return
+		}
+
+		sub.Network6 = subNetwork6.String()
+		usedNetworks6 = append(usedNetworks6, subNetwork6)
 	}
 
-	v.Network6 = ipBuf.String() + "::/64"
+	return
+}
+
+func (v *Vpc) Json() {
+	if v.Network6 == "" {
+		v.Network6 = getDefaultNetwork6(v.Id)
+	}
 }
 
 func (v *Vpc) GetSubnet(id primitive.ObjectID) (sub *Subnet) {
@@ -359,21 +587,12 @@ func (v *Vpc) GetNetwork() (network *net.IPNet, err error) {
 }
 
 func (v *Vpc) GetNetwork6() (network *net.IPNet, err error) {
-	netHash := md5.New()
-	netHash.Write(v.Id[:])
-	netHashSum := fmt.Sprintf("%x", netHash.Sum(nil))[:12]
-
-	ip := fmt.Sprintf("fd97%s", netHashSum)
-	ipBuf := bytes.Buffer{}
-
-	for i, run := range ip {
-		if i%4 == 0 && i != 0 && i != len(ip)-1 {
-			ipBuf.WriteRune(':')
-		}
-		ipBuf.WriteRune(run)
+	network6 := v.Network6
+	if network6 == "" {
+		network6 = getDefaultNetwork6(v.Id)
 	}
 
-	_, network, err = net.ParseCIDR(ipBuf.String() + "::/64")
+	_, network, err = net.ParseCIDR(network6)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.Wrap(err, "vpc: Failed to parse network"),
@@ -384,6 +603,50 @@ func (v *Vpc) GetNetwork6() (network *net.IPNet, err error) {
 	return
 }
 
+func (v *Vpc) GetBandwidth(ingress, egress int) (int, int) {
+	if ingress == 0 {
+		ingress = v.BandwidthIngress
+	}
+	if egress == 0 {
+		egress = v.BandwidthEgress
+	}
+	return ingress, egress
+}
+
+func (v *Vpc) Overlaps(vc *Vpc) (overlaps bool, err error) {
+	network, err := v.GetNetwork()
+	if err != nil {
+		return
+	}
+
+	vcNetwork, err := vc.GetNetwork()
+	if err != nil {
+		return
+	}
+
+	if networkOverlaps(network, vcNetwork) {
+		overlaps = true
+		return
+	}
+
+	network6, err := v.GetNetwork6()
+	if err != nil {
+		return
+	}
+
+	vcNetwork6, err := vc.GetNetwork6()
+	if err != nil {
+		return
+	}
+
+	if networkOverlaps(network6, vcNetwork6) {
+		overlaps = true
+		return
+	}
+
+	return
+}
+
 func (v *Vpc) InitVpc() {
 	v.VpcId = rand.Intn(4085) + 10
 
@@ -548,7 +811,52 @@ func (v *Vpc) GetIp(db *database.Database,
 	return
 }
 
+func (v *Vpc) getSubnetNetwork6(addr net.IP) (network *net.IPNet) {
+	for _, sub := range v.Subnets {
+		if sub.Network6 == "" {
+			continue
+		}
+
+		subNetwork, err := sub.GetNetwork()
+		if err != nil || !subNetwork.Contains(addr) {
+			continue
+		}
+
+		_, network, err = net.ParseCIDR(sub.Network6)
+		if err != nil {
+			network = nil
+			continue
+		}
+
+		return
+	}
+
+	network, err := v.GetNetwork6()
+	if err != nil {
+		network = nil
+	}
+
+	return
+}
+
+// GetIp6 returns the IPv6 address for the VPC IPv4 address. VPCs with an
+// assigned IPv6 network use the /64 of the subnet containing the address.
 func (v *Vpc) GetIp6(addr net.IP) net.IP {
+	if v.Network6 != "" {
+		network := v.getSubnetNetwork6(addr)
+		if network != nil {
+			macHash := md5.New()
+			macHash.Write(addr)
+			macHashSum := macHash.Sum(nil)
+
+			ip := make(net.IP, net.IPv6len)
+			copy(ip[:8], network.IP.To16()[:8])
+			copy(ip[8:], macHashSum[:8])
+
+			return ip
+		}
+	}
+
 	netHash := md5.New()
 	netHash.Write(v.Id[:])
 	netHashSum := fmt.Sprintf("%x", netHash.Sum(nil))[:12]
@@ -3,4 +3,7 @@ package zone
 const (
 	Default   = "default"
 	VxlanVlan = "vxlan_vlan"
+
+	Spread = "spread"
+	Pack   = "pack"
 )
@@ -14,6 +14,7 @@ type Zone struct {
 	Name        string             `bson:"name" json:"name"`
 	Comment     string             `bson:"comment" json:"comment"`
 	NetworkMode string             `bson:"network_mode" json:"network_mode"`
+	Placement   string             `bson:"placement" json:"placement"`
 }
 
 func (z *Zone) Validate(db *database.Database) (
@@ -43,6 +44,20 @@ func (z *Zone) Validate(db *database.Database) (
 		return
 	}
 
+	switch z.Placement {
+	case Spread, Pack:
+		break
+	case "":
+		z.Placement = Spread
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "invalid_placement",
+			Message: "Placement strategy invalid",
+		}
+		return
+	}
+
 	return
 }
 